@@ -3,6 +3,7 @@ package main
 import (
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"strconv"
@@ -10,6 +11,7 @@ import (
 
 	"github.com/sidecus/raft/pkg/raft"
 	"github.com/sidecus/raft/pkg/rkv"
+	"github.com/sidecus/raft/pkg/trace"
 	"github.com/sidecus/raft/pkg/util"
 )
 
@@ -19,12 +21,46 @@ func main() {
 	nodeID := -1
 	addresses := ""
 	logLevel := 3
+	logFormat := util.LogFormatText
+	subsystemLogLevels := ""
+	noProxy := false
+	dataDir := ""
+	bootstrapFrom := ""
+	join := false
+	forceNewCluster := false
+	metricsAddress := ""
+	traceFile := ""
+	traceSample := 1.0
+	healthAddress := ""
+	readyMaxLag := rkv.DefaultReadyMaxLag
+	historyRetention := int64(rkv.DefaultHistoryRetention)
+	shutdownTimeout := rkv.DefaultShutdownTimeout
 
 	flag.IntVar(&nodeID, "nodeid", -1, "current node ID. 0 to n where n is total nodes")
 	flag.StringVar(&addresses, "addresses", "", "comma separated node addresses, ordered by nodeID")
-	flag.IntVar(&logLevel, "loglevel", 3, "log level. 1 - error, 2 - warning, 3 - info, 4 - traces, default 3")
+	flag.IntVar(&logLevel, "loglevel", 3, "log level. 1 - error, 2 - warning, 3 - info, 4 - traces, 5 - verbose, default 3")
+	flag.StringVar(&logFormat, "logformat", util.LogFormatText, "log format, text or json")
+	flag.StringVar(&subsystemLogLevels, "loglevels", "", "comma separated log levels of subsystems overriding -loglevel, e.g. election=4,replication=2. Subsystems: node, election, replication, snapshot, rkv")
+	flag.BoolVar(&noProxy, "noproxy", false, "don't proxy requests to the leader, return not leader errors with leader hints instead")
+	flag.StringVar(&dataDir, "datadir", "", "keep the kv store on disk in this dir instead of in memory")
+	flag.StringVar(&bootstrapFrom, "bootstrap-from", "", "start a new cluster from the snapshot file. Other nodes need to start empty with -join")
+	flag.BoolVar(&join, "join", false, "don't start elections until hearing from a leader, to join a cluster started with -bootstrap-from")
+	flag.BoolVar(&forceNewCluster, "force-new-cluster", false, "unsafe: start a single node cluster from what's left of this node, after the other nodes are lost")
+	flag.StringVar(&metricsAddress, "metrics", "", "serve Prometheus metrics over HTTP on this address, e.g. :9100")
+	flag.StringVar(&traceFile, "trace", "", "trace client requests across nodes, appending spans as JSON lines to this file, or - for stdout")
+	flag.Float64Var(&traceSample, "trace-sample", 1.0, "ratio of client requests traced, between 0 and 1")
+	flag.StringVar(&healthAddress, "health", "", "serve /healthz and /readyz over HTTP on this address, e.g. :9100, which can be the same as -metrics")
+	flag.IntVar(&readyMaxLag, "ready-max-lag", rkv.DefaultReadyMaxLag, "max number of entries the node can be behind the leader's commit index while ready")
+	flag.Int64Var(&historyRetention, "history-retention", rkv.DefaultHistoryRetention, "number of revisions of key history kept, older history is compacted automatically. -1 keeps all history until Compact")
+	flag.DurationVar(&shutdownTimeout, "shutdown-timeout", rkv.DefaultShutdownTimeout, "max time to wait for requests in flight, and then for leadership transfer, on SIGINT or SIGTERM")
 	flag.Parse()
 
+	if countSet(bootstrapFrom != "", join, forceNewCluster) > 1 {
+		fmt.Println("only one of -bootstrap-from, -join and -force-new-cluster can be used")
+		printUsage()
+		os.Exit(1)
+	}
+
 	addrArray := strings.Split(addresses, ",")
 	if nodeID < 0 || nodeID >= len(addrArray) {
 		fmt.Println("nodeID is out of range for addresses")
@@ -40,18 +76,94 @@ func main() {
 	}
 
 	util.SetLogLevel(logLevel)
+	if err = setLogOptions(logFormat, subsystemLogLevels); err != nil {
+		fmt.Println(err)
+		printUsage()
+		os.Exit(1)
+	}
+
+	var traceExporter trace.IExporter
+	if traceFile == "-" {
+		traceExporter = trace.NewWriterExporter(os.Stdout)
+	} else if traceFile != "" {
+		if traceExporter, err = trace.NewFileExporter(traceFile); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	}
+	trace.SetSampleRatio(traceSample)
+
+	runRPC(nodeID, port, addrArray, rkv.Options{DisableProxy: noProxy, DataDir: dataDir, BootstrapFrom: bootstrapFrom, Join: join, ForceNewCluster: forceNewCluster, MetricsAddress: metricsAddress, TraceExporter: traceExporter, HealthAddress: healthAddress, ReadyMaxLag: readyMaxLag, HistoryRetention: historyRetention, ShutdownTimeout: shutdownTimeout})
 
-	runRPC(nodeID, port, addrArray)
+	// flush spans recorded during shutdown
+	if closer, ok := traceExporter.(io.Closer); ok {
+		closer.Close()
+	}
 }
 
 func printUsage() {
-	fmt.Println("rkv -nodeid id -addresses node0address:port,node1address:port,node2addresses:port... -loglevel level")
+	fmt.Println("rkv -nodeid id -addresses node0address:port,node1address:port,node2addresses:port... -loglevel level [-logformat text|json] [-loglevels subsystem=level,...] [-noproxy] [-datadir dir] [-bootstrap-from snapshotfile | -join | -force-new-cluster] [-metrics address] [-trace file [-trace-sample ratio]] [-health address [-ready-max-lag entries]] [-history-retention revisions] [-shutdown-timeout duration]")
 	fmt.Println("   -id: 0 based current node ID, indexed into addresses to get local port")
 	fmt.Println("   -addresses: comma separated server:port for all nodes")
-	fmt.Println("   -loglevel: number 1-4 (1 - error, 2 - warning, 3 - info, 4 - traces, 5 - verbose), default 3")
+	fmt.Println("   -loglevel: number 1-5 (1 - error, 2 - warning, 3 - info, 4 - traces, 5 - verbose), default 3")
+	fmt.Println("   -logformat: text or json, default text")
+	fmt.Println("   -loglevels: log levels of raft subsystems (node, election, replication, snapshot) and rkv overriding -loglevel, e.g. election=4,replication=2")
+	fmt.Println("   -noproxy: followers return not leader errors with leader hints instead of proxying to the leader")
+	fmt.Println("   -datadir: keep the kv store on disk in the dir, so that it survives restarts. All nodes need to use it or not")
+	fmt.Println("   -bootstrap-from: start a new cluster from the snapshot file, after the old one lost quorum for good. Other nodes need to start empty with -join")
+	fmt.Println("   -join: don't start elections until hearing from a leader, to join a cluster started with -bootstrap-from")
+	fmt.Println("   -force-new-cluster: unsafe, start a single node cluster from the data dir or the latest snapshot of this node, ignoring other addresses")
+	fmt.Println("   -metrics: serve Prometheus metrics over HTTP on /metrics at the address, e.g. :9100")
+	fmt.Println("   -trace: trace client requests through proxy, proposal, replication, commit and apply, appending spans as JSON lines to the file, - for stdout")
+	fmt.Println("   -trace-sample: ratio of client requests traced, between 0 and 1, default 1")
+	fmt.Println("   -health: serve /healthz (alive) and /readyz (known leader, caught up, not installing a snapshot) over HTTP at the address, e.g. :9100")
+	fmt.Println("   -ready-max-lag: max number of entries the node can be behind the leader's commit index while ready, default 1000")
+	fmt.Println("   -history-retention: number of revisions of key history kept, older history is compacted automatically by the leader. -1 keeps all history until Compact, default 100000")
+	fmt.Println("   -shutdown-timeout: max time to wait for requests in flight, and then for leadership transfer, on SIGINT or SIGTERM, default 10s")
+}
+
+// setLogOptions sets the log format and the subsystem log levels, e.g. "election=4,replication=2"
+func setLogOptions(format string, subsystemLevels string) error {
+	if err := util.SetLogFormat(format); err != nil {
+		return err
+	}
+	if subsystemLevels == "" {
+		return nil
+	}
+
+	subsystems := map[string]bool{
+		raft.LogSubsystemNode:        true,
+		raft.LogSubsystemElection:    true,
+		raft.LogSubsystemReplication: true,
+		raft.LogSubsystemSnapshot:    true,
+		rkv.LogSubsystemRKV:          true,
+	}
+	for _, v := range strings.Split(subsystemLevels, ",") {
+		parts := strings.SplitN(v, "=", 2)
+		if len(parts) < 2 || !subsystems[parts[0]] {
+			return fmt.Errorf("invalid subsystem log level %s, should be subsystem=level", v)
+		}
+		level, err := strconv.Atoi(parts[1])
+		if err != nil {
+			return fmt.Errorf("invalid subsystem log level %s, should be subsystem=level", v)
+		}
+		util.SetSubsystemLogLevel(parts[0], level)
+	}
+	return nil
+}
+
+// countSet counts the flags which are set
+func countSet(flags ...bool) int {
+	count := 0
+	for _, f := range flags {
+		if f {
+			count++
+		}
+	}
+	return count
 }
 
-func runRPC(nodeID int, port string, addresses []string) {
+func runRPC(nodeID int, port string, addresses []string, opts rkv.Options) {
 	// initialize peers
 	peers := make(map[int]raft.NodeInfo)
 	for i, v := range addresses {
@@ -65,7 +177,7 @@ func runRPC(nodeID int, port string, addresses []string) {
 		}
 	}
 
-	rkv.StartRKV(nodeID, port, peers)
+	rkv.StartRKV(nodeID, port, peers, opts)
 }
 
 func getNodePort(nodeID int, addresses []string) (string, error) {
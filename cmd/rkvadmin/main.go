@@ -0,0 +1,136 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/sidecus/raft/pkg/rkv"
+)
+
+const (
+	headerMode = "header"
+	verifyMode = "verify"
+	dumpMode   = "dump"
+	diffMode   = "diff"
+	buildMode  = "build"
+)
+
+func main() {
+	mode := parseArgs()
+
+	var err error
+	switch mode.name {
+	case headerMode:
+		err = runHeader(mode.params.(string))
+	case verifyMode:
+		err = runVerify(mode.params.(string))
+	case dumpMode:
+		err = runDump(mode.params.(dumpParams))
+	case diffMode:
+		err = runDiff(mode.params.(diffParams))
+	case buildMode:
+		err = runBuild(mode.params.(buildParams))
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+type runMode struct {
+	name   string
+	params interface{}
+}
+
+func parseArgs() runMode {
+	if len(os.Args) < 2 {
+		fmt.Println("Not enough arguments")
+		printUsage()
+		os.Exit(1)
+	}
+
+	mode := runMode{
+		name: os.Args[1],
+	}
+	file := ""
+
+	args := os.Args[2:]
+	switch mode.name {
+	case headerMode:
+		headerCmd := flag.NewFlagSet(headerMode, flag.ExitOnError)
+		headerCmd.StringVar(&file, "file", "", "snapshot file")
+		headerCmd.Parse(args)
+		mode.params = file
+	case verifyMode:
+		verifyCmd := flag.NewFlagSet(verifyMode, flag.ExitOnError)
+		verifyCmd.StringVar(&file, "file", "", "snapshot file")
+		verifyCmd.Parse(args)
+		mode.params = file
+	case dumpMode:
+		dp := dumpParams{}
+		dumpCmd := flag.NewFlagSet(dumpMode, flag.ExitOnError)
+		dumpCmd.StringVar(&file, "file", "", "snapshot file")
+		dumpCmd.StringVar(&dp.prefix, "prefix", "", "dump keys with the prefix, empty means all keys")
+		dumpCmd.Parse(args)
+		dp.file = file
+		mode.params = dp
+	case diffMode:
+		dp := diffParams{}
+		diffCmd := flag.NewFlagSet(diffMode, flag.ExitOnError)
+		diffCmd.StringVar(&dp.a, "a", "", "snapshot file to diff from")
+		diffCmd.StringVar(&dp.b, "b", "", "snapshot file to diff to")
+		diffCmd.StringVar(&dp.prefix, "prefix", "", "diff keys with the prefix, empty means all keys")
+		diffCmd.Parse(args)
+		if dp.a == "" || dp.b == "" {
+			printUsage()
+			log.Fatalln("a and b cannot be empty")
+		}
+		file = dp.a
+		mode.params = dp
+	case buildMode:
+		bp := buildParams{}
+		buildCmd := flag.NewFlagSet(buildMode, flag.ExitOnError)
+		buildCmd.StringVar(&file, "file", "", "snapshot file to create")
+		buildCmd.StringVar(&bp.input, "input", "-", "JSON lines file with the keys, e.g. from rkvclient export or rkvadmin dump, - for stdin")
+		buildCmd.StringVar(&bp.format, "format", rkv.SnapshotFormatJSON, "json for nodes with in memory stores, bolt for nodes started with -datadir")
+		buildCmd.IntVar(&bp.header.NodeID, "node", 0, "node ID in the snapshot header")
+		buildCmd.IntVar(&bp.header.Term, "term", 0, "term of the last log entry in the snapshot")
+		buildCmd.IntVar(&bp.header.Index, "index", 0, "index of the last log entry in the snapshot")
+		buildCmd.Parse(args)
+		bp.file = file
+		mode.params = bp
+	default:
+		mode.name = ""
+	}
+
+	if mode.name == "" {
+		printUsage()
+		log.Fatalln("Unsupported mode")
+	}
+
+	if file == "" {
+		printUsage()
+		log.Fatalln("file cannot be empty")
+	}
+
+	return mode
+}
+
+func printUsage() {
+	fmt.Println("Usage of rkvadmin:")
+	fmt.Println("\trkvadmin <mode> <modeparams>")
+	fmt.Println("\tWorks on snapshot files offline, e.g. Node0_T1L4096_local.rkvsnapshot in the snapshot path of a node")
+	fmt.Println("Supported modes:")
+	fmt.Println("\theader -file <file>")
+	fmt.Println("\tverify -file <file>")
+	fmt.Println("\tdump   -file <file> [-prefix <prefix>] > <dumpfile>")
+	fmt.Println("\tdiff   -a <file> -b <file> [-prefix <prefix>]")
+	fmt.Println("\tbuild  -file <file> -input <dumpfile> [-format json|bolt] [-node <nodeid>] [-term <term>] [-index <index>]")
+	fmt.Println()
+	fmt.Println("dump files are JSON lines, same as rkvclient export:")
+	fmt.Println(`	{"key": "k1", "value": "v1"}`)
+	fmt.Println()
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/sidecus/raft/pkg/raft"
+	"github.com/sidecus/raft/pkg/rkv"
+)
+
+// jsonKV is one line in JSON lines dumps, same as rkvclient
+type jsonKV struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+type dumpParams struct {
+	file   string
+	prefix string
+}
+
+type diffParams struct {
+	a      string
+	b      string
+	prefix string
+}
+
+type buildParams struct {
+	file   string
+	input  string
+	format string
+	header raft.SnapshotHeader
+}
+
+// loadSnapshot loads the kv store in a snapshot file. The checksum is not verified
+func loadSnapshot(file string) (raft.SnapshotHeader, *rkv.SnapshotStore, error) {
+	header, r, err := raft.OpenSnapshotFile(file)
+	if err != nil {
+		return header, nil, err
+	}
+	defer r.Close()
+
+	s, err := rkv.LoadSnapshot(r)
+	if err != nil {
+		return header, nil, fmt.Errorf("invalid state machine data in %s: %w", file, err)
+	}
+	return header, s, nil
+}
+
+func printHeader(header raft.SnapshotHeader) {
+	fmt.Printf("Node    :%d\n", header.NodeID)
+	fmt.Printf("Term    :%d\n", header.Term)
+	fmt.Printf("Index   :%d\n", header.Index)
+}
+
+// runHeader prints the snapshot header and the size of the state machine data
+func runHeader(file string) error {
+	header, r, err := raft.OpenSnapshotFile(file)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	size, err := io.Copy(io.Discard, r)
+	if err != nil {
+		return err
+	}
+
+	printHeader(header)
+	fmt.Printf("Size    :%d bytes\n", size)
+	return nil
+}
+
+// runVerify checks the snapshot checksum, and that the state machine data can be loaded
+func runVerify(file string) error {
+	if _, err := raft.VerifySnapshotFile(file); err != nil {
+		return err
+	}
+
+	header, s, err := loadSnapshot(file)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	info := s.Info()
+	printHeader(header)
+	fmt.Printf("Format  :%s\n", info.Format)
+	fmt.Printf("Revision:%d\n", info.Revision)
+	fmt.Printf("Compact :%d\n", info.CompactRevision)
+	fmt.Printf("Keys    :%d\n", info.Keys)
+	fmt.Printf("Leases  :%d\n", info.Leases)
+	fmt.Printf("Success :%v\n", true)
+	return nil
+}
+
+// runDump writes keys with the prefix to stdout as JSON lines, which can be loaded by rkvclient import or build.
+// The summary goes to stderr so that stdout can be redirected to a dump file
+func runDump(params dumpParams) error {
+	_, s, err := loadSnapshot(params.file)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	w := bufio.NewWriter(os.Stdout)
+	encoder := json.NewEncoder(w)
+	count := 0
+	s.Ascend(params.prefix, func(key string, entry rkv.KVEntry) bool {
+		if err = encoder.Encode(jsonKV{Key: key, Value: entry.Value}); err != nil {
+			return false
+		}
+		count++
+		return true
+	})
+	if err == nil {
+		err = w.Flush()
+	}
+
+	fmt.Fprintf(os.Stderr, "Dumped:%d keys (revision %d)\n", count, s.Info().Revision)
+	return err
+}
+
+// runDiff prints keys removed (-), changed (~) and added (+) in snapshot b compared to snapshot a, in key order
+func runDiff(params diffParams) error {
+	_, a, err := loadSnapshot(params.a)
+	if err != nil {
+		return err
+	}
+	defer a.Close()
+
+	_, b, err := loadSnapshot(params.b)
+	if err != nil {
+		return err
+	}
+	defer b.Close()
+
+	removed, changed, added := 0, 0, 0
+	a.Ascend(params.prefix, func(key string, entry rkv.KVEntry) bool {
+		other, ok := b.Get(key)
+		if !ok {
+			fmt.Printf("- %s=%s (revision %d)\n", key, entry.Value, entry.Revision)
+			removed++
+		} else if other != entry {
+			fmt.Printf("~ %s=%s (revision %d) -> %s (revision %d)\n", key, entry.Value, entry.Revision, other.Value, other.Revision)
+			changed++
+		}
+		return true
+	})
+	b.Ascend(params.prefix, func(key string, entry rkv.KVEntry) bool {
+		if _, ok := a.Get(key); !ok {
+			fmt.Printf("+ %s=%s (revision %d)\n", key, entry.Value, entry.Revision)
+			added++
+		}
+		return true
+	})
+
+	fmt.Printf("Revision:%d -> %d\n", a.Info().Revision, b.Info().Revision)
+	fmt.Printf("Removed :%d\n", removed)
+	fmt.Printf("Changed :%d\n", changed)
+	fmt.Printf("Added   :%d\n", added)
+	return nil
+}
+
+// runBuild creates a snapshot file with keys from a JSON lines dump, e.g. to repair a cluster from a backup
+func runBuild(params buildParams) error {
+	r := io.Reader(os.Stdin)
+	if params.input != "-" {
+		f, err := os.Open(params.input)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	decoder := json.NewDecoder(r)
+	next := func() (string, string, error) {
+		var kv jsonKV
+		if err := decoder.Decode(&kv); err != nil {
+			return "", "", err
+		}
+		if kv.Key == "" {
+			return "", "", errors.New("invalid JSON line: key cannot be empty")
+		}
+		return kv.Key, kv.Value, nil
+	}
+
+	w, err := raft.CreateSnapshotFile(params.file, params.header)
+	if err != nil {
+		return err
+	}
+
+	count, err := rkv.WriteSnapshot(w, params.format, next)
+	if closeErr := w.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(params.file)
+		return err
+	}
+
+	fmt.Printf("Built   :%d keys\n", count)
+	return nil
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+
+	"github.com/sidecus/raft/pkg/rkv/client"
+	"github.com/sidecus/raft/pkg/rkv/pbv2"
+)
+
+type exportParams struct {
+	prefix   string
+	revision int64
+}
+
+// runExport writes keys with the prefix to stdout as JSON lines, which can be loaded by import.
+// The summary goes to stderr so that stdout can be redirected to a dump file
+func runExport(c *client.Client, params exportParams) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	w := bufio.NewWriter(os.Stdout)
+	encoder := json.NewEncoder(w)
+	count := 0
+	var writeErr error
+	revision, err := c.Export(ctx, params.prefix, params.revision, func(kv *pbv2.KeyValue) {
+		if writeErr != nil {
+			return
+		}
+		if writeErr = encoder.Encode(jsonKV{Key: kv.Key, Value: kv.Value}); writeErr == nil {
+			count++
+		}
+	})
+	if err == nil {
+		err = writeErr
+	}
+	if err == nil {
+		err = w.Flush()
+	}
+
+	fmt.Fprintf(os.Stderr, "Exported:%d keys (revision %d)\n", count, revision)
+	return err
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/sidecus/raft/pkg/rkv/client"
+	"github.com/sidecus/raft/pkg/rkv/pbv2"
+)
+
+const (
+	jsonFormat = "json"
+	csvFormat  = "csv"
+)
+
+const maxImportBatch = 1000
+
+// jsonKV is one line in JSON lines dumps
+type jsonKV struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+type importParams struct {
+	file   string
+	format string
+	batch  int
+}
+
+// runImport loads keys from a JSON lines or CSV file (or stdin) with BulkLoad, one log entry per batch.
+// Runs until all keys are loaded or interrupted, and prints how many keys are loaded either way
+func runImport(c *client.Client, params importParams) error {
+	if params.batch <= 0 || params.batch > maxImportBatch {
+		return fmt.Errorf("batch must be 1 to %d", maxImportBatch)
+	}
+
+	r := io.Reader(os.Stdin)
+	if params.file != "-" {
+		f, err := os.Open(params.file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	read, err := newKVReader(r, params.format, params.file)
+	if err != nil {
+		return err
+	}
+
+	next := func() ([]*pbv2.KeyValue, error) {
+		kvs := make([]*pbv2.KeyValue, 0, params.batch)
+		for len(kvs) < params.batch {
+			kv, err := read()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, err
+			}
+			kvs = append(kvs, kv)
+		}
+		if len(kvs) == 0 {
+			return nil, io.EOF
+		}
+		return kvs, nil
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	count, err := c.BulkLoad(ctx, next)
+	fmt.Printf("Imported:%d keys\n", count)
+	return err
+}
+
+// newKVReader creates a reader returning one key value pair at a time, and io.EOF at the end.
+// JSON lines are objects like {"key": "k1", "value": "v1"}, CSV records are key,value.
+// Format is decided by the file extension if it's empty
+func newKVReader(r io.Reader, format string, file string) (func() (*pbv2.KeyValue, error), error) {
+	if format == "" {
+		format = jsonFormat
+		if strings.HasSuffix(strings.ToLower(file), ".csv") {
+			format = csvFormat
+		}
+	}
+
+	switch format {
+	case jsonFormat:
+		decoder := json.NewDecoder(r)
+		return func() (*pbv2.KeyValue, error) {
+			var kv jsonKV
+			if err := decoder.Decode(&kv); err != nil {
+				return nil, err
+			}
+			if kv.Key == "" {
+				return nil, errors.New("invalid JSON line: key cannot be empty")
+			}
+			return &pbv2.KeyValue{Key: kv.Key, Value: kv.Value}, nil
+		}, nil
+	case csvFormat:
+		reader := csv.NewReader(r)
+		reader.FieldsPerRecord = 2
+		return func() (*pbv2.KeyValue, error) {
+			record, err := reader.Read()
+			if err != nil {
+				return nil, err
+			}
+			return &pbv2.KeyValue{Key: record[0], Value: record[1]}, nil
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported import format %s", format)
+	}
+}
@@ -6,98 +6,141 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"strings"
 	"time"
 
 	"google.golang.org/grpc"
 
-	"github.com/sidecus/raft/pkg/rkv/pb"
+	"github.com/sidecus/raft/pkg/rkv/client"
+	"github.com/sidecus/raft/pkg/rkv/pbv2"
 )
 
 const (
 	getMode       = "get"
 	setMode       = "set"
 	delMode       = "del"
+	txnMode       = "txn"
+	watchMode     = "watch"
+	scanMode      = "scan"
+	historyMode   = "history"
+	compactMode   = "compact"
+	importMode    = "import"
+	exportMode    = "export"
 	benchMarkMode = "benchmark"
 )
 
 func main() {
 	mode := parseArgs()
 
-	conn, err := getConnection(mode.address)
-	if err != nil {
-		fmt.Println(err)
-		os.Exit(1)
-	}
-	defer conn.Close()
+	if mode.name == benchMarkMode {
+		// benchmark runs against one node over v1 API
+		conn, err := getConnection(mode.addresses[0])
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		defer conn.Close()
 
-	switch mode.name {
-	case getMode:
-		get(conn, &pb.GetRequest{Key: mode.params.(string)})
-	case setMode:
-		set(conn, &pb.SetRequest{Key: mode.params.(keyValuePair).key, Value: mode.params.(keyValuePair).value})
-	case delMode:
-		delete(conn, &pb.DeleteRequest{Key: mode.params.(string)})
-	case benchMarkMode:
 		benchmark(conn, mode.params.(int))
+		return
 	}
-}
 
-func getConnection(address string) (*grpc.ClientConn, error) {
-	return grpc.Dial(address, grpc.WithInsecure(), grpc.WithBlock())
-}
-
-func get(conn *grpc.ClientConn, req *pb.GetRequest) {
-	client := pb.NewKVStoreRaftClient(conn)
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
-	defer cancel()
-
-	reply, err := client.Get(ctx, req)
+	c, err := client.New(client.Options{Endpoints: mode.addresses, StaleReads: mode.stale})
 	if err != nil {
 		fmt.Println(err)
 		os.Exit(1)
 	}
-	fmt.Printf("Success :%v\n", reply.Success)
-	fmt.Printf("Value   :%s\n", reply.Value)
-	fmt.Printf("Run on  :Node%d\n", reply.NodeID)
-}
+	defer c.Close()
 
-func set(conn *grpc.ClientConn, req *pb.SetRequest) {
-	client := pb.NewKVStoreRaftClient(conn)
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
-	defer cancel()
+	if mode.name == watchMode {
+		// watch runs until interrupted
+		if err = runWatch(c, mode.params.(watchParams)); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		return
+	}
 
-	reply, err := client.Set(ctx, req)
-	if err != nil {
-		fmt.Println(err)
-		os.Exit(1)
+	if mode.name == importMode {
+		// import runs until all keys are loaded
+		if err = runImport(c, mode.params.(importParams)); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if mode.name == exportMode {
+		// export runs until all keys are written, and only writes keys to stdout
+		if err = runExport(c, mode.params.(exportParams)); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
 	}
-	fmt.Printf("Success :%v\n", reply.Success)
-	fmt.Printf("Run on  :Node%d\n", reply.NodeID)
-}
 
-func delete(conn *grpc.ClientConn, req *pb.DeleteRequest) {
-	client := pb.NewKVStoreRaftClient(conn)
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	reply, err := client.Delete(ctx, req)
+	switch mode.name {
+	case getMode:
+		kr := mode.params.(keyRevision)
+		var value string
+		if kr.revision != 0 {
+			value, err = c.GetAt(ctx, kr.key, kr.revision)
+		} else {
+			value, err = c.Get(ctx, kr.key)
+		}
+		if err == nil {
+			fmt.Printf("Value   :%s\n", value)
+		}
+	case setMode:
+		kvp := mode.params.(keyValuePair)
+		err = c.SetWithTTL(ctx, kvp.key, kvp.value, kvp.ttl)
+	case delMode:
+		err = c.Delete(ctx, mode.params.(string))
+	case txnMode:
+		err = runTxn(ctx, c, mode.params.(string))
+	case scanMode:
+		err = runScan(ctx, c, mode.params.(scanParams))
+	case historyMode:
+		var events []*pbv2.Event
+		if events, err = c.History(ctx, mode.params.(string)); err == nil {
+			for _, e := range events {
+				fmt.Printf("%-6s %s=%s (revision %d)\n", e.Type, e.Key, e.Value, e.Revision)
+			}
+		}
+	case compactMode:
+		err = c.Compact(ctx, mode.params.(int64))
+	}
+
 	if err != nil {
 		fmt.Println(err)
 		os.Exit(1)
 	}
-	fmt.Printf("Success :%v\n", reply.Success)
-	fmt.Printf("Run on  :Node%d\n", reply.NodeID)
+	fmt.Printf("Success :%v\n", true)
+}
+
+func getConnection(address string) (*grpc.ClientConn, error) {
+	return grpc.Dial(address, grpc.WithInsecure(), grpc.WithBlock())
 }
 
 type runMode struct {
-	name    string
-	address string
-	params  interface{}
+	name      string
+	addresses []string
+	stale     bool
+	params    interface{}
+}
+
+type keyRevision struct {
+	key      string
+	revision int64
 }
 
 type keyValuePair struct {
 	key   string
 	value string
+	ttl   time.Duration
 }
 
 func parseArgs() runMode {
@@ -110,35 +153,100 @@ func parseArgs() runMode {
 	mode := runMode{
 		name: os.Args[1],
 	}
+	address := ""
 
 	args := os.Args[2:]
 	switch mode.name {
 	case getMode:
-		key := ""
+		kr := keyRevision{}
 		getCmd := flag.NewFlagSet(getMode, flag.ExitOnError)
-		getCmd.StringVar(&mode.address, "address", "", "rpc endpoint")
-		getCmd.StringVar(&key, "key", "", "kv store key to get")
+		getCmd.StringVar(&address, "address", "", "comma separated rpc endpoints of cluster nodes, ordered by node ID")
+		getCmd.StringVar(&kr.key, "key", "", "kv store key to get")
+		getCmd.Int64Var(&kr.revision, "rev", 0, "revision to read the key at, 0 means the current revision")
+		getCmd.BoolVar(&mode.stale, "stale", false, "allow stale reads from any node")
 		getCmd.Parse(args)
-		mode.params = key
+		mode.params = kr
 	case setMode:
 		kvp := keyValuePair{}
 		setCmd := flag.NewFlagSet(setMode, flag.ExitOnError)
-		setCmd.StringVar(&mode.address, "address", "", "rpc endpoint")
+		setCmd.StringVar(&address, "address", "", "comma separated rpc endpoints of cluster nodes, ordered by node ID")
 		setCmd.StringVar(&kvp.key, "key", "", "kv store key to set")
 		setCmd.StringVar(&kvp.value, "value", "", "kv store value to set")
+		setCmd.DurationVar(&kvp.ttl, "ttl", 0, "time to live of the key, e.g. 10s. 0 means no expiry")
 		setCmd.Parse(args)
 		mode.params = kvp
 	case delMode:
 		key := ""
 		delCmd := flag.NewFlagSet(delMode, flag.ExitOnError)
-		delCmd.StringVar(&mode.address, "address", "", "rpc endpoint")
+		delCmd.StringVar(&address, "address", "", "comma separated rpc endpoints of cluster nodes, ordered by node ID")
 		delCmd.StringVar(&key, "key", "", "kv store key to delete")
 		delCmd.Parse(args)
 		mode.params = key
+	case txnMode:
+		file := ""
+		txnCmd := flag.NewFlagSet(txnMode, flag.ExitOnError)
+		txnCmd.StringVar(&address, "address", "", "comma separated rpc endpoints of cluster nodes, ordered by node ID")
+		txnCmd.StringVar(&file, "file", "-", "JSON file with the txn compares and ops, - for stdin")
+		txnCmd.Parse(args)
+		mode.params = file
+	case watchMode:
+		wp := watchParams{}
+		watchCmd := flag.NewFlagSet(watchMode, flag.ExitOnError)
+		watchCmd.StringVar(&address, "address", "", "comma separated rpc endpoints of cluster nodes, ordered by node ID")
+		watchCmd.StringVar(&wp.key, "key", "", "kv store key, or prefix with -prefix, to watch")
+		watchCmd.BoolVar(&wp.prefix, "prefix", false, "watch all keys with the prefix")
+		watchCmd.Int64Var(&wp.revision, "rev", 0, "revision to start watching from, 0 means from now")
+		watchCmd.Parse(args)
+		mode.params = wp
+	case scanMode:
+		sp := scanParams{}
+		scanCmd := flag.NewFlagSet(scanMode, flag.ExitOnError)
+		scanCmd.StringVar(&address, "address", "", "comma separated rpc endpoints of cluster nodes, ordered by node ID")
+		scanCmd.StringVar(&sp.prefix, "prefix", "", "list keys with the prefix")
+		scanCmd.StringVar(&sp.start, "start", "", "list keys from start, inclusive. Ignored with -prefix")
+		scanCmd.StringVar(&sp.end, "end", "", "list keys up to end, exclusive. Ignored with -prefix")
+		scanCmd.Int64Var(&sp.limit, "limit", 0, "max number of keys to list, 0 means all")
+		scanCmd.Int64Var(&sp.revision, "rev", 0, "revision to read the keys at, 0 means the current revision")
+		scanCmd.BoolVar(&mode.stale, "stale", false, "allow stale reads from any node")
+		scanCmd.Parse(args)
+		mode.params = sp
+	case historyMode:
+		key := ""
+		historyCmd := flag.NewFlagSet(historyMode, flag.ExitOnError)
+		historyCmd.StringVar(&address, "address", "", "comma separated rpc endpoints of cluster nodes, ordered by node ID")
+		historyCmd.StringVar(&key, "key", "", "kv store key to list versions of")
+		historyCmd.BoolVar(&mode.stale, "stale", false, "allow stale reads from any node")
+		historyCmd.Parse(args)
+		mode.params = key
+	case compactMode:
+		revision := int64(0)
+		compactCmd := flag.NewFlagSet(compactMode, flag.ExitOnError)
+		compactCmd.StringVar(&address, "address", "", "comma separated rpc endpoints of cluster nodes, ordered by node ID")
+		compactCmd.Int64Var(&revision, "rev", 0, "drop versions replaced or deleted before this revision")
+		compactCmd.Parse(args)
+		mode.params = revision
+	case importMode:
+		ip := importParams{}
+		importCmd := flag.NewFlagSet(importMode, flag.ExitOnError)
+		importCmd.StringVar(&address, "address", "", "comma separated rpc endpoints of cluster nodes, ordered by node ID")
+		importCmd.StringVar(&ip.file, "file", "-", "JSON lines or CSV file with the keys to load, - for stdin")
+		importCmd.StringVar(&ip.format, "format", "", "json or csv. Decided by the file extension if not set, json for stdin")
+		importCmd.IntVar(&ip.batch, "batch", 500, "number of keys set in one log entry, at most 1000")
+		importCmd.Parse(args)
+		mode.params = ip
+	case exportMode:
+		ep := exportParams{}
+		exportCmd := flag.NewFlagSet(exportMode, flag.ExitOnError)
+		exportCmd.StringVar(&address, "address", "", "comma separated rpc endpoints of cluster nodes, ordered by node ID")
+		exportCmd.StringVar(&ep.prefix, "prefix", "", "export keys with the prefix, empty means all keys")
+		exportCmd.Int64Var(&ep.revision, "rev", 0, "revision to export the keys at, 0 means the current revision")
+		exportCmd.BoolVar(&mode.stale, "stale", false, "allow stale reads from any node")
+		exportCmd.Parse(args)
+		mode.params = ep
 	case benchMarkMode:
 		times := 10000
 		benchMarkCmd := flag.NewFlagSet(benchMarkMode, flag.ExitOnError)
-		benchMarkCmd.StringVar(&mode.address, "address", "", "rpc endpoint")
+		benchMarkCmd.StringVar(&address, "address", "", "comma separated rpc endpoints of cluster nodes, ordered by node ID")
 		benchMarkCmd.IntVar(&times, "times", 10000, "times to run")
 		benchMarkCmd.Parse(args)
 		mode.params = times
@@ -151,21 +259,37 @@ func parseArgs() runMode {
 		log.Fatalln("Unsupported mode")
 	}
 
-	if mode.address == "" {
+	if address == "" {
 		printUsage()
 		log.Fatalln("address cannot be empty")
 	}
+	mode.addresses = strings.Split(address, ",")
 
 	return mode
 }
 
 func printUsage() {
 	fmt.Println("Usage of rkvclient:")
-	fmt.Println("\trkvclient <mode> -address <nodeaddress> <othermodeparams>")
+	fmt.Println("\trkvclient <mode> -address <nodeaddresses> <othermodeparams>")
+	fmt.Println("\t<nodeaddresses> is a comma separated list of cluster nodes, ordered by node ID. benchmark only uses the first one")
 	fmt.Println("Supported modes:")
-	fmt.Println("\tset       -address <address> -key <key> -value <value>")
-	fmt.Println("\tget       -address <address> -key <key>")
-	fmt.Println("\tdel       -address <address> -key <key>")
+	fmt.Println("\tset       -address <addresses> -key <key> -value <value> [-ttl <duration>]")
+	fmt.Println("\tget       -address <addresses> -key <key> [-rev <revision>] [-stale]")
+	fmt.Println("\tdel       -address <addresses> -key <key>")
+	fmt.Println("\ttxn       -address <addresses> -file <jsonfile>")
+	fmt.Println("\tscan      -address <addresses> [-prefix <prefix>] [-start <start> -end <end>] [-limit <limit>] [-rev <revision>] [-stale]")
+	fmt.Println("\thistory   -address <addresses> -key <key> [-stale]")
+	fmt.Println("\tcompact   -address <addresses> -rev <revision>")
+	fmt.Println("\twatch     -address <addresses> -key <key> [-prefix] [-rev <revision>]")
+	fmt.Println("\timport    -address <addresses> -file <file> [-format json|csv] [-batch <batch>]")
+	fmt.Println("\texport    -address <addresses> [-prefix <prefix>] [-rev <revision>] [-stale] > <file>")
 	fmt.Println("\tbenchmark -address <address> -times <times>")
 	fmt.Println()
+	fmt.Println("txn JSON format:")
+	fmt.Println(`	{"compares": [{"key": "k1", "target": "VALUE|REVISION", "result": "EQUAL|NOT_EQUAL|GREATER|LESS", "value": "v1", "revision": "1"}],`)
+	fmt.Println(`	 "success": [{"type": "GET|SET|DELETE", "key": "k1", "value": "v1"}], "failure": [...]}`)
+	fmt.Println("import formats, one key per line. export writes JSON lines:")
+	fmt.Println(`	JSON lines: {"key": "k1", "value": "v1"}`)
+	fmt.Println(`	CSV: k1,v1`)
+	fmt.Println()
 }
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/sidecus/raft/pkg/rkv/client"
+	"github.com/sidecus/raft/pkg/rkv/pbv2"
+)
+
+const scanPageSize = 100
+
+type scanParams struct {
+	prefix   string
+	start    string
+	end      string
+	limit    int64
+	revision int64
+}
+
+// runScan prints keys in the range or with the prefix, fetching them page by page.
+// All pages are read at the revision of the first page so that the listing is consistent
+func runScan(ctx context.Context, c *client.Client, params scanParams) error {
+	count := int64(0)
+	token := ""
+	revision := params.revision
+	for {
+		pageSize := int64(scanPageSize)
+		if params.limit > 0 && params.limit-count < pageSize {
+			pageSize = params.limit - count
+		}
+
+		var reply *pbv2.RangeReply
+		var err error
+		if params.prefix != "" {
+			reply, err = c.Prefix(ctx, params.prefix, revision, pageSize, token)
+		} else {
+			reply, err = c.Range(ctx, params.start, params.end, revision, pageSize, token)
+		}
+		if err != nil {
+			return err
+		}
+
+		for _, kv := range reply.Kvs {
+			fmt.Printf("%s=%s (revision %d)\n", kv.Key, kv.Value, kv.Revision)
+		}
+		count += int64(len(reply.Kvs))
+		revision = reply.Revision
+
+		if token = reply.NextPageToken; token == "" || (params.limit > 0 && count >= params.limit) {
+			fmt.Printf("Keys    :%d (revision %d)\n", count, revision)
+			return nil
+		}
+	}
+}
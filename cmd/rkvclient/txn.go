@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"google.golang.org/protobuf/encoding/protojson"
+
+	"github.com/sidecus/raft/pkg/rkv/client"
+	"github.com/sidecus/raft/pkg/rkv/pbv2"
+)
+
+// runTxn reads the txn from the JSON file (or stdin) and prints the result of each applied op
+func runTxn(ctx context.Context, c *client.Client, file string) error {
+	var data []byte
+	var err error
+	if file == "-" {
+		data, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		data, err = ioutil.ReadFile(file)
+	}
+	if err != nil {
+		return err
+	}
+
+	txn := &pbv2.TxnRequest{}
+	if err = protojson.Unmarshal(data, txn); err != nil {
+		return fmt.Errorf("invalid txn JSON: %w", err)
+	}
+
+	reply, err := c.Txn(ctx, txn)
+	if err != nil {
+		return err
+	}
+
+	ops := txn.Success
+	fmt.Printf("Compares:%v\n", reply.Succeeded)
+	if !reply.Succeeded {
+		ops = txn.Failure
+	}
+	for i, r := range reply.Results {
+		fmt.Printf("%-6s %s: found=%v revision=%d value=%s\n", ops[i].Type, ops[i].Key, r.Found, r.Revision, r.Value)
+	}
+	return nil
+}
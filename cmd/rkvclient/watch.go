@@ -0,0 +1,31 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+
+	"github.com/sidecus/raft/pkg/rkv/client"
+	"github.com/sidecus/raft/pkg/rkv/pbv2"
+)
+
+type watchParams struct {
+	key      string
+	prefix   bool
+	revision int64
+}
+
+// runWatch prints changes until interrupted
+func runWatch(c *client.Client, params watchParams) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	err := c.Watch(ctx, params.key, params.prefix, params.revision, func(e *pbv2.Event) {
+		fmt.Printf("%-6s %s=%s (revision %d)\n", e.Type, e.Key, e.Value, e.Revision)
+	})
+	if err == context.Canceled {
+		return nil
+	}
+	return err
+}
@@ -3,7 +3,9 @@ module github.com/sidecus/raft
 go 1.16
 
 require (
-	github.com/golang/protobuf v1.5.1
+	github.com/golang/protobuf v1.5.1 // indirect
+	github.com/prometheus/client_golang v1.11.0
+	go.etcd.io/bbolt v1.3.6
 	google.golang.org/grpc v1.36.0
 	google.golang.org/protobuf v1.26.0
 )
@@ -13,16 +13,29 @@ type replicationReq struct {
 	reqwg    *sync.WaitGroup
 }
 
+// signal signals the requester that the request is processed
+func (r replicationReq) signal() {
+	if r.reqwg != nil {
+		r.reqwg.Done()
+	}
+}
+
 // batchReplicator processes incoming requests (best effort) while at the same time tries to batch them for better efficency.
-// For each request in the request queue:
-// 1. If request id is less than lastMatch, signal done direclty (already replicated)
-// 2. If request id is larger than lastMatch, trigger a new replicate (a few items in batch). signal done afterwards regardless
-//    whether the target id is satisfied or not.
-// In short, each request in the queue will trigger at most 1 replicate
+// Each time it picks up requests, it drains all requests currently in the queue as one batch:
+// 1. If all request ids are less than lastMatch, signal done direclty (already replicated)
+// 2. If any request id is larger than lastMatch, trigger a new replicate (a few items in batch). signal done afterwards regardless
+//    whether the target ids are satisfied or not.
+// In short, each request in the queue will trigger at most 1 replicate, which starts after the request is queued
 type batchReplicator struct {
 	replicateFn func() int
 	requests    chan replicationReq
 	wg          sync.WaitGroup
+
+	// requests are closed after stop once no one is sending, so that queued requests are still processed
+	mu      sync.Mutex
+	stopped bool
+	done    chan struct{}
+	senders sync.WaitGroup
 }
 
 // newBatchReplicator creates a new batcher
@@ -30,6 +43,7 @@ func newBatchReplicator(replicate func() int) *batchReplicator {
 	return &batchReplicator{
 		replicateFn: replicate,
 		requests:    make(chan replicationReq, maxAppendEntriesCount),
+		done:        make(chan struct{}),
 	}
 }
 
@@ -38,14 +52,22 @@ func (b *batchReplicator) start() {
 	b.wg.Add(1)
 	go func() {
 		lastMatch := -1
+		batch := make([]replicationReq, 0, maxAppendEntriesCount)
 		for r := range b.requests {
-			if r.targetID > lastMatch {
+			batch = b.drain(append(batch[:0], r))
+
+			targetID := -1
+			for _, v := range batch {
+				targetID = util.Max(targetID, v.targetID)
+			}
+
+			if targetID > lastMatch {
 				// invoke new batch operation to see whether we can process up to targetID
 				lastMatch = b.replicateFn()
 			}
 
-			if r.reqwg != nil {
-				r.reqwg.Done()
+			for _, v := range batch {
+				v.signal()
 			}
 		}
 
@@ -53,12 +75,61 @@ func (b *batchReplicator) start() {
 	}()
 }
 
-// stop stops the batcher and wait for finish
+// drain appends all requests currently pending in the queue to batch without blocking
+func (b *batchReplicator) drain(batch []replicationReq) []replicationReq {
+	for {
+		select {
+		case r, ok := <-b.requests:
+			if !ok {
+				return batch
+			}
+			batch = append(batch, r)
+		default:
+			return batch
+		}
+	}
+}
+
+// stop stops the batcher and wait for finish. Requests already queued are still processed
 func (b *batchReplicator) stop() {
+	b.mu.Lock()
+	b.stopped = true
+	close(b.done)
+	b.mu.Unlock()
+
+	b.senders.Wait()
 	close(b.requests)
 	b.wg.Wait()
 }
 
+// enqueue queues a request. Requests after stop are signaled done right away.
+// Blocking senders don't hold the lock while waiting, since the batcher might need the node lock to make progress
+func (b *batchReplicator) enqueue(r replicationReq, block bool) {
+	b.mu.Lock()
+	if b.stopped {
+		b.mu.Unlock()
+		r.signal()
+		return
+	}
+	b.senders.Add(1)
+	b.mu.Unlock()
+	defer b.senders.Done()
+
+	if block {
+		select {
+		case b.requests <- r:
+		case <-b.done:
+			r.signal()
+		}
+		return
+	}
+
+	select {
+	case b.requests <- r:
+	default:
+	}
+}
+
 // requestReplicateTo requests a process towards the target id.
 // It'll block if current request queue is full.
 // true - if the targetID is processed within one batch after the request has been picked up by the batcher
@@ -68,17 +139,17 @@ func (b *batchReplicator) requestReplicateTo(targetID int, wg *sync.WaitGroup) {
 		util.Panicln("invalid target index")
 	}
 
-	b.requests <- replicationReq{
-		targetID: targetID,
-		reqwg:    wg,
-	}
+	b.enqueue(replicationReq{targetID: targetID, reqwg: wg}, true)
+}
+
+// requestReplicate requests a new replicate regardless of lastMatch, e.g. to confirm leadership.
+// It'll block if current request queue is full. The replicate starts after the request is queued
+func (b *batchReplicator) requestReplicate(wg *sync.WaitGroup) {
+	b.enqueue(replicationReq{targetID: targetAny, reqwg: wg}, true)
 }
 
 // tryRequestReplicate request a batch process with no target.
 // It won't block if request queue is full. wg is optional
 func (b *batchReplicator) tryRequestReplicate(wg *sync.WaitGroup) {
-	select {
-	case b.requests <- replicationReq{targetID: targetAny, reqwg: wg}:
-	default:
-	}
+	b.enqueue(replicationReq{targetID: targetAny, reqwg: wg}, false)
 }
@@ -4,6 +4,7 @@ import (
 	"sync"
 	"sync/atomic"
 	"testing"
+	"time"
 )
 
 func TestBatchReplicate(t *testing.T) {
@@ -63,3 +64,85 @@ func TestTryRequestReplicate(t *testing.T) {
 		replicator.tryRequestReplicate(nil)
 	}
 }
+
+func TestRequestReplicate(t *testing.T) {
+	calls := int32(0)
+	replicator := newBatchReplicator(func() int {
+		atomic.AddInt32(&calls, 1)
+		return targetAny - 1
+	})
+
+	replicator.start()
+	var wg sync.WaitGroup
+
+	// requestReplicate always triggers a replicate even when everything is replicated
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		replicator.requestReplicate(&wg)
+		wg.Wait()
+		if atomic.LoadInt32(&calls) != int32(i+1) {
+			t.Error("requestReplicate didn't trigger replicate")
+		}
+	}
+
+	replicator.stop()
+}
+
+func TestBatchDrain(t *testing.T) {
+	calls := 0
+	replicator := newBatchReplicator(func() int {
+		calls++
+		return 100
+	})
+
+	// queue requests before starting so that they are picked up as one batch
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		replicator.requestReplicateTo(i, &wg)
+	}
+	replicator.start()
+	wg.Wait()
+
+	if calls != 1 {
+		t.Errorf("queued requests should be batched into 1 replicate, got %d", calls)
+	}
+
+	replicator.stop()
+}
+
+func TestStopBatchReplicator(t *testing.T) {
+	block := make(chan struct{})
+	replicator := newBatchReplicator(func() int {
+		<-block
+		return 0
+	})
+	replicator.start()
+
+	// fill up the queue while the batcher is busy, so that the next sender blocks
+	var wg sync.WaitGroup
+	for i := 0; i <= cap(replicator.requests)+1; i++ {
+		wg.Add(1)
+		go replicator.requestReplicate(&wg)
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		replicator.stop()
+		close(stopped)
+	}()
+	close(block)
+
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("stop should not wait for blocked senders")
+	}
+	wg.Wait()
+
+	// requests after stop are signaled right away
+	wg.Add(2)
+	replicator.requestReplicateTo(1, &wg)
+	replicator.tryRequestReplicate(&wg)
+	wg.Wait()
+}
@@ -0,0 +1,62 @@
+package raft
+
+import (
+	"github.com/sidecus/raft/pkg/util"
+)
+
+// Log subsystems. Each can have its own log level, e.g. by util.SetSubsystemLogLevel for the default logger
+const (
+	// LogSubsystemNode logs node lifecycle, e.g. start, restore and bootstrap
+	LogSubsystemNode = "node"
+	// LogSubsystemElection logs terms, votes and leadership changes
+	LogSubsystemElection = "election"
+	// LogSubsystemReplication logs AppendEntries and commits
+	LogSubsystemReplication = "replication"
+	// LogSubsystemSnapshot logs taking and installing snapshots
+	LogSubsystemSnapshot = "snapshot"
+)
+
+// Logger logs raft events with key/value fields. Embedding apps can implement it to route raft logs to their own logger.
+// level is one of util.LevelError to util.LevelVerbose, and keyvals are alternating keys and values, e.g. "term", 3.
+// *util.Logger implements it, and util.DefaultLogger() is used when nodes are created without a logger
+type Logger interface {
+	Log(level int, subsystem string, msg string, keyvals ...interface{})
+}
+
+// nodeLogger adds the node ID to all logs of a node. The zero value logs to the default logger
+type nodeLogger struct {
+	logger Logger
+	nodeID int
+}
+
+func newNodeLogger(nodeID int, logger Logger) nodeLogger {
+	return nodeLogger{logger: logger, nodeID: nodeID}
+}
+
+func (l nodeLogger) log(level int, subsystem string, msg string, keyvals []interface{}) {
+	logger := l.logger
+	if logger == nil {
+		logger = util.DefaultLogger()
+	}
+	logger.Log(level, subsystem, msg, append([]interface{}{"node", l.nodeID}, keyvals...)...)
+}
+
+func (l nodeLogger) error(subsystem string, msg string, keyvals ...interface{}) {
+	l.log(util.LevelError, subsystem, msg, keyvals)
+}
+
+func (l nodeLogger) warn(subsystem string, msg string, keyvals ...interface{}) {
+	l.log(util.LevelWarning, subsystem, msg, keyvals)
+}
+
+func (l nodeLogger) info(subsystem string, msg string, keyvals ...interface{}) {
+	l.log(util.LevelInfo, subsystem, msg, keyvals)
+}
+
+func (l nodeLogger) trace(subsystem string, msg string, keyvals ...interface{}) {
+	l.log(util.LevelTrace, subsystem, msg, keyvals)
+}
+
+func (l nodeLogger) verbose(subsystem string, msg string, keyvals ...interface{}) {
+	l.log(util.LevelVerbose, subsystem, msg, keyvals)
+}
@@ -0,0 +1,61 @@
+package raft
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/sidecus/raft/pkg/util"
+)
+
+type testLogEntry struct {
+	level     int
+	subsystem string
+	msg       string
+	keyvals   []interface{}
+}
+
+type testLogger struct {
+	mu      sync.Mutex
+	entries []testLogEntry
+}
+
+func (l *testLogger) Log(level int, subsystem string, msg string, keyvals ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.entries = append(l.entries, testLogEntry{level: level, subsystem: subsystem, msg: msg, keyvals: keyvals})
+}
+
+func (l *testLogger) find(subsystem string, msg string) *testLogEntry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for i := range l.entries {
+		if l.entries[i].subsystem == subsystem && l.entries[i].msg == msg {
+			return &l.entries[i]
+		}
+	}
+	return nil
+}
+
+func TestNodeLogger(t *testing.T) {
+	logger := &testLogger{}
+	ret, err := NewNode(5, map[int]NodeInfo{}, &testStateMachine{}, &MockPeerFactory{}, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := ret.(*node)
+	n.timer = &fakeRaftTimer{}
+
+	n.Start()
+	defer n.Stop()
+
+	if e := logger.find(LogSubsystemNode, "Starting"); e == nil || e.level != util.LevelInfo {
+		t.Error("Node should log to the injected logger")
+	}
+	e := logger.find(LogSubsystemElection, "Won election")
+	if e == nil || len(e.keyvals) != 4 || e.keyvals[0] != "node" || e.keyvals[1] != 5 || e.keyvals[2] != "term" || e.keyvals[3] != 1 {
+		t.Error("Node logs should have the node ID and fields", e)
+	}
+
+	// zero value logs to the default logger instead of panicking
+	nodeLogger{}.verbose(LogSubsystemNode, "test")
+}
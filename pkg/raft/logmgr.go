@@ -1,17 +1,27 @@
 package raft
 
 import (
+	"fmt"
+	"time"
+
+	"github.com/sidecus/raft/pkg/trace"
 	"github.com/sidecus/raft/pkg/util"
 )
 
 const snapshotEntriesCount = 4096
 const logsCapacity = snapshotEntriesCount * 3 / 2
 
+// noopCmdType is reserved for the no-op entry appended by a new leader. It's never applied to the state machine
+const noopCmdType = -1
+
 // LogEntry - one raft log entry, with term and index
 type LogEntry struct {
 	Index int
 	Term  int
 	Cmd   StateMachineCmd
+
+	// span of the proposal on the leader if the cmd is traced. It's not replicated
+	span trace.SpanContext
 }
 
 // ILogManager defines the interface for log manager
@@ -19,6 +29,7 @@ type ILogManager interface {
 	LastIndex() int
 	LastTerm() int
 	CommitIndex() int
+	LastApplied() int
 	SnapshotIndex() int
 	SnapshotTerm() int
 	SnapshotFile() string
@@ -28,7 +39,11 @@ type ILogManager interface {
 	ProcessCmd(cmd StateMachineCmd, term int) int
 	ProcessLogs(prevLogIndex, prevLogTerm int, entries []LogEntry) (prevMatch bool)
 	CommitAndApply(targetIndex int) (newCommit bool, newSnapshot bool)
+	TakeResult(index int) interface{}
+	TraceEntry(index int, span trace.SpanContext)
 	InstallSnapshot(snapshotFile string, snapshotIndex int, snapshotTerm int) error
+	EnsureSnapshot() error
+	Bootstrap(snapshotFile string) error
 
 	// proxy to state machine Get
 	IValueGetter
@@ -47,11 +62,19 @@ type logManager struct {
 	lastApplied   int
 	logs          []LogEntry
 
+	// results of applied cmds proposed by this node, waiting to be taken by the proposer
+	results map[int]interface{}
+
+	// psm is the state machine when it persists its own state, nil otherwise
+	psm IPersistentStateMachine
+
+	log nodeLogger
+
 	IStateMachine
 }
 
-// newLogMgr creates a new logmgr
-func newLogMgr(nodeID int, sm IStateMachine) ILogManager {
+// newLogMgr creates a new logmgr. Logs go to the default logger if logger is nil
+func newLogMgr(nodeID int, sm IStateMachine, logger Logger) ILogManager {
 	if sm == nil {
 		util.Panicf("state machien cannot be nil")
 	}
@@ -65,12 +88,36 @@ func newLogMgr(nodeID int, sm IStateMachine) ILogManager {
 		snapshotTerm:  -1,
 		lastApplied:   -1,
 		logs:          make([]LogEntry, 0, logsCapacity),
+		results:       make(map[int]interface{}),
 		IStateMachine: sm,
+		log:           newNodeLogger(nodeID, logger),
+	}
+
+	if psm, ok := sm.(IPersistentStateMachine); ok {
+		lm.psm = psm
+		if index, term := psm.LastApplied(); index >= 0 {
+			lm.restore(index, term)
+		}
 	}
 
 	return lm
 }
 
+// restore resumes from the last entry applied by a persistent state machine, as if it's a snapshot.
+// No snapshot file is taken, since the state machine already persists its state. One is taken by EnsureSnapshot
+// if a follower needs logs before it, so that restarts don't write the whole store
+func (lm *logManager) restore(index int, term int) {
+	lm.snapshotIndex = index
+	lm.snapshotTerm = term
+	lm.snapshotFile = ""
+	lm.lastApplied = index
+	lm.commitIndex = index
+	lm.lastIndex = index
+	lm.lastTerm = term
+
+	lm.log.info(LogSubsystemNode, "Restored state machine", "index", index, "term", term)
+}
+
 // LastIndex returns the last index for the log
 func (lm *logManager) LastIndex() int {
 	return lm.lastIndex
@@ -86,6 +133,11 @@ func (lm *logManager) CommitIndex() int {
 	return lm.commitIndex
 }
 
+// LastApplied returns the index of the last entry applied to the state machine
+func (lm *logManager) LastApplied() int {
+	return lm.lastApplied
+}
+
 // SnapshotIndex returns the recent snapshot's last included index (-1 otherwise)
 func (lm *logManager) SnapshotIndex() int {
 	return lm.snapshotIndex
@@ -96,7 +148,8 @@ func (lm *logManager) SnapshotTerm() int {
 	return lm.snapshotTerm
 }
 
-// SnapshotFile returns the recent snapshot file (string zero value otherwise)
+// SnapshotFile returns the recent snapshot file (string zero value otherwise).
+// It can be empty with a snapshot index, e.g. for a persistent state machine, see EnsureSnapshot
 func (lm *logManager) SnapshotFile() string {
 	return lm.snapshotFile
 }
@@ -143,6 +196,11 @@ func (lm *logManager) ProcessCmd(cmd StateMachineCmd, term int) int {
 		Term:  term,
 	}
 	lm.appendLogs(entry)
+
+	// track the result so that the proposer can take it after the cmd is applied
+	if cmd.CmdType != noopCmdType {
+		lm.results[lm.lastIndex] = nil
+	}
 	return lm.lastIndex
 }
 
@@ -154,7 +212,7 @@ func (lm *logManager) ProcessLogs(prevLogIndex, prevLogTerm int, entries []LogEn
 	lm.validateLogEntries(prevLogIndex, prevLogTerm, entries)
 
 	prevMatch := lm.hasMatchingPrevEntry(prevLogIndex, prevLogTerm)
-	util.WriteVerbose("Match on prevIndex(%d) prevTerm(%d): %v", prevLogIndex, prevLogTerm, prevMatch)
+	lm.log.verbose(LogSubsystemReplication, "Matched prev entry", "prevIndex", prevLogIndex, "prevTerm", prevLogTerm, "match", prevMatch)
 	if !prevMatch {
 		return false
 	}
@@ -186,17 +244,32 @@ func (lm *logManager) CommitAndApply(targetIndex int) (newCommit bool, newSnapsh
 	// Set new commit index and apply commands to state machine if needed
 	lm.commitIndex = targetIndex
 	if lm.commitIndex > lm.lastApplied {
+		start := time.Now()
 		for i := lm.lastApplied + 1; i <= lm.commitIndex; i++ {
 			// Apply to statemachine
-			lm.Apply(lm.GetLogEntry(i).Cmd)
+			if entry := lm.GetLogEntry(i); entry.Cmd.CmdType != noopCmdType {
+				span := trace.StartWithParent(entry.span, "raft.apply")
+				span.SetAttribute("index", i)
+				result := lm.apply(entry)
+				span.End()
+				if _, ok := lm.results[i]; ok {
+					lm.results[i] = result
+				}
+			}
 		}
+		metrics.ObserveApply(lm.commitIndex-lm.lastApplied, time.Since(start))
 		lm.lastApplied = lm.commitIndex
 	}
 
-	// take snapshot if needed
-	if lm.lastApplied-lm.snapshotIndex >= snapshotEntriesCount {
+	// take snapshot if needed. A persistent state machine already has the state, so only logs are compacted
+	if lm.lastApplied-lm.snapshotIndex >= snapshotEntriesCount && lm.psm != nil {
+		deleteSnapshot(lm.snapshotFile)
+		lm.snapshotFile = ""
+		lm.compactLogs(lm.lastApplied)
+		lm.log.verbose(LogSubsystemSnapshot, "Compacted logs", "snapshotIndex", lm.snapshotIndex)
+	} else if lm.lastApplied-lm.snapshotIndex >= snapshotEntriesCount {
 		if err := lm.TakeSnapshot(); err != nil {
-			util.WriteError("Failed to take snapshot: %s", err)
+			lm.log.error(LogSubsystemSnapshot, "Failed to take snapshot", "err", err)
 		} else {
 			newSnapshot = true
 		}
@@ -205,12 +278,37 @@ func (lm *logManager) CommitAndApply(targetIndex int) (newCommit bool, newSnapsh
 	return
 }
 
+// apply applies the entry's cmd to the state machine
+func (lm *logManager) apply(entry LogEntry) interface{} {
+	if lm.psm != nil {
+		return lm.psm.ApplyEntry(entry.Index, entry.Term, entry.Cmd)
+	}
+	return lm.Apply(entry.Cmd)
+}
+
+// TraceEntry records the span which proposed the entry at index, so that its replication and apply are traced as children
+func (lm *logManager) TraceEntry(index int, span trace.SpanContext) {
+	if span.IsValid() && index > lm.snapshotIndex && index <= lm.lastIndex {
+		lm.logs[index-lm.snapshotIndex-1].span = span
+	}
+}
+
+// TakeResult returns the result of applying the cmd proposed at index and stops tracking it.
+// Returns nil if the cmd is not applied yet. Note the entry at index might have been overwritten by
+// a new leader, caller should check the entry's term before trusting the result
+func (lm *logManager) TakeResult(index int) interface{} {
+	result := lm.results[index]
+	delete(lm.results, index)
+	return result
+}
+
 // TakeSnapshot takes a snap shot and saves it to a file
 func (lm *logManager) TakeSnapshot() error {
-	if lm.lastApplied == lm.snapshotIndex {
-		return nil // nothing to do
+	if lm.lastApplied == lm.snapshotIndex && (lm.snapshotFile != "" || lm.lastApplied < 0) {
+		return nil // nothing to do, unless the file of a restored or compacted snapshot is not taken yet
 	}
 
+	start := time.Now()
 	index := lm.lastApplied
 	term := lm.getLogEntryTerm(index)
 
@@ -219,17 +317,31 @@ func (lm *logManager) TakeSnapshot() error {
 	if err != nil {
 		return err
 	}
-	defer w.Close()
 
 	// try deleting the old snapshot file
 	deleteSnapshot(lm.snapshotFile)
 
-	// Serialize from statemachine, truncate logs and update info
-	if err = lm.Serialize(w); err != nil {
-		util.WriteError("Fatal: Serialize snapshot file %s failed. err:%s", file, err)
+	// Serialize from statemachine, truncate logs and update info. Closing the file writes its checksum
+	err = lm.Serialize(w)
+	if closeErr := w.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		lm.log.error(LogSubsystemSnapshot, "Failed to serialize snapshot", "file", file, "err", err)
 		return err
 	}
 
+	lm.compactLogs(index)
+	lm.snapshotFile = file
+
+	metrics.ObserveSnapshot(SnapshotTake, snapshotFileSize(file), time.Since(start))
+	return nil
+}
+
+// compactLogs drops logs up to index, which becomes the snapshot index. Caller takes care of the snapshot file
+func (lm *logManager) compactLogs(index int) {
+	term := lm.getLogEntryTerm(index)
+
 	// use copy to ensure lm.logs always point to backing array start
 	remaining, _, _ := lm.GetLogEntries(index+1, lm.lastIndex+1)
 	lm.logs = lm.logs[0:len(remaining)]
@@ -237,16 +349,34 @@ func (lm *logManager) TakeSnapshot() error {
 
 	lm.snapshotIndex = index
 	lm.snapshotTerm = term
-	lm.snapshotFile = file
+}
 
-	return nil
+// EnsureSnapshot takes a snapshot file at the last applied entry if there is none, e.g. after a persistent state machine
+// is restored or its logs are compacted. Such snapshots are taken lazily, only when a follower needs one
+func (lm *logManager) EnsureSnapshot() error {
+	if lm.snapshotFile != "" || lm.lastApplied < 0 {
+		return nil
+	}
+	return lm.TakeSnapshot()
 }
 
 // InstallSnapshot installs a snapshot
 // For simplicity, we drop all local logs after installing the snapshot
 func (lm *logManager) InstallSnapshot(snapshotFile string, snapshotIndex int, snapshotTerm int) error {
+	start := time.Now()
+
+	// Verify the snapshot before touching the state machine, e.g. in case it's corrupted in transfer
+	header, err := VerifySnapshotFile(snapshotFile)
+	if err == nil && (header.Index != snapshotIndex || header.Term != snapshotTerm) {
+		err = fmt.Errorf("snapshot file %s is at T%dL%d, expecting T%dL%d: %w", snapshotFile, header.Term, header.Index, snapshotTerm, snapshotIndex, errorInvalidSnapshotInfo)
+	}
+	if err != nil {
+		lm.log.error(LogSubsystemSnapshot, "Invalid snapshot file", "file", snapshotFile, "err", err)
+		return err
+	}
+
 	// Read snapshot and deserialize
-	r, err := openSnapshot(snapshotFile)
+	_, r, err := OpenSnapshotFile(snapshotFile)
 	if err != nil {
 		return err
 	}
@@ -256,8 +386,13 @@ func (lm *logManager) InstallSnapshot(snapshotFile string, snapshotIndex int, sn
 	deleteSnapshot(lm.snapshotFile)
 
 	// deserialize into statemachine, update info
-	if err = lm.Deserialize(r); err != nil {
-		util.WriteError("Fatal: Deserialize from snapshot file %s failed. err:%s", snapshotFile, err)
+	if lm.psm != nil {
+		err = lm.psm.DeserializeAt(r, snapshotIndex, snapshotTerm)
+	} else {
+		err = lm.Deserialize(r)
+	}
+	if err != nil {
+		lm.log.error(LogSubsystemSnapshot, "Failed to deserialize snapshot", "file", snapshotFile, "err", err)
 		return err
 	}
 
@@ -270,6 +405,43 @@ func (lm *logManager) InstallSnapshot(snapshotFile string, snapshotIndex int, sn
 	lm.lastTerm = snapshotTerm
 	lm.logs = lm.logs[0:0]
 
+	metrics.ObserveSnapshot(SnapshotInstall, snapshotFileSize(snapshotFile), time.Since(start))
+	return nil
+}
+
+// Bootstrap loads the state machine from a snapshot file taken by any node, e.g. of a cluster which lost quorum for good.
+// The snapshot keeps its index but moves to term 0, so that the new cluster starts its terms afresh.
+// Like restore, a local snapshot file is only taken from it when the other nodes need one
+func (lm *logManager) Bootstrap(snapshotFile string) error {
+	header, err := VerifySnapshotFile(snapshotFile)
+	if err == nil && header.Index < 0 {
+		err = fmt.Errorf("snapshot file %s is at index %d: %w", snapshotFile, header.Index, errorInvalidSnapshotInfo)
+	}
+	if err != nil {
+		return err
+	}
+
+	_, r, err := OpenSnapshotFile(snapshotFile)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	if lm.psm != nil {
+		err = lm.psm.DeserializeAt(r, header.Index, 0)
+	} else {
+		err = lm.Deserialize(r)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to load state machine from snapshot file %s: %w", snapshotFile, err)
+	}
+
+	// drop what's restored from a persistent state machine if any
+	deleteSnapshot(lm.snapshotFile)
+	lm.logs = lm.logs[0:0]
+	lm.restore(header.Index, 0)
+
+	lm.log.info(LogSubsystemNode, "Bootstrapped from snapshot file", "file", snapshotFile, "snapshotNode", header.NodeID, "term", header.Term, "index", header.Index)
 	return nil
 }
 
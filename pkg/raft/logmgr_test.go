@@ -3,6 +3,7 @@ package raft
 import (
 	"encoding/json"
 	"io"
+	"os"
 	"testing"
 )
 
@@ -10,9 +11,10 @@ type testStateMachine struct {
 	lastApplied int
 }
 
-func (sm *testStateMachine) Apply(cmd StateMachineCmd) {
+func (sm *testStateMachine) Apply(cmd StateMachineCmd) interface{} {
 	data := cmd.Data.(int)
 	sm.lastApplied = data
+	return data * 10
 }
 
 func (sm *testStateMachine) Get(param ...interface{}) (result interface{}, err error) {
@@ -28,7 +30,7 @@ func (sm *testStateMachine) Deserialize(r io.Reader) error {
 }
 
 func TestNewLogManager(t *testing.T) {
-	lm := newLogMgr(100, &testStateMachine{}).(*logManager)
+	lm := newLogMgr(100, &testStateMachine{}, nil).(*logManager)
 
 	if lm.nodeID != 100 {
 		t.Error("LogManager created with invalid node ID")
@@ -64,7 +66,7 @@ func TestNewLogManager(t *testing.T) {
 }
 
 func TestProcessCmd(t *testing.T) {
-	lm := newLogMgr(100, &testStateMachine{}).(*logManager)
+	lm := newLogMgr(100, &testStateMachine{}, nil).(*logManager)
 	cmd := StateMachineCmd{}
 	if lm.LastIndex() != -1 {
 		t.Error("LastIndex is not -1 upon init")
@@ -117,7 +119,7 @@ func TestProcessCmd(t *testing.T) {
 
 func TestProcessLogs(t *testing.T) {
 	sm := &testStateMachine{lastApplied: -1}
-	lm := newLogMgr(100, sm).(*logManager)
+	lm := newLogMgr(100, sm, nil).(*logManager)
 	lm.logs = make([]LogEntry, 5)
 	lm.lastIndex = 14
 	lm.lastTerm = 13
@@ -217,7 +219,7 @@ func TestProcessLogs(t *testing.T) {
 
 func TestCommit(t *testing.T) {
 	sm := &testStateMachine{lastApplied: -1}
-	lm := newLogMgr(100, sm).(*logManager)
+	lm := newLogMgr(100, sm, nil).(*logManager)
 
 	// append two logs to it
 	entries := generateTestEntries(-1, 1)
@@ -495,9 +497,9 @@ func TestGetLogEntries(t *testing.T) {
 
 func TestSnapshot(t *testing.T) {
 	setSnapshotPathToTempDir()
-	lmSrc := newLogMgr(100, &testStateMachine{lastApplied: 100}).(*logManager)
+	lmSrc := newLogMgr(100, &testStateMachine{lastApplied: 100}, nil).(*logManager)
 	smDst := &testStateMachine{}
-	lmDst := newLogMgr(200, smDst).(*logManager)
+	lmDst := newLogMgr(200, smDst, nil).(*logManager)
 
 	// Take snapshot on empty state (usually won't happen)
 	testSnapshot(lmSrc, lmDst, t)
@@ -569,6 +571,9 @@ func testSnapshot(lmSrc, lmDst *logManager, t *testing.T) {
 		return
 	}
 
+	if err := lmDst.InstallSnapshot(lmSrc.snapshotFile, lmSrc.snapshotIndex+1, lmSrc.snapshotTerm); err == nil {
+		t.Error("Install snapshot should fail when the file is at a different index")
+	}
 	if err := lmDst.InstallSnapshot(lmSrc.snapshotFile, lmSrc.snapshotIndex, lmSrc.snapshotTerm); err != nil {
 		t.Error("Install snapshot failed")
 	}
@@ -596,3 +601,144 @@ func logsEqual(src, dst []LogEntry) bool {
 
 	return true
 }
+
+func TestTakeResult(t *testing.T) {
+	lm := newLogMgr(100, &testStateMachine{}, nil).(*logManager)
+
+	lm.ProcessCmd(StateMachineCmd{CmdType: noopCmdType}, 1)
+	index := lm.ProcessCmd(StateMachineCmd{CmdType: 1, Data: 3}, 1)
+
+	if lm.TakeResult(index) != nil {
+		t.Error("TakeResult should return nil before the cmd is applied")
+	}
+
+	index = lm.ProcessCmd(StateMachineCmd{CmdType: 1, Data: 5}, 1)
+	lm.CommitAndApply(index)
+
+	if result := lm.TakeResult(index); result == nil || result.(int) != 50 {
+		t.Error("TakeResult should return the result of applying the cmd")
+	}
+	if len(lm.results) != 0 {
+		t.Error("Results should only be tracked for cmds proposed but not taken yet")
+	}
+}
+
+// testPersistentStateMachine tracks the applied index and term like a persistent state machine would
+type testPersistentStateMachine struct {
+	testStateMachine
+	Index int
+	Term  int
+}
+
+func (sm *testPersistentStateMachine) ApplyEntry(index int, term int, cmd StateMachineCmd) interface{} {
+	sm.Index, sm.Term = index, term
+	return sm.Apply(cmd)
+}
+
+func (sm *testPersistentStateMachine) DeserializeAt(r io.Reader, index int, term int) error {
+	sm.Index, sm.Term = index, term
+	return sm.Deserialize(r)
+}
+
+func (sm *testPersistentStateMachine) LastApplied() (int, int) {
+	return sm.Index, sm.Term
+}
+
+func TestPersistentStateMachine(t *testing.T) {
+	setSnapshotPathToTempDir()
+	sm := &testPersistentStateMachine{Index: -1, Term: -1}
+	lm := newLogMgr(100, sm, nil).(*logManager)
+	if lm.lastIndex != -1 || lm.snapshotFile != "" {
+		t.Fatal("LogManager should start from empty logs when nothing is applied")
+	}
+
+	lm.ProcessLogs(-1, -1, generateTestEntries(-1, 2))
+	lm.CommitAndApply(1)
+	if sm.Index != 1 || sm.Term != 2 {
+		t.Error("Persistent state machine should be told the index and term of applied entries")
+	}
+
+	// a restarted node resumes from what the state machine has applied
+	lm = newLogMgr(100, sm, nil).(*logManager)
+	if lm.lastIndex != 1 || lm.lastTerm != 2 || lm.lastApplied != 1 || lm.commitIndex != 1 || lm.snapshotIndex != 1 {
+		t.Error("LogManager should resume from the last applied entry of the state machine")
+	}
+	if lm.snapshotFile != "" {
+		t.Error("LogManager should not take a snapshot file when resuming")
+	}
+	if err := lm.EnsureSnapshot(); err != nil || lm.snapshotFile == "" || lm.snapshotIndex != 1 {
+		t.Fatal("EnsureSnapshot should take a snapshot file at the last applied entry", err)
+	}
+	file := lm.snapshotFile
+	if err := lm.EnsureSnapshot(); err != nil || lm.snapshotFile != file {
+		t.Error("EnsureSnapshot should keep the snapshot file already taken")
+	}
+	if !lm.ProcessLogs(1, 2, generateTestEntries(1, 3)) {
+		t.Error("LogManager should accept logs after the last applied entry")
+	}
+
+	dst := &testPersistentStateMachine{Index: -1, Term: -1}
+	lmDst := newLogMgr(200, dst, nil).(*logManager)
+	if err := lmDst.InstallSnapshot(lm.snapshotFile, 1, 2); err != nil || dst.Index != 1 || dst.Term != 2 {
+		t.Error("InstallSnapshot should tell the persistent state machine the snapshot index and term")
+	}
+
+	// compaction drops logs without taking a snapshot file, the state machine already has the state
+	for i := 0; i < snapshotEntriesCount; i++ {
+		lm.ProcessCmd(StateMachineCmd{Data: i}, 3)
+	}
+	if _, newSnapshot := lm.CommitAndApply(lm.lastIndex); newSnapshot || lm.snapshotIndex != lm.lastIndex || len(lm.logs) != 0 {
+		t.Error("LogManager should compact logs of a persistent state machine")
+	}
+	if _, err := os.Stat(file); lm.snapshotFile != "" || !os.IsNotExist(err) {
+		t.Error("Compaction should drop the older snapshot file of a persistent state machine")
+	}
+	if err := lm.EnsureSnapshot(); err != nil || lm.snapshotFile == "" || lm.snapshotTerm != 3 {
+		t.Error("EnsureSnapshot should take a snapshot file after compaction", err)
+	}
+}
+
+func TestBootstrap(t *testing.T) {
+	setSnapshotPathToTempDir()
+	src := newLogMgr(1, &testStateMachine{}, nil).(*logManager)
+	src.ProcessLogs(-1, -1, generateTestEntries(-1, 3))
+	src.CommitAndApply(1)
+	if err := src.TakeSnapshot(); err != nil {
+		t.Fatal(err)
+	}
+
+	// a restarted persistent state machine is replaced by the snapshot
+	sm := &testPersistentStateMachine{Index: 10, Term: 5}
+	lm := newLogMgr(2, sm, nil).(*logManager)
+	if err := lm.EnsureSnapshot(); err != nil {
+		t.Fatal(err)
+	}
+	restored := lm.snapshotFile
+	if err := lm.Bootstrap(src.snapshotFile); err != nil {
+		t.Fatal(err)
+	}
+
+	if sm.Index != 1 || sm.Term != 0 {
+		t.Error("Bootstrap should load the state machine at the snapshot index with term 0")
+	}
+	if lm.snapshotIndex != 1 || lm.snapshotTerm != 0 || lm.lastIndex != 1 || lm.lastTerm != 0 || lm.commitIndex != 1 || lm.lastApplied != 1 {
+		t.Error("Bootstrap should resume logs from the snapshot index with term 0")
+	}
+	if _, err := os.Stat(restored); !os.IsNotExist(err) || lm.snapshotFile != "" {
+		t.Error("Bootstrap should delete the snapshot file of the replaced state machine")
+	}
+	if err := lm.EnsureSnapshot(); err != nil {
+		t.Fatal(err)
+	}
+	defer deleteSnapshot(lm.snapshotFile)
+	if header, err := VerifySnapshotFile(lm.snapshotFile); err != nil || header != (SnapshotHeader{NodeID: 2, Term: 0, Index: 1}) {
+		t.Error("Bootstrap should allow taking a snapshot which can be sent to other nodes", header, err)
+	}
+	if !lm.ProcessLogs(1, 0, generateTestEntries(1, 1)) {
+		t.Error("Bootstrap should accept logs after the snapshot")
+	}
+
+	if err := newLogMgr(3, &testStateMachine{}, nil).Bootstrap(src.snapshotFile + ".missing"); err == nil {
+		t.Error("Bootstrap should fail without a valid snapshot file")
+	}
+}
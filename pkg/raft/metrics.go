@@ -0,0 +1,65 @@
+package raft
+
+import (
+	"time"
+)
+
+// RPC names reported to IMetrics
+const (
+	RPCAppendEntries   = "AppendEntries"
+	RPCRequestVote     = "RequestVote"
+	RPCInstallSnapshot = "InstallSnapshot"
+)
+
+// Snapshot operations reported to IMetrics
+const (
+	SnapshotTake    = "take"
+	SnapshotInstall = "install"
+)
+
+// IMetrics collects raft metrics, e.g. to expose them to Prometheus.
+// Methods might be called with the node lock held, so they should be concurrency safe and return quickly
+type IMetrics interface {
+	// TermChanged is called when the node moves to a higher term
+	TermChanged(term int)
+	// StateChanged is called when the node becomes follower, candidate or leader
+	StateChanged(state NodeState)
+	// ElectionStarted is called when the node starts an election
+	ElectionStarted()
+	// ElectionWon is called when the node wins an election
+	ElectionWon()
+	// ObserveRPC is called when an RPC to a peer returns
+	ObserveRPC(rpc string, peerID int, duration time.Duration, err error)
+	// SetReplicationLag is called by the leader with the number of entries a follower is behind
+	SetReplicationLag(peerID int, lag int)
+	// ObserveCommit is called by the leader when a cmd is committed, with the time since it's proposed
+	ObserveCommit(duration time.Duration)
+	// ObserveApply is called when committed entries are applied to the state machine
+	ObserveApply(entries int, duration time.Duration)
+	// ObserveSnapshot is called when a snapshot is taken or installed, with the snapshot file size
+	ObserveSnapshot(op string, size int64, duration time.Duration)
+}
+
+// metrics collects metrics of all nodes in the process, no-op by default
+var metrics IMetrics = noopMetrics{}
+
+// SetMetrics sets the metrics collector. It should be called before creating nodes
+func SetMetrics(m IMetrics) {
+	if m == nil {
+		m = noopMetrics{}
+	}
+	metrics = m
+}
+
+// noopMetrics drops all metrics
+type noopMetrics struct{}
+
+func (noopMetrics) TermChanged(term int)                                                 {}
+func (noopMetrics) StateChanged(state NodeState)                                         {}
+func (noopMetrics) ElectionStarted()                                                     {}
+func (noopMetrics) ElectionWon()                                                         {}
+func (noopMetrics) ObserveRPC(rpc string, peerID int, duration time.Duration, err error) {}
+func (noopMetrics) SetReplicationLag(peerID int, lag int)                                {}
+func (noopMetrics) ObserveCommit(duration time.Duration)                                 {}
+func (noopMetrics) ObserveApply(entries int, duration time.Duration)                     {}
+func (noopMetrics) ObserveSnapshot(op string, size int64, duration time.Duration)        {}
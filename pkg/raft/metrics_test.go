@@ -0,0 +1,104 @@
+package raft
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+type testMetrics struct {
+	mu        sync.Mutex
+	terms     []int
+	states    []NodeState
+	elections int
+	wins      int
+	commits   int
+	applied   int
+}
+
+func (m *testMetrics) TermChanged(term int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.terms = append(m.terms, term)
+}
+
+func (m *testMetrics) StateChanged(state NodeState) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.states = append(m.states, state)
+}
+
+func (m *testMetrics) ElectionStarted() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.elections++
+}
+
+func (m *testMetrics) ElectionWon() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.wins++
+}
+
+func (m *testMetrics) ObserveRPC(rpc string, peerID int, duration time.Duration, err error) {}
+func (m *testMetrics) SetReplicationLag(peerID int, lag int)                                {}
+
+func (m *testMetrics) ObserveCommit(duration time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.commits++
+}
+
+func (m *testMetrics) ObserveApply(entries int, duration time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.applied += entries
+}
+
+func (m *testMetrics) ObserveSnapshot(op string, size int64, duration time.Duration) {}
+
+func TestMetrics(t *testing.T) {
+	m := &testMetrics{}
+	SetMetrics(m)
+	defer SetMetrics(nil)
+
+	ret, err := NewNode(0, map[int]NodeInfo{}, &testStateMachine{}, &MockPeerFactory{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := ret.(*node)
+	n.timer = &fakeRaftTimer{}
+
+	n.Start()
+	defer n.Stop()
+	if _, err := n.Execute(context.Background(), &StateMachineCmd{CmdType: 1, Data: 2}); err != nil {
+		t.Fatal(err)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.terms) != 1 || m.terms[0] != 1 {
+		t.Error("TermChanged should be called when moving to term 1", m.terms)
+	}
+	if len(m.states) != 3 || m.states[0] != NodeStateFollower || m.states[1] != NodeStateCandidate || m.states[2] != NodeStateLeader {
+		t.Error("StateChanged should be called on each state transition", m.states)
+	}
+	if m.elections != 1 || m.wins != 1 {
+		t.Error("Election should be started and won once")
+	}
+	if m.commits != 1 {
+		t.Error("ObserveCommit should be called for the executed cmd")
+	}
+	// noop entry is committed without being applied to the state machine, but still counted
+	if m.applied != 2 {
+		t.Error("ObserveApply should count all committed entries", m.applied)
+	}
+}
+
+func TestSetMetricsNil(t *testing.T) {
+	SetMetrics(nil)
+	if _, ok := metrics.(noopMetrics); !ok {
+		t.Error("SetMetrics(nil) should reset to no-op metrics")
+	}
+}
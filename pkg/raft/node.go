@@ -4,14 +4,21 @@ import (
 	"context"
 	"errors"
 	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/sidecus/raft/pkg/trace"
 	"github.com/sidecus/raft/pkg/util"
 )
 
-var errorInsufficientPeers = errors.New("At least 2 peers required to make a 3 node cluster")
 var errorCurrentNodeInPeers = errors.New("current node should not exist as one of its peers")
 var errorInvalidPeerNodeID = errors.New("peer node has invalid node ID")
-var errorNoLeaderAvailable = errors.New("No leader currently available")
+
+// ErrorNoLeaderAvailable is returned by Execute and Get when there is no known leader
+var ErrorNoLeaderAvailable = errors.New("No leader currently available")
+
+// ErrorNodeStopped is returned by RPCs after the node is stopped
+var ErrorNodeStopped = errors.New("Node is stopped")
 
 // NodeState is the state of the node
 type NodeState int
@@ -31,6 +38,22 @@ type NodeInfo struct {
 	Endpoint string
 }
 
+// NodeStatus is a point in time view of a node, e.g. for health checks
+type NodeStatus struct {
+	NodeID    int
+	State     NodeState
+	Term      int
+	LeaderID  int
+	LastIndex int
+	// CommitIndex and AppliedIndex are local. LeaderCommitIndex is the leader's commit index as last heard from it,
+	// which tells how far behind the node is
+	CommitIndex       int
+	AppliedIndex      int
+	LeaderCommitIndex int
+	// InstallingSnapshot is true while the node receives or installs a snapshot from the leader
+	InstallingSnapshot bool
+}
+
 // INodeRPCProvider interface defines the RPC related methods for a node
 type INodeRPCProvider interface {
 	// AppendEntries appends entries
@@ -42,6 +65,9 @@ type INodeRPCProvider interface {
 	// InstallSnapshot installs a snapshot.
 	InstallSnapshot(ctx context.Context, req *SnapshotRequest) (*AppendEntriesReply, error)
 
+	// TimeoutNow starts an election right away, sent by the leader transferring leadership
+	TimeoutNow(ctx context.Context, req *TimeoutNowRequest) (*TimeoutNowReply, error)
+
 	// Get gets a committed and applied value from state machine
 	Get(ctx context.Context, req *GetRequest) (*GetReply, error)
 
@@ -54,12 +80,21 @@ type INode interface {
 	// Start starts the node
 	Start()
 
-	// Stop stops the node
+	// Stop stops the node. In flight applies and snapshots finish first, and RPCs fail with ErrorNodeStopped afterwards
 	Stop()
 
+	// TransferLeadership hands leadership over to a follower if the node is the leader, e.g. before stopping it
+	TransferLeadership(ctx context.Context) error
+
 	// NodeID returns the node's ID
 	NodeID() int
 
+	// LeaderID returns the known leader's ID, or -1 if there is none
+	LeaderID() int
+
+	// Status returns the node's status. It doesn't wait for a snapshot being installed
+	Status() NodeStatus
+
 	// OnSnapshotPart is invoked when receiving a snapshot part (full snapshot might still be pending)
 	OnSnapshotPart(part *SnapshotRequestHeader) bool
 
@@ -81,39 +116,127 @@ type node struct {
 	logMgr        ILogManager
 	peerMgr       IPeerManager
 	timer         IRaftTimer
+
+	// joining nodes don't start elections until they hear from a leader, see JoinCluster
+	joining bool
+	// the leader doesn't accept new cmds while transferring leadership, see TransferLeadership
+	transferring bool
+	stopped      bool
+
+	// leader's commit index as last heard from it
+	leaderCommitIndex int
+	// snapshot installation status, accessed atomically so that Status doesn't wait for the node lock held by InstallSnapshot.
+	// lastSnapshotPart is the unix nano time the last snapshot part was received
+	installingSnapshot int32
+	lastSnapshotPart   int64
+
+	log nodeLogger
 }
 
-// NewNode creates a new node
-func NewNode(nodeID int, peers map[int]NodeInfo, sm IStateMachine, proxyFactory IPeerProxyFactory) (INode, error) {
+// NewNode creates a new node. Logs go to logger, or util.DefaultLogger() if it's nil
+func NewNode(nodeID int, peers map[int]NodeInfo, sm IStateMachine, proxyFactory IPeerProxyFactory, logger Logger) (INode, error) {
 	if err := validateCluster(nodeID, peers); err != nil {
 		return nil, err
 	}
 	size := len(peers) + 1
+	logMgr := newLogMgr(nodeID, sm, logger)
 
 	n := &node{
-		mu:            sync.RWMutex{},
-		clusterSize:   size,
-		nodeID:        nodeID,
-		nodeState:     NodeStateFollower,
-		currentTerm:   0,
-		currentLeader: -1,
-		votedFor:      -1,
-		votes:         make(map[int]bool, size),
-		logMgr:        newLogMgr(nodeID, sm),
-	}
-
-	n.timer = newRaftTimer(n.onTimer)
+		mu:          sync.RWMutex{},
+		clusterSize: size,
+		nodeID:      nodeID,
+		nodeState:   NodeStateFollower,
+		// terms are not persisted. Start from the last term in logs restored by a persistent state machine,
+		// since a leader can't append entries with lower terms
+		currentTerm:       util.Max(0, logMgr.LastTerm()),
+		currentLeader:     -1,
+		votedFor:          -1,
+		votes:             make(map[int]bool, size),
+		logMgr:            logMgr,
+		leaderCommitIndex: logMgr.CommitIndex(),
+		log:               newNodeLogger(nodeID, logger),
+	}
+
+	n.timer = newRaftTimer(n.onTimer, n.log)
 	n.peerMgr = newPeerManager(peers, n.replicateData, proxyFactory)
 
 	return n, nil
 }
 
-// validateCluster validates params for the raft cluster
-func validateCluster(nodeID int, peers map[int]NodeInfo) error {
-	if len(peers) < 2 {
-		return errorInsufficientPeers
+// RecoverCluster creates a node of a new cluster with the state machine loaded from a snapshot file, e.g. taken by a node
+// of a cluster which lost quorum for good. peers is the configuration of the new cluster, and terms start afresh.
+// Other nodes of the new cluster start empty and receive the snapshot from the leader by InstallSnapshot,
+// and they need to join by JoinCluster so that only this node can be elected
+func RecoverCluster(nodeID int, peers map[int]NodeInfo, sm IStateMachine, proxyFactory IPeerProxyFactory, logger Logger, snapshotFile string) (INode, error) {
+	n, err := NewNode(nodeID, peers, sm, proxyFactory, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = n.(*node).bootstrap(snapshotFile); err != nil {
+		return nil, err
+	}
+
+	return n, nil
+}
+
+// bootstrap loads the state machine from the snapshot file, and moves the node to its term (0) and commit index.
+// A persistent state machine restored by NewNode has its own term, which is dropped with it
+func (n *node) bootstrap(snapshotFile string) error {
+	if err := n.logMgr.Bootstrap(snapshotFile); err != nil {
+		return err
+	}
+
+	n.currentTerm = util.Max(0, n.logMgr.LastTerm())
+	n.votedFor = -1
+	n.leaderCommitIndex = n.logMgr.CommitIndex()
+	return nil
+}
+
+// JoinCluster creates an empty node which doesn't start elections until it hears from a leader, e.g. to join a cluster
+// recovered by RecoverCluster. Otherwise empty nodes might elect one of them and never accept the recovered snapshot
+func JoinCluster(nodeID int, peers map[int]NodeInfo, sm IStateMachine, proxyFactory IPeerProxyFactory, logger Logger) (INode, error) {
+	n, err := NewNode(nodeID, peers, sm, proxyFactory, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	n.(*node).joining = true
+	return n, nil
+}
+
+// ForceNewCluster creates the only node of a new cluster from what's left of a node, e.g. when the other nodes of its cluster
+// are lost for good. This is unsafe: entries committed by the lost nodes but not applied here are gone. The node resumes from
+// the state machine if it persists its own state, otherwise from its latest snapshot file in the snapshot path
+func ForceNewCluster(nodeID int, sm IStateMachine, proxyFactory IPeerProxyFactory, logger Logger) (INode, error) {
+	log := newNodeLogger(nodeID, logger)
+	log.warn(LogSubsystemNode, "Forcing a new single node cluster. Entries not applied to this node are lost")
+
+	n, err := NewNode(nodeID, map[int]NodeInfo{}, sm, proxyFactory, logger)
+	if err != nil {
+		return nil, err
 	}
 
+	logMgr := n.(*node).logMgr
+	if logMgr.LastIndex() < 0 {
+		file, err := latestSnapshotFile(nodeID)
+		if err != nil {
+			return nil, err
+		}
+		if file == "" {
+			log.warn(LogSubsystemNode, "Found no state to resume from, starting empty")
+		} else if err = n.(*node).bootstrap(file); err != nil {
+			return nil, err
+		}
+	}
+
+	log.warn(LogSubsystemNode, "Forced a new single node cluster", "index", logMgr.LastIndex())
+	return n, nil
+}
+
+// validateCluster validates params for the raft cluster.
+// Clusters of any size are allowed, though 1 or 2 node clusters can't tolerate any node failure and are meant for development
+func validateCluster(nodeID int, peers map[int]NodeInfo) error {
 	for i, p := range peers {
 		if p.NodeID == nodeID {
 			return errorCurrentNodeInPeers
@@ -130,44 +253,130 @@ func (n *node) NodeID() int {
 	return n.nodeID
 }
 
+// LeaderID returns the known leader's ID, or -1 if there is none
+func (n *node) LeaderID() int {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	return n.knownLeader()
+}
+
+// Status returns the node's status. While a snapshot is being installed under the node lock, only NodeID and
+// InstallingSnapshot are set, so that health checks don't wait for it
+func (n *node) Status() NodeStatus {
+	status := NodeStatus{NodeID: n.nodeID, InstallingSnapshot: n.isInstallingSnapshot()}
+	if atomic.LoadInt32(&n.installingSnapshot) != 0 {
+		return status
+	}
+
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+
+	status.State = n.nodeState
+	status.Term = n.currentTerm
+	status.LeaderID = n.knownLeader()
+	status.LastIndex = n.logMgr.LastIndex()
+	status.CommitIndex = n.logMgr.CommitIndex()
+	status.AppliedIndex = n.logMgr.LastApplied()
+	status.LeaderCommitIndex = n.leaderCommitIndex
+	if n.nodeState == NodeStateLeader {
+		status.LeaderCommitIndex = status.CommitIndex
+	}
+	return status
+}
+
+// isInstallingSnapshot tells whether the node is installing a snapshot, or received a snapshot part recently.
+// A snapshot stream which stops for longer than the snapshot RPC timeout is considered aborted
+func (n *node) isInstallingSnapshot() bool {
+	if atomic.LoadInt32(&n.installingSnapshot) != 0 {
+		return true
+	}
+	last := atomic.LoadInt64(&n.lastSnapshotPart)
+	return last != 0 && time.Since(time.Unix(0, last)) < rpcSnapshotTimeout
+}
+
 // Start starts the node
 func (n *node) Start() {
 	n.mu.Lock()
 	defer n.mu.Unlock()
 
-	util.WriteInfo("Node%d starting...", n.nodeID)
+	n.log.info(LogSubsystemNode, "Starting", "clusterSize", n.clusterSize)
 	n.timer.start()
 	n.peerMgr.start()
 
 	// Enter follower state
-	n.enterFollowerState(n.nodeID, 0)
+	n.enterFollowerState(n.nodeID, n.currentTerm)
+
+	if n.clusterSize == 1 {
+		// no one else to vote, win the election right away
+		n.enterCandidateState()
+		n.enterLeaderState()
+	}
 }
 
 // Stop stops a node
 func (n *node) Stop() {
 	n.mu.Lock()
-	defer n.mu.Unlock()
+	n.stopped = true
+	n.mu.Unlock()
 
+	// timer callbacks and replication acquire the lock, so stop them without holding it.
+	// In flight applies and snapshots are done with the lock held, wait for them after
 	n.timer.stop()
 	n.peerMgr.stop()
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.log.info(LogSubsystemNode, "Stopped", "term", n.currentTerm, "commitIndex", n.logMgr.CommitIndex())
+}
+
+// Get gets values from state machine
+// If stale reads are allowed, it reads from the local state machine
+// If current node is the leader, it'll confirm its leadership before reading so that the read is linearizable
+// If current node is not the leader, it'll proxy the request to leader node
+func (n *node) Get(ctx context.Context, req *GetRequest) (*GetReply, error) {
+	if req.AllowStale {
+		return n.localGet(req)
+	}
+
+	n.mu.RLock()
+	state := n.nodeState
+	leader := n.knownLeader()
+	stopped := n.stopped
+	n.mu.RUnlock()
+
+	switch {
+	case stopped:
+		return nil, ErrorNodeStopped
+	case leader == -1:
+		// no leader available now, error out
+		return nil, ErrorNoLeaderAvailable
+	case state != NodeStateLeader:
+		// We are not the leader, proxy to leader
+		return n.peerMgr.getPeer(leader).Get(ctx, req)
+	default:
+		// we are the leader
+		return n.leaderGet(ctx, req)
+	}
 }
 
-// Get gets values from state machine, no need to proxy
-func (n *node) Get(ctx context.Context, req *GetRequest) (result *GetReply, err error) {
+// localGet reads from the local state machine without any leadership check
+func (n *node) localGet(req *GetRequest) (*GetReply, error) {
 	n.mu.RLock()
 	defer n.mu.RUnlock()
 
-	var ret interface{}
-	if ret, err = n.logMgr.Get(req.Params...); err != nil {
-		return
+	if n.stopped {
+		return nil, ErrorNodeStopped
 	}
 
-	result = &GetReply{
-		NodeID: n.nodeID,
-		Data:   ret,
+	ret, err := n.logMgr.Get(req.Params...)
+	if err != nil {
+		return nil, err
 	}
 
-	return
+	return &GetReply{
+		NodeID: n.nodeID,
+		Data:   ret,
+	}, nil
 }
 
 // Execute runs a command via the raft node
@@ -176,16 +385,24 @@ func (n *node) Get(ctx context.Context, req *GetRequest) (result *GetReply, err
 func (n *node) Execute(ctx context.Context, cmd *StateMachineCmd) (*ExecuteReply, error) {
 	n.mu.RLock()
 	state := n.nodeState
-	leader := n.currentLeader
+	leader := n.knownLeader()
+	stopped := n.stopped
 	n.mu.RUnlock()
 
 	switch {
+	case stopped:
+		return nil, ErrorNodeStopped
 	case leader == -1:
 		// no leader available now, error out
-		return nil, errorNoLeaderAvailable
+		return nil, ErrorNoLeaderAvailable
 	case state != NodeStateLeader:
 		// We are not the leader, proxy to leader
-		return n.peerMgr.getPeer(leader).Execute(ctx, cmd)
+		ctx, span := trace.StartChild(ctx, "raft.proxy")
+		span.SetAttribute("leader", leader)
+		reply, err := n.peerMgr.getPeer(leader).Execute(ctx, cmd)
+		span.SetError(err)
+		span.End()
+		return reply, err
 	default:
 		// we are the leader
 		return n.leaderExecute(ctx, cmd)
@@ -197,10 +414,14 @@ func (n *node) AppendEntries(ctx context.Context, req *AppendEntriesRequest) (*A
 	n.mu.Lock()
 	defer n.mu.Unlock()
 
+	if n.stopped {
+		return nil, ErrorNodeStopped
+	}
+
 	n.tryFollowNewTerm(req.LeaderID, req.Term, true)
 
 	// After above call, n.currentLeader has been updated accordingly if req.Term is the same or higher
-	util.WriteTrace("T%d: Received AE from Leader%d, prevIndex: %d, prevTerm: %d, entryCnt: %d", n.currentTerm, req.LeaderID, req.PrevLogIndex, req.PrevLogTerm, len(req.Entries))
+	n.log.trace(LogSubsystemReplication, "Received AE", "term", n.currentTerm, "leader", req.LeaderID, "prevIndex", req.PrevLogIndex, "prevTerm", req.PrevLogTerm, "entries", len(req.Entries))
 	lastMatchIndex, prevMatch := req.PrevLogIndex, false
 	if req.Term >= n.currentTerm {
 		prevMatch = n.logMgr.ProcessLogs(req.PrevLogIndex, req.PrevLogTerm, req.Entries)
@@ -210,6 +431,7 @@ func (n *node) AppendEntries(ctx context.Context, req *AppendEntriesRequest) (*A
 			lastMatchIndex = n.logMgr.LastIndex()
 			n.commitTo(util.Min(req.LeaderCommit, n.logMgr.LastIndex()))
 		}
+		n.leaderCommitIndex = req.LeaderCommit
 	}
 
 	return &AppendEntriesReply{
@@ -223,25 +445,42 @@ func (n *node) AppendEntries(ctx context.Context, req *AppendEntriesRequest) (*A
 
 // InstallSnapshot installs a snapshot
 func (n *node) InstallSnapshot(ctx context.Context, req *SnapshotRequest) (*AppendEntriesReply, error) {
+	atomic.StoreInt32(&n.installingSnapshot, 1)
+	defer func() {
+		atomic.StoreInt64(&n.lastSnapshotPart, 0)
+		atomic.StoreInt32(&n.installingSnapshot, 0)
+	}()
+
 	n.mu.Lock()
 	defer n.mu.Unlock()
 
+	if n.stopped {
+		return nil, ErrorNodeStopped
+	}
+
 	n.tryFollowNewTerm(req.LeaderID, req.Term, true)
 
 	// After above call, n.currentLeader has been updated accordingly if req.Term is the same or higher
 	success := false
 	lastMatchIndex := req.SnapshotIndex
 	if req.Term >= n.currentTerm {
-		if n.logMgr.SnapshotIndex() == req.SnapshotIndex && n.logMgr.SnapshotTerm() == req.SnapshotTerm {
-			util.WriteInfo("T%d: Node%d ignoring duplicate T%dL%d snapshot from Node%d\n", n.currentTerm, n.nodeID, req.SnapshotTerm, req.SnapshotIndex, req.LeaderID)
+		if req.SnapshotIndex <= n.logMgr.CommitIndex() {
+			// We already have everything in the snapshot committed. Installing it would roll back our state machine.
+			// Reply with our commit index, which is guaranteed to match leader's logs
+			n.log.info(LogSubsystemSnapshot, "Ignoring snapshot, already committed past it", "term", n.currentTerm, "leader", req.LeaderID, "snapshotTerm", req.SnapshotTerm, "snapshotIndex", req.SnapshotIndex, "commitIndex", n.logMgr.CommitIndex())
+			if req.File != n.logMgr.SnapshotFile() {
+				deleteSnapshot(req.File)
+			}
 			success = true
+			lastMatchIndex = n.logMgr.CommitIndex()
 		} else {
 			// only process logs when term is valid
-			util.WriteInfo("T%d: Node%d installing T%dL%d snapshot from Node%d\n", n.currentTerm, n.nodeID, req.SnapshotTerm, req.SnapshotIndex, req.LeaderID)
+			n.log.info(LogSubsystemSnapshot, "Installing snapshot", "term", n.currentTerm, "leader", req.LeaderID, "snapshotTerm", req.SnapshotTerm, "snapshotIndex", req.SnapshotIndex)
 			if err := n.logMgr.InstallSnapshot(req.File, req.SnapshotIndex, req.SnapshotTerm); err != nil {
-				util.WriteError("T%d: Install snapshot failed. %s\n", n.currentTerm, err)
+				n.log.error(LogSubsystemSnapshot, "Failed to install snapshot", "term", n.currentTerm, "err", err)
 			} else {
 				success = true
+				n.leaderCommitIndex = util.Max(n.leaderCommitIndex, req.SnapshotIndex)
 			}
 		}
 	}
@@ -258,9 +497,15 @@ func (n *node) InstallSnapshot(ctx context.Context, req *SnapshotRequest) (*Appe
 
 // OnSnapshotPart is invoked when a snapshot part is received. Returns false if we don't want to continue (e.g. lower term)
 func (n *node) OnSnapshotPart(part *SnapshotRequestHeader) bool {
+	atomic.StoreInt64(&n.lastSnapshotPart, time.Now().UnixNano())
+
 	n.mu.Lock()
 	defer n.mu.Unlock()
 
+	if n.stopped {
+		return false
+	}
+
 	// Teated in the same way as AE request
 	return n.tryFollowNewTerm(part.LeaderID, part.Term, true)
 }
@@ -270,6 +515,10 @@ func (n *node) RequestVote(ctx context.Context, req *RequestVoteRequest) (*Reque
 	n.mu.Lock()
 	defer n.mu.Unlock()
 
+	if n.stopped {
+		return nil, ErrorNodeStopped
+	}
+
 	// Here is what the paper says (5.2 and 5.4):
 	// 1. if req.Term > currentTerm, convert to follower state (reset votedFor)
 	// 2. if req.Term < currentTerm deny vote
@@ -281,7 +530,7 @@ func (n *node) RequestVote(ctx context.Context, req *RequestVoteRequest) (*Reque
 		if req.LastLogIndex >= n.logMgr.LastIndex() && req.LastLogTerm >= n.logMgr.LastTerm() {
 			n.votedFor = req.CandidateID
 			voteGranted = true
-			util.WriteInfo("T%d: \U0001f4e7 Node%d voted for Node%d\n", req.Term, n.nodeID, req.CandidateID)
+			n.log.info(LogSubsystemElection, "Voted", "term", req.Term, "candidate", req.CandidateID)
 		}
 	}
 
@@ -293,6 +542,29 @@ func (n *node) RequestVote(ctx context.Context, req *RequestVoteRequest) (*Reque
 	}, nil
 }
 
+// TimeoutNow handles raft RPC TimeoutNow calls. The follower starts an election right away if the request is
+// from the leader of its current term, without waiting for its election timeout (raft thesis section 3.10)
+func (n *node) TimeoutNow(ctx context.Context, req *TimeoutNowRequest) (*TimeoutNowReply, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.stopped {
+		return nil, ErrorNodeStopped
+	}
+
+	n.tryFollowNewTerm(req.LeaderID, req.Term, true)
+	if req.Term == n.currentTerm && n.nodeState == NodeStateFollower {
+		n.log.info(LogSubsystemElection, "Leadership transferred to us", "term", n.currentTerm, "leader", req.LeaderID)
+		// startElection acquires the lock
+		go n.startElection()
+	}
+
+	return &TimeoutNowReply{
+		Term:   n.currentTerm,
+		NodeID: n.nodeID,
+	}, nil
+}
+
 // onTimer handles a timer event. Action is based on node's current state.
 func (n *node) onTimer(state NodeState, term int) {
 	n.mu.RLock()
@@ -300,7 +572,7 @@ func (n *node) onTimer(state NodeState, term int) {
 	if state == n.nodeState && term == n.currentTerm {
 		if n.nodeState == NodeStateLeader {
 			fn = n.sendHeartbeat
-		} else {
+		} else if !n.joining {
 			fn = n.startElection
 		}
 	}
@@ -317,6 +589,7 @@ func (n *node) onTimer(state NodeState, term int) {
 func (n *node) enterFollowerState(sourceNodeID, newTerm int) {
 	oldLeader := n.currentLeader
 	n.nodeState = NodeStateFollower
+	metrics.StateChanged(NodeStateFollower)
 	n.currentLeader = sourceNodeID
 	n.setTerm(newTerm)
 
@@ -324,7 +597,7 @@ func (n *node) enterFollowerState(sourceNodeID, newTerm int) {
 	n.refreshTimer()
 
 	if n.nodeID != sourceNodeID && oldLeader != n.currentLeader {
-		util.WriteInfo("T%d: Node%d follows Node%d on new Term\n", n.currentTerm, n.nodeID, sourceNodeID)
+		n.log.info(LogSubsystemElection, "Following on new term", "term", n.currentTerm, "source", sourceNodeID)
 	}
 }
 
@@ -333,6 +606,8 @@ func (n *node) enterCandidateState() {
 	n.nodeState = NodeStateCandidate
 	n.currentLeader = -1
 	n.setTerm(n.currentTerm + 1)
+	metrics.StateChanged(NodeStateCandidate)
+	metrics.ElectionStarted()
 
 	// vote for self first
 	n.votedFor = n.nodeID
@@ -342,7 +617,7 @@ func (n *node) enterCandidateState() {
 	// reset timer
 	n.refreshTimer()
 
-	util.WriteInfo("T%d: \u270b Node%d starts election\n", n.currentTerm, n.nodeID)
+	n.log.info(LogSubsystemElection, "Starting election", "term", n.currentTerm)
 }
 
 // start an election
@@ -377,9 +652,11 @@ func (n *node) prepareCampaign() func() <-chan *RequestVoteReply {
 
 		n.peerMgr.waitAll(func(peer *Peer, wg *sync.WaitGroup) {
 			go func() {
+				start := time.Now()
 				reply, err := peer.RequestVote(ctx, req)
+				metrics.ObserveRPC(RPCRequestVote, peer.NodeID, time.Since(start), err)
 				if err == nil {
-					util.WriteInfo("T%d: Vote reply from Node%d, granted:%v\n", currentTerm, reply.NodeID, reply.VoteGranted)
+					n.log.info(LogSubsystemElection, "Vote reply received", "term", currentTerm, "peer", reply.NodeID, "granted", reply.VoteGranted)
 					rvReplies <- reply
 				}
 				wg.Done()
@@ -402,7 +679,7 @@ func (n *node) countVotes(replies <-chan *RequestVoteReply) {
 
 		if n.nodeState != NodeStateCandidate || reply.VotedTerm != n.currentTerm || !reply.VoteGranted {
 			// stale vote or denied, ignore
-			util.WriteTrace("T%d: Stale or ungranted vote received from Node%d, term:%d, voteGranted:%t\n", n.currentTerm, reply.NodeID, reply.VotedTerm, reply.VoteGranted)
+			n.log.trace(LogSubsystemElection, "Stale or ungranted vote", "term", n.currentTerm, "peer", reply.NodeID, "votedTerm", reply.VotedTerm, "granted", reply.VoteGranted)
 			continue
 		}
 
@@ -423,7 +700,7 @@ func (n *node) tryFollowNewTerm(sourceNodeID, newTerm int, isAppendEntries bool)
 	if newTerm > n.currentTerm {
 		// Follow newer term right away. sourceNodeID might not be the new leader, but it potentially
 		// has better knowledge of the leader than us
-		util.WriteInfo("T%d: Received new term from Node%d, newTerm:%d.\n", n.currentTerm, sourceNodeID, newTerm)
+		n.log.info(LogSubsystemElection, "Received new term", "term", n.currentTerm, "peer", sourceNodeID, "newTerm", newTerm)
 		follow = true
 	} else if newTerm == n.currentTerm && isAppendEntries {
 		// For AE calls, we should (re)follow when term is the same
@@ -432,6 +709,10 @@ func (n *node) tryFollowNewTerm(sourceNodeID, newTerm int, isAppendEntries bool)
 
 	if follow {
 		n.enterFollowerState(sourceNodeID, newTerm)
+		if isAppendEntries && n.joining {
+			n.log.info(LogSubsystemNode, "Joined the cluster", "term", n.currentTerm, "leader", sourceNodeID)
+			n.joining = false
+		}
 	}
 
 	return follow
@@ -441,13 +722,22 @@ func (n *node) tryFollowNewTerm(sourceNodeID, newTerm int, isAppendEntries bool)
 // Called by both leader (upon AE reply) or follower (upon AE request)
 func (n *node) commitTo(targetCommitIndex int) {
 	if newCommit, newSnapshot := n.logMgr.CommitAndApply(targetCommitIndex); newCommit {
-		util.WriteTrace("T%d: Node%d committed to L%d\n", n.currentTerm, n.nodeID, n.logMgr.CommitIndex())
+		n.log.trace(LogSubsystemReplication, "Committed", "term", n.currentTerm, "commitIndex", n.logMgr.CommitIndex())
 		if newSnapshot {
-			util.WriteInfo("T%d: Node%d created new snapshot T%dL%d\n", n.currentTerm, n.nodeID, n.logMgr.SnapshotTerm(), n.logMgr.SnapshotIndex())
+			n.log.info(LogSubsystemSnapshot, "Took snapshot", "term", n.currentTerm, "snapshotTerm", n.logMgr.SnapshotTerm(), "snapshotIndex", n.logMgr.SnapshotIndex())
 		}
 	}
 }
 
+// knownLeader returns the leader we can forward requests to, or -1 if there is none.
+// currentLeader can be ourselves even when we are not the leader, e.g. right after starting
+func (n *node) knownLeader() int {
+	if n.currentLeader == n.nodeID && n.nodeState != NodeStateLeader {
+		return -1
+	}
+	return n.currentLeader
+}
+
 // count votes for current node and term and return true if we won
 func (n *node) wonElection() bool {
 	total := 0
@@ -456,7 +746,12 @@ func (n *node) wonElection() bool {
 			total++
 		}
 	}
-	return total > n.clusterSize/2
+	return total >= majority(n.clusterSize)
+}
+
+// majority returns the number of nodes making a majority of the cluster, e.g. 2 of 2 nodes, 2 of 3 nodes and 3 of 4 nodes
+func majority(clusterSize int) int {
+	return clusterSize/2 + 1
 }
 
 // setTerm sets a new term
@@ -468,6 +763,7 @@ func (n *node) setTerm(newTerm int) {
 	if newTerm > n.currentTerm {
 		// reset vote on higher term
 		n.votedFor = -1
+		metrics.TermChanged(newTerm)
 	}
 
 	n.currentTerm = newTerm
@@ -1,8 +1,13 @@
 package raft
 
 import (
+	"context"
 	"os"
+	"sync"
 	"testing"
+	"time"
+
+	"github.com/sidecus/raft/pkg/trace"
 )
 
 func TestNewNode(t *testing.T) {
@@ -14,7 +19,7 @@ func TestNewNode(t *testing.T) {
 	peerCount := 2
 	nodeID := peerCount // last node
 	peers := createTestPeerInfo(peerCount)
-	ret, err := NewNode(nodeID, peers, &testStateMachine{}, &MockPeerFactory{})
+	ret, err := NewNode(nodeID, peers, &testStateMachine{}, &MockPeerFactory{}, nil)
 	if err != nil {
 		t.Error(err)
 	}
@@ -283,7 +288,7 @@ func TestLeaderCommit(t *testing.T) {
 		lastApplied: -111,
 	}
 	peerMgr := createTestPeerManager(2)
-	logMgr := newLogMgr(100, sm).(*logManager)
+	logMgr := newLogMgr(100, sm, nil).(*logManager)
 
 	peerMgr.getPeer(0).nextIndex = 2
 	peerMgr.getPeer(0).matchIndex = 1
@@ -326,7 +331,7 @@ func TestLeaderCommit(t *testing.T) {
 }
 
 func TestReplicateData(t *testing.T) {
-	logMgr := newLogMgr(100, &testStateMachine{lastApplied: -111}).(*logManager)
+	logMgr := newLogMgr(100, &testStateMachine{lastApplied: -111}, nil).(*logManager)
 	for i := 0; i < 5; i++ {
 		logMgr.ProcessCmd(StateMachineCmd{
 			CmdType: 1,
@@ -412,6 +417,39 @@ func TestReplicateData(t *testing.T) {
 		t.Error("wrong info in SnapshotRequest")
 	}
 }
+
+func TestReplicateRestoredSnapshot(t *testing.T) {
+	SetSnapshotPath(t.TempDir())
+	peers := map[int]NodeInfo{1: {NodeID: 1}}
+	peerMgr := newPeerManager(peers, nil, &MockPeerFactory{})
+	peer1 := peerMgr.getPeer(1)
+	proxy1 := peer1.IPeerProxy.(*MockPeerProxy)
+
+	n := &node{
+		nodeID:      2,
+		nodeState:   NodeStateLeader,
+		clusterSize: 3,
+		currentTerm: 5,
+		logMgr:      newLogMgr(2, &testPersistentStateMachine{Index: 5, Term: 4}, nil),
+		peerMgr:     peerMgr,
+		log:         newNodeLogger(2, nil),
+	}
+
+	// a restored persistent state machine has no snapshot file until a follower needs one
+	peer1.nextIndex = 6
+	n.replicateData(peer1)
+	if proxy1.isReq != nil || n.logMgr.SnapshotFile() != "" {
+		t.Error("replicateData should not take a snapshot file when the follower doesn't need one")
+	}
+
+	peer1.nextIndex = 3
+	n.replicateData(peer1)
+	if proxy1.isReq == nil || proxy1.isReq.File == "" || proxy1.isReq.File != n.logMgr.SnapshotFile() ||
+		proxy1.isReq.SnapshotIndex != 5 || proxy1.isReq.SnapshotTerm != 4 {
+		t.Error("replicateData should take a snapshot file of the restored state machine and send it")
+	}
+}
+
 func TestWonElection(t *testing.T) {
 	n := &node{}
 	n.clusterSize = 3
@@ -426,4 +464,492 @@ func TestWonElection(t *testing.T) {
 	if !n.wonElection() {
 		t.Error("wonElection should return true on 2 votes out of 3")
 	}
+
+	n.clusterSize = 2
+	n.votes = map[int]bool{0: true}
+	if n.wonElection() {
+		t.Error("wonElection should return false on 1 vote out of 2")
+	}
+	n.votes[1] = true
+	if !n.wonElection() {
+		t.Error("wonElection should return true on 2 votes out of 2")
+	}
+
+	n.clusterSize = 1
+	n.votes = map[int]bool{0: true}
+	if !n.wonElection() {
+		t.Error("wonElection should return true on 1 vote out of 1")
+	}
+}
+
+func TestMajority(t *testing.T) {
+	for size, expected := range map[int]int{1: 1, 2: 2, 3: 2, 4: 3, 5: 3} {
+		if majority(size) != expected {
+			t.Errorf("majority of %d nodes should be %d", size, expected)
+		}
+	}
+}
+
+func TestSingleNodeCluster(t *testing.T) {
+	ret, err := NewNode(0, map[int]NodeInfo{}, &testStateMachine{}, &MockPeerFactory{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := ret.(*node)
+	n.timer = &fakeRaftTimer{}
+
+	n.Start()
+	defer n.Stop()
+	if n.nodeState != NodeStateLeader || n.currentTerm != 1 || n.logMgr.CommitIndex() != 0 {
+		t.Fatal("Single node should become the leader and commit its no-op entry on start")
+	}
+
+	reply, err := n.Execute(context.Background(), &StateMachineCmd{CmdType: 1, Data: 2})
+	if err != nil || !reply.Success || reply.Result.(int) != 20 || n.logMgr.CommitIndex() != 1 {
+		t.Error("Single node should commit cmds right away")
+	}
+	if reply, err := n.Get(context.Background(), &GetRequest{Params: []interface{}{5}}); err != nil || reply.Data.(int) != 5 {
+		t.Error("Single node should serve linearizable reads", err)
+	}
+}
+
+func TestStatus(t *testing.T) {
+	ret, _ := NewNode(2, createTestPeerInfo(2), &testStateMachine{}, &MockPeerFactory{}, nil)
+	n := ret.(*node)
+	n.timer = &fakeRaftTimer{}
+
+	status := n.Status()
+	if status.NodeID != 2 || status.LeaderID != -1 || status.State != NodeStateFollower || status.AppliedIndex != -1 || status.LeaderCommitIndex != -1 {
+		t.Error("New node should have no leader and nothing applied", status)
+	}
+
+	entries := []LogEntry{{Index: 0, Term: 1, Cmd: StateMachineCmd{CmdType: 1, Data: 1}}, {Index: 1, Term: 1, Cmd: StateMachineCmd{CmdType: 1, Data: 2}}}
+	n.AppendEntries(context.Background(), &AppendEntriesRequest{Term: 1, LeaderID: 0, PrevLogIndex: -1, PrevLogTerm: -1, LeaderCommit: 5, Entries: entries})
+	status = n.Status()
+	if status.LeaderID != 0 || status.Term != 1 || status.LastIndex != 1 || status.CommitIndex != 1 || status.AppliedIndex != 1 || status.LeaderCommitIndex != 5 {
+		t.Error("Status should have the leader and its commit index from AE", status)
+	}
+
+	n.OnSnapshotPart(&SnapshotRequestHeader{Term: 1, LeaderID: 0, SnapshotIndex: 10, SnapshotTerm: 1})
+	if !n.Status().InstallingSnapshot {
+		t.Error("Node should be installing a snapshot after receiving a part")
+	}
+	n.lastSnapshotPart = time.Now().Add(-rpcSnapshotTimeout).UnixNano()
+	if n.Status().InstallingSnapshot {
+		t.Error("Snapshot stream should be considered aborted when no part is received in time")
+	}
+
+	// Status doesn't wait for the node lock while installing a snapshot
+	n.mu.Lock()
+	n.installingSnapshot = 1
+	if status = n.Status(); !status.InstallingSnapshot || status.NodeID != 2 {
+		t.Error("Status should report installing snapshot without the node lock")
+	}
+	n.installingSnapshot = 0
+	n.mu.Unlock()
+}
+
+type testSpanExporter struct {
+	mu    sync.Mutex
+	spans []trace.SpanData
+}
+
+func (e *testSpanExporter) Export(span trace.SpanData) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.spans = append(e.spans, span)
+}
+
+func TestExecuteTracing(t *testing.T) {
+	e := &testSpanExporter{}
+	trace.SetExporter(e)
+	defer trace.SetExporter(nil)
+
+	ret, _ := NewNode(0, map[int]NodeInfo{}, &testStateMachine{}, &MockPeerFactory{}, nil)
+	n := ret.(*node)
+	n.timer = &fakeRaftTimer{}
+	n.Start()
+	defer n.Stop()
+
+	// untraced requests, e.g. from the expirer, don't start traces
+	n.Execute(context.Background(), &StateMachineCmd{CmdType: 1, Data: 1})
+	if len(e.spans) != 0 {
+		t.Fatal("Execute should not start new traces")
+	}
+
+	ctx, root := trace.Start(context.Background(), "Set")
+	n.Execute(ctx, &StateMachineCmd{CmdType: 1, Data: 2})
+	root.End()
+
+	spans := make(map[string]trace.SpanData)
+	for _, s := range e.spans {
+		spans[s.Name] = s
+	}
+	propose, commit, apply := spans["raft.propose"], spans["raft.commit"], spans["raft.apply"]
+	if propose.ParentID != root.Context().SpanID.String() || propose.Attributes["index"] != 2 {
+		t.Error("Proposal should be traced as a child of the request", propose)
+	}
+	if commit.ParentID != propose.SpanID || commit.Attributes["committed"] != true {
+		t.Error("Commit should be traced as a child of the proposal", commit)
+	}
+	if apply.ParentID != propose.SpanID || apply.Attributes["index"] != 2 {
+		t.Error("Apply should be traced as a child of the proposal", apply)
+	}
+}
+
+func TestStartReplicationSpans(t *testing.T) {
+	if spans := startReplicationSpans(1, []LogEntry{{Index: 1}}); len(spans) != 0 {
+		t.Error("Untraced entries should not be traced when tracing is off")
+	}
+
+	trace.SetExporter(&testSpanExporter{})
+	defer trace.SetExporter(nil)
+
+	_, span := trace.Start(context.Background(), "propose")
+	entries := []LogEntry{{Index: 1}, {Index: 2, span: span.Context()}, {Index: 3}}
+	spans := startReplicationSpans(1, entries)
+	if len(spans) != 1 || spans[0].Context().TraceID != span.Context().TraceID {
+		t.Error("Only traced entries should be traced when replicated")
+	}
+}
+
+func TestEnterLeaderStateAppendsNoop(t *testing.T) {
+	logMgr := newLogMgr(100, &testStateMachine{}, nil).(*logManager)
+	n := &node{
+		nodeID:      100,
+		nodeState:   NodeStateCandidate,
+		currentTerm: 3,
+		peerMgr:     createTestPeerManager(2),
+		timer:       &fakeRaftTimer{},
+		logMgr:      logMgr,
+	}
+
+	n.enterLeaderState()
+
+	if logMgr.lastIndex != 0 || logMgr.lastTerm != 3 || logMgr.logs[0].Cmd.CmdType != noopCmdType {
+		t.Error("enterLeaderState should append a no-op entry in current term")
+	}
+	if n.peerMgr.getPeer(0).nextIndex != 0 {
+		t.Error("enterLeaderState should reset nextIndex before appending the no-op entry")
+	}
+
+	// no-op entry should not be applied to state machine
+	logMgr.CommitAndApply(0)
+	if logMgr.lastApplied != 0 || logMgr.IStateMachine.(*testStateMachine).lastApplied != 0 {
+		t.Error("no-op entry should be committed without being applied to state machine")
+	}
+}
+
+func TestCommittedWithTerm(t *testing.T) {
+	logMgr := newLogMgr(100, &testStateMachine{}, nil).(*logManager)
+	for i := 0; i < 5; i++ {
+		logMgr.ProcessCmd(StateMachineCmd{CmdType: 1, Data: i}, i+1)
+	}
+	logMgr.CommitAndApply(3)
+	n := &node{logMgr: logMgr}
+
+	if n.committedWithTerm(-1, -1) {
+		t.Error("committedWithTerm should return false on -1")
+	}
+	if !n.committedWithTerm(2, 3) {
+		t.Error("committedWithTerm should return true on committed entry with the same term")
+	}
+	if n.committedWithTerm(2, 2) {
+		t.Error("committedWithTerm should return false on committed entry with a different term")
+	}
+	if n.committedWithTerm(4, 5) {
+		t.Error("committedWithTerm should return false on uncommitted entry")
+	}
+
+	logMgr.snapshotIndex = 2
+	logMgr.snapshotTerm = 3
+	if !n.committedWithTerm(2, 3) {
+		t.Error("committedWithTerm should check snapshot term on snapshot index")
+	}
+	if n.committedWithTerm(1, 2) {
+		t.Error("committedWithTerm should return false on compacted entries")
+	}
+}
+
+func TestKnownLeader(t *testing.T) {
+	n := &node{
+		nodeID:        1,
+		nodeState:     NodeStateFollower,
+		currentLeader: 1,
+	}
+
+	if n.knownLeader() != -1 {
+		t.Error("knownLeader should not return self when we are not the leader")
+	}
+
+	n.currentLeader = 2
+	if n.knownLeader() != 2 {
+		t.Error("knownLeader should return current leader")
+	}
+
+	n.nodeState = NodeStateLeader
+	n.currentLeader = 1
+	if n.knownLeader() != 1 {
+		t.Error("knownLeader should return self when we are the leader")
+	}
+}
+
+func TestLeaderExecuteOnNonLeader(t *testing.T) {
+	logMgr := newLogMgr(100, &testStateMachine{}, nil).(*logManager)
+	n := &node{
+		nodeState: NodeStateFollower,
+		logMgr:    logMgr,
+	}
+
+	if _, err := n.leaderExecute(context.Background(), &StateMachineCmd{CmdType: 1, Data: 1}); err != ErrorNoLongerLeader {
+		t.Error("leaderExecute should error out when node is no longer leader")
+	}
+	if logMgr.lastIndex != -1 {
+		t.Error("leaderExecute should not append logs when node is no longer leader")
+	}
+}
+
+func TestInstallCommittedSnapshot(t *testing.T) {
+	logMgr := newLogMgr(100, &testStateMachine{}, nil).(*logManager)
+	for i := 0; i < 5; i++ {
+		logMgr.ProcessCmd(StateMachineCmd{CmdType: 1, Data: i}, 1)
+	}
+	logMgr.CommitAndApply(3)
+
+	n := &node{
+		nodeID:      100,
+		currentTerm: 1,
+		logMgr:      logMgr,
+		timer:       &fakeRaftTimer{},
+	}
+
+	// snapshot older than our commit index should not be installed
+	req := &SnapshotRequest{
+		SnapshotRequestHeader: SnapshotRequestHeader{Term: 1, LeaderID: 1, SnapshotIndex: 2, SnapshotTerm: 1},
+		File:                  "nonexistent.rkvsnapshot",
+	}
+	reply, _ := n.InstallSnapshot(context.Background(), req)
+	if !reply.Success || reply.LastMatch != 3 {
+		t.Error("InstallSnapshot should reply success with commit index on committed snapshot")
+	}
+	if logMgr.snapshotIndex != -1 || logMgr.lastIndex != 4 {
+		t.Error("InstallSnapshot should not install snapshot already committed")
+	}
+}
+
+func TestStaleGet(t *testing.T) {
+	n := &node{
+		nodeID:        1,
+		nodeState:     NodeStateFollower,
+		currentLeader: -1,
+		logMgr:        newLogMgr(1, &testStateMachine{}, nil),
+	}
+
+	reply, err := n.Get(context.Background(), &GetRequest{Params: []interface{}{5}, AllowStale: true})
+	if err != nil || reply.NodeID != 1 || reply.Data.(int) != 5 {
+		t.Error("Stale reads should be served locally without a leader")
+	}
+
+	if _, err = n.Get(context.Background(), &GetRequest{Params: []interface{}{5}}); err != ErrorNoLeaderAvailable {
+		t.Error("Non stale reads should require a leader")
+	}
+}
+
+func TestRecoverCluster(t *testing.T) {
+	setSnapshotPathToTempDir()
+	src := newLogMgr(5, &testStateMachine{}, nil).(*logManager)
+	src.ProcessLogs(-1, -1, generateTestEntries(-1, 7))
+	src.CommitAndApply(1)
+	src.TakeSnapshot()
+	defer deleteSnapshot(src.snapshotFile)
+
+	ret, err := RecoverCluster(2, createTestPeerInfo(2), &testStateMachine{}, &MockPeerFactory{}, nil, src.snapshotFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := ret.(*node)
+	defer deleteSnapshot(n.logMgr.SnapshotFile())
+
+	if n.currentTerm != 0 || n.logMgr.SnapshotIndex() != 1 || n.logMgr.SnapshotTerm() != 0 || n.logMgr.LastIndex() != 1 {
+		t.Error("RecoverCluster should start from the snapshot in a fresh term")
+	}
+
+	// a persistent state machine of the old cluster is replaced along with its term, without writing snapshot files
+	ret, err = RecoverCluster(2, createTestPeerInfo(2), &testPersistentStateMachine{Index: 10, Term: 5}, &MockPeerFactory{}, nil, src.snapshotFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n = ret.(*node)
+	if n.currentTerm != 0 || n.leaderCommitIndex != 1 || n.logMgr.LastIndex() != 1 || n.logMgr.LastTerm() != 0 {
+		t.Error("RecoverCluster should move a persistent state machine to the snapshot in a fresh term")
+	}
+	if n.logMgr.SnapshotFile() != "" {
+		t.Error("RecoverCluster should not take snapshot files of a persistent state machine")
+	}
+
+	if _, err = RecoverCluster(2, createTestPeerInfo(3), &testStateMachine{}, &MockPeerFactory{}, nil, src.snapshotFile); err == nil {
+		t.Error("RecoverCluster should validate the cluster")
+	}
+	if _, err = RecoverCluster(2, createTestPeerInfo(2), &testStateMachine{}, &MockPeerFactory{}, nil, ""); err == nil {
+		t.Error("RecoverCluster should fail without a snapshot file")
+	}
+}
+
+func TestJoinCluster(t *testing.T) {
+	ret, err := JoinCluster(2, createTestPeerInfo(2), &testStateMachine{}, &MockPeerFactory{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := ret.(*node)
+	n.timer = &fakeRaftTimer{}
+
+	n.onTimer(NodeStateFollower, 0)
+	if n.nodeState != NodeStateFollower || n.currentTerm != 0 {
+		t.Error("Joining node should not start elections")
+	}
+
+	n.RequestVote(context.Background(), &RequestVoteRequest{Term: 1, CandidateID: 1, LastLogIndex: 5, LastLogTerm: 1})
+	n.onTimer(NodeStateFollower, 1)
+	if !n.joining || n.nodeState != NodeStateFollower || n.currentTerm != 1 {
+		t.Error("Joining node should keep waiting for a leader after voting")
+	}
+
+	n.AppendEntries(context.Background(), &AppendEntriesRequest{Term: 1, LeaderID: 1, PrevLogIndex: -1, PrevLogTerm: -1})
+	if n.joining || n.currentLeader != 1 {
+		t.Error("Joining node should join the cluster after hearing from a leader")
+	}
+}
+
+func TestForceNewCluster(t *testing.T) {
+	SetSnapshotPath(t.TempDir())
+	old := newLogMgr(7, &testStateMachine{}, nil).(*logManager)
+	old.ProcessLogs(-1, -1, generateTestEntries(-1, 3))
+	old.CommitAndApply(1)
+	old.TakeSnapshot()
+
+	ret, err := ForceNewCluster(7, &testStateMachine{}, &MockPeerFactory{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := ret.(*node)
+	if n.clusterSize != 1 || n.logMgr.LastIndex() != 1 || n.logMgr.SnapshotFile() == old.snapshotFile {
+		t.Error("ForceNewCluster should resume from the latest snapshot of the node as a single node cluster")
+	}
+
+	// single node cluster elects itself and commits without followers
+	n.timer = &fakeRaftTimer{}
+	n.startElection()
+	if n.nodeState != NodeStateLeader || n.logMgr.CommitIndex() != 2 {
+		t.Fatal("Single node should win election and commit its no-op entry")
+	}
+	reply, err := n.Execute(context.Background(), &StateMachineCmd{CmdType: 1, Data: 4})
+	if err != nil || !reply.Success || reply.Result.(int) != 40 {
+		t.Error("Single node should commit cmds right away")
+	}
+	if _, err = n.Get(context.Background(), &GetRequest{Params: []interface{}{5}}); err != nil {
+		t.Error("Single node should serve reads as the leader", err)
+	}
+
+	// persistent state machines are preferred over snapshot files
+	ret, _ = ForceNewCluster(7, &testPersistentStateMachine{Index: 5, Term: 4}, &MockPeerFactory{}, nil)
+	if n = ret.(*node); n.logMgr.LastIndex() != 5 || n.currentTerm != 4 {
+		t.Error("ForceNewCluster should resume from the persistent state machine with its term")
+	}
+
+	SetSnapshotPath(t.TempDir())
+	if ret, err = ForceNewCluster(7, &testStateMachine{}, &MockPeerFactory{}, nil); err != nil || ret.(*node).logMgr.LastIndex() != -1 {
+		t.Error("ForceNewCluster should start empty without any state", err)
+	}
+}
+
+func TestTimeoutNow(t *testing.T) {
+	ret, _ := NewNode(2, createTestPeerInfo(2), &testStateMachine{}, &MockPeerFactory{}, nil)
+	n := ret.(*node)
+	n.timer = &fakeRaftTimer{}
+	n.AppendEntries(context.Background(), &AppendEntriesRequest{Term: 1, LeaderID: 0, PrevLogIndex: -1, PrevLogTerm: -1, LeaderCommit: -1})
+
+	// stale requests are ignored
+	reply, _ := n.TimeoutNow(context.Background(), &TimeoutNowRequest{Term: 0, LeaderID: 1})
+	if reply.Term != 1 || reply.NodeID != 2 || n.Status().State != NodeStateFollower {
+		t.Error("TimeoutNow with a lower term should not start an election")
+	}
+
+	// election starts right away, and peers grant their votes
+	n.TimeoutNow(context.Background(), &TimeoutNowRequest{Term: 1, LeaderID: 0})
+	for i := 0; i < 100 && n.Status().State != NodeStateLeader; i++ {
+		time.Sleep(time.Millisecond * 10)
+	}
+	if status := n.Status(); status.State != NodeStateLeader || status.Term != 2 {
+		t.Error("TimeoutNow should start an election right away", status)
+	}
+}
+
+func TestTransferLeadership(t *testing.T) {
+	ret, _ := NewNode(3, createTestPeerInfo(3), &testStateMachine{}, &MockPeerFactory{}, nil)
+	n := ret.(*node)
+	n.timer = &fakeRaftTimer{}
+
+	// nothing to do on followers
+	if err := n.TransferLeadership(context.Background()); err != nil {
+		t.Error("TransferLeadership should do nothing on a follower")
+	}
+
+	n.Start()
+	defer n.Stop()
+	n.mu.Lock()
+	n.enterCandidateState()
+	n.enterLeaderState()
+	n.transferring = true
+	n.mu.Unlock()
+
+	// no new cmds while transferring
+	if _, err := n.Execute(context.Background(), &StateMachineCmd{CmdType: 1, Data: 1}); err != ErrorNoLongerLeader {
+		t.Error("Execute should be rejected while transferring leadership")
+	}
+
+	n.mu.Lock()
+	n.transferring = false
+	n.mu.Unlock()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := n.TransferLeadership(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// the target is caught up before TimeoutNow is sent, and we follow it in the new term
+	status := n.Status()
+	target := n.peerMgr.mostUpToDate()
+	if !target.upToDate(status.LastIndex) || target.IPeerProxy.(*MockPeerProxy).tnReq == nil {
+		t.Error("TimeoutNow should be sent to an up to date follower")
+	}
+	if status.State != NodeStateFollower || status.Term != 2 || n.transferring {
+		t.Error("Node should follow the new term after transferring leadership", status)
+	}
+}
+
+func TestStop(t *testing.T) {
+	ret, _ := NewNode(2, createTestPeerInfo(2), &testStateMachine{}, &MockPeerFactory{}, nil)
+	n := ret.(*node)
+	n.Start()
+
+	stopped := make(chan struct{})
+	go func() {
+		n.Stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("Stop should not hang")
+	}
+
+	if _, err := n.AppendEntries(context.Background(), &AppendEntriesRequest{Term: 1, LeaderID: 0}); err != ErrorNodeStopped {
+		t.Error("AppendEntries should fail after stop")
+	}
+	if _, err := n.Execute(context.Background(), &StateMachineCmd{CmdType: 1, Data: 1}); err != ErrorNodeStopped {
+		t.Error("Execute should fail after stop")
+	}
+	if _, err := n.Get(context.Background(), &GetRequest{AllowStale: true}); err != ErrorNodeStopped {
+		t.Error("Get should fail after stop")
+	}
 }
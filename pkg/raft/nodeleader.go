@@ -3,29 +3,48 @@ package raft
 import (
 	"context"
 	"errors"
+	"fmt"
 	"sync"
 	"time"
 
+	"github.com/sidecus/raft/pkg/trace"
 	"github.com/sidecus/raft/pkg/util"
 )
 
 const rpcTimeOut = time.Duration(200) * time.Millisecond
 const rpcSnapshotTimeout = rpcTimeOut * 3
+const transferPollInterval = 10 * time.Millisecond
 
-var errNoLongerLeader = errors.New("Node is no longer leader")
+// ErrorNoLongerLeader is returned by Execute when the node loses leadership before the cmd is appended
+var ErrorNoLongerLeader = errors.New("Node is no longer leader")
+
+// ErrorLeadershipNotConfirmed is returned by Get when the leader cannot confirm it's still the leader
+var ErrorLeadershipNotConfirmed = errors.New("Leader cannot confirm its leadership for reads")
+
+var errorLeadershipNotTransferred = errors.New("leadership is not transferred")
+var errorNoSnapshotFile = errors.New("no snapshot file to send")
 
 // enterLeaderState resets leader indicies. Caller should acquire writer lock
 func (n *node) enterLeaderState() {
 	n.nodeState = NodeStateLeader
 	n.currentLeader = n.nodeID
+	metrics.StateChanged(NodeStateLeader)
+	metrics.ElectionWon()
 
 	// reset all follower's indicies
 	n.peerMgr.resetFollowerIndicies(n.logMgr.LastIndex())
 
+	// append a no-op entry so that we can commit entries from previous terms and serve reads (raft paper section 8)
+	n.logMgr.ProcessCmd(StateMachineCmd{CmdType: noopCmdType}, n.currentTerm)
+	if n.clusterSize == 1 {
+		// nothing to replicate, commit right away
+		n.leaderCommit()
+	}
+
 	// send heartbeat (which also resets timer)
 	n.sendHeartbeat()
 
-	util.WriteInfo("T%d: \U0001f451 Node%d won election\n", n.currentTerm, n.nodeID)
+	n.log.info(LogSubsystemElection, "Won election", "term", n.currentTerm)
 }
 
 // send heartbeat. This is non blocking
@@ -41,15 +60,17 @@ func (n *node) sendHeartbeat() {
 // 2. leader execute propogating entries
 // 3. backfilling follower
 func (n *node) replicateData(follower *Peer) int {
+	sentAt := time.Now()
+	n.ensureSnapshot(follower)
 	doReplicate := n.prepareReplication(follower)
 	reply, err := doReplicate()
 
 	if err != nil {
-		util.WriteTrace("T%d: Failed to replicate data to Node%d. %s", n.currentTerm, follower.NodeID, err)
+		n.log.trace(LogSubsystemReplication, "Failed to replicate", "term", n.currentTerm, "peer", follower.NodeID, "err", err)
 		reply = nil
 	}
 
-	return n.processReplicationResult(follower, reply)
+	return n.processReplicationResult(follower, reply, sentAt)
 }
 
 // prepareReplication prepares replication for the given node.
@@ -58,7 +79,7 @@ func (n *node) prepareReplication(follower *Peer) func() (*AppendEntriesReply, e
 	defer n.mu.RUnlock()
 
 	if n.nodeState != NodeStateLeader {
-		return func() (*AppendEntriesReply, error) { return nil, errNoLongerLeader }
+		return func() (*AppendEntriesReply, error) { return nil, ErrorNoLongerLeader }
 	}
 
 	currentTerm := n.currentTerm
@@ -66,12 +87,18 @@ func (n *node) prepareReplication(follower *Peer) func() (*AppendEntriesReply, e
 	// Snapshot scenario
 	if follower.shouldSendSnapshot(n.logMgr.SnapshotIndex()) {
 		req := n.createSnapshotRequest()
+		if req.File == "" {
+			return func() (*AppendEntriesReply, error) { return nil, errorNoSnapshotFile }
+		}
 		return func() (*AppendEntriesReply, error) {
 			ctx, cancel := context.WithTimeout(context.Background(), rpcSnapshotTimeout)
 			defer cancel()
 
-			util.WriteTrace("T%d: Sending snapshot to Node%d (T%dL%d)\n", currentTerm, follower.NodeID, req.SnapshotTerm, req.SnapshotIndex)
-			return follower.InstallSnapshot(ctx, req)
+			n.log.trace(LogSubsystemSnapshot, "Sending snapshot", "term", currentTerm, "peer", follower.NodeID, "snapshotTerm", req.SnapshotTerm, "snapshotIndex", req.SnapshotIndex)
+			start := time.Now()
+			reply, err := follower.InstallSnapshot(ctx, req)
+			metrics.ObserveRPC(RPCInstallSnapshot, follower.NodeID, time.Since(start), err)
+			return reply, err
 		}
 	}
 
@@ -82,14 +109,43 @@ func (n *node) prepareReplication(follower *Peer) func() (*AppendEntriesReply, e
 		ctx, cancel := context.WithTimeout(context.Background(), rpcTimeOut)
 		defer cancel()
 
-		util.WriteVerbose("T%d: Sending AE request to Node%d. prevIndex: %d, prevTerm: %d, entryCnt: %d\n", currentTerm, follower.NodeID, req.PrevLogIndex, req.PrevLogTerm, len(req.Entries))
-		return follower.AppendEntries(ctx, req)
+		// The follower continues the trace of the first traced entry in the request
+		spans := startReplicationSpans(follower.NodeID, req.Entries)
+		if len(spans) > 0 {
+			ctx = trace.ContextWithSpanContext(ctx, spans[0].Context())
+		}
+
+		n.log.verbose(LogSubsystemReplication, "Sending AE", "term", currentTerm, "peer", follower.NodeID, "prevIndex", req.PrevLogIndex, "prevTerm", req.PrevLogTerm, "entries", len(req.Entries))
+		start := time.Now()
+		reply, err := follower.AppendEntries(ctx, req)
+		metrics.ObserveRPC(RPCAppendEntries, follower.NodeID, time.Since(start), err)
+
+		for _, span := range spans {
+			span.SetAttribute("success", err == nil && reply.Success)
+			span.SetError(err)
+			span.End()
+		}
+		return reply, err
 	}
 }
 
+// startReplicationSpans starts a span for each traced entry sent to a follower
+func startReplicationSpans(peerID int, entries []LogEntry) []*trace.Span {
+	var spans []*trace.Span
+	for _, entry := range entries {
+		if span := trace.StartWithParent(entry.span, "raft.replicate"); span != nil {
+			span.SetAttribute("peer", peerID)
+			span.SetAttribute("index", entry.Index)
+			span.SetAttribute("entries", len(entries))
+			spans = append(spans, span)
+		}
+	}
+	return spans
+}
+
 // processReplicationResult handles append entries reply for replications.
 // returns lastMatchIndex, or -1 if there is any "error"
-func (n *node) processReplicationResult(follower *Peer, reply *AppendEntriesReply) int {
+func (n *node) processReplicationResult(follower *Peer, reply *AppendEntriesReply, sentAt time.Time) int {
 	n.mu.Lock()
 	defer n.mu.Unlock()
 
@@ -109,8 +165,13 @@ func (n *node) processReplicationResult(follower *Peer, reply *AppendEntriesRepl
 		return -1
 	}
 
+	// follower acknowledged us as leader of current term, record it for read confirmation
+	follower.updateLastAck(sentAt)
+
 	// 5.3 update follower indicies based on reply and last match index info from the reply
 	follower.updateMatchIndex(reply.Success, reply.LastMatch)
+	n.log.verbose(LogSubsystemReplication, "Updated follower indices", "peer", follower.NodeID, "match", reply.Success, "lastMatch", reply.LastMatch, "nextIndex", follower.nextIndex)
+	metrics.SetReplicationLag(follower.NodeID, n.logMgr.LastIndex()-follower.matchIndex)
 
 	// Then check whether there are logs to commit
 	newCommit := reply.Success && n.leaderCommit()
@@ -149,7 +210,7 @@ func (n *node) leaderCommit() bool {
 	}
 
 	if commitIndex > n.logMgr.CommitIndex() {
-		util.WriteTrace("T%d: Leader%d committing to L%d upon quorum", n.currentTerm, n.nodeID, commitIndex)
+		n.log.trace(LogSubsystemReplication, "Committing upon quorum", "term", n.currentTerm, "commitIndex", commitIndex)
 		n.commitTo(commitIndex)
 		return true
 	}
@@ -161,7 +222,30 @@ func (n *node) leaderCommit() bool {
 // This will trigger replicateData for all followers and wait for them to finish
 func (n *node) leaderExecute(ctx context.Context, cmd *StateMachineCmd) (*ExecuteReply, error) {
 	n.mu.Lock()
-	targetIndex := n.logMgr.ProcessCmd(*cmd, n.currentTerm)
+	if n.nodeState != NodeStateLeader || n.transferring {
+		// we might have lost leadership after Execute checked node state, or be handing it over
+		n.mu.Unlock()
+		return nil, ErrorNoLongerLeader
+	}
+	term := n.currentTerm
+	proposedAt := time.Now()
+	ctx, span := trace.StartChild(ctx, "raft.propose")
+	defer span.End()
+	targetIndex := n.logMgr.ProcessCmd(*cmd, term)
+	n.logMgr.TraceEntry(targetIndex, span.Context())
+	span.SetAttribute("index", targetIndex)
+	span.SetAttribute("term", term)
+
+	_, commitSpan := trace.StartChild(ctx, "raft.commit")
+	defer commitSpan.End()
+	if n.clusterSize == 1 {
+		// nothing to replicate, commit right away
+		n.leaderCommit()
+		reply := n.executeReply(targetIndex, term, proposedAt)
+		n.mu.Unlock()
+		commitSpan.SetAttribute("committed", reply.Success)
+		return reply, nil
+	}
 	n.mu.Unlock()
 
 	// Try to replicate new entry to all followers
@@ -169,8 +253,79 @@ func (n *node) leaderExecute(ctx context.Context, cmd *StateMachineCmd) (*Execut
 		p.requestReplicateTo(targetIndex, wg)
 	})
 
-	success := n.logMgr.CommitIndex() >= targetIndex
-	return &ExecuteReply{NodeID: n.nodeID, Success: success}, nil
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	reply := n.executeReply(targetIndex, term, proposedAt)
+	commitSpan.SetAttribute("committed", reply.Success)
+	return reply, nil
+}
+
+// executeReply creates the reply for the cmd proposed at index in term. Caller should acquire writer lock.
+// The entry might have been overwritten by a new leader, only report success when our own entry is committed
+func (n *node) executeReply(index int, term int, proposedAt time.Time) *ExecuteReply {
+	success := n.committedWithTerm(index, term)
+	result := n.logMgr.TakeResult(index)
+
+	reply := &ExecuteReply{NodeID: n.nodeID, Success: success}
+	if success {
+		reply.Result = result
+		metrics.ObserveCommit(time.Since(proposedAt))
+	}
+	return reply
+}
+
+// leaderGet reads from state machine after confirming leadership with a quorum (raft paper section 8).
+// Requests sent to followers after the read starts must be acknowledged by majority in current term,
+// and the leader must have committed an entry in current term so that its commit index is up to date
+func (n *node) leaderGet(ctx context.Context, req *GetRequest) (*GetReply, error) {
+	n.mu.RLock()
+	term := n.currentTerm
+	n.mu.RUnlock()
+
+	// request a new round of replication to all followers and wait for them to finish
+	start := time.Now()
+	n.peerMgr.waitAll(func(p *Peer, wg *sync.WaitGroup) {
+		p.requestReplicate(wg)
+	})
+
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+
+	if n.stopped {
+		return nil, ErrorNodeStopped
+	}
+
+	if n.nodeState != NodeStateLeader || n.currentTerm != term {
+		return nil, ErrorNoLongerLeader
+	}
+
+	if !n.committedWithTerm(n.logMgr.CommitIndex(), term) || !n.peerMgr.quorumAcked(start) {
+		return nil, ErrorLeadershipNotConfirmed
+	}
+
+	ret, err := n.logMgr.Get(req.Params...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &GetReply{
+		NodeID: n.nodeID,
+		Data:   ret,
+	}, nil
+}
+
+// committedWithTerm tells whether the entry at index is committed and has the given term.
+// Returns false if that can no longer be told, e.g. the entry is compacted into a snapshot
+func (n *node) committedWithTerm(index int, term int) bool {
+	if index < 0 || index > n.logMgr.CommitIndex() || index < n.logMgr.SnapshotIndex() {
+		return false
+	}
+
+	if index == n.logMgr.SnapshotIndex() {
+		return n.logMgr.SnapshotTerm() == term
+	}
+
+	return n.logMgr.GetLogEntry(index).Term == term
 }
 
 // createAERequest creates an AppendEntriesRequest with proper log payload
@@ -196,6 +351,30 @@ func (n *node) createAERequest(startIdx int, maxCnt int) *AppendEntriesRequest {
 	return req
 }
 
+// ensureSnapshot takes the snapshot file if the follower needs one and it's not taken yet,
+// e.g. when the node restores from a persistent state machine
+func (n *node) ensureSnapshot(follower *Peer) {
+	n.mu.RLock()
+	needed := n.nodeState == NodeStateLeader && follower.shouldSendSnapshot(n.logMgr.SnapshotIndex()) && n.logMgr.SnapshotFile() == ""
+	n.mu.RUnlock()
+	if !needed {
+		return
+	}
+
+	// Taking a snapshot changes the logs, so it needs the writer lock
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.logMgr.SnapshotFile() != "" {
+		return
+	}
+	if err := n.logMgr.EnsureSnapshot(); err != nil {
+		n.log.error(LogSubsystemSnapshot, "Failed to take snapshot", "term", n.currentTerm, "peer", follower.NodeID, "err", err)
+		return
+	}
+	n.log.info(LogSubsystemSnapshot, "Took snapshot", "term", n.currentTerm, "snapshotTerm", n.logMgr.SnapshotTerm(), "snapshotIndex", n.logMgr.SnapshotIndex())
+}
+
 // createSnapshotRequest creates a snapshot request to send to follower
 func (n *node) createSnapshotRequest() *SnapshotRequest {
 	return &SnapshotRequest{
@@ -208,3 +387,71 @@ func (n *node) createSnapshotRequest() *SnapshotRequest {
 		File: n.logMgr.SnapshotFile(),
 	}
 }
+
+// TransferLeadership hands leadership over to the most up to date follower (raft thesis section 3.10).
+// The leader stops accepting new cmds, replicates its logs to the follower, and then asks it to start an election right away.
+// Returns nil if the node is not the leader or has no peers. If leadership is not transferred before ctx is done,
+// the node resumes accepting cmds and returns an error
+func (n *node) TransferLeadership(ctx context.Context) error {
+	n.mu.Lock()
+	if n.nodeState != NodeStateLeader || n.clusterSize == 1 || n.stopped {
+		n.mu.Unlock()
+		return nil
+	}
+	term := n.currentTerm
+	n.transferring = true
+	n.mu.Unlock()
+
+	defer func() {
+		n.mu.Lock()
+		n.transferring = false
+		n.mu.Unlock()
+	}()
+
+	ticker := time.NewTicker(transferPollInterval)
+	defer ticker.Stop()
+	sent := false
+	var lastTarget *Peer
+	for {
+		n.mu.RLock()
+		if n.nodeState != NodeStateLeader || n.currentTerm != term {
+			n.log.info(LogSubsystemElection, "Transferred leadership", "term", n.currentTerm, "leader", n.currentLeader)
+			n.mu.RUnlock()
+			return nil
+		}
+		target := n.peerMgr.mostUpToDate()
+		lastIndex := n.logMgr.LastIndex()
+		upToDate := target.upToDate(lastIndex)
+		n.mu.RUnlock()
+
+		if !upToDate {
+			// catch the follower up, entries proposed before the transfer started included
+			var wg sync.WaitGroup
+			wg.Add(1)
+			target.requestReplicateTo(lastIndex, &wg)
+			wg.Wait()
+		} else if !sent {
+			if target != lastTarget {
+				n.log.info(LogSubsystemElection, "Transferring leadership", "term", term, "peer", target.NodeID, "lastIndex", lastIndex)
+				lastTarget = target
+			}
+			reply, err := target.TimeoutNow(ctx, &TimeoutNowRequest{Term: term, LeaderID: n.nodeID})
+			if err != nil {
+				// keep trying until ctx is done, the peer might be restarting
+				n.log.trace(LogSubsystemElection, "Failed to send TimeoutNow", "term", term, "peer", target.NodeID, "err", err)
+			} else {
+				sent = true
+				n.mu.Lock()
+				n.tryFollowNewTerm(reply.NodeID, reply.Term, false)
+				n.mu.Unlock()
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			n.log.warn(LogSubsystemElection, "Failed to transfer leadership", "term", term, "err", ctx.Err())
+			return fmt.Errorf("%w: %s", errorLeadershipNotTransferred, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
@@ -1,6 +1,8 @@
 package raft
 
 import (
+	"time"
+
 	"github.com/sidecus/raft/pkg/util"
 )
 
@@ -12,6 +14,7 @@ type Peer struct {
 	NodeInfo
 	nextIndex  int
 	matchIndex int
+	lastAck    time.Time // send time of the latest request acknowledged in current term
 
 	*batchReplicator
 	IPeerProxy
@@ -52,18 +55,29 @@ func (p *Peer) hasConsensus(logIndex int) bool {
 func (p *Peer) resetFollowerIndex(lastLogIndex int) {
 	p.nextIndex = lastLogIndex + 1
 	p.matchIndex = -1
+	p.lastAck = time.Time{}
+}
+
+// updateLastAck records that the follower acknowledged a request sent at sentAt
+func (p *Peer) updateLastAck(sentAt time.Time) {
+	if sentAt.After(p.lastAck) {
+		p.lastAck = sentAt
+	}
+}
+
+// ackedSince tells whether the follower acknowledged any request sent at or after t
+func (p *Peer) ackedSince(t time.Time) bool {
+	return !p.lastAck.Before(t)
 }
 
 // updateMatchIndex updates match index for a given node
 func (p *Peer) updateMatchIndex(match bool, lastMatch int) {
 	if match {
 		if p.matchIndex < lastMatch {
-			util.WriteVerbose("Updating Node%d's nextIndex. lastMatch %d", p.NodeID, lastMatch)
 			p.nextIndex = lastMatch + 1
 			p.matchIndex = lastMatch
 		}
 	} else {
-		util.WriteVerbose("Decreasing Node%d's nextIndex. lastMatch %d", p.NodeID, lastMatch)
 		// prev entries don't match. decrement nextIndex.
 		// cap it to 0. It is meaningless when less than zero
 		p.nextIndex = util.Max(0, p.nextIndex-nextIndexFallbackStep)
@@ -3,11 +3,11 @@ package raft
 import (
 	"errors"
 	"sync"
+	"time"
 
 	"github.com/sidecus/raft/pkg/util"
 )
 
-var errorNoPeersProvided = errors.New("No raft peers provided")
 var errorInvalidNodeID = errors.New("Invalid node id")
 
 // IPeerProxy defines the RPC client interface for a specific peer nodes
@@ -28,7 +28,9 @@ type IPeerManager interface {
 	waitAll(action func(*Peer, *sync.WaitGroup))
 	resetFollowerIndicies(lastLogIndex int)
 	quorumReached(logIndex int) bool
+	quorumAcked(since time.Time) bool
 	tryReplicateAll()
+	mostUpToDate() *Peer
 
 	start()
 	stop()
@@ -39,12 +41,8 @@ type peerManager struct {
 	peers map[int]*Peer
 }
 
-// newPeerManager creates the node proxy for kv store
+// newPeerManager creates the node proxy for kv store. peers is empty in a single node cluster
 func newPeerManager(peers map[int]NodeInfo, replicate func(*Peer) int, proxyFactory IPeerProxyFactory) IPeerManager {
-	if len(peers) == 0 {
-		util.Panicf("%s\n", errorNoPeersProvided)
-	}
-
 	mgr := &peerManager{
 		peers: make(map[int]*Peer),
 	}
@@ -98,17 +96,28 @@ func (mgr *peerManager) resetFollowerIndicies(lastLogIndex int) {
 func (mgr *peerManager) quorumReached(logIndex int) bool {
 	// both match count and majority should include the leader itself, which is not part of the peerManager
 	matchCnt := 1
-	quorum := (len(mgr.peers) + 1) / 2
+	quorum := majority(len(mgr.peers) + 1)
 	for _, p := range mgr.peers {
 		if p.hasConsensus(logIndex) {
 			matchCnt++
-			if matchCnt > quorum {
-				return true
-			}
 		}
 	}
 
-	return false
+	return matchCnt >= quorum
+}
+
+// quorumAcked tells whether majority of the followers acknowledged requests sent at or after the given time
+func (mgr *peerManager) quorumAcked(since time.Time) bool {
+	// both ack count and majority should include the leader itself, which is not part of the peerManager
+	ackCnt := 1
+	quorum := majority(len(mgr.peers) + 1)
+	for _, p := range mgr.peers {
+		if p.ackedSince(since) {
+			ackCnt++
+		}
+	}
+
+	return ackCnt >= quorum
 }
 
 // tryReplicateAll tries to request replication to all peers
@@ -118,6 +127,17 @@ func (mgr *peerManager) tryReplicateAll() {
 	}
 }
 
+// mostUpToDate returns the peer with the highest match index, or nil if there are no peers
+func (mgr *peerManager) mostUpToDate() *Peer {
+	var ret *Peer
+	for _, p := range mgr.peers {
+		if ret == nil || p.matchIndex > ret.matchIndex || (p.matchIndex == ret.matchIndex && p.NodeID < ret.NodeID) {
+			ret = p
+		}
+	}
+	return ret
+}
+
 // Start starts a replication goroutine for each follower
 func (mgr *peerManager) start() {
 	for _, p := range mgr.peers {
@@ -3,6 +3,7 @@ package raft
 import (
 	"context"
 	"testing"
+	"time"
 )
 
 // PeerProxy mock
@@ -10,6 +11,7 @@ type MockPeerProxy struct {
 	nodeID int
 	aeReq  *AppendEntriesRequest
 	isReq  *SnapshotRequest
+	tnReq  *TimeoutNowRequest
 }
 
 func (proxy *MockPeerProxy) AppendEntries(ctx context.Context, req *AppendEntriesRequest) (*AppendEntriesReply, error) {
@@ -22,7 +24,12 @@ func (proxy *MockPeerProxy) AppendEntries(ctx context.Context, req *AppendEntrie
 	}, nil
 }
 func (proxy *MockPeerProxy) RequestVote(ctx context.Context, req *RequestVoteRequest) (*RequestVoteReply, error) {
-	return nil, nil
+	return &RequestVoteReply{NodeID: proxy.nodeID, Term: req.Term, VotedTerm: req.Term, VoteGranted: true}, nil
+}
+func (proxy *MockPeerProxy) TimeoutNow(ctx context.Context, req *TimeoutNowRequest) (*TimeoutNowReply, error) {
+	// reply as if the peer started an election right away
+	proxy.tnReq = req
+	return &TimeoutNowReply{NodeID: proxy.nodeID, Term: req.Term + 1}, nil
 }
 func (proxy *MockPeerProxy) InstallSnapshot(ctx context.Context, req *SnapshotRequest) (*AppendEntriesReply, error) {
 	proxy.isReq = req
@@ -136,3 +143,47 @@ func TestQuorumReached(t *testing.T) {
 		}
 	}
 }
+
+func TestSmallClusterQuorum(t *testing.T) {
+	mgr := createTestPeerManager(0)
+	if !mgr.quorumReached(10) || !mgr.quorumAcked(time.Now()) {
+		t.Error("Leader of a single node cluster should be the quorum itself")
+	}
+
+	// both nodes are needed in a 2 node cluster
+	mgr = createTestPeerManager(1)
+	start := time.Now()
+	if mgr.quorumReached(0) || mgr.quorumAcked(start) {
+		t.Error("Leader of a 2 node cluster should not be the quorum itself")
+	}
+	mgr.getPeer(0).matchIndex = 3
+	mgr.getPeer(0).updateLastAck(start)
+	if !mgr.quorumReached(3) || mgr.quorumReached(4) || !mgr.quorumAcked(start) {
+		t.Error("Leader and the follower should be the quorum of a 2 node cluster")
+	}
+}
+
+func TestQuorumAcked(t *testing.T) {
+	mgr := createTestPeerManager(4)
+	start := time.Now()
+
+	if mgr.quorumAcked(start) {
+		t.Error("quorumAcked returns true when no follower acknowledged")
+	}
+
+	mgr.getPeer(0).updateLastAck(start.Add(-time.Millisecond))
+	mgr.getPeer(1).updateLastAck(start)
+	if mgr.quorumAcked(start) {
+		t.Error("quorumAcked should not count acks for requests sent before the given time")
+	}
+
+	mgr.getPeer(2).updateLastAck(start.Add(time.Millisecond))
+	if !mgr.quorumAcked(start) {
+		t.Error("quorumAcked should return true when majority acknowledged")
+	}
+
+	mgr.resetFollowerIndicies(10)
+	if mgr.quorumAcked(start) {
+		t.Error("resetFollowerIndicies should reset acks")
+	}
+}
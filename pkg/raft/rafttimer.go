@@ -29,14 +29,18 @@ type raftTimer struct {
 	wg       sync.WaitGroup
 	timer    *time.Timer
 	evt      chan resetEvt
+	done     chan struct{}
 	callback func(state NodeState, term int)
+	log      nodeLogger
 }
 
 // newRaftTimer creates a new raft timer
-func newRaftTimer(timerCallback func(state NodeState, term int)) IRaftTimer {
+func newRaftTimer(timerCallback func(state NodeState, term int), log nodeLogger) IRaftTimer {
 	rt := &raftTimer{
 		callback: timerCallback,
+		log:      log,
 		evt:      make(chan resetEvt, 100), // use buffered channels so that we don't block sender
+		done:     make(chan struct{}),
 	}
 
 	return rt
@@ -49,39 +53,41 @@ func (rt *raftTimer) start() {
 	go rt.run()
 }
 
-// stopRaftTimer stops the raft timer goroutine
+// stop stops the raft timer goroutine and waits for it to exit. Timer channels are never closed, so we signal done instead.
+// It must not be called with locks acquired by the callback
 func (rt *raftTimer) stop() {
-	util.StopTimer(rt.timer)
+	close(rt.done)
 	rt.wg.Wait()
-	rt.timer = nil
+	util.StopTimer(rt.timer)
 }
 
 // Reset refreshes the timer based on node state and tries to drain pending timer events if any
 func (rt *raftTimer) reset(newState NodeState, term int) {
-	rt.evt <- resetEvt{state: newState, term: term}
+	select {
+	case rt.evt <- resetEvt{state: newState, term: term}:
+	case <-rt.done:
+	}
 }
 
 // run runs the timer event loop
 func (rt *raftTimer) run() {
+	defer rt.wg.Done()
+
 	state, term := NodeStateFollower, 0
-	stop := false
-	for !stop {
+	for {
 		select {
 		case info := <-rt.evt:
 			state, term = info.state, info.term
 			timeout := getTimeout(state, term)
-			util.WriteVerbose("Resetting timer. state:%d, term:%d, timeout:%dms", state, term, timeout/time.Millisecond)
+			rt.log.verbose(LogSubsystemElection, "Resetting timer", "state", state, "term", term, "timeoutMS", int64(timeout/time.Millisecond))
 			util.ResetTimer(rt.timer, timeout)
-		case _, ok := <-rt.timer.C:
-			if !ok {
-				stop = true
-				break
-			}
-			util.WriteVerbose("Timer event received. state:%d, term:%d", state, term)
+		case <-rt.done:
+			return
+		case <-rt.timer.C:
+			rt.log.verbose(LogSubsystemElection, "Timer event received", "state", state, "term", term)
 			rt.callback(state, term)
 		}
 	}
-	rt.wg.Done()
 }
 
 var firstFollow = true
@@ -1,7 +1,9 @@
 package raft
 
 import (
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 // Fake timer implementation for other unit tests
@@ -20,5 +22,27 @@ func (t *fakeRaftTimer) reset(newState NodeState, term int) {
 }
 
 func TestRaftTimer(t *testing.T) {
-	// stop
+	calls := int32(0)
+	rt := newRaftTimer(func(state NodeState, term int) {
+		atomic.AddInt32(&calls, 1)
+	}, nodeLogger{})
+
+	rt.start()
+	rt.reset(NodeStateLeader, 1)
+	time.Sleep(heartbeatTimeout * 2)
+	if atomic.LoadInt32(&calls) == 0 {
+		t.Error("timer should fire after heartbeat timeout")
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		rt.stop()
+		rt.reset(NodeStateFollower, 2) // doesn't block after stop
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("timer should stop")
+	}
 }
@@ -35,6 +35,18 @@ type RequestVoteReply struct {
 	VoteGranted bool
 }
 
+// TimeoutNowRequest asks a follower to start an election right away, to transfer leadership to it
+type TimeoutNowRequest struct {
+	Term     int
+	LeaderID int
+}
+
+// TimeoutNowReply reply type for TimeoutNow calls
+type TimeoutNowReply struct {
+	NodeID int
+	Term   int
+}
+
 // SnapshotRequestHeader defines headers for a snapshot
 type SnapshotRequestHeader struct {
 	Term          int
@@ -53,6 +65,9 @@ type SnapshotRequest struct {
 // GetRequest is used for an get operation
 type GetRequest struct {
 	Params []interface{}
+	// AllowStale reads from the local state machine on any node without confirming leadership.
+	// Such reads are fast but might return stale data
+	AllowStale bool
 }
 
 // GetReply is used to reply to GetRequest
@@ -65,4 +80,6 @@ type GetReply struct {
 type ExecuteReply struct {
 	NodeID  int
 	Success bool
+	// Result is returned by the state machine's Apply, only available when Success is true
+	Result interface{}
 }
@@ -1,8 +1,11 @@
 package raft
 
 import (
+	"encoding/binary"
 	"errors"
 	"fmt"
+	"hash"
+	"hash/crc32"
 	"io"
 	"os"
 	"path/filepath"
@@ -10,11 +13,28 @@ import (
 
 const snapshotChunkSize = 8 * 1024
 
+// Snapshot files start with the magic and the header, followed by the state machine data,
+// and end with a trailer of the data size and its CRC32 (Castagnoli) checksum. All numbers are big endian
+const snapshotMagic = "rkvsnap1"
+const snapshotHeaderSize = len(snapshotMagic) + 3*8
+const snapshotTrailerSize = 8 + 4
+
+var snapshotCRCTable = crc32.MakeTable(crc32.Castagnoli)
+
 var snapshotPath string
 var errorInvalidSnapshotInfo = errors.New("Invalid snapshot index/term")
 var errorEmptySnapshot = errors.New("empty snapshot received")
 var errorSnapshotFromStaleLeader = errors.New("snapshot received from a stale leader")
 var errorDifferentHeader = errors.New("Different snapshot header received for the same snapshot")
+var errorInvalidSnapshotFile = errors.New("not a snapshot file, or it's truncated")
+var errorSnapshotChecksum = errors.New("snapshot data doesn't match the checksum")
+
+// SnapshotHeader is the header of snapshot files. NodeID is the node which took the snapshot
+type SnapshotHeader struct {
+	NodeID int
+	Term   int
+	Index  int
+}
 
 // SetSnapshotPath set the snapshot saving path
 func SetSnapshotPath(path string) {
@@ -29,14 +49,169 @@ func openSnapshot(file string) (reader io.ReadCloser, err error) {
 // createSnapshot creates a snapshot file based on the info provided
 // suffix will be appended to the snapshotfile name, can be "remote" when receiving over gRPC and "local" when creating locally
 func createSnapshot(nodeID int, term int, index int, suffix string) (file string, writer io.WriteCloser, err error) {
+	if file, err = snapshotFileName(nodeID, term, index, suffix); err != nil {
+		return "", nil, err
+	}
+
+	writer, err = CreateSnapshotFile(file, SnapshotHeader{NodeID: nodeID, Term: term, Index: index})
+	return file, writer, err
+}
+
+// snapshotFileName returns the full path of the snapshot file
+func snapshotFileName(nodeID int, term int, index int, suffix string) (string, error) {
 	if term < 0 || index < 0 || suffix == "" {
-		return "", nil, errorInvalidSnapshotInfo
+		return "", errorInvalidSnapshotInfo
 	}
 
 	fileName := fmt.Sprintf("Node%d_T%dL%d_%s.rkvsnapshot", nodeID, term, index, suffix)
-	fullpath := filepath.Join(snapshotPath, fileName)
-	f, err := os.Create(fullpath)
-	return fullpath, f, err
+	return filepath.Join(snapshotPath, fileName), nil
+}
+
+// CreateSnapshotFile creates a snapshot file with the header. State machine data is written to the returned writer,
+// and the trailer is written when it's closed
+func CreateSnapshotFile(file string, header SnapshotHeader) (io.WriteCloser, error) {
+	f, err := os.Create(file)
+	if err != nil {
+		return nil, err
+	}
+
+	buf := make([]byte, snapshotHeaderSize)
+	copy(buf, snapshotMagic)
+	binary.BigEndian.PutUint64(buf[len(snapshotMagic):], uint64(header.NodeID))
+	binary.BigEndian.PutUint64(buf[len(snapshotMagic)+8:], uint64(header.Term))
+	binary.BigEndian.PutUint64(buf[len(snapshotMagic)+16:], uint64(header.Index))
+	if _, err = f.Write(buf); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return &snapshotFileWriter{f: f, crc: crc32.New(snapshotCRCTable)}, nil
+}
+
+// snapshotFileWriter writes state machine data to a snapshot file, and the trailer on Close
+type snapshotFileWriter struct {
+	f    *os.File
+	crc  hash.Hash32
+	size uint64
+}
+
+func (w *snapshotFileWriter) Write(p []byte) (int, error) {
+	n, err := w.f.Write(p)
+	w.crc.Write(p[:n])
+	w.size += uint64(n)
+	return n, err
+}
+
+func (w *snapshotFileWriter) Close() error {
+	trailer := make([]byte, snapshotTrailerSize)
+	binary.BigEndian.PutUint64(trailer, w.size)
+	binary.BigEndian.PutUint32(trailer[8:], w.crc.Sum32())
+	_, err := w.f.Write(trailer)
+	if closeErr := w.f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// OpenSnapshotFile opens a snapshot file, and returns its header and a reader of the state machine data.
+// The checksum is not verified, use VerifySnapshotFile for that
+func OpenSnapshotFile(file string) (SnapshotHeader, io.ReadCloser, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return SnapshotHeader{}, nil, err
+	}
+
+	header, size, err := readSnapshotHeader(f)
+	if err != nil {
+		f.Close()
+		return SnapshotHeader{}, nil, fmt.Errorf("%s: %w", file, err)
+	}
+
+	reader := struct {
+		io.Reader
+		io.Closer
+	}{io.LimitReader(f, size), f}
+	return header, reader, nil
+}
+
+// readSnapshotHeader reads the header of a snapshot file, and returns it with the state machine data size
+func readSnapshotHeader(f *os.File) (SnapshotHeader, int64, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return SnapshotHeader{}, 0, err
+	}
+	size := info.Size() - int64(snapshotHeaderSize+snapshotTrailerSize)
+
+	buf := make([]byte, snapshotHeaderSize)
+	if _, err = io.ReadFull(f, buf); err != nil || size < 0 || string(buf[:len(snapshotMagic)]) != snapshotMagic {
+		return SnapshotHeader{}, 0, errorInvalidSnapshotFile
+	}
+
+	header := SnapshotHeader{
+		NodeID: int(binary.BigEndian.Uint64(buf[len(snapshotMagic):])),
+		Term:   int(binary.BigEndian.Uint64(buf[len(snapshotMagic)+8:])),
+		Index:  int(binary.BigEndian.Uint64(buf[len(snapshotMagic)+16:])),
+	}
+	return header, size, nil
+}
+
+// VerifySnapshotFile checks the state machine data in a snapshot file against the size and checksum in its trailer
+func VerifySnapshotFile(file string) (SnapshotHeader, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return SnapshotHeader{}, err
+	}
+	defer f.Close()
+
+	header, size, err := readSnapshotHeader(f)
+	if err != nil {
+		return SnapshotHeader{}, fmt.Errorf("%s: %w", file, err)
+	}
+
+	crc := crc32.New(snapshotCRCTable)
+	trailer := make([]byte, snapshotTrailerSize)
+	if _, err = io.CopyN(crc, f, size); err == nil {
+		_, err = io.ReadFull(f, trailer)
+	}
+	if err != nil {
+		return header, err
+	}
+
+	if binary.BigEndian.Uint64(trailer) != uint64(size) || binary.BigEndian.Uint32(trailer[8:]) != crc.Sum32() {
+		return header, fmt.Errorf("%s: %w", file, errorSnapshotChecksum)
+	}
+	return header, nil
+}
+
+// latestSnapshotFile returns the snapshot file of the node with the highest index in the snapshot path,
+// either taken locally or received from the leader. Returns empty string if there is none
+func latestSnapshotFile(nodeID int) (string, error) {
+	files, err := filepath.Glob(filepath.Join(snapshotPath, fmt.Sprintf("Node%d_T*L*_*.rkvsnapshot", nodeID)))
+	if err != nil {
+		return "", err
+	}
+
+	latest, latestIndex := "", -1
+	for _, file := range files {
+		var id, term, index int
+		var suffix string
+		if n, _ := fmt.Sscanf(filepath.Base(file), "Node%d_T%dL%d_%s", &id, &term, &index, &suffix); n != 4 || id != nodeID {
+			continue
+		}
+		if index > latestIndex {
+			latest, latestIndex = file, index
+		}
+	}
+	return latest, nil
+}
+
+// snapshotFileSize returns the size of a snapshot file, 0 if it can't be told
+func snapshotFileSize(file string) int64 {
+	info, err := os.Stat(file)
+	if err != nil {
+		return 0
+	}
+	return info.Size()
 }
 
 // deleteSnapshot deletes a snapshot file
@@ -55,9 +230,13 @@ func ReceiveSnapshot(nodeID int, reader *SnapshotStreamReader) (req *SnapshotReq
 	snapshotTerm := req.SnapshotTerm
 	snapshotIndex := req.SnapshotIndex
 
+	// the stream carries the whole snapshot file including its header and checksum, which is verified when it's installed
 	var file string
 	var w io.WriteCloser
-	if file, w, err = createSnapshot(nodeID, snapshotTerm, snapshotIndex, "remote"); err != nil {
+	if file, err = snapshotFileName(nodeID, snapshotTerm, snapshotIndex, "remote"); err != nil {
+		return
+	}
+	if w, err = os.Create(file); err != nil {
 		return
 	}
 	defer w.Close()
@@ -1,6 +1,7 @@
 package raft
 
 import (
+	"bytes"
 	"errors"
 	"io"
 	"os"
@@ -75,6 +76,53 @@ func TestCreateSnapshot(t *testing.T) {
 	}
 }
 
+func TestSnapshotFile(t *testing.T) {
+	file := filepath.Join(setSnapshotPathToTempDir(), "TestSnapshotFile.rkvsnapshot")
+	defer deleteSnapshot(file)
+
+	header := SnapshotHeader{NodeID: 1, Term: 2, Index: 3}
+	w, err := CreateSnapshotFile(file, header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := createTestData(7)
+	w.Write(data)
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	h, r, err := OpenSnapshotFile(file)
+	if err != nil || h != header {
+		t.Fatal("OpenSnapshotFile should return the header", err)
+	}
+	read, _ := io.ReadAll(r)
+	r.Close()
+	if !bytes.Equal(read, data) {
+		t.Error("OpenSnapshotFile should only read the state machine data")
+	}
+	if h, err = VerifySnapshotFile(file); err != nil || h != header {
+		t.Error("VerifySnapshotFile should succeed on intact file", err)
+	}
+
+	// corrupt one byte of the data
+	content, _ := os.ReadFile(file)
+	content[snapshotHeaderSize+10]++
+	os.WriteFile(file, content, 0644)
+	if _, err = VerifySnapshotFile(file); !errors.Is(err, errorSnapshotChecksum) {
+		t.Error("VerifySnapshotFile should detect corrupted data", err)
+	}
+
+	os.WriteFile(file, content[:len(content)-100], 0644)
+	if _, err = VerifySnapshotFile(file); err == nil {
+		t.Error("VerifySnapshotFile should detect truncated file")
+	}
+
+	os.WriteFile(file, data, 0644)
+	if _, _, err = OpenSnapshotFile(file); !errors.Is(err, errorInvalidSnapshotFile) {
+		t.Error("OpenSnapshotFile should reject files without header", err)
+	}
+}
+
 func TestReceiveSnapshot(t *testing.T) {
 	setSnapshotPathToTempDir()
 
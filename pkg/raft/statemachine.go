@@ -18,8 +18,75 @@ type IValueGetter interface {
 // Writer locks for Apply/Deserialize
 // Reader locks for Serialize/Get
 type IStateMachine interface {
-	Apply(cmd StateMachineCmd)
+	// Apply applies the cmd and returns its result, which is returned to the caller of Execute on the proposing node
+	Apply(cmd StateMachineCmd) interface{}
 	Serialize(io.Writer) error
 	Deserialize(reader io.Reader) error
 	IValueGetter
 }
+
+// IPersistentStateMachine is a state machine which persists its own state, e.g. in an on disk store.
+// The index and term of the last applied entry are saved atomically with the changes of each entry,
+// so that a restarted node resumes from them and only needs the log after them
+type IPersistentStateMachine interface {
+	IStateMachine
+	// ApplyEntry applies the cmd of the log entry at index and term. It's used instead of Apply
+	ApplyEntry(index int, term int, cmd StateMachineCmd) interface{}
+	// DeserializeAt installs a snapshot taken at index and term. It's used instead of Deserialize
+	DeserializeAt(reader io.Reader, index int, term int) error
+	// LastApplied returns the index and term of the last applied entry, -1 and -1 if there is none
+	LastApplied() (index int, term int)
+}
+
+// ICommandCodec encodes and decodes StateMachineCmd.Data and cmd results, so that commands can be carried by transports as opaque bytes.
+// Each state machine provides its own codec, and registers it with the transport it uses
+type ICommandCodec interface {
+	Encode(cmdType int, data interface{}) ([]byte, error)
+	Decode(cmdType int, data []byte) (interface{}, error)
+	EncodeCmdResult(cmdType int, result interface{}) ([]byte, error)
+	DecodeCmdResult(cmdType int, data []byte) (interface{}, error)
+}
+
+// EncodeCmd encodes a cmd's data using the codec. No-op cmds from raft don't carry any data and skip the codec
+func EncodeCmd(codec ICommandCodec, cmd StateMachineCmd) ([]byte, error) {
+	if cmd.CmdType == noopCmdType {
+		return nil, nil
+	}
+	return codec.Encode(cmd.CmdType, cmd.Data)
+}
+
+// DecodeCmd decodes a cmd from its type and data using the codec
+func DecodeCmd(codec ICommandCodec, cmdType int, data []byte) (StateMachineCmd, error) {
+	cmd := StateMachineCmd{CmdType: cmdType}
+	if cmdType == noopCmdType {
+		return cmd, nil
+	}
+
+	var err error
+	cmd.Data, err = codec.Decode(cmdType, data)
+	return cmd, err
+}
+
+// IQueryCodec encodes and decodes Get params and results, so that reads can be proxied to the leader by transports.
+// params are passed to EncodeResult/DecodeResult so that codecs can tell result types apart for different queries
+type IQueryCodec interface {
+	EncodeParams(params []interface{}) ([]byte, error)
+	DecodeParams(data []byte) ([]interface{}, error)
+	EncodeResult(params []interface{}, result interface{}) ([]byte, error)
+	DecodeResult(params []interface{}, data []byte) (interface{}, error)
+}
+
+// ICodec is everything a transport needs to carry commands and reads for a state machine
+type ICodec interface {
+	ICommandCodec
+	IQueryCodec
+}
+
+// IErrorCodec is optionally implemented by codecs to carry state machine errors, e.g. key not found, across transports.
+// Without it the proxying node only gets the error message back, and errors.Is doesn't work on it
+type IErrorCodec interface {
+	// EncodeError returns a code for a known state machine error, false for other errors
+	EncodeError(err error) (code string, ok bool)
+	// DecodeError returns the error for a code returned by EncodeError, false for unknown codes
+	DecodeError(code string) (err error, ok bool)
+}
@@ -0,0 +1,61 @@
+package raft
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+// testCodec encodes int cmd data used by testStateMachine
+type testCodec struct{}
+
+func (c testCodec) Encode(cmdType int, data interface{}) ([]byte, error) {
+	return json.Marshal(data)
+}
+
+func (c testCodec) Decode(cmdType int, data []byte) (interface{}, error) {
+	if cmdType != 1 {
+		return nil, errors.New("unknown cmd type")
+	}
+	var v int
+	err := json.Unmarshal(data, &v)
+	return v, err
+}
+
+func (c testCodec) EncodeCmdResult(cmdType int, result interface{}) ([]byte, error) {
+	return json.Marshal(result)
+}
+
+func (c testCodec) DecodeCmdResult(cmdType int, data []byte) (interface{}, error) {
+	var v int
+	err := json.Unmarshal(data, &v)
+	return v, err
+}
+
+func TestEncodeDecodeCmd(t *testing.T) {
+	data, err := EncodeCmd(testCodec{}, StateMachineCmd{CmdType: 1, Data: 42})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cmd, err := DecodeCmd(testCodec{}, 1, data)
+	if err != nil || cmd.CmdType != 1 || cmd.Data.(int) != 42 {
+		t.Error("DecodeCmd doesn't decode data encoded by EncodeCmd")
+	}
+
+	if _, err = DecodeCmd(testCodec{}, 2, data); err == nil {
+		t.Error("DecodeCmd should return codec errors")
+	}
+}
+
+func TestEncodeDecodeNoopCmd(t *testing.T) {
+	data, err := EncodeCmd(testCodec{}, StateMachineCmd{CmdType: noopCmdType})
+	if err != nil || data != nil {
+		t.Error("EncodeCmd should not encode any data for no-op cmd")
+	}
+
+	cmd, err := DecodeCmd(testCodec{}, noopCmdType, nil)
+	if err != nil || cmd.CmdType != noopCmdType || cmd.Data != nil {
+		t.Error("DecodeCmd should decode no-op cmd without using the codec")
+	}
+}
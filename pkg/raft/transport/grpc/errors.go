@@ -0,0 +1,84 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/sidecus/raft/pkg/raft"
+	"github.com/sidecus/raft/pkg/raft/transport/grpc/pb"
+)
+
+// raftErrors are raft errors carried across the wire as gRPC status errors, so that the
+// proxying node gets the same error back and callers can use errors.Is
+var raftErrors = []struct {
+	err  error
+	code codes.Code
+}{
+	{raft.ErrorNoLeaderAvailable, codes.Unavailable},
+	{raft.ErrorNoLongerLeader, codes.Aborted},
+	{raft.ErrorLeadershipNotConfirmed, codes.Aborted},
+	{raft.ErrorNodeStopped, codes.Unavailable},
+}
+
+// stateMachineError is a state machine error from a remote node. It keeps the remote message,
+// and unwraps to the error decoded by the codec so that errors.Is works on it
+type stateMachineError struct {
+	msg string
+	err error
+}
+
+func (e *stateMachineError) Error() string { return e.msg }
+func (e *stateMachineError) Unwrap() error { return e.err }
+
+// toStatusError converts raft errors, context errors and state machine errors known to the codec to gRPC status errors.
+// Other errors are returned as is
+func toStatusError(err error, codec raft.ICodec) error {
+	for _, v := range raftErrors {
+		if errors.Is(err, v.err) {
+			return status.Error(v.code, v.err.Error())
+		}
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) {
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	}
+
+	if ec, ok := codec.(raft.IErrorCodec); ok {
+		if code, ok := ec.EncodeError(err); ok {
+			if s, e := status.New(codes.FailedPrecondition, err.Error()).WithDetails(&pb.StateMachineError{Code: code}); e == nil {
+				return s.Err()
+			}
+		}
+	}
+
+	return err
+}
+
+// fromStatusError converts status errors created by toStatusError back to raft errors and state machine errors
+func fromStatusError(err error, codec raft.ICodec) error {
+	s, ok := status.FromError(err)
+	if !ok {
+		return err
+	}
+
+	for _, v := range raftErrors {
+		if s.Code() == v.code && s.Message() == v.err.Error() {
+			return v.err
+		}
+	}
+
+	if ec, ok := codec.(raft.IErrorCodec); ok {
+		for _, d := range s.Details() {
+			if sme, ok := d.(*pb.StateMachineError); ok {
+				if decoded, ok := ec.DecodeError(sme.Code); ok {
+					return &stateMachineError{msg: s.Message(), err: decoded}
+				}
+			}
+		}
+	}
+
+	return err
+}
@@ -0,0 +1,251 @@
+package grpc
+
+import (
+	"github.com/sidecus/raft/pkg/raft"
+	"github.com/sidecus/raft/pkg/raft/transport/grpc/pb"
+)
+
+func toRaftAERequest(req *pb.AppendEntriesRequest, codec raft.ICommandCodec) (*raft.AppendEntriesRequest, error) {
+	entries := make([]raft.LogEntry, len(req.Entries))
+	for i, v := range req.Entries {
+		cmd, err := raft.DecodeCmd(codec, int(v.CmdType), v.Data)
+		if err != nil {
+			return nil, err
+		}
+
+		entries[i] = raft.LogEntry{
+			Index: int(v.Index),
+			Term:  int(v.Term),
+			Cmd:   cmd,
+		}
+	}
+
+	ae := &raft.AppendEntriesRequest{
+		Term:         int(req.Term),
+		LeaderID:     int(req.LeaderID),
+		PrevLogIndex: int(req.PrevLogIndex),
+		PrevLogTerm:  int(req.PrevLogTerm),
+		LeaderCommit: int(req.LeaderCommit),
+		Entries:      entries,
+	}
+
+	return ae, nil
+}
+
+func fromRaftAERequest(req *raft.AppendEntriesRequest, codec raft.ICommandCodec) (*pb.AppendEntriesRequest, error) {
+	entries := make([]*pb.LogEntry, len(req.Entries))
+	for i, v := range req.Entries {
+		data, err := raft.EncodeCmd(codec, v.Cmd)
+		if err != nil {
+			return nil, err
+		}
+
+		entries[i] = &pb.LogEntry{
+			Index:   int64(v.Index),
+			Term:    int64(v.Term),
+			CmdType: int32(v.Cmd.CmdType),
+			Data:    data,
+		}
+	}
+
+	ae := &pb.AppendEntriesRequest{
+		Term:         int64(req.Term),
+		LeaderID:     int64(req.LeaderID),
+		PrevLogIndex: int64(req.PrevLogIndex),
+		PrevLogTerm:  int64(req.PrevLogTerm),
+		LeaderCommit: int64(req.LeaderCommit),
+		Entries:      entries,
+	}
+
+	return ae, nil
+}
+
+func toRaftAEReply(resp *pb.AppendEntriesReply) *raft.AppendEntriesReply {
+	return &raft.AppendEntriesReply{
+		NodeID:    int(resp.NodeID),
+		LeaderID:  int(resp.LeaderID),
+		Term:      int(resp.Term),
+		Success:   resp.Success,
+		LastMatch: int(resp.LastMatch),
+	}
+}
+
+func fromRaftAEReply(resp *raft.AppendEntriesReply) *pb.AppendEntriesReply {
+	return &pb.AppendEntriesReply{
+		Term:      int64(resp.Term),
+		NodeID:    int64(resp.NodeID),
+		LeaderID:  int64(resp.LeaderID),
+		Success:   resp.Success,
+		LastMatch: int64(resp.LastMatch),
+	}
+}
+
+func toRaftRVRequest(req *pb.RequestVoteRequest) *raft.RequestVoteRequest {
+	return &raft.RequestVoteRequest{
+		Term:         int(req.Term),
+		CandidateID:  int(req.CandidateID),
+		LastLogIndex: int(req.LastLogIndex),
+		LastLogTerm:  int(req.LastLogTerm),
+	}
+}
+
+func fromRaftRVRequest(req *raft.RequestVoteRequest) *pb.RequestVoteRequest {
+	return &pb.RequestVoteRequest{
+		Term:         int64(req.Term),
+		CandidateID:  int64(req.CandidateID),
+		LastLogIndex: int64(req.LastLogIndex),
+		LastLogTerm:  int64(req.LastLogTerm),
+	}
+}
+
+func toRaftRVReply(resp *pb.RequestVoteReply) *raft.RequestVoteReply {
+	return &raft.RequestVoteReply{
+		NodeID:      int(resp.NodeID),
+		Term:        int(resp.Term),
+		VotedTerm:   int(resp.VotedTerm),
+		VoteGranted: resp.VoteGranted,
+	}
+}
+
+func fromRaftRVReply(resp *raft.RequestVoteReply) *pb.RequestVoteReply {
+	return &pb.RequestVoteReply{
+		NodeID:      int64(resp.NodeID),
+		Term:        int64(resp.Term),
+		VotedTerm:   int64(resp.VotedTerm),
+		VoteGranted: resp.VoteGranted,
+	}
+}
+
+func toRaftTimeoutNowRequest(req *pb.TimeoutNowRequest) *raft.TimeoutNowRequest {
+	return &raft.TimeoutNowRequest{
+		Term:     int(req.Term),
+		LeaderID: int(req.LeaderID),
+	}
+}
+
+func fromRaftTimeoutNowRequest(req *raft.TimeoutNowRequest) *pb.TimeoutNowRequest {
+	return &pb.TimeoutNowRequest{
+		Term:     int64(req.Term),
+		LeaderID: int64(req.LeaderID),
+	}
+}
+
+func toRaftTimeoutNowReply(resp *pb.TimeoutNowReply) *raft.TimeoutNowReply {
+	return &raft.TimeoutNowReply{
+		NodeID: int(resp.NodeID),
+		Term:   int(resp.Term),
+	}
+}
+
+func fromRaftTimeoutNowReply(resp *raft.TimeoutNowReply) *pb.TimeoutNowReply {
+	return &pb.TimeoutNowReply{
+		NodeID: int64(resp.NodeID),
+		Term:   int64(resp.Term),
+	}
+}
+
+// Converts a gRPC snapshot request to our own format
+// Snapshot file is left blank, need to be filled by the caller
+func toRaftSnapshotRequestHeader(req *pb.SnapshotRequest) *raft.SnapshotRequestHeader {
+	return &raft.SnapshotRequestHeader{
+		Term:          int(req.Term),
+		LeaderID:      int(req.LeaderID),
+		SnapshotIndex: int(req.SnapshotIndex),
+		SnapshotTerm:  int(req.SnapshotTerm),
+	}
+}
+
+// This initiazes a gRPC snapshot request from the raft request.
+// Note it doesn't fill in the data part - that should be done by the proxy by streaming req.File
+func fromRaftSnapshotRequestHeader(req *raft.SnapshotRequestHeader) *pb.SnapshotRequest {
+	return &pb.SnapshotRequest{
+		Term:          int64(req.Term),
+		LeaderID:      int64(req.LeaderID),
+		SnapshotIndex: int64(req.SnapshotIndex),
+		SnapshotTerm:  int64(req.SnapshotTerm),
+	}
+}
+
+func toRaftExecuteRequest(req *pb.ExecuteRequest, codec raft.ICommandCodec) (*raft.StateMachineCmd, error) {
+	cmd, err := raft.DecodeCmd(codec, int(req.CmdType), req.Data)
+	if err != nil {
+		return nil, err
+	}
+	return &cmd, nil
+}
+
+func fromRaftExecuteRequest(cmd *raft.StateMachineCmd, codec raft.ICommandCodec) (*pb.ExecuteRequest, error) {
+	data, err := raft.EncodeCmd(codec, *cmd)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.ExecuteRequest{CmdType: int32(cmd.CmdType), Data: data}, nil
+}
+
+// cmdType is from the original request, used by the codec to decode the result
+func toRaftExecuteReply(resp *pb.ExecuteReply, cmdType int, codec raft.ICommandCodec) (*raft.ExecuteReply, error) {
+	reply := &raft.ExecuteReply{
+		NodeID:  int(resp.NodeID),
+		Success: resp.Success,
+	}
+
+	if len(resp.Result) > 0 {
+		result, err := codec.DecodeCmdResult(cmdType, resp.Result)
+		if err != nil {
+			return nil, err
+		}
+		reply.Result = result
+	}
+
+	return reply, nil
+}
+
+func fromRaftExecuteReply(resp *raft.ExecuteReply, cmdType int, codec raft.ICommandCodec) (*pb.ExecuteReply, error) {
+	reply := &pb.ExecuteReply{
+		NodeID:  int64(resp.NodeID),
+		Success: resp.Success,
+	}
+
+	if resp.Result != nil {
+		result, err := codec.EncodeCmdResult(cmdType, resp.Result)
+		if err != nil {
+			return nil, err
+		}
+		reply.Result = result
+	}
+
+	return reply, nil
+}
+
+func toRaftGetRequest(req *pb.GetRequest, codec raft.IQueryCodec) (*raft.GetRequest, error) {
+	params, err := codec.DecodeParams(req.Params)
+	if err != nil {
+		return nil, err
+	}
+	return &raft.GetRequest{Params: params}, nil
+}
+
+func fromRaftGetRequest(req *raft.GetRequest, codec raft.IQueryCodec) (*pb.GetRequest, error) {
+	params, err := codec.EncodeParams(req.Params)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.GetRequest{Params: params}, nil
+}
+
+// params are from the original request, used by the codec to decode the result
+func toRaftGetReply(resp *pb.GetReply, params []interface{}, codec raft.IQueryCodec) (*raft.GetReply, error) {
+	data, err := codec.DecodeResult(params, resp.Data)
+	if err != nil {
+		return nil, err
+	}
+	return &raft.GetReply{NodeID: int(resp.NodeID), Data: data}, nil
+}
+
+func fromRaftGetReply(resp *raft.GetReply, params []interface{}, codec raft.IQueryCodec) (*pb.GetReply, error) {
+	data, err := codec.EncodeResult(params, resp.Data)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.GetReply{NodeID: int64(resp.NodeID), Data: data}, nil
+}
@@ -0,0 +1,332 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.2.0
+// - protoc             v3.14.0
+// source: pb/raft.proto
+
+package pb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+// RaftTransportClient is the client API for RaftTransport service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type RaftTransportClient interface {
+	// AppendEntries
+	AppendEntries(ctx context.Context, in *AppendEntriesRequest, opts ...grpc.CallOption) (*AppendEntriesReply, error)
+	// RequestVote
+	RequestVote(ctx context.Context, in *RequestVoteRequest, opts ...grpc.CallOption) (*RequestVoteReply, error)
+	// InstallSnapshot - note we are returning AppendEntriesReply since this is a special kind of AppendEntries
+	InstallSnapshot(ctx context.Context, opts ...grpc.CallOption) (RaftTransport_InstallSnapshotClient, error)
+	// TimeoutNow asks a follower to start an election right away, used by the leader to transfer leadership
+	TimeoutNow(ctx context.Context, in *TimeoutNowRequest, opts ...grpc.CallOption) (*TimeoutNowReply, error)
+	// Execute runs a state machine command, used by followers to proxy writes to the leader
+	Execute(ctx context.Context, in *ExecuteRequest, opts ...grpc.CallOption) (*ExecuteReply, error)
+	// Get reads from the state machine, used by followers to proxy reads to the leader
+	Get(ctx context.Context, in *GetRequest, opts ...grpc.CallOption) (*GetReply, error)
+}
+
+type raftTransportClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewRaftTransportClient(cc grpc.ClientConnInterface) RaftTransportClient {
+	return &raftTransportClient{cc}
+}
+
+func (c *raftTransportClient) AppendEntries(ctx context.Context, in *AppendEntriesRequest, opts ...grpc.CallOption) (*AppendEntriesReply, error) {
+	out := new(AppendEntriesReply)
+	err := c.cc.Invoke(ctx, "/raft.RaftTransport/AppendEntries", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *raftTransportClient) RequestVote(ctx context.Context, in *RequestVoteRequest, opts ...grpc.CallOption) (*RequestVoteReply, error) {
+	out := new(RequestVoteReply)
+	err := c.cc.Invoke(ctx, "/raft.RaftTransport/RequestVote", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *raftTransportClient) InstallSnapshot(ctx context.Context, opts ...grpc.CallOption) (RaftTransport_InstallSnapshotClient, error) {
+	stream, err := c.cc.NewStream(ctx, &RaftTransport_ServiceDesc.Streams[0], "/raft.RaftTransport/InstallSnapshot", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &raftTransportInstallSnapshotClient{stream}
+	return x, nil
+}
+
+type RaftTransport_InstallSnapshotClient interface {
+	Send(*SnapshotRequest) error
+	CloseAndRecv() (*AppendEntriesReply, error)
+	grpc.ClientStream
+}
+
+type raftTransportInstallSnapshotClient struct {
+	grpc.ClientStream
+}
+
+func (x *raftTransportInstallSnapshotClient) Send(m *SnapshotRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *raftTransportInstallSnapshotClient) CloseAndRecv() (*AppendEntriesReply, error) {
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	m := new(AppendEntriesReply)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *raftTransportClient) TimeoutNow(ctx context.Context, in *TimeoutNowRequest, opts ...grpc.CallOption) (*TimeoutNowReply, error) {
+	out := new(TimeoutNowReply)
+	err := c.cc.Invoke(ctx, "/raft.RaftTransport/TimeoutNow", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *raftTransportClient) Execute(ctx context.Context, in *ExecuteRequest, opts ...grpc.CallOption) (*ExecuteReply, error) {
+	out := new(ExecuteReply)
+	err := c.cc.Invoke(ctx, "/raft.RaftTransport/Execute", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *raftTransportClient) Get(ctx context.Context, in *GetRequest, opts ...grpc.CallOption) (*GetReply, error) {
+	out := new(GetReply)
+	err := c.cc.Invoke(ctx, "/raft.RaftTransport/Get", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// RaftTransportServer is the server API for RaftTransport service.
+// All implementations must embed UnimplementedRaftTransportServer
+// for forward compatibility
+type RaftTransportServer interface {
+	// AppendEntries
+	AppendEntries(context.Context, *AppendEntriesRequest) (*AppendEntriesReply, error)
+	// RequestVote
+	RequestVote(context.Context, *RequestVoteRequest) (*RequestVoteReply, error)
+	// InstallSnapshot - note we are returning AppendEntriesReply since this is a special kind of AppendEntries
+	InstallSnapshot(RaftTransport_InstallSnapshotServer) error
+	// TimeoutNow asks a follower to start an election right away, used by the leader to transfer leadership
+	TimeoutNow(context.Context, *TimeoutNowRequest) (*TimeoutNowReply, error)
+	// Execute runs a state machine command, used by followers to proxy writes to the leader
+	Execute(context.Context, *ExecuteRequest) (*ExecuteReply, error)
+	// Get reads from the state machine, used by followers to proxy reads to the leader
+	Get(context.Context, *GetRequest) (*GetReply, error)
+	mustEmbedUnimplementedRaftTransportServer()
+}
+
+// UnimplementedRaftTransportServer must be embedded to have forward compatible implementations.
+type UnimplementedRaftTransportServer struct {
+}
+
+func (UnimplementedRaftTransportServer) AppendEntries(context.Context, *AppendEntriesRequest) (*AppendEntriesReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AppendEntries not implemented")
+}
+func (UnimplementedRaftTransportServer) RequestVote(context.Context, *RequestVoteRequest) (*RequestVoteReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RequestVote not implemented")
+}
+func (UnimplementedRaftTransportServer) InstallSnapshot(RaftTransport_InstallSnapshotServer) error {
+	return status.Errorf(codes.Unimplemented, "method InstallSnapshot not implemented")
+}
+func (UnimplementedRaftTransportServer) TimeoutNow(context.Context, *TimeoutNowRequest) (*TimeoutNowReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method TimeoutNow not implemented")
+}
+func (UnimplementedRaftTransportServer) Execute(context.Context, *ExecuteRequest) (*ExecuteReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Execute not implemented")
+}
+func (UnimplementedRaftTransportServer) Get(context.Context, *GetRequest) (*GetReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Get not implemented")
+}
+func (UnimplementedRaftTransportServer) mustEmbedUnimplementedRaftTransportServer() {}
+
+// UnsafeRaftTransportServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to RaftTransportServer will
+// result in compilation errors.
+type UnsafeRaftTransportServer interface {
+	mustEmbedUnimplementedRaftTransportServer()
+}
+
+func RegisterRaftTransportServer(s grpc.ServiceRegistrar, srv RaftTransportServer) {
+	s.RegisterService(&RaftTransport_ServiceDesc, srv)
+}
+
+func _RaftTransport_AppendEntries_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AppendEntriesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RaftTransportServer).AppendEntries(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/raft.RaftTransport/AppendEntries",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RaftTransportServer).AppendEntries(ctx, req.(*AppendEntriesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RaftTransport_RequestVote_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RequestVoteRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RaftTransportServer).RequestVote(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/raft.RaftTransport/RequestVote",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RaftTransportServer).RequestVote(ctx, req.(*RequestVoteRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RaftTransport_InstallSnapshot_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(RaftTransportServer).InstallSnapshot(&raftTransportInstallSnapshotServer{stream})
+}
+
+type RaftTransport_InstallSnapshotServer interface {
+	SendAndClose(*AppendEntriesReply) error
+	Recv() (*SnapshotRequest, error)
+	grpc.ServerStream
+}
+
+type raftTransportInstallSnapshotServer struct {
+	grpc.ServerStream
+}
+
+func (x *raftTransportInstallSnapshotServer) SendAndClose(m *AppendEntriesReply) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *raftTransportInstallSnapshotServer) Recv() (*SnapshotRequest, error) {
+	m := new(SnapshotRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _RaftTransport_TimeoutNow_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TimeoutNowRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RaftTransportServer).TimeoutNow(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/raft.RaftTransport/TimeoutNow",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RaftTransportServer).TimeoutNow(ctx, req.(*TimeoutNowRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RaftTransport_Execute_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ExecuteRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RaftTransportServer).Execute(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/raft.RaftTransport/Execute",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RaftTransportServer).Execute(ctx, req.(*ExecuteRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RaftTransport_Get_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RaftTransportServer).Get(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/raft.RaftTransport/Get",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RaftTransportServer).Get(ctx, req.(*GetRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// RaftTransport_ServiceDesc is the grpc.ServiceDesc for RaftTransport service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var RaftTransport_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "raft.RaftTransport",
+	HandlerType: (*RaftTransportServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "AppendEntries",
+			Handler:    _RaftTransport_AppendEntries_Handler,
+		},
+		{
+			MethodName: "RequestVote",
+			Handler:    _RaftTransport_RequestVote_Handler,
+		},
+		{
+			MethodName: "TimeoutNow",
+			Handler:    _RaftTransport_TimeoutNow_Handler,
+		},
+		{
+			MethodName: "Execute",
+			Handler:    _RaftTransport_Execute_Handler,
+		},
+		{
+			MethodName: "Get",
+			Handler:    _RaftTransport_Get_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "InstallSnapshot",
+			Handler:       _RaftTransport_InstallSnapshot_Handler,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "pb/raft.proto",
+}
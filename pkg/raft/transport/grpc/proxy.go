@@ -0,0 +1,133 @@
+package grpc
+
+import (
+	"context"
+
+	"github.com/sidecus/raft/pkg/raft"
+	"github.com/sidecus/raft/pkg/raft/transport/grpc/pb"
+	"github.com/sidecus/raft/pkg/trace"
+	"github.com/sidecus/raft/pkg/util"
+	"google.golang.org/grpc"
+)
+
+// proxyFactory creates gRPC peer proxies, implementing raft.IPeerProxyFactory
+type proxyFactory struct {
+	codec raft.ICodec
+}
+
+// NewProxyFactory creates a proxy factory for raft nodes to talk to each other over gRPC.
+// codec is the state machine's codec used to encode commands and reads
+func NewProxyFactory(codec raft.ICodec) raft.IPeerProxyFactory {
+	return &proxyFactory{codec: codec}
+}
+
+// NewPeerProxy factory method to create a new proxy
+func (f *proxyFactory) NewPeerProxy(info raft.NodeInfo) raft.IPeerProxy {
+	conn, err := grpc.Dial(info.Endpoint, grpc.WithInsecure())
+	if err != nil {
+		// Our RPC connection is nonblocking so should not be expecting an error here
+		util.Panicln(err)
+	}
+
+	return &proxy{
+		codec:     f.codec,
+		rpcClient: pb.NewRaftTransportClient(conn),
+	}
+}
+
+// proxy talks to one peer's RaftTransport service, implementing raft.IPeerProxy
+type proxy struct {
+	codec     raft.ICodec
+	rpcClient pb.RaftTransportClient
+}
+
+// AppendEntries sends AE request to one single node
+func (p *proxy) AppendEntries(ctx context.Context, req *raft.AppendEntriesRequest) (*raft.AppendEntriesReply, error) {
+	ae, err := fromRaftAERequest(req, p.codec)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := p.rpcClient.AppendEntries(trace.OutgoingContext(ctx), ae)
+	if err != nil {
+		return nil, err
+	}
+
+	return toRaftAEReply(resp), nil
+}
+
+// RequestVote handles raft RPC RV calls to a given node
+func (p *proxy) RequestVote(ctx context.Context, req *raft.RequestVoteRequest) (*raft.RequestVoteReply, error) {
+	resp, err := p.rpcClient.RequestVote(ctx, fromRaftRVRequest(req))
+	if err != nil {
+		return nil, err
+	}
+
+	return toRaftRVReply(resp), nil
+}
+
+// TimeoutNow asks the peer to start an election right away
+func (p *proxy) TimeoutNow(ctx context.Context, req *raft.TimeoutNowRequest) (*raft.TimeoutNowReply, error) {
+	resp, err := p.rpcClient.TimeoutNow(ctx, fromRaftTimeoutNowRequest(req))
+	if err != nil {
+		return nil, fromStatusError(err, p.codec)
+	}
+
+	return toRaftTimeoutNowReply(resp), nil
+}
+
+// InstallSnapshot takes snapshot request (with snapshotfile) and streams it to the remote peer
+func (p *proxy) InstallSnapshot(ctx context.Context, req *raft.SnapshotRequest) (*raft.AppendEntriesReply, error) {
+	// Create gRPC stream writer
+	stream, err := p.rpcClient.InstallSnapshot(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	writer := raft.NewSnapshotStreamWriter(&req.SnapshotRequestHeader, func(header *raft.SnapshotRequestHeader, data []byte) error {
+		sr := fromRaftSnapshotRequestHeader(header)
+		sr.Data = data
+		return stream.Send(sr)
+	})
+
+	// Send snapshot content
+	raft.SendSnapshot(req.File, writer)
+
+	// Close and reply
+	resp, err := stream.CloseAndRecv()
+	if err != nil {
+		return nil, err
+	}
+
+	return toRaftAEReply(resp), nil
+}
+
+// Get gets values from state machine against leader
+func (p *proxy) Get(ctx context.Context, req *raft.GetRequest) (*raft.GetReply, error) {
+	gr, err := fromRaftGetRequest(req, p.codec)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := p.rpcClient.Get(ctx, gr)
+	if err != nil {
+		return nil, fromStatusError(err, p.codec)
+	}
+
+	return toRaftGetReply(resp, req.Params, p.codec)
+}
+
+// Execute runs a command via the leader
+func (p *proxy) Execute(ctx context.Context, cmd *raft.StateMachineCmd) (*raft.ExecuteReply, error) {
+	er, err := fromRaftExecuteRequest(cmd, p.codec)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := p.rpcClient.Execute(trace.OutgoingContext(ctx), er)
+	if err != nil {
+		return nil, fromStatusError(err, p.codec)
+	}
+
+	return toRaftExecuteReply(resp, cmd.CmdType, p.codec)
+}
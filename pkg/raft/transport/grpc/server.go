@@ -0,0 +1,132 @@
+package grpc
+
+import (
+	"context"
+	"time"
+
+	"github.com/sidecus/raft/pkg/raft"
+	"github.com/sidecus/raft/pkg/raft/transport/grpc/pb"
+	"google.golang.org/grpc"
+)
+
+const snapshotInstallTimeout = 500 * time.Millisecond
+
+// Server serves the RaftTransport service for any raft.INode
+type Server struct {
+	node  raft.INode
+	codec raft.ICodec
+	pb.UnimplementedRaftTransportServer
+}
+
+// NewServer creates a transport server for the node. codec is the state machine's codec
+func NewServer(node raft.INode, codec raft.ICodec) *Server {
+	return &Server{
+		node:  node,
+		codec: codec,
+	}
+}
+
+// Register registers the RaftTransport service on a gRPC server,
+// so that it can share the same server (and port) with the state machine's own services
+func (s *Server) Register(server *grpc.Server) {
+	pb.RegisterRaftTransportServer(server, s)
+}
+
+// AppendEntries implements pb.RaftTransportServer.AppendEntries
+func (s *Server) AppendEntries(ctx context.Context, req *pb.AppendEntriesRequest) (*pb.AppendEntriesReply, error) {
+	ae, err := toRaftAERequest(req, s.codec)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := s.node.AppendEntries(ctx, ae)
+	if err != nil {
+		return nil, err
+	}
+
+	return fromRaftAEReply(resp), nil
+}
+
+// RequestVote implements pb.RaftTransportServer.RequestVote
+func (s *Server) RequestVote(ctx context.Context, req *pb.RequestVoteRequest) (*pb.RequestVoteReply, error) {
+	resp, err := s.node.RequestVote(ctx, toRaftRVRequest(req))
+	if err != nil {
+		return nil, err
+	}
+
+	return fromRaftRVReply(resp), nil
+}
+
+// TimeoutNow implements pb.RaftTransportServer.TimeoutNow
+func (s *Server) TimeoutNow(ctx context.Context, req *pb.TimeoutNowRequest) (*pb.TimeoutNowReply, error) {
+	resp, err := s.node.TimeoutNow(ctx, toRaftTimeoutNowRequest(req))
+	if err != nil {
+		return nil, toStatusError(err, s.codec)
+	}
+
+	return fromRaftTimeoutNowReply(resp), nil
+}
+
+// InstallSnapshot receives and installs snapshot on current node
+func (s *Server) InstallSnapshot(stream pb.RaftTransport_InstallSnapshotServer) error {
+	// Create snapshot reader over grpc
+	recvFunc := func() (*raft.SnapshotRequestHeader, []byte, error) {
+		pbReq, err := stream.Recv()
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return toRaftSnapshotRequestHeader(pbReq), pbReq.Data, nil
+	}
+	reader, err := raft.NewSnapshotStreamReader(recvFunc, s.node.OnSnapshotPart)
+	if err != nil {
+		return err
+	}
+
+	// receive snapshot to file
+	req, err := raft.ReceiveSnapshot(s.node.NodeID(), reader)
+	if err != nil {
+		return err
+	}
+
+	// Install and reply
+	ctx, cancel := context.WithTimeout(context.Background(), snapshotInstallTimeout)
+	defer cancel()
+
+	reply, err := s.node.InstallSnapshot(ctx, req)
+	if err != nil {
+		return err
+	}
+
+	return stream.SendAndClose(fromRaftAEReply(reply))
+}
+
+// Execute implements pb.RaftTransportServer.Execute
+func (s *Server) Execute(ctx context.Context, req *pb.ExecuteRequest) (*pb.ExecuteReply, error) {
+	cmd, err := toRaftExecuteRequest(req, s.codec)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := s.node.Execute(ctx, cmd)
+	if err != nil {
+		return nil, toStatusError(err, s.codec)
+	}
+
+	return fromRaftExecuteReply(resp, cmd.CmdType, s.codec)
+}
+
+// Get implements pb.RaftTransportServer.Get
+func (s *Server) Get(ctx context.Context, req *pb.GetRequest) (*pb.GetReply, error) {
+	gr, err := toRaftGetRequest(req, s.codec)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := s.node.Get(ctx, gr)
+	if err != nil {
+		return nil, toStatusError(err, s.codec)
+	}
+
+	return fromRaftGetReply(resp, gr.Params, s.codec)
+}
@@ -1,4 +1,4 @@
-package rkv
+package grpc
 
 import (
 	"context"
@@ -6,7 +6,7 @@ import (
 	"io"
 	"time"
 
-	"github.com/sidecus/raft/pkg/rkv/pb"
+	"github.com/sidecus/raft/pkg/raft/transport/grpc/pb"
 	"google.golang.org/grpc/metadata"
 )
 
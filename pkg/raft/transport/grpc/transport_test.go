@@ -0,0 +1,298 @@
+package grpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sidecus/raft/pkg/raft"
+	"google.golang.org/grpc"
+)
+
+var errTestNotFound = errors.New("not found")
+
+// testCodec encodes string cmd data, string params and string results, and carries errTestNotFound
+type testCodec struct{}
+
+func (c testCodec) EncodeError(err error) (string, bool) {
+	return "NOT_FOUND", errors.Is(err, errTestNotFound)
+}
+
+func (c testCodec) DecodeError(code string) (error, bool) {
+	return errTestNotFound, code == "NOT_FOUND"
+}
+
+func (c testCodec) Encode(cmdType int, data interface{}) ([]byte, error) {
+	return json.Marshal(data)
+}
+
+func (c testCodec) Decode(cmdType int, data []byte) (interface{}, error) {
+	var v string
+	err := json.Unmarshal(data, &v)
+	return v, err
+}
+
+func (c testCodec) EncodeCmdResult(cmdType int, result interface{}) ([]byte, error) {
+	return json.Marshal(result)
+}
+
+func (c testCodec) DecodeCmdResult(cmdType int, data []byte) (interface{}, error) {
+	var v string
+	err := json.Unmarshal(data, &v)
+	return v, err
+}
+
+func (c testCodec) EncodeParams(params []interface{}) ([]byte, error) {
+	return json.Marshal(params)
+}
+
+func (c testCodec) DecodeParams(data []byte) ([]interface{}, error) {
+	var params []interface{}
+	err := json.Unmarshal(data, &params)
+	return params, err
+}
+
+func (c testCodec) EncodeResult(params []interface{}, result interface{}) ([]byte, error) {
+	return json.Marshal(result)
+}
+
+func (c testCodec) DecodeResult(params []interface{}, data []byte) (interface{}, error) {
+	var v string
+	err := json.Unmarshal(data, &v)
+	return v, err
+}
+
+// testNode records requests it receives and replies with canned results
+type testNode struct {
+	ae  *raft.AppendEntriesRequest
+	rv  *raft.RequestVoteRequest
+	tn  *raft.TimeoutNowRequest
+	cmd *raft.StateMachineCmd
+
+	snapshot *raft.SnapshotRequest
+}
+
+func (n *testNode) Start()        {}
+func (n *testNode) Stop()         {}
+func (n *testNode) NodeID() int   { return 1 }
+func (n *testNode) LeaderID() int { return 1 }
+func (n *testNode) Status() raft.NodeStatus {
+	return raft.NodeStatus{NodeID: 1, LeaderID: 1}
+}
+func (n *testNode) TransferLeadership(ctx context.Context) error {
+	return nil
+}
+func (n *testNode) OnSnapshotPart(part *raft.SnapshotRequestHeader) bool {
+	return true
+}
+func (n *testNode) AppendEntries(ctx context.Context, req *raft.AppendEntriesRequest) (*raft.AppendEntriesReply, error) {
+	n.ae = req
+	return &raft.AppendEntriesReply{NodeID: 1, LeaderID: req.LeaderID, Term: req.Term, Success: true, LastMatch: 6}, nil
+}
+func (n *testNode) RequestVote(ctx context.Context, req *raft.RequestVoteRequest) (*raft.RequestVoteReply, error) {
+	n.rv = req
+	return &raft.RequestVoteReply{NodeID: 1, Term: req.Term, VotedTerm: req.Term, VoteGranted: true}, nil
+}
+func (n *testNode) TimeoutNow(ctx context.Context, req *raft.TimeoutNowRequest) (*raft.TimeoutNowReply, error) {
+	n.tn = req
+	if req.Term < 0 {
+		return nil, raft.ErrorNodeStopped
+	}
+	return &raft.TimeoutNowReply{NodeID: 1, Term: req.Term}, nil
+}
+func (n *testNode) InstallSnapshot(ctx context.Context, req *raft.SnapshotRequest) (*raft.AppendEntriesReply, error) {
+	n.snapshot = req
+	return &raft.AppendEntriesReply{NodeID: 1, Term: req.Term, Success: true, LastMatch: req.SnapshotIndex}, nil
+}
+func (n *testNode) Get(ctx context.Context, req *raft.GetRequest) (*raft.GetReply, error) {
+	switch req.Params[0].(string) {
+	case "missing":
+		return nil, fmt.Errorf("key missing: %w", errTestNotFound)
+	case "failed":
+		return nil, errors.New("failed")
+	case "noleader":
+		return nil, fmt.Errorf("proxy: %w", raft.ErrorNoLeaderAvailable)
+	}
+	return &raft.GetReply{NodeID: 1, Data: "value of " + req.Params[0].(string)}, nil
+}
+func (n *testNode) Execute(ctx context.Context, cmd *raft.StateMachineCmd) (*raft.ExecuteReply, error) {
+	n.cmd = cmd
+	return &raft.ExecuteReply{NodeID: 1, Success: true, Result: "result of " + cmd.Data.(string)}, nil
+}
+
+// startTestServer serves the transport for node on a random local port and returns a proxy to it
+func startTestServer(t *testing.T, node raft.INode) (raft.IPeerProxy, func()) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := grpc.NewServer()
+	NewServer(node, testCodec{}).Register(server)
+	go server.Serve(lis)
+
+	proxy := NewProxyFactory(testCodec{}).NewPeerProxy(raft.NodeInfo{NodeID: 1, Endpoint: lis.Addr().String()})
+	return proxy, server.Stop
+}
+
+func TestAppendEntries(t *testing.T) {
+	node := &testNode{}
+	proxy, stop := startTestServer(t, node)
+	defer stop()
+
+	req := &raft.AppendEntriesRequest{
+		Term:         3,
+		LeaderID:     0,
+		PrevLogIndex: 4,
+		PrevLogTerm:  2,
+		LeaderCommit: 4,
+		Entries: []raft.LogEntry{
+			{Index: 5, Term: 3, Cmd: raft.StateMachineCmd{CmdType: 1, Data: "a"}},
+			{Index: 6, Term: 3, Cmd: raft.StateMachineCmd{CmdType: 2, Data: "b"}},
+		},
+	}
+
+	reply, err := proxy.AppendEntries(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reply.Success || reply.NodeID != 1 || reply.Term != 3 || reply.LastMatch != 6 {
+		t.Error("AppendEntries returns wrong reply")
+	}
+
+	got := node.ae
+	if got.Term != req.Term || got.LeaderID != req.LeaderID || got.PrevLogIndex != req.PrevLogIndex ||
+		got.PrevLogTerm != req.PrevLogTerm || got.LeaderCommit != req.LeaderCommit || len(got.Entries) != len(req.Entries) {
+		t.Fatal("AppendEntries delivers different request")
+	}
+	for i, v := range got.Entries {
+		if v != req.Entries[i] {
+			t.Errorf("AppendEntries delivers different entry at %d", i)
+		}
+	}
+}
+
+func TestRequestVote(t *testing.T) {
+	node := &testNode{}
+	proxy, stop := startTestServer(t, node)
+	defer stop()
+
+	req := &raft.RequestVoteRequest{Term: 5, CandidateID: 2, LastLogIndex: 10, LastLogTerm: 4}
+	reply, err := proxy.RequestVote(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reply.VoteGranted || reply.NodeID != 1 || reply.Term != 5 || reply.VotedTerm != 5 {
+		t.Error("RequestVote returns wrong reply")
+	}
+	if *node.rv != *req {
+		t.Error("RequestVote delivers different request")
+	}
+}
+
+func TestTimeoutNow(t *testing.T) {
+	node := &testNode{}
+	proxy, stop := startTestServer(t, node)
+	defer stop()
+
+	req := &raft.TimeoutNowRequest{Term: 5, LeaderID: 2}
+	reply, err := proxy.TimeoutNow(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reply.NodeID != 1 || reply.Term != 5 || *node.tn != *req {
+		t.Error("TimeoutNow returns wrong reply or delivers different request")
+	}
+
+	// stopped node error is carried across the wire
+	if _, err = proxy.TimeoutNow(context.Background(), &raft.TimeoutNowRequest{Term: -1}); !errors.Is(err, raft.ErrorNodeStopped) {
+		t.Error("TimeoutNow should return ErrorNodeStopped from a stopped node")
+	}
+}
+
+func TestExecute(t *testing.T) {
+	node := &testNode{}
+	proxy, stop := startTestServer(t, node)
+	defer stop()
+
+	reply, err := proxy.Execute(context.Background(), &raft.StateMachineCmd{CmdType: 1, Data: "a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reply.Success || reply.NodeID != 1 || reply.Result.(string) != "result of a" {
+		t.Error("Execute returns wrong reply")
+	}
+	if node.cmd.CmdType != 1 || node.cmd.Data.(string) != "a" {
+		t.Error("Execute delivers different cmd")
+	}
+}
+
+func TestGet(t *testing.T) {
+	proxy, stop := startTestServer(t, &testNode{})
+	defer stop()
+
+	reply, err := proxy.Get(context.Background(), &raft.GetRequest{Params: []interface{}{"a"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reply.NodeID != 1 || reply.Data.(string) != "value of a" {
+		t.Error("Get returns wrong reply")
+	}
+
+	if _, err = proxy.Get(context.Background(), &raft.GetRequest{Params: []interface{}{"failed"}}); err == nil {
+		t.Error("Get should return errors from the remote node")
+	}
+	_, err = proxy.Get(context.Background(), &raft.GetRequest{Params: []interface{}{"missing"}})
+	if !errors.Is(err, errTestNotFound) || err.Error() != "key missing: not found" {
+		t.Error("Get should return state machine errors known to the codec with errors.Is working on them", err)
+	}
+	if _, err = proxy.Get(context.Background(), &raft.GetRequest{Params: []interface{}{"noleader"}}); err != raft.ErrorNoLeaderAvailable {
+		t.Error("Get should return raft errors from the remote node as is")
+	}
+}
+
+func TestInstallSnapshot(t *testing.T) {
+	dir, err := ioutil.TempDir("", "transport")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	raft.SetSnapshotPath(dir)
+
+	content := []byte("snapshot content")
+	file := filepath.Join(dir, "leader.rkvsnapshot")
+	if err = ioutil.WriteFile(file, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	node := &testNode{}
+	proxy, stop := startTestServer(t, node)
+	defer stop()
+
+	req := &raft.SnapshotRequest{
+		SnapshotRequestHeader: raft.SnapshotRequestHeader{Term: 3, LeaderID: 0, SnapshotIndex: 20, SnapshotTerm: 2},
+		File:                  file,
+	}
+	reply, err := proxy.InstallSnapshot(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reply.Success || reply.LastMatch != 20 {
+		t.Error("InstallSnapshot returns wrong reply")
+	}
+
+	got := node.snapshot
+	if got.SnapshotRequestHeader != req.SnapshotRequestHeader || got.File == file {
+		t.Fatal("InstallSnapshot delivers wrong request")
+	}
+	received, err := ioutil.ReadFile(got.File)
+	if err != nil || string(received) != string(content) {
+		t.Error("InstallSnapshot delivers different snapshot content")
+	}
+}
@@ -0,0 +1,631 @@
+// Package client is a Go client for rkv clusters, built on the v2 API.
+// It discovers and caches the leader, retries on leader changes and unreachable nodes with backoff,
+// and optionally load balances reads across all nodes when stale reads are allowed.
+// Writes are only retried when the request is known not to be processed, since nodes don't dedupe requests
+
+package client
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	"github.com/sidecus/raft/pkg/rkv/pbv2"
+)
+
+// ErrKeyNotFound is returned by Get when the key doesn't exist
+var ErrKeyNotFound = errors.New("key not found")
+
+// ErrTimeout is returned when a request times out on the server. Writes might still be committed later so they are not retried
+var ErrTimeout = errors.New("request timed out")
+
+// ErrUnknownResult is returned when a write was sent but no reply came back, e.g. the connection broke.
+// The write might still be applied, so it's not retried
+var ErrUnknownResult = errors.New("write result unknown")
+
+// ErrNoLeader is returned when no leader can be found after all retries
+var ErrNoLeader = errors.New("no leader available")
+
+// ErrLeaseNotFound is returned when the lease doesn't exist, e.g. it has expired
+var ErrLeaseNotFound = errors.New("lease not found")
+
+// ErrCompacted is returned by Watch when the revision to start or resume from is no longer available
+var ErrCompacted = errors.New("revision compacted")
+
+var errorNoEndpoints = errors.New("at least one endpoint is required")
+
+// shuttingDownMessage is the message of the Unavailable status returned by nodes shutting down, before processing requests
+const shuttingDownMessage = "node is shutting down"
+
+const defaultMaxRetries = 5
+const defaultBackoff = 50 * time.Millisecond
+const defaultMaxBackoff = time.Second
+
+// Options configures a Client
+type Options struct {
+	// Endpoints are the addresses of all nodes in the cluster, ordered by node ID
+	Endpoints []string
+	// StaleReads load balances reads across all nodes. Such reads are served from local node state and might be stale
+	StaleReads bool
+	// MaxRetries is how many times a request is retried on leader changes or unreachable nodes. Default 5
+	MaxRetries int
+	// Backoff is the initial wait before retrying, doubled on each retry up to MaxBackoff. Default 50ms and 1s
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+}
+
+// Client talks to an rkv cluster. It is concurrency safe
+type Client struct {
+	opts    Options
+	conns   []*grpc.ClientConn
+	clients []pbv2.KVStoreClient
+
+	mu       sync.Mutex
+	leader   int // index of the cached leader in endpoints, -1 if unknown
+	nextNode int // next node to try for round robin
+}
+
+// New creates a client for the cluster. Connections are established lazily
+func New(opts Options) (*Client, error) {
+	if len(opts.Endpoints) == 0 {
+		return nil, errorNoEndpoints
+	}
+	if opts.MaxRetries <= 0 {
+		opts.MaxRetries = defaultMaxRetries
+	}
+	if opts.Backoff <= 0 {
+		opts.Backoff = defaultBackoff
+	}
+	if opts.MaxBackoff < opts.Backoff {
+		opts.MaxBackoff = defaultMaxBackoff
+	}
+
+	c := &Client{opts: opts, leader: -1}
+	for _, endpoint := range opts.Endpoints {
+		conn, err := grpc.Dial(endpoint, grpc.WithInsecure(), grpc.WithUnaryInterceptor(recordPeer))
+		if err != nil {
+			c.Close()
+			return nil, err
+		}
+		c.conns = append(c.conns, conn)
+		c.clients = append(c.clients, pbv2.NewKVStoreClient(conn))
+	}
+
+	return c, nil
+}
+
+// Close closes all connections
+func (c *Client) Close() error {
+	var err error
+	for _, conn := range c.conns {
+		if e := conn.Close(); e != nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// Set sets a key to value
+func (c *Client) Set(ctx context.Context, key string, value string) error {
+	return c.SetWithTTL(ctx, key, value, 0)
+}
+
+// SetWithTTL sets a key to value which expires after ttl. 0 means no expiry. ttl is rounded to milliseconds
+func (c *Client) SetWithTTL(ctx context.Context, key string, value string, ttl time.Duration) error {
+	return c.set(ctx, &pbv2.SetRequest{Key: key, Value: value, Ttl: ttl.Milliseconds()})
+}
+
+// SetWithLease sets a key to value and attaches it to the lease. The key is deleted when the lease is revoked or expires
+func (c *Client) SetWithLease(ctx context.Context, key string, value string, lease int64) error {
+	return c.set(ctx, &pbv2.SetRequest{Key: key, Value: value, Lease: lease})
+}
+
+func (c *Client) set(ctx context.Context, req *pbv2.SetRequest) error {
+	return c.do(ctx, false, true, func(ctx context.Context, client pbv2.KVStoreClient, header *pbv2.RequestHeader) (*pbv2.ResponseHeader, error) {
+		req.Header = header
+		reply, err := client.Set(ctx, req)
+		return reply.GetHeader(), err
+	})
+}
+
+// Delete deletes a key
+func (c *Client) Delete(ctx context.Context, key string) error {
+	return c.do(ctx, false, true, func(ctx context.Context, client pbv2.KVStoreClient, header *pbv2.RequestHeader) (*pbv2.ResponseHeader, error) {
+		reply, err := client.Delete(ctx, &pbv2.DeleteRequest{Header: header, Key: key})
+		return reply.GetHeader(), err
+	})
+}
+
+// Get gets a key's value. Returns ErrKeyNotFound if the key doesn't exist
+func (c *Client) Get(ctx context.Context, key string) (string, error) {
+	var value string
+	err := c.do(ctx, c.opts.StaleReads, false, func(ctx context.Context, client pbv2.KVStoreClient, header *pbv2.RequestHeader) (*pbv2.ResponseHeader, error) {
+		reply, err := client.Get(ctx, &pbv2.GetRequest{Header: header, Key: key, AllowStale: c.opts.StaleReads})
+		value = reply.GetValue()
+		return reply.GetHeader(), err
+	})
+
+	return value, err
+}
+
+// GetAt gets the value of a key at a past revision. Returns ErrCompacted if the revision has been compacted
+func (c *Client) GetAt(ctx context.Context, key string, revision int64) (string, error) {
+	var value string
+	err := c.do(ctx, c.opts.StaleReads, false, func(ctx context.Context, client pbv2.KVStoreClient, header *pbv2.RequestHeader) (*pbv2.ResponseHeader, error) {
+		reply, err := client.Get(ctx, &pbv2.GetRequest{Header: header, Key: key, AllowStale: c.opts.StaleReads, Revision: revision})
+		value = reply.GetValue()
+		return reply.GetHeader(), err
+	})
+
+	return value, err
+}
+
+// History gets the retained versions of a key in revision order, deletes included
+func (c *Client) History(ctx context.Context, key string) ([]*pbv2.Event, error) {
+	var events []*pbv2.Event
+	err := c.do(ctx, c.opts.StaleReads, false, func(ctx context.Context, client pbv2.KVStoreClient, header *pbv2.RequestHeader) (*pbv2.ResponseHeader, error) {
+		reply, err := client.History(ctx, &pbv2.HistoryRequest{Header: header, Key: key, AllowStale: c.opts.StaleReads})
+		events = reply.GetEvents()
+		return reply.GetHeader(), err
+	})
+
+	return events, err
+}
+
+// Range gets one page of keys in [start, end) in order. Empty end means no upper bound.
+// revision 0 reads the current revision, limit 0 means the server default, and pageToken is the NextPageToken of the previous page.
+// Pass the Revision of the first page to read the rest at the same revision
+func (c *Client) Range(ctx context.Context, start string, end string, revision int64, limit int64, pageToken string) (*pbv2.RangeReply, error) {
+	var reply *pbv2.RangeReply
+	err := c.do(ctx, c.opts.StaleReads, false, func(ctx context.Context, client pbv2.KVStoreClient, header *pbv2.RequestHeader) (*pbv2.ResponseHeader, error) {
+		var err error
+		req := &pbv2.RangeRequest{Header: header, Start: start, End: end, Limit: limit, PageToken: pageToken, AllowStale: c.opts.StaleReads, Revision: revision}
+		reply, err = client.Range(ctx, req)
+		return reply.GetHeader(), err
+	})
+
+	return reply, err
+}
+
+// Prefix gets one page of keys with the prefix in order, see Range
+func (c *Client) Prefix(ctx context.Context, prefix string, revision int64, limit int64, pageToken string) (*pbv2.RangeReply, error) {
+	var reply *pbv2.RangeReply
+	err := c.do(ctx, c.opts.StaleReads, false, func(ctx context.Context, client pbv2.KVStoreClient, header *pbv2.RequestHeader) (*pbv2.ResponseHeader, error) {
+		var err error
+		req := &pbv2.PrefixRequest{Header: header, Prefix: prefix, Limit: limit, PageToken: pageToken, AllowStale: c.opts.StaleReads, Revision: revision}
+		reply, err = client.Prefix(ctx, req)
+		return reply.GetHeader(), err
+	})
+
+	return reply, err
+}
+
+// Export streams all keys with the prefix to fn in key order, read at revision, and returns the revision they are read at.
+// Empty prefix exports all keys, and revision 0 means the current revision. Since fn might have seen part of the keys,
+// Export is not retried once started. Rerun it at the returned revision for the same view, unless it's compacted since
+func (c *Client) Export(ctx context.Context, prefix string, revision int64, fn func(*pbv2.KeyValue)) (int64, error) {
+	target := c.pickNode(c.opts.StaleReads)
+	req := &pbv2.ExportRequest{Header: &pbv2.RequestHeader{RequestID: newRequestID()}, Prefix: prefix, Revision: revision, AllowStale: c.opts.StaleReads}
+	stream, err := c.clients[target].Export(ctx, req)
+	if err != nil {
+		c.forgetLeader(target)
+		return revision, fmt.Errorf("%s: %w", c.opts.Endpoints[target], err)
+	}
+
+	for {
+		reply, err := stream.Recv()
+		if err == io.EOF {
+			return revision, nil
+		}
+		if err != nil {
+			c.forgetLeader(target)
+			return revision, fmt.Errorf("%s: %w", c.opts.Endpoints[target], err)
+		}
+
+		c.updateLeader(target, reply.Header.Leader)
+		if _, err = toError(reply.Header); err != nil {
+			return revision, err
+		}
+		revision = reply.Revision
+		for _, kv := range reply.Kvs {
+			fn(kv)
+		}
+	}
+}
+
+// Txn applies the txn atomically on the leader. Header of txn is ignored, the client sets its own
+func (c *Client) Txn(ctx context.Context, txn *pbv2.TxnRequest) (*pbv2.TxnReply, error) {
+	var reply *pbv2.TxnReply
+	err := c.do(ctx, false, true, func(ctx context.Context, client pbv2.KVStoreClient, header *pbv2.RequestHeader) (*pbv2.ResponseHeader, error) {
+		var err error
+		req := &pbv2.TxnRequest{Header: header, Compares: txn.Compares, Success: txn.Success, Failure: txn.Failure}
+		reply, err = client.Txn(ctx, req)
+		return reply.GetHeader(), err
+	})
+
+	return reply, err
+}
+
+// BatchSet sets all keys in kvs atomically in one log entry, and returns the revision they are set at.
+// Revision in kvs is ignored, and ttl applies to all keys. At most 1000 keys in one call
+func (c *Client) BatchSet(ctx context.Context, kvs []*pbv2.KeyValue, ttl time.Duration) (int64, error) {
+	var revision int64
+	err := c.do(ctx, false, true, func(ctx context.Context, client pbv2.KVStoreClient, header *pbv2.RequestHeader) (*pbv2.ResponseHeader, error) {
+		reply, err := client.BatchSet(ctx, &pbv2.BatchSetRequest{Header: header, Kvs: kvs, Ttl: ttl.Milliseconds()})
+		revision = reply.GetRevision()
+		return reply.GetHeader(), err
+	})
+
+	return revision, err
+}
+
+// BatchDelete deletes all keys atomically in one log entry, and returns the number of keys which existed. At most 1000 keys in one call
+func (c *Client) BatchDelete(ctx context.Context, keys []string) (int64, error) {
+	var deleted int64
+	err := c.do(ctx, false, true, func(ctx context.Context, client pbv2.KVStoreClient, header *pbv2.RequestHeader) (*pbv2.ResponseHeader, error) {
+		reply, err := client.BatchDelete(ctx, &pbv2.BatchDeleteRequest{Header: header, Keys: keys})
+		deleted = reply.GetDeleted()
+		return reply.GetHeader(), err
+	})
+
+	return deleted, err
+}
+
+// BulkLoad streams batches of keys returned by next until it returns io.EOF, and returns the number of keys set.
+// Each batch is set atomically like BatchSet, but the load as a whole is not. Since next can't be rewound, BulkLoad is not retried,
+// and on errors the count tells how many keys from the start are set, so that the caller can resume from there
+func (c *Client) BulkLoad(ctx context.Context, next func() ([]*pbv2.KeyValue, error)) (int64, error) {
+	target := c.pickNode(false)
+	stream, err := c.clients[target].BulkLoad(ctx)
+	if err != nil {
+		c.forgetLeader(target)
+		return 0, fmt.Errorf("%s: %w", c.opts.Endpoints[target], err)
+	}
+
+	header := &pbv2.RequestHeader{RequestID: newRequestID()}
+	var nextErr error
+	for {
+		var kvs []*pbv2.KeyValue
+		if kvs, nextErr = next(); nextErr != nil {
+			break
+		}
+		// io.EOF from Send means the server has replied with an error, which is got by CloseAndRecv
+		if err = stream.Send(&pbv2.BatchSetRequest{Header: header, Kvs: kvs}); err != nil {
+			break
+		}
+	}
+
+	reply, err := stream.CloseAndRecv()
+	if err != nil {
+		c.forgetLeader(target)
+		return 0, fmt.Errorf("%s: %w", c.opts.Endpoints[target], err)
+	}
+	c.updateLeader(target, reply.Header.Leader)
+	if _, err = toError(reply.Header); err == nil && nextErr != io.EOF {
+		err = nextErr
+	}
+	return reply.Count, err
+}
+
+// Compact drops versions older than revision on all nodes. Returns ErrCompacted if it's already compacted
+func (c *Client) Compact(ctx context.Context, revision int64) error {
+	return c.do(ctx, false, true, func(ctx context.Context, client pbv2.KVStoreClient, header *pbv2.RequestHeader) (*pbv2.ResponseHeader, error) {
+		reply, err := client.Compact(ctx, &pbv2.CompactRequest{Header: header, Revision: revision})
+		return reply.GetHeader(), err
+	})
+}
+
+// LeaseGrant creates a lease with ttl, rounded to milliseconds, and returns its ID
+func (c *Client) LeaseGrant(ctx context.Context, ttl time.Duration) (int64, error) {
+	var id int64
+	err := c.do(ctx, false, true, func(ctx context.Context, client pbv2.KVStoreClient, header *pbv2.RequestHeader) (*pbv2.ResponseHeader, error) {
+		reply, err := client.LeaseGrant(ctx, &pbv2.LeaseGrantRequest{Header: header, Ttl: ttl.Milliseconds()})
+		id = reply.GetId()
+		return reply.GetHeader(), err
+	})
+
+	return id, err
+}
+
+// LeaseRevoke revokes the lease and deletes all keys attached to it
+func (c *Client) LeaseRevoke(ctx context.Context, id int64) error {
+	return c.do(ctx, false, true, func(ctx context.Context, client pbv2.KVStoreClient, header *pbv2.RequestHeader) (*pbv2.ResponseHeader, error) {
+		reply, err := client.LeaseRevoke(ctx, &pbv2.LeaseRevokeRequest{Header: header, Id: id})
+		return reply.GetHeader(), err
+	})
+}
+
+// KeepAlive keeps the lease alive until ctx is done, refreshing it every third of its ttl over a stream to the leader.
+// The stream is reopened with backoff on leader changes and unreachable nodes.
+// Returns ErrLeaseNotFound when the lease is lost, e.g. it expired while we couldn't reach the cluster, otherwise ctx.Err()
+func (c *Client) KeepAlive(ctx context.Context, id int64) error {
+	backoff := c.opts.Backoff
+	for {
+		refreshed, err := c.keepAlive(ctx, id)
+		switch {
+		case ctx.Err() != nil:
+			return ctx.Err()
+		case errors.Is(err, ErrLeaseNotFound):
+			return err
+		case refreshed:
+			backoff = c.opts.Backoff
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		if backoff *= 2; backoff > c.opts.MaxBackoff {
+			backoff = c.opts.MaxBackoff
+		}
+	}
+}
+
+// keepAlive refreshes the lease over one stream until it fails. refreshed tells whether any refresh succeeded
+func (c *Client) keepAlive(ctx context.Context, id int64) (refreshed bool, err error) {
+	target := c.pickNode(false)
+	stream, err := c.clients[target].LeaseKeepAlive(ctx)
+	if err != nil {
+		c.forgetLeader(target)
+		return false, err
+	}
+	defer stream.CloseSend()
+
+	for {
+		if err = stream.Send(&pbv2.LeaseKeepAliveRequest{Header: &pbv2.RequestHeader{RequestID: newRequestID()}, Id: id}); err != nil {
+			c.forgetLeader(target)
+			return refreshed, err
+		}
+		reply, err := stream.Recv()
+		if err != nil {
+			c.forgetLeader(target)
+			return refreshed, err
+		}
+
+		c.updateLeader(target, reply.Header.Leader)
+		if retry, err := toError(reply.Header); err != nil {
+			if retry {
+				// e.g. the node is shutting down, but still hints itself as the leader
+				c.forgetLeader(target)
+			}
+			return refreshed, err
+		}
+		refreshed = true
+
+		select {
+		case <-ctx.Done():
+			return refreshed, ctx.Err()
+		case <-time.After(time.Duration(reply.Ttl) * time.Millisecond / 3):
+		}
+	}
+}
+
+// Watch calls fn for each change to key, or to all keys with the prefix, starting from startRevision (0 means from now)
+// until ctx is done. It reconnects with backoff on errors and resumes after the last revision seen, so no events are missed.
+// Returns ErrCompacted if the revision to resume from is no longer available, otherwise ctx.Err() or the request error
+func (c *Client) Watch(ctx context.Context, key string, prefix bool, startRevision int64, fn func(*pbv2.Event)) error {
+	next := startRevision
+	backoff := c.opts.Backoff
+	for {
+		created, retry, err := c.watch(ctx, key, prefix, &next, fn)
+		switch {
+		case ctx.Err() != nil:
+			return ctx.Err()
+		case !retry:
+			return err
+		case created:
+			backoff = c.opts.Backoff
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		if backoff *= 2; backoff > c.opts.MaxBackoff {
+			backoff = c.opts.MaxBackoff
+		}
+	}
+}
+
+// watch watches over one stream until it fails, and moves next past events seen. created tells whether the watch was created
+func (c *Client) watch(ctx context.Context, key string, prefix bool, next *int64, fn func(*pbv2.Event)) (created bool, retry bool, err error) {
+	target := c.pickNode(false)
+	req := &pbv2.WatchRequest{Header: &pbv2.RequestHeader{RequestID: newRequestID()}, Key: key, Prefix: prefix, StartRevision: *next}
+	stream, err := c.clients[target].Watch(ctx, req)
+	if err != nil {
+		c.forgetLeader(target)
+		return false, true, err
+	}
+
+	for {
+		reply, err := stream.Recv()
+		if err != nil {
+			c.forgetLeader(target)
+			return created, true, err
+		}
+
+		if e := reply.GetHeader().GetError(); e != nil {
+			// the watch is served by any node. Retry on all errors but bad requests and compaction, e.g. when we are too slow
+			_, err = toError(reply.Header)
+			return created, e.Code != pbv2.ErrorCode_INVALID_ARGUMENT && e.Code != pbv2.ErrorCode_COMPACTED, err
+		}
+
+		if !created {
+			created = true
+			// remember where we started so that we can resume from there if no events come before reconnecting
+			*next = reply.StartRevision
+		}
+		for _, e := range reply.Events {
+			fn(e)
+			*next = e.Revision + 1
+		}
+	}
+}
+
+type callFunc func(ctx context.Context, client pbv2.KVStoreClient, header *pbv2.RequestHeader) (*pbv2.ResponseHeader, error)
+
+// do sends the request to the leader (or any node for stale reads), retrying on leader changes and unreachable nodes.
+// The same request ID is used for all attempts. Nodes don't dedupe requests by ID, so writes are only retried
+// on NOT_LEADER and NO_LEADER replies, or when they failed before being sent or while the node is shutting down
+func (c *Client) do(ctx context.Context, anyNode bool, write bool, call callFunc) error {
+	header := &pbv2.RequestHeader{RequestID: newRequestID()}
+	backoff := c.opts.Backoff
+
+	var lastErr error
+	for attempt := 0; attempt <= c.opts.MaxRetries; attempt++ {
+		target := c.pickNode(anyNode)
+		var sentTo peer.Peer
+		reply, err := call(context.WithValue(ctx, peerKey{}, &sentTo), c.clients[target], header)
+
+		redirect := false
+		switch {
+		case ctx.Err() != nil:
+			return ctx.Err()
+		case err != nil:
+			// node is unreachable, forget it if it's the leader and try others
+			c.forgetLeader(target)
+			lastErr = fmt.Errorf("%s: %w", c.opts.Endpoints[target], err)
+			if write && sentTo.Addr != nil && !isShuttingDown(err) {
+				// the write might have been processed, retrying could apply it twice
+				return fmt.Errorf("%w: %s", ErrUnknownResult, lastErr)
+			}
+		default:
+			c.updateLeader(target, reply.Leader)
+			var retry bool
+			if retry, lastErr = toError(reply); !retry {
+				return lastErr
+			}
+			// redirect to the new leader right away if we know it
+			redirect = reply.Error.GetCode() == pbv2.ErrorCode_NOT_LEADER && reply.Leader != nil
+		}
+
+		if !redirect {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(backoff):
+			}
+			if backoff *= 2; backoff > c.opts.MaxBackoff {
+				backoff = c.opts.MaxBackoff
+			}
+		}
+	}
+
+	return lastErr
+}
+
+// peerKey is the context key of the peer.Peer recordPeer records the node a call is sent to in
+type peerKey struct{}
+
+// recordPeer is a unary client interceptor recording the peer in ctx once the call is sent on a connection.
+// The peer is left empty when the call fails before that, e.g. the node is unreachable
+func recordPeer(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	if p, ok := ctx.Value(peerKey{}).(*peer.Peer); ok {
+		opts = append(opts, grpc.Peer(p))
+	}
+	return invoker(ctx, method, req, reply, cc, opts...)
+}
+
+// isShuttingDown tells whether err is the rejection from a node shutting down, which doesn't process the request
+func isShuttingDown(err error) bool {
+	s, ok := status.FromError(err)
+	return ok && s.Code() == codes.Unavailable && s.Message() == shuttingDownMessage
+}
+
+// pickNode returns the cached leader, or the next node in round robin if the leader is unknown or any node can serve
+func (c *Client) pickNode(anyNode bool) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !anyNode && c.leader != -1 {
+		return c.leader
+	}
+
+	target := c.nextNode
+	c.nextNode = (c.nextNode + 1) % len(c.clients)
+	return target
+}
+
+// updateLeader caches the leader from the hint returned by node target
+func (c *Client) updateLeader(target int, hint *pbv2.LeaderHint) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	switch {
+	case hint == nil:
+		c.leader = -1
+	case hint.Endpoint == "":
+		// target is the leader itself
+		c.leader = target
+	default:
+		c.leader = c.findNode(hint)
+	}
+}
+
+// findNode finds the node in endpoints by the hint. Falls back to node ID since endpoints are ordered by node ID
+func (c *Client) findNode(hint *pbv2.LeaderHint) int {
+	for i, v := range c.opts.Endpoints {
+		if v == hint.Endpoint {
+			return i
+		}
+	}
+
+	if hint.NodeID >= 0 && int(hint.NodeID) < len(c.opts.Endpoints) {
+		return int(hint.NodeID)
+	}
+	return -1
+}
+
+// forgetLeader clears the cached leader if it's node target
+func (c *Client) forgetLeader(target int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.leader == target {
+		c.leader = -1
+	}
+}
+
+// toError converts the reply error to a client error, and tells whether the request should be retried
+func toError(reply *pbv2.ResponseHeader) (bool, error) {
+	e := reply.Error
+	if e == nil {
+		return false, nil
+	}
+
+	switch e.Code {
+	case pbv2.ErrorCode_KEY_NOT_FOUND:
+		return false, ErrKeyNotFound
+	case pbv2.ErrorCode_LEASE_NOT_FOUND:
+		return false, ErrLeaseNotFound
+	case pbv2.ErrorCode_COMPACTED:
+		return false, fmt.Errorf("%w: %s", ErrCompacted, e.Message)
+	case pbv2.ErrorCode_NO_LEADER:
+		return true, fmt.Errorf("%w: %s", ErrNoLeader, e.Message)
+	case pbv2.ErrorCode_NOT_LEADER:
+		return true, fmt.Errorf("%w: %s", ErrNoLeader, e.Message)
+	case pbv2.ErrorCode_TIMEOUT:
+		return false, fmt.Errorf("%w: %s (request %s)", ErrTimeout, e.Message, reply.RequestID)
+	default:
+		return false, fmt.Errorf("%s: %s (request %s)", e.Code, e.Message, reply.RequestID)
+	}
+}
+
+// newRequestID generates a random request ID
+func newRequestID() string {
+	var b [8]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
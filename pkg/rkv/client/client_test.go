@@ -0,0 +1,680 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/sidecus/raft/pkg/rkv/pbv2"
+)
+
+// fakeCluster serves the v2 API from in process nodes which share one map
+type fakeCluster struct {
+	mu        sync.Mutex
+	leader    int // -1 if there is no leader
+	endpoints []string
+	servers   []*grpc.Server
+	data      map[string]string
+	served    []int // requests served by each node
+	notLeader int   // NOT_LEADER replies returned
+	ttl       int64 // ttl of the last Set
+	leases    map[int64]int64
+	refreshes int // lease keepalives served
+	events    []*pbv2.Event
+	compacted int64
+	watches   int // watch streams opened
+	drops     int // Set and Get calls to fail with Unavailable after serving them, as if the reply was lost
+	rejects   int // Set and Get calls to reject as if the node is shutting down
+}
+
+type fakeNode struct {
+	id      int
+	cluster *fakeCluster
+	pbv2.UnimplementedKVStoreServer
+}
+
+func newFakeCluster(t *testing.T, size int, leader int) *fakeCluster {
+	c := &fakeCluster{leader: leader, data: make(map[string]string), leases: make(map[int64]int64), served: make([]int, size)}
+	for i := 0; i < size; i++ {
+		lis, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		server := grpc.NewServer()
+		pbv2.RegisterKVStoreServer(server, &fakeNode{id: i, cluster: c})
+		go server.Serve(lis)
+
+		c.endpoints = append(c.endpoints, lis.Addr().String())
+		c.servers = append(c.servers, server)
+	}
+	return c
+}
+
+func (c *fakeCluster) stop() {
+	for _, s := range c.servers {
+		s.Stop()
+	}
+}
+
+// take decrements the count of calls to fail, and returns true if the call should fail
+func (c *fakeCluster) take(count *int) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if *count == 0 {
+		return false
+	}
+	*count--
+	return true
+}
+
+func (c *fakeCluster) setLeader(leader int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.leader = leader
+}
+
+// serve runs fn if node is the leader (or allowStale), and creates the reply header
+func (n *fakeNode) serve(header *pbv2.RequestHeader, allowStale bool, fn func() *pbv2.Error) *pbv2.ResponseHeader {
+	c := n.cluster
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	reply := &pbv2.ResponseHeader{RequestID: header.GetRequestID(), NodeID: int64(n.id)}
+	if c.leader != -1 {
+		reply.Leader = &pbv2.LeaderHint{NodeID: int64(c.leader), Endpoint: c.endpoints[c.leader]}
+		if c.leader == n.id {
+			reply.Leader.Endpoint = ""
+		}
+	}
+
+	switch {
+	case allowStale || c.leader == n.id:
+		c.served[n.id]++
+		reply.Error = fn()
+	case c.leader == -1:
+		reply.Error = &pbv2.Error{Code: pbv2.ErrorCode_NO_LEADER, Message: "no leader"}
+	default:
+		c.notLeader++
+		reply.Error = &pbv2.Error{Code: pbv2.ErrorCode_NOT_LEADER, Message: "not leader"}
+	}
+
+	return reply
+}
+
+func (n *fakeNode) Set(ctx context.Context, req *pbv2.SetRequest) (*pbv2.SetReply, error) {
+	if n.cluster.take(&n.cluster.rejects) {
+		return nil, status.Error(codes.Unavailable, shuttingDownMessage)
+	}
+	header := n.serve(req.Header, false, func() *pbv2.Error {
+		if req.Key == "timeout" {
+			return &pbv2.Error{Code: pbv2.ErrorCode_TIMEOUT, Message: "not committed"}
+		}
+		n.cluster.ttl = req.Ttl
+		n.cluster.data[req.Key] = req.Value
+		return nil
+	})
+	if n.cluster.take(&n.cluster.drops) {
+		return nil, status.Error(codes.Unavailable, "transport is closing")
+	}
+	return &pbv2.SetReply{Header: header}, nil
+}
+
+func (n *fakeNode) Delete(ctx context.Context, req *pbv2.DeleteRequest) (*pbv2.DeleteReply, error) {
+	header := n.serve(req.Header, false, func() *pbv2.Error {
+		delete(n.cluster.data, req.Key)
+		return nil
+	})
+	return &pbv2.DeleteReply{Header: header}, nil
+}
+
+func (n *fakeNode) Get(ctx context.Context, req *pbv2.GetRequest) (*pbv2.GetReply, error) {
+	reply := &pbv2.GetReply{}
+	reply.Header = n.serve(req.Header, req.AllowStale, func() *pbv2.Error {
+		v, ok := n.cluster.data[req.Key]
+		if !ok {
+			return &pbv2.Error{Code: pbv2.ErrorCode_KEY_NOT_FOUND, Message: "not found"}
+		}
+		reply.Value = v
+		return nil
+	})
+	if n.cluster.take(&n.cluster.drops) {
+		return nil, status.Error(codes.Unavailable, "transport is closing")
+	}
+	return reply, nil
+}
+
+// Txn only supports value EQUAL compares and SET ops
+func (n *fakeNode) Txn(ctx context.Context, req *pbv2.TxnRequest) (*pbv2.TxnReply, error) {
+	reply := &pbv2.TxnReply{}
+	reply.Header = n.serve(req.Header, false, func() *pbv2.Error {
+		reply.Succeeded = true
+		for _, c := range req.Compares {
+			reply.Succeeded = reply.Succeeded && n.cluster.data[c.Key] == c.Value
+		}
+		ops := req.Success
+		if !reply.Succeeded {
+			ops = req.Failure
+		}
+		for _, op := range ops {
+			n.cluster.data[op.Key] = op.Value
+			reply.Results = append(reply.Results, &pbv2.TxnOpResult{Found: true, Value: op.Value})
+		}
+		return nil
+	})
+	return reply, nil
+}
+
+func (n *fakeNode) LeaseGrant(ctx context.Context, req *pbv2.LeaseGrantRequest) (*pbv2.LeaseGrantReply, error) {
+	reply := &pbv2.LeaseGrantReply{Ttl: req.Ttl}
+	reply.Header = n.serve(req.Header, false, func() *pbv2.Error {
+		reply.Id = int64(len(n.cluster.leases) + 1)
+		n.cluster.leases[reply.Id] = req.Ttl
+		return nil
+	})
+	return reply, nil
+}
+
+func (n *fakeNode) LeaseRevoke(ctx context.Context, req *pbv2.LeaseRevokeRequest) (*pbv2.LeaseRevokeReply, error) {
+	header := n.serve(req.Header, false, func() *pbv2.Error {
+		delete(n.cluster.leases, req.Id)
+		return nil
+	})
+	return &pbv2.LeaseRevokeReply{Header: header}, nil
+}
+
+func (n *fakeNode) LeaseKeepAlive(stream pbv2.KVStore_LeaseKeepAliveServer) error {
+	for {
+		req, err := stream.Recv()
+		if err != nil {
+			return nil
+		}
+
+		reply := &pbv2.LeaseKeepAliveReply{Id: req.Id}
+		reply.Header = n.serve(req.Header, false, func() *pbv2.Error {
+			ttl, ok := n.cluster.leases[req.Id]
+			if !ok {
+				return &pbv2.Error{Code: pbv2.ErrorCode_LEASE_NOT_FOUND, Message: "lease not found"}
+			}
+			n.cluster.refreshes++
+			reply.Ttl = ttl
+			return nil
+		})
+		if err = stream.Send(reply); err != nil {
+			return err
+		}
+	}
+}
+
+// Watch serves at most 2 events from cluster.events on each stream and then ends it, to test resuming
+func (n *fakeNode) Watch(req *pbv2.WatchRequest, stream pbv2.KVStore_WatchServer) error {
+	c := n.cluster
+	c.mu.Lock()
+	c.watches++
+	reply := &pbv2.WatchReply{Header: &pbv2.ResponseHeader{}, StartRevision: req.StartRevision}
+	if reply.StartRevision == 0 {
+		reply.StartRevision = int64(len(c.events) + 1)
+	}
+	if req.StartRevision != 0 && req.StartRevision <= c.compacted {
+		reply.Header = &pbv2.ResponseHeader{Error: &pbv2.Error{Code: pbv2.ErrorCode_COMPACTED, Message: "compacted"}}
+	}
+	events := c.events
+	c.mu.Unlock()
+
+	if err := stream.Send(reply); err != nil || reply.Header.Error != nil {
+		return err
+	}
+
+	sent := 0
+	for _, e := range events {
+		if e.Revision >= reply.StartRevision && sent < 2 {
+			stream.Send(&pbv2.WatchReply{Header: &pbv2.ResponseHeader{}, Events: []*pbv2.Event{e}})
+			sent++
+		}
+	}
+	return nil
+}
+
+// Prefix returns one key per page, the page token is the next key
+func (n *fakeNode) Prefix(ctx context.Context, req *pbv2.PrefixRequest) (*pbv2.RangeReply, error) {
+	reply := &pbv2.RangeReply{Revision: req.Revision}
+	reply.Header = n.serve(req.Header, req.AllowStale, func() *pbv2.Error {
+		if req.Revision != 0 && req.Revision <= n.cluster.compacted {
+			return &pbv2.Error{Code: pbv2.ErrorCode_COMPACTED, Message: "compacted"}
+		}
+		var keys []string
+		for k := range n.cluster.data {
+			if strings.HasPrefix(k, req.Prefix) && k >= req.PageToken {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		if len(keys) > 0 {
+			reply.Kvs = []*pbv2.KeyValue{{Key: keys[0], Value: n.cluster.data[keys[0]]}}
+		}
+		if len(keys) > 1 {
+			reply.NextPageToken = keys[1]
+		}
+		return nil
+	})
+	return reply, nil
+}
+
+// Export sends keys with the prefix two at a time at revision 7, or an error reply if the revision is compacted
+func (n *fakeNode) Export(req *pbv2.ExportRequest, stream pbv2.KVStore_ExportServer) error {
+	var kvs []*pbv2.KeyValue
+	header := n.serve(req.Header, req.AllowStale, func() *pbv2.Error {
+		if req.Revision != 0 && req.Revision <= n.cluster.compacted {
+			return &pbv2.Error{Code: pbv2.ErrorCode_COMPACTED, Message: "compacted"}
+		}
+		for k, v := range n.cluster.data {
+			if strings.HasPrefix(k, req.Prefix) {
+				kvs = append(kvs, &pbv2.KeyValue{Key: k, Value: v})
+			}
+		}
+		return nil
+	})
+	if header.Error != nil {
+		return stream.Send(&pbv2.ExportReply{Header: header})
+	}
+
+	sort.Slice(kvs, func(i, j int) bool { return kvs[i].Key < kvs[j].Key })
+	for len(kvs) > 2 {
+		if err := stream.Send(&pbv2.ExportReply{Header: header, Kvs: kvs[:2], Revision: 7}); err != nil {
+			return err
+		}
+		kvs = kvs[2:]
+	}
+	return stream.Send(&pbv2.ExportReply{Header: header, Kvs: kvs, Revision: 7})
+}
+
+// BulkLoad sets keys of each request, and fails at the first request with an empty key
+func (n *fakeNode) BulkLoad(stream pbv2.KVStore_BulkLoadServer) error {
+	reply := &pbv2.BulkLoadReply{}
+	for {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			return stream.SendAndClose(reply)
+		}
+		if err != nil {
+			return err
+		}
+
+		reply.Header = n.serve(req.Header, false, func() *pbv2.Error {
+			for _, kv := range req.Kvs {
+				if kv.Key == "" {
+					return &pbv2.Error{Code: pbv2.ErrorCode_INVALID_ARGUMENT, Message: "empty key"}
+				}
+			}
+			for _, kv := range req.Kvs {
+				n.cluster.data[kv.Key] = kv.Value
+			}
+			return nil
+		})
+		if reply.Header.Error != nil {
+			return stream.SendAndClose(reply)
+		}
+		reply.Count += int64(len(req.Kvs))
+	}
+}
+
+func newTestClient(t *testing.T, endpoints []string, staleReads bool) *Client {
+	c, err := New(Options{Endpoints: endpoints, StaleReads: staleReads, Backoff: time.Millisecond, MaxBackoff: 10 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestLeaderDiscovery(t *testing.T) {
+	cluster := newFakeCluster(t, 3, 2)
+	defer cluster.stop()
+	c := newTestClient(t, cluster.endpoints, false)
+	defer c.Close()
+
+	ctx := context.Background()
+	if err := c.Set(ctx, "a", "1"); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := c.Get(ctx, "a"); err != nil || v != "1" {
+		t.Error("Get should return the value set")
+	}
+	if err := c.SetWithTTL(ctx, "a", "1", 2*time.Second); err != nil || cluster.ttl != 2000 {
+		t.Error("SetWithTTL should send ttl in milliseconds")
+	}
+	if err := c.Delete(ctx, "a"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Get(ctx, "a"); err != ErrKeyNotFound {
+		t.Error("Get should return ErrKeyNotFound after delete")
+	}
+
+	// first request hits node0 and gets redirected, after which the leader is cached
+	if cluster.notLeader != 1 || cluster.served[2] != 5 {
+		t.Errorf("Client should discover and cache the leader, %d redirects, %d served by leader", cluster.notLeader, cluster.served[2])
+	}
+}
+
+func TestLeaderChange(t *testing.T) {
+	cluster := newFakeCluster(t, 3, 0)
+	defer cluster.stop()
+	c := newTestClient(t, cluster.endpoints, false)
+	defer c.Close()
+
+	ctx := context.Background()
+	if err := c.Set(ctx, "a", "1"); err != nil {
+		t.Fatal(err)
+	}
+
+	// old leader redirects to the new one
+	cluster.setLeader(1)
+	if err := c.Set(ctx, "a", "2"); err != nil {
+		t.Fatal(err)
+	}
+
+	// no leader for a while, the client keeps retrying with backoff
+	cluster.setLeader(-1)
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		cluster.setLeader(2)
+	}()
+	if err := c.Set(ctx, "a", "3"); err != nil {
+		t.Fatal(err)
+	}
+	if cluster.data["a"] != "3" {
+		t.Error("Set should succeed after a new leader is elected")
+	}
+}
+
+func TestNoLeader(t *testing.T) {
+	cluster := newFakeCluster(t, 3, -1)
+	defer cluster.stop()
+	c := newTestClient(t, cluster.endpoints, false)
+	defer c.Close()
+
+	if err := c.Set(context.Background(), "a", "1"); !errors.Is(err, ErrNoLeader) {
+		t.Error("Set should return ErrNoLeader after retries")
+	}
+}
+
+func TestUnreachableNode(t *testing.T) {
+	cluster := newFakeCluster(t, 3, 1)
+	defer cluster.stop()
+
+	// node0 is unreachable
+	cluster.servers[0].Stop()
+	c := newTestClient(t, cluster.endpoints, false)
+	defer c.Close()
+
+	if err := c.Set(context.Background(), "a", "1"); err != nil {
+		t.Error("Client should skip unreachable nodes")
+	}
+}
+
+func TestTimeoutNotRetried(t *testing.T) {
+	cluster := newFakeCluster(t, 3, 0)
+	defer cluster.stop()
+	c := newTestClient(t, cluster.endpoints, false)
+	defer c.Close()
+
+	if err := c.Set(context.Background(), "timeout", "1"); !errors.Is(err, ErrTimeout) {
+		t.Error("Set should return ErrTimeout")
+	}
+	if cluster.served[0] != 1 {
+		t.Error("Timed out writes should not be retried")
+	}
+}
+
+func TestWriteRetries(t *testing.T) {
+	cluster := newFakeCluster(t, 3, 0)
+	defer cluster.stop()
+	c := newTestClient(t, cluster.endpoints, false)
+	defer c.Close()
+	ctx := context.Background()
+
+	// the write is applied but its reply is lost
+	cluster.drops = 1
+	if err := c.Set(ctx, "a", "1"); !errors.Is(err, ErrUnknownResult) {
+		t.Errorf("Set should return ErrUnknownResult when the reply is lost, got %v", err)
+	}
+	if cluster.served[0] != 1 {
+		t.Error("Writes which might have been applied should not be retried")
+	}
+
+	// reads are retried
+	cluster.drops = 1
+	if v, err := c.Get(ctx, "a"); err != nil || v != "1" {
+		t.Error("Reads should be retried when the reply is lost")
+	}
+
+	// writes rejected by a node shutting down are not processed, and are retried
+	cluster.rejects = 1
+	if err := c.Set(ctx, "a", "2"); err != nil || cluster.data["a"] != "2" {
+		t.Error("Writes rejected by a node shutting down should be retried")
+	}
+}
+
+func TestStaleReads(t *testing.T) {
+	cluster := newFakeCluster(t, 3, 0)
+	defer cluster.stop()
+	c := newTestClient(t, cluster.endpoints, true)
+	defer c.Close()
+
+	ctx := context.Background()
+	if err := c.Set(ctx, "a", "1"); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 6; i++ {
+		if v, err := c.Get(ctx, "a"); err != nil || v != "1" {
+			t.Fatal("Stale reads should return the value set")
+		}
+	}
+
+	for i, v := range cluster.served {
+		if v < 2 {
+			t.Errorf("Stale reads should be load balanced, node%d served %d requests", i, v)
+		}
+	}
+}
+
+func TestNoEndpoints(t *testing.T) {
+	if _, err := New(Options{}); err == nil {
+		t.Error("New should fail without endpoints")
+	}
+}
+
+func TestTxn(t *testing.T) {
+	cluster := newFakeCluster(t, 3, 1)
+	defer cluster.stop()
+	c := newTestClient(t, cluster.endpoints, false)
+	defer c.Close()
+
+	txn := &pbv2.TxnRequest{
+		Compares: []*pbv2.Compare{{Key: "a", Value: ""}},
+		Success:  []*pbv2.TxnOp{{Type: pbv2.TxnOp_SET, Key: "a", Value: "1"}, {Type: pbv2.TxnOp_SET, Key: "b", Value: "1"}},
+	}
+	reply, err := c.Txn(context.Background(), txn)
+	if err != nil || !reply.Succeeded || len(reply.Results) != 2 {
+		t.Fatal("Txn should be applied by the leader")
+	}
+	if cluster.served[1] != 1 || cluster.data["b"] != "1" {
+		t.Error("Txn should be sent to the leader")
+	}
+}
+
+func TestLease(t *testing.T) {
+	cluster := newFakeCluster(t, 3, 2)
+	defer cluster.stop()
+	c := newTestClient(t, cluster.endpoints, false)
+	defer c.Close()
+
+	id, err := c.LeaseGrant(context.Background(), 30*time.Millisecond)
+	if err != nil || id != 1 {
+		t.Fatal("LeaseGrant should return the lease ID")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	go func() {
+		// leader changes while keeping alive
+		time.Sleep(30 * time.Millisecond)
+		cluster.setLeader(0)
+	}()
+	if err = c.KeepAlive(ctx, id); err != context.DeadlineExceeded {
+		t.Errorf("KeepAlive should run until ctx is done, got %v", err)
+	}
+	cluster.mu.Lock()
+	refreshes := cluster.refreshes
+	cluster.mu.Unlock()
+	if refreshes < 5 {
+		t.Errorf("KeepAlive should refresh every third of ttl, %d refreshes", refreshes)
+	}
+
+	if err = c.LeaseRevoke(context.Background(), id); err != nil {
+		t.Fatal(err)
+	}
+	if err = c.KeepAlive(context.Background(), id); err != ErrLeaseNotFound {
+		t.Error("KeepAlive should return ErrLeaseNotFound on revoked lease")
+	}
+}
+
+func TestWatch(t *testing.T) {
+	cluster := newFakeCluster(t, 3, 0)
+	defer cluster.stop()
+	c := newTestClient(t, cluster.endpoints, false)
+	defer c.Close()
+
+	for i := 1; i <= 5; i++ {
+		cluster.events = append(cluster.events, &pbv2.Event{Key: "a", Revision: int64(i)})
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var revisions []int64
+	err := c.Watch(ctx, "a", false, 2, func(e *pbv2.Event) {
+		if revisions = append(revisions, e.Revision); len(revisions) == 4 {
+			cancel()
+		}
+	})
+
+	if err != context.Canceled {
+		t.Errorf("Watch should run until ctx is done, got %v", err)
+	}
+	for i, r := range revisions {
+		if r != int64(i+2) {
+			t.Fatalf("Watch should resume without missing or repeating events, got %v", revisions)
+		}
+	}
+	if cluster.watches < 2 {
+		t.Error("Watch should reconnect when the stream ends")
+	}
+
+	cluster.compacted = 3
+	if err = c.Watch(context.Background(), "a", false, 1, func(e *pbv2.Event) {}); !errors.Is(err, ErrCompacted) {
+		t.Errorf("Watch should return ErrCompacted, got %v", err)
+	}
+}
+
+func TestPrefix(t *testing.T) {
+	cluster := newFakeCluster(t, 3, 1)
+	defer cluster.stop()
+	c := newTestClient(t, cluster.endpoints, false)
+	defer c.Close()
+
+	ctx := context.Background()
+	for _, k := range []string{"t1/b", "t1/a", "t2/a"} {
+		c.Set(ctx, k, k)
+	}
+
+	var keys []string
+	token := ""
+	for {
+		reply, err := c.Prefix(ctx, "t1/", 5, 1, token)
+		if err != nil || reply.Revision != 5 {
+			t.Fatal("Prefix should read at revision", err)
+		}
+		for _, kv := range reply.Kvs {
+			keys = append(keys, kv.Key)
+		}
+		if token = reply.NextPageToken; token == "" {
+			break
+		}
+	}
+	if strings.Join(keys, ",") != "t1/a,t1/b" {
+		t.Errorf("Prefix should page through keys, got %v", keys)
+	}
+
+	cluster.compacted = 5
+	if _, err := c.Prefix(ctx, "t1/", 5, 1, ""); !errors.Is(err, ErrCompacted) {
+		t.Errorf("Prefix at compacted revision should return ErrCompacted, got %v", err)
+	}
+}
+
+// feed returns batches one by one, then end
+func feed(end error, batches ...[]*pbv2.KeyValue) func() ([]*pbv2.KeyValue, error) {
+	return func() ([]*pbv2.KeyValue, error) {
+		if len(batches) == 0 {
+			return nil, end
+		}
+		kvs := batches[0]
+		batches = batches[1:]
+		return kvs, nil
+	}
+}
+
+func TestBulkLoad(t *testing.T) {
+	cluster := newFakeCluster(t, 3, 0)
+	defer cluster.stop()
+	c := newTestClient(t, cluster.endpoints, false)
+	defer c.Close()
+	ctx := context.Background()
+
+	count, err := c.BulkLoad(ctx, feed(io.EOF, []*pbv2.KeyValue{{Key: "a", Value: "1"}, {Key: "b", Value: "2"}}, []*pbv2.KeyValue{{Key: "c", Value: "3"}}))
+	if err != nil || count != 3 || cluster.data["c"] != "3" {
+		t.Fatal("BulkLoad should set all keys", err)
+	}
+
+	readErr := errors.New("bad input")
+	if count, err = c.BulkLoad(ctx, feed(readErr, []*pbv2.KeyValue{{Key: "d", Value: "4"}})); err != readErr || count != 1 || cluster.data["d"] != "4" {
+		t.Errorf("BulkLoad should return the error from next with the count of keys set, got %d %v", count, err)
+	}
+
+	count, err = c.BulkLoad(ctx, feed(io.EOF, []*pbv2.KeyValue{{Key: "e", Value: "5"}}, []*pbv2.KeyValue{{Key: ""}}, []*pbv2.KeyValue{{Key: "f", Value: "6"}}))
+	if err == nil || count != 1 || cluster.data["f"] != "" {
+		t.Errorf("BulkLoad should stop at the failed batch, got %d %v", count, err)
+	}
+}
+
+func TestExport(t *testing.T) {
+	cluster := newFakeCluster(t, 3, 0)
+	defer cluster.stop()
+	c := newTestClient(t, cluster.endpoints, false)
+	defer c.Close()
+
+	ctx := context.Background()
+	for _, k := range []string{"t1/c", "t1/a", "t2/a", "t1/b"} {
+		c.Set(ctx, k, k)
+	}
+
+	var keys []string
+	revision, err := c.Export(ctx, "t1/", 0, func(kv *pbv2.KeyValue) {
+		keys = append(keys, kv.Key+"="+kv.Value)
+	})
+	if err != nil || revision != 7 || strings.Join(keys, ",") != "t1/a=t1/a,t1/b=t1/b,t1/c=t1/c" {
+		t.Errorf("Export should stream all keys with the prefix, got %v at revision %d, %v", keys, revision, err)
+	}
+
+	cluster.compacted = 5
+	if _, err = c.Export(ctx, "", 5, func(kv *pbv2.KeyValue) {}); !errors.Is(err, ErrCompacted) {
+		t.Errorf("Export at compacted revision should return ErrCompacted, got %v", err)
+	}
+}
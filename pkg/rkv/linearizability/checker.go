@@ -0,0 +1,290 @@
+package linearizability
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strings"
+)
+
+// kvState is the state of a single key in the sequential kv model
+type kvState struct {
+	exists bool
+	value  string
+}
+
+// step applies an operation to the state. Returns false if the operation cannot happen on this state
+func (s kvState) step(op *Operation) (bool, kvState) {
+	switch op.Type {
+	case OpSet:
+		return true, kvState{exists: true, value: op.Value}
+	case OpDelete:
+		return true, kvState{}
+	default:
+		return op.Found == s.exists && (!op.Found || op.Value == s.value), s
+	}
+}
+
+// Result is the result of a linearizability check
+type Result struct {
+	Linearizable bool
+	Key          string      // first key found to be not linearizable
+	History      []Operation // operations on Key, sorted by call time
+}
+
+// String describes the result, listing the offending key history when not linearizable
+func (r Result) String() string {
+	if r.Linearizable {
+		return "history is linearizable"
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "history on key %q is not linearizable:\n", r.Key)
+	for _, op := range r.History {
+		fmt.Fprintf(&sb, "  %s\n", describe(op))
+	}
+	return sb.String()
+}
+
+// Check verifies whether the history is linearizable against a kv store which starts empty.
+// Keys are independent so the history is partitioned by key and each key is checked separately
+func Check(history []Operation) Result {
+	partitions := make(map[string][]Operation)
+	for _, op := range prune(history) {
+		partitions[op.Key] = append(partitions[op.Key], op)
+	}
+
+	// check keys in a stable order so that results are reproducible
+	keys := make([]string, 0, len(partitions))
+	for k := range partitions {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		ops := partitions[k]
+		if !checkKey(ops) {
+			sort.Slice(ops, func(i, j int) bool { return ops[i].Call < ops[j].Call })
+			return Result{Linearizable: false, Key: k, History: ops}
+		}
+	}
+
+	return Result{Linearizable: true}
+}
+
+// prune drops sets with unknown outcome whose value is never observed by any get.
+// Such a set can only make gets fail if it takes effect, so assuming it never happened is always at least as good.
+// This keeps the search small when there are lots of timed out writes, e.g. when a majority is down
+func prune(history []Operation) []Operation {
+	type keyValue struct{ key, value string }
+	observed := make(map[keyValue]bool)
+	for _, op := range history {
+		if op.Type == OpGet && op.Found {
+			observed[keyValue{op.Key, op.Value}] = true
+		}
+	}
+
+	pruned := make([]Operation, 0, len(history))
+	for _, op := range history {
+		if op.Type == OpSet && op.Unknown() && !observed[keyValue{op.Key, op.Value}] {
+			continue
+		}
+		pruned = append(pruned, op)
+	}
+
+	return pruned
+}
+
+// entry is one call or return event in the doubly linked history list
+type entry struct {
+	id    int
+	op    *Operation
+	time  int64
+	match *entry // call entries point to their return entry, nil for return entries
+	prev  *entry
+	next  *entry
+}
+
+// checkKey checks a single key history with the Wing & Gong algorithm improved by Lowe,
+// caching (linearized set, state) pairs which have already been explored
+func checkKey(ops []Operation) bool {
+	head := buildEntries(ops)
+	linearized := newBitset(len(ops))
+	cache := make(map[uint64][]cacheEntry)
+
+	type frame struct {
+		entry *entry
+		state kvState
+	}
+	var calls []frame
+
+	state := kvState{}
+	e := head.next
+	for head.next != nil {
+		if e.match != nil {
+			ok, newState := state.step(e.op)
+			if ok {
+				newLinearized := linearized.clone().set(e.id)
+				if addToCache(cache, newLinearized, newState) {
+					calls = append(calls, frame{entry: e, state: state})
+					state = newState
+					linearized.set(e.id)
+					lift(e)
+					e = head.next
+					continue
+				}
+			}
+			e = e.next
+		} else {
+			// we hit a return before linearizing its call, backtrack
+			if len(calls) == 0 {
+				return false
+			}
+			top := calls[len(calls)-1]
+			calls = calls[:len(calls)-1]
+			e, state = top.entry, top.state
+			linearized.clear(e.id)
+			unlift(e)
+			e = e.next
+		}
+	}
+
+	return true
+}
+
+// buildEntries creates the sorted call/return list with a sentinel head
+func buildEntries(ops []Operation) *entry {
+	events := make([]*entry, 0, len(ops)*2)
+	for i := range ops {
+		op := &ops[i]
+		ret := &entry{id: i, op: op, time: op.Return}
+		call := &entry{id: i, op: op, time: op.Call, match: ret}
+		events = append(events, call, ret)
+	}
+
+	// Calls and returns never share a timestamp from Recorder except for pending returns.
+	// Keep calls ahead of returns on ties so that ops with unknown outcome stay concurrent
+	sort.SliceStable(events, func(i, j int) bool {
+		if events[i].time != events[j].time {
+			return events[i].time < events[j].time
+		}
+		return events[i].match != nil && events[j].match == nil
+	})
+
+	head := &entry{id: -1}
+	prev := head
+	for _, e := range events {
+		prev.next = e
+		e.prev = prev
+		prev = e
+	}
+
+	return head
+}
+
+// lift removes a call entry and its matching return from the list
+func lift(e *entry) {
+	e.prev.next = e.next
+	if e.next != nil {
+		e.next.prev = e.prev
+	}
+	m := e.match
+	m.prev.next = m.next
+	if m.next != nil {
+		m.next.prev = m.prev
+	}
+}
+
+// unlift puts a lifted call entry and its matching return back to the list
+func unlift(e *entry) {
+	m := e.match
+	m.prev.next = m
+	if m.next != nil {
+		m.next.prev = m
+	}
+	e.prev.next = e
+	if e.next != nil {
+		e.next.prev = e
+	}
+}
+
+type cacheEntry struct {
+	linearized bitset
+	state      kvState
+}
+
+// addToCache adds the (linearized, state) pair to the cache. Returns false if it's already there
+func addToCache(cache map[uint64][]cacheEntry, linearized bitset, state kvState) bool {
+	h := linearized.hash()
+	for _, c := range cache[h] {
+		if c.state == state && c.linearized.equals(linearized) {
+			return false
+		}
+	}
+	cache[h] = append(cache[h], cacheEntry{linearized: linearized, state: state})
+	return true
+}
+
+// bitset is a fixed size set of operation ids
+type bitset []uint64
+
+func newBitset(n int) bitset {
+	return make(bitset, (n+63)/64)
+}
+
+func (b bitset) clone() bitset {
+	c := make(bitset, len(b))
+	copy(c, b)
+	return c
+}
+
+func (b bitset) set(i int) bitset {
+	b[i/64] |= 1 << uint(i%64)
+	return b
+}
+
+func (b bitset) clear(i int) bitset {
+	b[i/64] &^= 1 << uint(i%64)
+	return b
+}
+
+func (b bitset) equals(other bitset) bool {
+	for i := range b {
+		if b[i] != other[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func (b bitset) hash() uint64 {
+	h := fnv.New64a()
+	var buf [8]byte
+	for _, v := range b {
+		for i := range buf {
+			buf[i] = byte(v >> (8 * uint(i)))
+		}
+		h.Write(buf[:])
+	}
+	return h.Sum64()
+}
+
+// describe formats an operation for diagnostics
+func describe(op Operation) string {
+	ret := fmt.Sprint(op.Return)
+	if op.Unknown() {
+		ret = "?"
+	}
+
+	switch op.Type {
+	case OpSet:
+		return fmt.Sprintf("[%d, %s] client%d set %q", op.Call, ret, op.ClientID, op.Value)
+	case OpDelete:
+		return fmt.Sprintf("[%d, %s] client%d delete", op.Call, ret, op.ClientID)
+	default:
+		if !op.Found {
+			return fmt.Sprintf("[%d, %s] client%d get -> not found", op.Call, ret, op.ClientID)
+		}
+		return fmt.Sprintf("[%d, %s] client%d get -> %q", op.Call, ret, op.ClientID, op.Value)
+	}
+}
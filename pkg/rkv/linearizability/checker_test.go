@@ -0,0 +1,124 @@
+package linearizability
+
+import (
+	"testing"
+)
+
+func TestRecorder(t *testing.T) {
+	r := NewRecorder()
+
+	set := r.Invoke(0, OpSet, "a", "1")
+	get := r.Invoke(1, OpGet, "a", "")
+	r.Ok(set, "", false)
+	r.Ok(get, "1", true)
+
+	failed := r.Invoke(0, OpSet, "a", "2")
+	r.Fail(failed)
+	unknownGet := r.Invoke(1, OpGet, "a", "")
+	r.Info(unknownGet)
+	unknownDel := r.Invoke(0, OpDelete, "a", "")
+	r.Info(unknownDel)
+	r.Invoke(2, OpSet, "b", "3")
+
+	history := r.History()
+	if len(history) != 4 {
+		t.Fatalf("History should contain 4 operations, got %d", len(history))
+	}
+
+	if history[0].Type != OpSet || history[0].Call != 1 || history[0].Return != 3 {
+		t.Error("Recorder records wrong timestamps for set")
+	}
+	if history[1].Type != OpGet || !history[1].Found || history[1].Value != "1" || history[1].Call != 2 || history[1].Return != 4 {
+		t.Error("Recorder records wrong get result")
+	}
+	if history[2].Type != OpDelete || !history[2].Unknown() {
+		t.Error("Recorder should keep writes with unknown outcome as pending")
+	}
+	if history[3].Key != "b" || !history[3].Unknown() {
+		t.Error("History should treat in flight writes as unknown")
+	}
+}
+
+func TestCheckSequential(t *testing.T) {
+	history := []Operation{
+		{Type: OpGet, Key: "a", Found: false, Call: 1, Return: 2},
+		{Type: OpSet, Key: "a", Value: "1", Call: 3, Return: 4},
+		{Type: OpGet, Key: "a", Value: "1", Found: true, Call: 5, Return: 6},
+		{Type: OpDelete, Key: "a", Call: 7, Return: 8},
+		{Type: OpGet, Key: "a", Found: false, Call: 9, Return: 10},
+	}
+
+	if r := Check(history); !r.Linearizable {
+		t.Errorf("Sequential history should be linearizable. %s", r)
+	}
+
+	// stale read after delete
+	history = append(history, Operation{Type: OpGet, Key: "a", Value: "1", Found: true, Call: 11, Return: 12})
+	if r := Check(history); r.Linearizable || r.Key != "a" {
+		t.Error("Stale read should not be linearizable")
+	}
+}
+
+func TestCheckConcurrent(t *testing.T) {
+	// two concurrent sets, readers can observe them in either order, but not go back in time
+	history := []Operation{
+		{ClientID: 0, Type: OpSet, Key: "a", Value: "1", Call: 1, Return: 5},
+		{ClientID: 1, Type: OpSet, Key: "a", Value: "2", Call: 2, Return: 6},
+		{ClientID: 2, Type: OpGet, Key: "a", Value: "2", Found: true, Call: 3, Return: 4},
+		{ClientID: 2, Type: OpGet, Key: "a", Value: "1", Found: true, Call: 7, Return: 8},
+	}
+	if r := Check(history); !r.Linearizable {
+		t.Errorf("Concurrent sets should be linearizable in either order. %s", r)
+	}
+
+	history = append(history, Operation{ClientID: 3, Type: OpGet, Key: "a", Value: "2", Found: true, Call: 9, Return: 10})
+	if r := Check(history); r.Linearizable {
+		t.Error("Reading an overwritten value after both sets finish should not be linearizable")
+	}
+}
+
+func TestCheckUnknown(t *testing.T) {
+	// a write with unknown outcome can take effect at any time after its invocation, or never
+	history := []Operation{
+		{ClientID: 0, Type: OpSet, Key: "a", Value: "1", Call: 1, Return: 2},
+		{ClientID: 1, Type: OpSet, Key: "a", Value: "2", Call: 3, Return: pendingReturn},
+		{ClientID: 2, Type: OpGet, Key: "a", Value: "1", Found: true, Call: 4, Return: 5},
+		{ClientID: 2, Type: OpGet, Key: "a", Value: "2", Found: true, Call: 6, Return: 7},
+	}
+	if r := Check(history); !r.Linearizable {
+		t.Errorf("Unknown write should be allowed to take effect late. %s", r)
+	}
+
+	history = []Operation{
+		{ClientID: 0, Type: OpSet, Key: "a", Value: "1", Call: 1, Return: 2},
+		{ClientID: 1, Type: OpSet, Key: "a", Value: "2", Call: 3, Return: pendingReturn},
+		{ClientID: 2, Type: OpGet, Key: "a", Value: "1", Found: true, Call: 4, Return: 5},
+	}
+	if r := Check(history); !r.Linearizable {
+		t.Errorf("Unknown write should be allowed to never take effect. %s", r)
+	}
+
+	history = []Operation{
+		{ClientID: 1, Type: OpSet, Key: "a", Value: "2", Call: 3, Return: pendingReturn},
+		{ClientID: 2, Type: OpGet, Key: "a", Value: "2", Found: true, Call: 1, Return: 2},
+	}
+	if r := Check(history); r.Linearizable {
+		t.Error("Unknown write should not take effect before it's invoked")
+	}
+}
+
+func TestCheckPartitionsByKey(t *testing.T) {
+	history := []Operation{
+		{Type: OpSet, Key: "a", Value: "1", Call: 1, Return: 2},
+		{Type: OpGet, Key: "b", Found: false, Call: 3, Return: 4},
+		{Type: OpGet, Key: "b", Value: "1", Found: true, Call: 5, Return: 6},
+	}
+
+	r := Check(history)
+	if r.Linearizable || r.Key != "b" {
+		t.Error("Check should report the key which is not linearizable")
+	}
+	if len(r.History) != 2 {
+		t.Error("Check should only report operations on the offending key")
+	}
+}
@@ -0,0 +1,145 @@
+package linearizability
+
+import (
+	"math"
+	"sync"
+
+	"github.com/sidecus/raft/pkg/util"
+)
+
+// OpType is the type of a kv operation
+type OpType int
+
+const (
+	// OpGet reads a key
+	OpGet = OpType(1)
+	// OpSet writes a key
+	OpSet = OpType(2)
+	// OpDelete deletes a key
+	OpDelete = OpType(3)
+)
+
+// pendingReturn is the return time used for operations with unknown outcome. Such operations
+// might take effect at any point after they are invoked, or never at all
+const pendingReturn = math.MaxInt64
+
+// Operation is one completed client operation in a history
+// Call and Return are logical timestamps, Return is pendingReturn if the outcome is unknown
+type Operation struct {
+	ClientID int
+	Type     OpType
+	Key      string
+	Value    string // value to set, or value returned by get
+	Found    bool   // whether get found the key
+	Call     int64
+	Return   int64
+}
+
+// Unknown tells whether the operation's outcome is indeterminate (e.g. timed out)
+func (op Operation) Unknown() bool {
+	return op.Return == pendingReturn
+}
+
+// Recorder records concurrent client operations into a history. It is concurrency safe.
+// Each operation starts with Invoke and ends with exactly one of Ok, Fail or Info, similar to Jepsen
+type Recorder struct {
+	mu      sync.Mutex
+	clock   int64
+	pending map[int]Operation
+	ops     []Operation
+	nextID  int
+}
+
+// NewRecorder creates a new history recorder
+func NewRecorder() *Recorder {
+	return &Recorder{
+		pending: make(map[int]Operation),
+	}
+}
+
+// Invoke records the invocation of an operation and returns an id for completing it
+// value is only meaningful for OpSet
+func (r *Recorder) Invoke(clientID int, opType OpType, key string, value string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	id := r.nextID
+	r.nextID++
+	r.pending[id] = Operation{
+		ClientID: clientID,
+		Type:     opType,
+		Key:      key,
+		Value:    value,
+		Call:     r.tick(),
+	}
+
+	return id
+}
+
+// Ok records a successful completion. value and found are only meaningful for OpGet
+func (r *Recorder) Ok(id int, value string, found bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	op := r.complete(id)
+	if op.Type == OpGet {
+		op.Value = value
+		op.Found = found
+	}
+	op.Return = r.tick()
+	r.ops = append(r.ops, op)
+}
+
+// Fail records an operation which definitely didn't take effect. It is dropped from the history
+func (r *Recorder) Fail(id int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.complete(id)
+}
+
+// Info records an operation with unknown outcome. Reads with unknown outcome are dropped
+// since they cannot change state, writes stay in the history as if they never returned
+func (r *Recorder) Info(id int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	op := r.complete(id)
+	if op.Type != OpGet {
+		op.Return = pendingReturn
+		r.ops = append(r.ops, op)
+	}
+}
+
+// History returns a copy of the recorded history. Operations still in flight are treated as unknown writes
+func (r *Recorder) History() []Operation {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	history := make([]Operation, len(r.ops), len(r.ops)+len(r.pending))
+	copy(history, r.ops)
+	for _, op := range r.pending {
+		if op.Type != OpGet {
+			op.Return = pendingReturn
+			history = append(history, op)
+		}
+	}
+
+	return history
+}
+
+// complete removes an operation from the pending list
+func (r *Recorder) complete(id int) Operation {
+	op, ok := r.pending[id]
+	if !ok {
+		util.Panicf("completing operation %d which is not pending\n", id)
+	}
+	delete(r.pending, id)
+	return op
+}
+
+// tick advances the logical clock. Caller should hold the lock
+func (r *Recorder) tick() int64 {
+	r.clock++
+	return r.clock
+}
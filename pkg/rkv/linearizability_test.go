@@ -0,0 +1,375 @@
+package rkv
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sidecus/raft/pkg/raft"
+	"github.com/sidecus/raft/pkg/rkv/linearizability"
+	"github.com/sidecus/raft/pkg/util"
+)
+
+var simDuration = flag.Duration("simduration", 5*time.Second, "how long the randomized linearizability test drives the simulated cluster")
+
+const simNodes = 5
+const simClients = 5
+const simFillers = 4
+const simKeys = 3
+
+var errorSimUnreachable = errors.New("simulated network: node unreachable")
+
+// simNetwork connects raft nodes within the same process. Links can be cut and nodes can be crashed.
+// A crashed node is cut off from all other nodes but keeps its state, as if it had persisted it before crashing
+type simNetwork struct {
+	mu      sync.RWMutex
+	nodes   map[int]raft.INode
+	crashed map[int]bool
+	cut     map[[2]int]bool
+
+	snapshots int32 // count of snapshots sent over the network
+}
+
+func newSimNetwork() *simNetwork {
+	return &simNetwork{
+		nodes:   make(map[int]raft.INode),
+		crashed: make(map[int]bool),
+		cut:     make(map[[2]int]bool),
+	}
+}
+
+// connected tells whether from can talk to to, and returns the target node if so
+func (net *simNetwork) connected(from, to int) (raft.INode, bool) {
+	net.mu.RLock()
+	defer net.mu.RUnlock()
+
+	if net.crashed[from] || net.crashed[to] || net.cut[[2]int{from, to}] {
+		return nil, false
+	}
+	return net.nodes[to], true
+}
+
+// partition splits nodes into two sides which cannot talk to each other
+func (net *simNetwork) partition(side []int) {
+	net.mu.Lock()
+	defer net.mu.Unlock()
+
+	inSide := make(map[int]bool, len(side))
+	for _, v := range side {
+		inSide[v] = true
+	}
+
+	for i := range net.nodes {
+		for j := range net.nodes {
+			if inSide[i] != inSide[j] {
+				net.cut[[2]int{i, j}] = true
+			}
+		}
+	}
+}
+
+func (net *simNetwork) crash(nodeID int) {
+	net.mu.Lock()
+	defer net.mu.Unlock()
+	net.crashed[nodeID] = true
+}
+
+// heal removes all partitions and brings crashed nodes back
+func (net *simNetwork) heal() {
+	net.mu.Lock()
+	defer net.mu.Unlock()
+	net.crashed = make(map[int]bool)
+	net.cut = make(map[[2]int]bool)
+}
+
+// isolateAll cuts off all nodes so that they stop making progress
+func (net *simNetwork) isolateAll() {
+	net.mu.Lock()
+	defer net.mu.Unlock()
+	for i := range net.nodes {
+		net.crashed[i] = true
+	}
+}
+
+// simProxyFactory creates simulated proxies for one node, implementing raft.IPeerProxyFactory
+type simProxyFactory struct {
+	net    *simNetwork
+	nodeID int
+}
+
+func (f *simProxyFactory) NewPeerProxy(info raft.NodeInfo) raft.IPeerProxy {
+	return &simProxy{net: f.net, from: f.nodeID, to: info.NodeID}
+}
+
+// simProxy calls into the target node directly when the link is up
+type simProxy struct {
+	net  *simNetwork
+	from int
+	to   int
+}
+
+func (p *simProxy) target() (raft.INode, error) {
+	// small random delay to shuffle message ordering
+	time.Sleep(time.Duration(rand.Intn(500)) * time.Microsecond)
+	if n, ok := p.net.connected(p.from, p.to); ok {
+		return n, nil
+	}
+	return nil, errorSimUnreachable
+}
+
+func (p *simProxy) AppendEntries(ctx context.Context, req *raft.AppendEntriesRequest) (*raft.AppendEntriesReply, error) {
+	n, err := p.target()
+	if err != nil {
+		return nil, err
+	}
+	return n.AppendEntries(ctx, req)
+}
+
+func (p *simProxy) RequestVote(ctx context.Context, req *raft.RequestVoteRequest) (*raft.RequestVoteReply, error) {
+	n, err := p.target()
+	if err != nil {
+		return nil, err
+	}
+	return n.RequestVote(ctx, req)
+}
+
+func (p *simProxy) TimeoutNow(ctx context.Context, req *raft.TimeoutNowRequest) (*raft.TimeoutNowReply, error) {
+	n, err := p.target()
+	if err != nil {
+		return nil, err
+	}
+	return n.TimeoutNow(ctx, req)
+}
+
+// InstallSnapshot goes through the snapshot stream reader so that the receiver gets its own copy of the file
+func (p *simProxy) InstallSnapshot(ctx context.Context, req *raft.SnapshotRequest) (*raft.AppendEntriesReply, error) {
+	n, err := p.target()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := ioutil.ReadFile(req.File)
+	if err != nil {
+		return nil, err
+	}
+
+	sent := false
+	recv := func() (*raft.SnapshotRequestHeader, []byte, error) {
+		if sent {
+			return nil, nil, io.EOF
+		}
+		sent = true
+		header := req.SnapshotRequestHeader
+		return &header, data, nil
+	}
+
+	reader, err := raft.NewSnapshotStreamReader(recv, n.OnSnapshotPart)
+	if err != nil {
+		return nil, err
+	}
+
+	received, err := raft.ReceiveSnapshot(n.NodeID(), reader)
+	if err != nil {
+		return nil, err
+	}
+	atomic.AddInt32(&p.net.snapshots, 1)
+
+	return n.InstallSnapshot(ctx, received)
+}
+
+func (p *simProxy) Get(ctx context.Context, req *raft.GetRequest) (*raft.GetReply, error) {
+	n, err := p.target()
+	if err != nil {
+		return nil, err
+	}
+	return n.Get(ctx, req)
+}
+
+func (p *simProxy) Execute(ctx context.Context, cmd *raft.StateMachineCmd) (*raft.ExecuteReply, error) {
+	n, err := p.target()
+	if err != nil {
+		return nil, err
+	}
+	return n.Execute(ctx, cmd)
+}
+
+// simCluster is a set of rkv nodes over a simulated network
+type simCluster struct {
+	net   *simNetwork
+	nodes []raft.INode
+}
+
+func newSimCluster(t *testing.T, size int) *simCluster {
+	net := newSimNetwork()
+	nodes := make([]raft.INode, size)
+	for i := 0; i < size; i++ {
+		peers := make(map[int]raft.NodeInfo)
+		for j := 0; j < size; j++ {
+			if j != i {
+				peers[j] = raft.NodeInfo{NodeID: j, Endpoint: fmt.Sprintf("sim%d", j)}
+			}
+		}
+
+		n, err := raft.NewNode(i, peers, newRKVStore(), &simProxyFactory{net: net, nodeID: i}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		nodes[i] = n
+		net.nodes[i] = n
+	}
+
+	for _, n := range nodes {
+		n.Start()
+	}
+
+	return &simCluster{net: net, nodes: nodes}
+}
+
+// runClient issues random set/get/delete operations against random nodes and records them
+func (c *simCluster) runClient(clientID int, recorder *linearizability.Recorder, deadline time.Time) {
+	for seq := 0; time.Now().Before(deadline); seq++ {
+		n := c.nodes[rand.Intn(len(c.nodes))]
+		key := fmt.Sprintf("key%d", rand.Intn(simKeys))
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+
+		switch r := rand.Intn(10); {
+		case r < 5:
+			id := recorder.Invoke(clientID, linearizability.OpGet, key, "")
+			reply, err := n.Get(ctx, &raft.GetRequest{Params: []interface{}{key}})
+			switch {
+			case err == nil:
+				recorder.Ok(id, reply.Data.(KVEntry).Value, true)
+			case errors.Is(err, errorKeyNotFound):
+				recorder.Ok(id, "", false)
+			default:
+				recorder.Info(id)
+			}
+		default:
+			// use unique values so that reads can tell writes apart, which keeps the check fast
+			cmd := &raft.StateMachineCmd{CmdType: KVCmdDel, Data: KVCmdData{Key: key}}
+			opType, value := linearizability.OpDelete, ""
+			if r < 9 {
+				value = fmt.Sprintf("c%d-%d", clientID, seq)
+				cmd = &raft.StateMachineCmd{CmdType: KVCmdSet, Data: KVCmdData{Key: key, Value: value}}
+				opType = linearizability.OpSet
+			}
+
+			id := recorder.Invoke(clientID, opType, key, value)
+			reply, err := n.Execute(ctx, cmd)
+			switch {
+			case err != nil:
+				// Execute only errors out before the cmd is appended to any log
+				recorder.Fail(id)
+			case reply.Success:
+				recorder.Ok(id, "", false)
+			default:
+				// appended but not committed yet, it might still be committed later.
+				// back off since the cluster is likely unable to make progress
+				recorder.Info(id)
+				time.Sleep(100 * time.Millisecond)
+			}
+		}
+
+		cancel()
+	}
+}
+
+// runFiller writes keys which are not checked, to grow logs fast enough to trigger snapshots
+func (c *simCluster) runFiller(fillerID int, deadline time.Time) {
+	for seq := 0; time.Now().Before(deadline); seq++ {
+		n := c.nodes[rand.Intn(len(c.nodes))]
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		n.Execute(ctx, &raft.StateMachineCmd{
+			CmdType: KVCmdSet,
+			Data:    KVCmdData{Key: fmt.Sprintf("filler%d-%d", fillerID, seq%100), Value: fmt.Sprint(seq)},
+		})
+		cancel()
+	}
+}
+
+// runNemesis periodically heals the cluster, partitions it randomly or crashes nodes
+func (c *simCluster) runNemesis(deadline time.Time) {
+	for time.Now().Before(deadline) {
+		c.net.heal()
+		time.Sleep(time.Duration(500+rand.Intn(1000)) * time.Millisecond)
+
+		switch rand.Intn(3) {
+		case 0:
+			// partition into a random minority and majority
+			perm := rand.Perm(len(c.nodes))
+			c.net.partition(perm[:1+rand.Intn(len(c.nodes)/2)])
+		case 1:
+			// crash up to a minority of nodes
+			for _, v := range rand.Perm(len(c.nodes))[:1+rand.Intn(len(c.nodes)/2)] {
+				c.net.crash(v)
+			}
+		default:
+			// crash a majority, no progress should be possible
+			for _, v := range rand.Perm(len(c.nodes))[:len(c.nodes)/2+1] {
+				c.net.crash(v)
+			}
+		}
+		time.Sleep(time.Duration(500+rand.Intn(1500)) * time.Millisecond)
+	}
+	c.net.heal()
+}
+
+// TestLinearizability drives a simulated cluster with concurrent clients while partitioning and crashing nodes,
+// and checks the recorded history is linearizable. Use -simduration to run longer
+func TestLinearizability(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping randomized linearizability test in short mode")
+	}
+
+	dir, err := ioutil.TempDir("", "rkvsim")
+	if err != nil {
+		t.Fatal(err)
+	}
+	raft.SetSnapshotPath(dir)
+	util.SetLogLevel(util.LevelError)
+	defer util.SetLogLevel(util.LevelInfo)
+
+	cluster := newSimCluster(t, simNodes)
+	recorder := linearizability.NewRecorder()
+	deadline := time.Now().Add(*simDuration)
+
+	var wg sync.WaitGroup
+	for i := 0; i < simClients; i++ {
+		wg.Add(1)
+		go func(clientID int) {
+			cluster.runClient(clientID, recorder, deadline)
+			wg.Done()
+		}(i)
+	}
+	for i := 0; i < simFillers; i++ {
+		wg.Add(1)
+		go func(fillerID int) {
+			cluster.runFiller(fillerID, deadline)
+			wg.Done()
+		}(i)
+	}
+	wg.Add(1)
+	go func() {
+		cluster.runNemesis(deadline)
+		wg.Done()
+	}()
+	wg.Wait()
+
+	// Nodes cannot be stopped and restarted yet, cut them off so that they stop making progress
+	cluster.net.isolateAll()
+
+	history := recorder.History()
+	t.Logf("%d operations recorded, %d snapshots sent", len(history), atomic.LoadInt32(&cluster.net.snapshots))
+
+	if result := linearizability.Check(history); !result.Linearizable {
+		t.Error(result)
+	}
+}
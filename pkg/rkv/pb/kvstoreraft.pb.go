@@ -1,13 +1,12 @@
 // Code generated by protoc-gen-go. DO NOT EDIT.
 // versions:
-// 	protoc-gen-go v1.25.0
+// 	protoc-gen-go v1.26.0
 // 	protoc        v3.14.0
 // source: pb/kvstoreraft.proto
 
 package pb
 
 import (
-	proto "github.com/golang/protobuf/proto"
 	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
 	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
 	reflect "reflect"
@@ -21,575 +20,6 @@ const (
 	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
 )
 
-// This is a compile-time assertion that a sufficiently up-to-date version
-// of the legacy proto package is being used.
-const _ = proto.ProtoPackageIsVersion4
-
-type KVCmdData struct {
-	state         protoimpl.MessageState
-	sizeCache     protoimpl.SizeCache
-	unknownFields protoimpl.UnknownFields
-
-	Key   string `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
-	Value string `protobuf:"bytes,2,opt,name=value,proto3" json:"value,omitempty"`
-}
-
-func (x *KVCmdData) Reset() {
-	*x = KVCmdData{}
-	if protoimpl.UnsafeEnabled {
-		mi := &file_pb_kvstoreraft_proto_msgTypes[0]
-		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
-		ms.StoreMessageInfo(mi)
-	}
-}
-
-func (x *KVCmdData) String() string {
-	return protoimpl.X.MessageStringOf(x)
-}
-
-func (*KVCmdData) ProtoMessage() {}
-
-func (x *KVCmdData) ProtoReflect() protoreflect.Message {
-	mi := &file_pb_kvstoreraft_proto_msgTypes[0]
-	if protoimpl.UnsafeEnabled && x != nil {
-		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
-		if ms.LoadMessageInfo() == nil {
-			ms.StoreMessageInfo(mi)
-		}
-		return ms
-	}
-	return mi.MessageOf(x)
-}
-
-// Deprecated: Use KVCmdData.ProtoReflect.Descriptor instead.
-func (*KVCmdData) Descriptor() ([]byte, []int) {
-	return file_pb_kvstoreraft_proto_rawDescGZIP(), []int{0}
-}
-
-func (x *KVCmdData) GetKey() string {
-	if x != nil {
-		return x.Key
-	}
-	return ""
-}
-
-func (x *KVCmdData) GetValue() string {
-	if x != nil {
-		return x.Value
-	}
-	return ""
-}
-
-type KVCmd struct {
-	state         protoimpl.MessageState
-	sizeCache     protoimpl.SizeCache
-	unknownFields protoimpl.UnknownFields
-
-	CmdType int32      `protobuf:"varint,1,opt,name=cmdType,proto3" json:"cmdType,omitempty"`
-	Data    *KVCmdData `protobuf:"bytes,2,opt,name=Data,proto3" json:"Data,omitempty"`
-}
-
-func (x *KVCmd) Reset() {
-	*x = KVCmd{}
-	if protoimpl.UnsafeEnabled {
-		mi := &file_pb_kvstoreraft_proto_msgTypes[1]
-		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
-		ms.StoreMessageInfo(mi)
-	}
-}
-
-func (x *KVCmd) String() string {
-	return protoimpl.X.MessageStringOf(x)
-}
-
-func (*KVCmd) ProtoMessage() {}
-
-func (x *KVCmd) ProtoReflect() protoreflect.Message {
-	mi := &file_pb_kvstoreraft_proto_msgTypes[1]
-	if protoimpl.UnsafeEnabled && x != nil {
-		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
-		if ms.LoadMessageInfo() == nil {
-			ms.StoreMessageInfo(mi)
-		}
-		return ms
-	}
-	return mi.MessageOf(x)
-}
-
-// Deprecated: Use KVCmd.ProtoReflect.Descriptor instead.
-func (*KVCmd) Descriptor() ([]byte, []int) {
-	return file_pb_kvstoreraft_proto_rawDescGZIP(), []int{1}
-}
-
-func (x *KVCmd) GetCmdType() int32 {
-	if x != nil {
-		return x.CmdType
-	}
-	return 0
-}
-
-func (x *KVCmd) GetData() *KVCmdData {
-	if x != nil {
-		return x.Data
-	}
-	return nil
-}
-
-type LogEntry struct {
-	state         protoimpl.MessageState
-	sizeCache     protoimpl.SizeCache
-	unknownFields protoimpl.UnknownFields
-
-	Index int64  `protobuf:"varint,1,opt,name=index,proto3" json:"index,omitempty"`
-	Term  int64  `protobuf:"varint,2,opt,name=term,proto3" json:"term,omitempty"`
-	Cmd   *KVCmd `protobuf:"bytes,3,opt,name=cmd,proto3" json:"cmd,omitempty"`
-}
-
-func (x *LogEntry) Reset() {
-	*x = LogEntry{}
-	if protoimpl.UnsafeEnabled {
-		mi := &file_pb_kvstoreraft_proto_msgTypes[2]
-		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
-		ms.StoreMessageInfo(mi)
-	}
-}
-
-func (x *LogEntry) String() string {
-	return protoimpl.X.MessageStringOf(x)
-}
-
-func (*LogEntry) ProtoMessage() {}
-
-func (x *LogEntry) ProtoReflect() protoreflect.Message {
-	mi := &file_pb_kvstoreraft_proto_msgTypes[2]
-	if protoimpl.UnsafeEnabled && x != nil {
-		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
-		if ms.LoadMessageInfo() == nil {
-			ms.StoreMessageInfo(mi)
-		}
-		return ms
-	}
-	return mi.MessageOf(x)
-}
-
-// Deprecated: Use LogEntry.ProtoReflect.Descriptor instead.
-func (*LogEntry) Descriptor() ([]byte, []int) {
-	return file_pb_kvstoreraft_proto_rawDescGZIP(), []int{2}
-}
-
-func (x *LogEntry) GetIndex() int64 {
-	if x != nil {
-		return x.Index
-	}
-	return 0
-}
-
-func (x *LogEntry) GetTerm() int64 {
-	if x != nil {
-		return x.Term
-	}
-	return 0
-}
-
-func (x *LogEntry) GetCmd() *KVCmd {
-	if x != nil {
-		return x.Cmd
-	}
-	return nil
-}
-
-// The append entry request
-type AppendEntriesRequest struct {
-	state         protoimpl.MessageState
-	sizeCache     protoimpl.SizeCache
-	unknownFields protoimpl.UnknownFields
-
-	Term         int64       `protobuf:"varint,1,opt,name=term,proto3" json:"term,omitempty"`
-	LeaderID     int64       `protobuf:"varint,2,opt,name=leaderID,proto3" json:"leaderID,omitempty"`
-	PrevLogIndex int64       `protobuf:"varint,3,opt,name=prevLogIndex,proto3" json:"prevLogIndex,omitempty"`
-	PrevLogTerm  int64       `protobuf:"varint,4,opt,name=prevLogTerm,proto3" json:"prevLogTerm,omitempty"`
-	LeaderCommit int64       `protobuf:"varint,5,opt,name=leaderCommit,proto3" json:"leaderCommit,omitempty"`
-	Entries      []*LogEntry `protobuf:"bytes,6,rep,name=entries,proto3" json:"entries,omitempty"`
-}
-
-func (x *AppendEntriesRequest) Reset() {
-	*x = AppendEntriesRequest{}
-	if protoimpl.UnsafeEnabled {
-		mi := &file_pb_kvstoreraft_proto_msgTypes[3]
-		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
-		ms.StoreMessageInfo(mi)
-	}
-}
-
-func (x *AppendEntriesRequest) String() string {
-	return protoimpl.X.MessageStringOf(x)
-}
-
-func (*AppendEntriesRequest) ProtoMessage() {}
-
-func (x *AppendEntriesRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_pb_kvstoreraft_proto_msgTypes[3]
-	if protoimpl.UnsafeEnabled && x != nil {
-		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
-		if ms.LoadMessageInfo() == nil {
-			ms.StoreMessageInfo(mi)
-		}
-		return ms
-	}
-	return mi.MessageOf(x)
-}
-
-// Deprecated: Use AppendEntriesRequest.ProtoReflect.Descriptor instead.
-func (*AppendEntriesRequest) Descriptor() ([]byte, []int) {
-	return file_pb_kvstoreraft_proto_rawDescGZIP(), []int{3}
-}
-
-func (x *AppendEntriesRequest) GetTerm() int64 {
-	if x != nil {
-		return x.Term
-	}
-	return 0
-}
-
-func (x *AppendEntriesRequest) GetLeaderID() int64 {
-	if x != nil {
-		return x.LeaderID
-	}
-	return 0
-}
-
-func (x *AppendEntriesRequest) GetPrevLogIndex() int64 {
-	if x != nil {
-		return x.PrevLogIndex
-	}
-	return 0
-}
-
-func (x *AppendEntriesRequest) GetPrevLogTerm() int64 {
-	if x != nil {
-		return x.PrevLogTerm
-	}
-	return 0
-}
-
-func (x *AppendEntriesRequest) GetLeaderCommit() int64 {
-	if x != nil {
-		return x.LeaderCommit
-	}
-	return 0
-}
-
-func (x *AppendEntriesRequest) GetEntries() []*LogEntry {
-	if x != nil {
-		return x.Entries
-	}
-	return nil
-}
-
-// Append entries reply
-type AppendEntriesReply struct {
-	state         protoimpl.MessageState
-	sizeCache     protoimpl.SizeCache
-	unknownFields protoimpl.UnknownFields
-
-	Term      int64 `protobuf:"varint,1,opt,name=term,proto3" json:"term,omitempty"`
-	NodeID    int64 `protobuf:"varint,2,opt,name=nodeID,proto3" json:"nodeID,omitempty"`
-	LeaderID  int64 `protobuf:"varint,3,opt,name=leaderID,proto3" json:"leaderID,omitempty"`
-	Success   bool  `protobuf:"varint,4,opt,name=success,proto3" json:"success,omitempty"`
-	LastMatch int64 `protobuf:"varint,5,opt,name=lastMatch,proto3" json:"lastMatch,omitempty"`
-}
-
-func (x *AppendEntriesReply) Reset() {
-	*x = AppendEntriesReply{}
-	if protoimpl.UnsafeEnabled {
-		mi := &file_pb_kvstoreraft_proto_msgTypes[4]
-		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
-		ms.StoreMessageInfo(mi)
-	}
-}
-
-func (x *AppendEntriesReply) String() string {
-	return protoimpl.X.MessageStringOf(x)
-}
-
-func (*AppendEntriesReply) ProtoMessage() {}
-
-func (x *AppendEntriesReply) ProtoReflect() protoreflect.Message {
-	mi := &file_pb_kvstoreraft_proto_msgTypes[4]
-	if protoimpl.UnsafeEnabled && x != nil {
-		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
-		if ms.LoadMessageInfo() == nil {
-			ms.StoreMessageInfo(mi)
-		}
-		return ms
-	}
-	return mi.MessageOf(x)
-}
-
-// Deprecated: Use AppendEntriesReply.ProtoReflect.Descriptor instead.
-func (*AppendEntriesReply) Descriptor() ([]byte, []int) {
-	return file_pb_kvstoreraft_proto_rawDescGZIP(), []int{4}
-}
-
-func (x *AppendEntriesReply) GetTerm() int64 {
-	if x != nil {
-		return x.Term
-	}
-	return 0
-}
-
-func (x *AppendEntriesReply) GetNodeID() int64 {
-	if x != nil {
-		return x.NodeID
-	}
-	return 0
-}
-
-func (x *AppendEntriesReply) GetLeaderID() int64 {
-	if x != nil {
-		return x.LeaderID
-	}
-	return 0
-}
-
-func (x *AppendEntriesReply) GetSuccess() bool {
-	if x != nil {
-		return x.Success
-	}
-	return false
-}
-
-func (x *AppendEntriesReply) GetLastMatch() int64 {
-	if x != nil {
-		return x.LastMatch
-	}
-	return 0
-}
-
-// The request vote request
-type RequestVoteRequest struct {
-	state         protoimpl.MessageState
-	sizeCache     protoimpl.SizeCache
-	unknownFields protoimpl.UnknownFields
-
-	Term         int64 `protobuf:"varint,1,opt,name=term,proto3" json:"term,omitempty"`
-	CandidateID  int64 `protobuf:"varint,2,opt,name=candidateID,proto3" json:"candidateID,omitempty"`
-	LastLogIndex int64 `protobuf:"varint,3,opt,name=lastLogIndex,proto3" json:"lastLogIndex,omitempty"`
-	LastLogTerm  int64 `protobuf:"varint,4,opt,name=lastLogTerm,proto3" json:"lastLogTerm,omitempty"`
-}
-
-func (x *RequestVoteRequest) Reset() {
-	*x = RequestVoteRequest{}
-	if protoimpl.UnsafeEnabled {
-		mi := &file_pb_kvstoreraft_proto_msgTypes[5]
-		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
-		ms.StoreMessageInfo(mi)
-	}
-}
-
-func (x *RequestVoteRequest) String() string {
-	return protoimpl.X.MessageStringOf(x)
-}
-
-func (*RequestVoteRequest) ProtoMessage() {}
-
-func (x *RequestVoteRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_pb_kvstoreraft_proto_msgTypes[5]
-	if protoimpl.UnsafeEnabled && x != nil {
-		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
-		if ms.LoadMessageInfo() == nil {
-			ms.StoreMessageInfo(mi)
-		}
-		return ms
-	}
-	return mi.MessageOf(x)
-}
-
-// Deprecated: Use RequestVoteRequest.ProtoReflect.Descriptor instead.
-func (*RequestVoteRequest) Descriptor() ([]byte, []int) {
-	return file_pb_kvstoreraft_proto_rawDescGZIP(), []int{5}
-}
-
-func (x *RequestVoteRequest) GetTerm() int64 {
-	if x != nil {
-		return x.Term
-	}
-	return 0
-}
-
-func (x *RequestVoteRequest) GetCandidateID() int64 {
-	if x != nil {
-		return x.CandidateID
-	}
-	return 0
-}
-
-func (x *RequestVoteRequest) GetLastLogIndex() int64 {
-	if x != nil {
-		return x.LastLogIndex
-	}
-	return 0
-}
-
-func (x *RequestVoteRequest) GetLastLogTerm() int64 {
-	if x != nil {
-		return x.LastLogTerm
-	}
-	return 0
-}
-
-// The request vote response
-type RequestVoteReply struct {
-	state         protoimpl.MessageState
-	sizeCache     protoimpl.SizeCache
-	unknownFields protoimpl.UnknownFields
-
-	Term        int64 `protobuf:"varint,1,opt,name=term,proto3" json:"term,omitempty"`
-	NodeID      int64 `protobuf:"varint,2,opt,name=nodeID,proto3" json:"nodeID,omitempty"`
-	VotedTerm   int64 `protobuf:"varint,3,opt,name=votedTerm,proto3" json:"votedTerm,omitempty"`
-	VoteGranted bool  `protobuf:"varint,4,opt,name=voteGranted,proto3" json:"voteGranted,omitempty"`
-}
-
-func (x *RequestVoteReply) Reset() {
-	*x = RequestVoteReply{}
-	if protoimpl.UnsafeEnabled {
-		mi := &file_pb_kvstoreraft_proto_msgTypes[6]
-		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
-		ms.StoreMessageInfo(mi)
-	}
-}
-
-func (x *RequestVoteReply) String() string {
-	return protoimpl.X.MessageStringOf(x)
-}
-
-func (*RequestVoteReply) ProtoMessage() {}
-
-func (x *RequestVoteReply) ProtoReflect() protoreflect.Message {
-	mi := &file_pb_kvstoreraft_proto_msgTypes[6]
-	if protoimpl.UnsafeEnabled && x != nil {
-		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
-		if ms.LoadMessageInfo() == nil {
-			ms.StoreMessageInfo(mi)
-		}
-		return ms
-	}
-	return mi.MessageOf(x)
-}
-
-// Deprecated: Use RequestVoteReply.ProtoReflect.Descriptor instead.
-func (*RequestVoteReply) Descriptor() ([]byte, []int) {
-	return file_pb_kvstoreraft_proto_rawDescGZIP(), []int{6}
-}
-
-func (x *RequestVoteReply) GetTerm() int64 {
-	if x != nil {
-		return x.Term
-	}
-	return 0
-}
-
-func (x *RequestVoteReply) GetNodeID() int64 {
-	if x != nil {
-		return x.NodeID
-	}
-	return 0
-}
-
-func (x *RequestVoteReply) GetVotedTerm() int64 {
-	if x != nil {
-		return x.VotedTerm
-	}
-	return 0
-}
-
-func (x *RequestVoteReply) GetVoteGranted() bool {
-	if x != nil {
-		return x.VoteGranted
-	}
-	return false
-}
-
-// Snapshot request - we'll stream this via gRPC
-type SnapshotRequest struct {
-	state         protoimpl.MessageState
-	sizeCache     protoimpl.SizeCache
-	unknownFields protoimpl.UnknownFields
-
-	Term          int64  `protobuf:"varint,1,opt,name=term,proto3" json:"term,omitempty"`
-	LeaderID      int64  `protobuf:"varint,2,opt,name=leaderID,proto3" json:"leaderID,omitempty"`
-	SnapshotIndex int64  `protobuf:"varint,3,opt,name=snapshotIndex,proto3" json:"snapshotIndex,omitempty"`
-	SnapshotTerm  int64  `protobuf:"varint,4,opt,name=snapshotTerm,proto3" json:"snapshotTerm,omitempty"`
-	Data          []byte `protobuf:"bytes,5,opt,name=data,proto3" json:"data,omitempty"`
-}
-
-func (x *SnapshotRequest) Reset() {
-	*x = SnapshotRequest{}
-	if protoimpl.UnsafeEnabled {
-		mi := &file_pb_kvstoreraft_proto_msgTypes[7]
-		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
-		ms.StoreMessageInfo(mi)
-	}
-}
-
-func (x *SnapshotRequest) String() string {
-	return protoimpl.X.MessageStringOf(x)
-}
-
-func (*SnapshotRequest) ProtoMessage() {}
-
-func (x *SnapshotRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_pb_kvstoreraft_proto_msgTypes[7]
-	if protoimpl.UnsafeEnabled && x != nil {
-		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
-		if ms.LoadMessageInfo() == nil {
-			ms.StoreMessageInfo(mi)
-		}
-		return ms
-	}
-	return mi.MessageOf(x)
-}
-
-// Deprecated: Use SnapshotRequest.ProtoReflect.Descriptor instead.
-func (*SnapshotRequest) Descriptor() ([]byte, []int) {
-	return file_pb_kvstoreraft_proto_rawDescGZIP(), []int{7}
-}
-
-func (x *SnapshotRequest) GetTerm() int64 {
-	if x != nil {
-		return x.Term
-	}
-	return 0
-}
-
-func (x *SnapshotRequest) GetLeaderID() int64 {
-	if x != nil {
-		return x.LeaderID
-	}
-	return 0
-}
-
-func (x *SnapshotRequest) GetSnapshotIndex() int64 {
-	if x != nil {
-		return x.SnapshotIndex
-	}
-	return 0
-}
-
-func (x *SnapshotRequest) GetSnapshotTerm() int64 {
-	if x != nil {
-		return x.SnapshotTerm
-	}
-	return 0
-}
-
-func (x *SnapshotRequest) GetData() []byte {
-	if x != nil {
-		return x.Data
-	}
-	return nil
-}
-
 // SetRequest is the message used to set a value into kvstore
 type SetRequest struct {
 	state         protoimpl.MessageState
@@ -603,7 +33,7 @@ type SetRequest struct {
 func (x *SetRequest) Reset() {
 	*x = SetRequest{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_pb_kvstoreraft_proto_msgTypes[8]
+		mi := &file_pb_kvstoreraft_proto_msgTypes[0]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -616,7 +46,7 @@ func (x *SetRequest) String() string {
 func (*SetRequest) ProtoMessage() {}
 
 func (x *SetRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_pb_kvstoreraft_proto_msgTypes[8]
+	mi := &file_pb_kvstoreraft_proto_msgTypes[0]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -629,7 +59,7 @@ func (x *SetRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use SetRequest.ProtoReflect.Descriptor instead.
 func (*SetRequest) Descriptor() ([]byte, []int) {
-	return file_pb_kvstoreraft_proto_rawDescGZIP(), []int{8}
+	return file_pb_kvstoreraft_proto_rawDescGZIP(), []int{0}
 }
 
 func (x *SetRequest) GetKey() string {
@@ -659,7 +89,7 @@ type SetReply struct {
 func (x *SetReply) Reset() {
 	*x = SetReply{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_pb_kvstoreraft_proto_msgTypes[9]
+		mi := &file_pb_kvstoreraft_proto_msgTypes[1]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -672,7 +102,7 @@ func (x *SetReply) String() string {
 func (*SetReply) ProtoMessage() {}
 
 func (x *SetReply) ProtoReflect() protoreflect.Message {
-	mi := &file_pb_kvstoreraft_proto_msgTypes[9]
+	mi := &file_pb_kvstoreraft_proto_msgTypes[1]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -685,7 +115,7 @@ func (x *SetReply) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use SetReply.ProtoReflect.Descriptor instead.
 func (*SetReply) Descriptor() ([]byte, []int) {
-	return file_pb_kvstoreraft_proto_rawDescGZIP(), []int{9}
+	return file_pb_kvstoreraft_proto_rawDescGZIP(), []int{1}
 }
 
 func (x *SetReply) GetNodeID() int64 {
@@ -714,7 +144,7 @@ type DeleteRequest struct {
 func (x *DeleteRequest) Reset() {
 	*x = DeleteRequest{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_pb_kvstoreraft_proto_msgTypes[10]
+		mi := &file_pb_kvstoreraft_proto_msgTypes[2]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -727,7 +157,7 @@ func (x *DeleteRequest) String() string {
 func (*DeleteRequest) ProtoMessage() {}
 
 func (x *DeleteRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_pb_kvstoreraft_proto_msgTypes[10]
+	mi := &file_pb_kvstoreraft_proto_msgTypes[2]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -740,7 +170,7 @@ func (x *DeleteRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use DeleteRequest.ProtoReflect.Descriptor instead.
 func (*DeleteRequest) Descriptor() ([]byte, []int) {
-	return file_pb_kvstoreraft_proto_rawDescGZIP(), []int{10}
+	return file_pb_kvstoreraft_proto_rawDescGZIP(), []int{2}
 }
 
 func (x *DeleteRequest) GetKey() string {
@@ -763,7 +193,7 @@ type DeleteReply struct {
 func (x *DeleteReply) Reset() {
 	*x = DeleteReply{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_pb_kvstoreraft_proto_msgTypes[11]
+		mi := &file_pb_kvstoreraft_proto_msgTypes[3]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -776,7 +206,7 @@ func (x *DeleteReply) String() string {
 func (*DeleteReply) ProtoMessage() {}
 
 func (x *DeleteReply) ProtoReflect() protoreflect.Message {
-	mi := &file_pb_kvstoreraft_proto_msgTypes[11]
+	mi := &file_pb_kvstoreraft_proto_msgTypes[3]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -789,7 +219,7 @@ func (x *DeleteReply) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use DeleteReply.ProtoReflect.Descriptor instead.
 func (*DeleteReply) Descriptor() ([]byte, []int) {
-	return file_pb_kvstoreraft_proto_rawDescGZIP(), []int{11}
+	return file_pb_kvstoreraft_proto_rawDescGZIP(), []int{3}
 }
 
 func (x *DeleteReply) GetNodeID() int64 {
@@ -818,7 +248,7 @@ type GetRequest struct {
 func (x *GetRequest) Reset() {
 	*x = GetRequest{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_pb_kvstoreraft_proto_msgTypes[12]
+		mi := &file_pb_kvstoreraft_proto_msgTypes[4]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -831,7 +261,7 @@ func (x *GetRequest) String() string {
 func (*GetRequest) ProtoMessage() {}
 
 func (x *GetRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_pb_kvstoreraft_proto_msgTypes[12]
+	mi := &file_pb_kvstoreraft_proto_msgTypes[4]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -844,7 +274,7 @@ func (x *GetRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use GetRequest.ProtoReflect.Descriptor instead.
 func (*GetRequest) Descriptor() ([]byte, []int) {
-	return file_pb_kvstoreraft_proto_rawDescGZIP(), []int{12}
+	return file_pb_kvstoreraft_proto_rawDescGZIP(), []int{4}
 }
 
 func (x *GetRequest) GetKey() string {
@@ -868,7 +298,7 @@ type GetReply struct {
 func (x *GetReply) Reset() {
 	*x = GetReply{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_pb_kvstoreraft_proto_msgTypes[13]
+		mi := &file_pb_kvstoreraft_proto_msgTypes[5]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -881,7 +311,7 @@ func (x *GetReply) String() string {
 func (*GetReply) ProtoMessage() {}
 
 func (x *GetReply) ProtoReflect() protoreflect.Message {
-	mi := &file_pb_kvstoreraft_proto_msgTypes[13]
+	mi := &file_pb_kvstoreraft_proto_msgTypes[5]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -894,7 +324,7 @@ func (x *GetReply) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use GetReply.ProtoReflect.Descriptor instead.
 func (*GetReply) Descriptor() ([]byte, []int) {
-	return file_pb_kvstoreraft_proto_rawDescGZIP(), []int{13}
+	return file_pb_kvstoreraft_proto_rawDescGZIP(), []int{5}
 }
 
 func (x *GetReply) GetNodeID() int64 {
@@ -918,122 +348,107 @@ func (x *GetReply) GetValue() string {
 	return ""
 }
 
+// LeaderHint is attached as a detail to FailedPrecondition (not leader) status errors,
+// telling clients which node to redirect to
+type LeaderHint struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	LeaderID int64  `protobuf:"varint,1,opt,name=leaderID,proto3" json:"leaderID,omitempty"`
+	Endpoint string `protobuf:"bytes,2,opt,name=endpoint,proto3" json:"endpoint,omitempty"`
+}
+
+func (x *LeaderHint) Reset() {
+	*x = LeaderHint{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_pb_kvstoreraft_proto_msgTypes[6]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *LeaderHint) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LeaderHint) ProtoMessage() {}
+
+func (x *LeaderHint) ProtoReflect() protoreflect.Message {
+	mi := &file_pb_kvstoreraft_proto_msgTypes[6]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LeaderHint.ProtoReflect.Descriptor instead.
+func (*LeaderHint) Descriptor() ([]byte, []int) {
+	return file_pb_kvstoreraft_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *LeaderHint) GetLeaderID() int64 {
+	if x != nil {
+		return x.LeaderID
+	}
+	return 0
+}
+
+func (x *LeaderHint) GetEndpoint() string {
+	if x != nil {
+		return x.Endpoint
+	}
+	return ""
+}
+
 var File_pb_kvstoreraft_proto protoreflect.FileDescriptor
 
 var file_pb_kvstoreraft_proto_rawDesc = []byte{
 	0x0a, 0x14, 0x70, 0x62, 0x2f, 0x6b, 0x76, 0x73, 0x74, 0x6f, 0x72, 0x65, 0x72, 0x61, 0x66, 0x74,
-	0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12, 0x02, 0x70, 0x62, 0x22, 0x33, 0x0a, 0x09, 0x4b, 0x56,
-	0x43, 0x6d, 0x64, 0x44, 0x61, 0x74, 0x61, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x01,
-	0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c,
-	0x75, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x22,
-	0x44, 0x0a, 0x05, 0x4b, 0x56, 0x43, 0x6d, 0x64, 0x12, 0x18, 0x0a, 0x07, 0x63, 0x6d, 0x64, 0x54,
-	0x79, 0x70, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x05, 0x52, 0x07, 0x63, 0x6d, 0x64, 0x54, 0x79,
-	0x70, 0x65, 0x12, 0x21, 0x0a, 0x04, 0x44, 0x61, 0x74, 0x61, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b,
-	0x32, 0x0d, 0x2e, 0x70, 0x62, 0x2e, 0x4b, 0x56, 0x43, 0x6d, 0x64, 0x44, 0x61, 0x74, 0x61, 0x52,
-	0x04, 0x44, 0x61, 0x74, 0x61, 0x22, 0x51, 0x0a, 0x08, 0x4c, 0x6f, 0x67, 0x45, 0x6e, 0x74, 0x72,
-	0x79, 0x12, 0x14, 0x0a, 0x05, 0x69, 0x6e, 0x64, 0x65, 0x78, 0x18, 0x01, 0x20, 0x01, 0x28, 0x03,
-	0x52, 0x05, 0x69, 0x6e, 0x64, 0x65, 0x78, 0x12, 0x12, 0x0a, 0x04, 0x74, 0x65, 0x72, 0x6d, 0x18,
-	0x02, 0x20, 0x01, 0x28, 0x03, 0x52, 0x04, 0x74, 0x65, 0x72, 0x6d, 0x12, 0x1b, 0x0a, 0x03, 0x63,
-	0x6d, 0x64, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x09, 0x2e, 0x70, 0x62, 0x2e, 0x4b, 0x56,
-	0x43, 0x6d, 0x64, 0x52, 0x03, 0x63, 0x6d, 0x64, 0x22, 0xd8, 0x01, 0x0a, 0x14, 0x41, 0x70, 0x70,
-	0x65, 0x6e, 0x64, 0x45, 0x6e, 0x74, 0x72, 0x69, 0x65, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73,
-	0x74, 0x12, 0x12, 0x0a, 0x04, 0x74, 0x65, 0x72, 0x6d, 0x18, 0x01, 0x20, 0x01, 0x28, 0x03, 0x52,
-	0x04, 0x74, 0x65, 0x72, 0x6d, 0x12, 0x1a, 0x0a, 0x08, 0x6c, 0x65, 0x61, 0x64, 0x65, 0x72, 0x49,
-	0x44, 0x18, 0x02, 0x20, 0x01, 0x28, 0x03, 0x52, 0x08, 0x6c, 0x65, 0x61, 0x64, 0x65, 0x72, 0x49,
-	0x44, 0x12, 0x22, 0x0a, 0x0c, 0x70, 0x72, 0x65, 0x76, 0x4c, 0x6f, 0x67, 0x49, 0x6e, 0x64, 0x65,
-	0x78, 0x18, 0x03, 0x20, 0x01, 0x28, 0x03, 0x52, 0x0c, 0x70, 0x72, 0x65, 0x76, 0x4c, 0x6f, 0x67,
-	0x49, 0x6e, 0x64, 0x65, 0x78, 0x12, 0x20, 0x0a, 0x0b, 0x70, 0x72, 0x65, 0x76, 0x4c, 0x6f, 0x67,
-	0x54, 0x65, 0x72, 0x6d, 0x18, 0x04, 0x20, 0x01, 0x28, 0x03, 0x52, 0x0b, 0x70, 0x72, 0x65, 0x76,
-	0x4c, 0x6f, 0x67, 0x54, 0x65, 0x72, 0x6d, 0x12, 0x22, 0x0a, 0x0c, 0x6c, 0x65, 0x61, 0x64, 0x65,
-	0x72, 0x43, 0x6f, 0x6d, 0x6d, 0x69, 0x74, 0x18, 0x05, 0x20, 0x01, 0x28, 0x03, 0x52, 0x0c, 0x6c,
-	0x65, 0x61, 0x64, 0x65, 0x72, 0x43, 0x6f, 0x6d, 0x6d, 0x69, 0x74, 0x12, 0x26, 0x0a, 0x07, 0x65,
-	0x6e, 0x74, 0x72, 0x69, 0x65, 0x73, 0x18, 0x06, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x0c, 0x2e, 0x70,
-	0x62, 0x2e, 0x4c, 0x6f, 0x67, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x52, 0x07, 0x65, 0x6e, 0x74, 0x72,
-	0x69, 0x65, 0x73, 0x22, 0x94, 0x01, 0x0a, 0x12, 0x41, 0x70, 0x70, 0x65, 0x6e, 0x64, 0x45, 0x6e,
-	0x74, 0x72, 0x69, 0x65, 0x73, 0x52, 0x65, 0x70, 0x6c, 0x79, 0x12, 0x12, 0x0a, 0x04, 0x74, 0x65,
-	0x72, 0x6d, 0x18, 0x01, 0x20, 0x01, 0x28, 0x03, 0x52, 0x04, 0x74, 0x65, 0x72, 0x6d, 0x12, 0x16,
-	0x0a, 0x06, 0x6e, 0x6f, 0x64, 0x65, 0x49, 0x44, 0x18, 0x02, 0x20, 0x01, 0x28, 0x03, 0x52, 0x06,
-	0x6e, 0x6f, 0x64, 0x65, 0x49, 0x44, 0x12, 0x1a, 0x0a, 0x08, 0x6c, 0x65, 0x61, 0x64, 0x65, 0x72,
-	0x49, 0x44, 0x18, 0x03, 0x20, 0x01, 0x28, 0x03, 0x52, 0x08, 0x6c, 0x65, 0x61, 0x64, 0x65, 0x72,
-	0x49, 0x44, 0x12, 0x18, 0x0a, 0x07, 0x73, 0x75, 0x63, 0x63, 0x65, 0x73, 0x73, 0x18, 0x04, 0x20,
-	0x01, 0x28, 0x08, 0x52, 0x07, 0x73, 0x75, 0x63, 0x63, 0x65, 0x73, 0x73, 0x12, 0x1c, 0x0a, 0x09,
-	0x6c, 0x61, 0x73, 0x74, 0x4d, 0x61, 0x74, 0x63, 0x68, 0x18, 0x05, 0x20, 0x01, 0x28, 0x03, 0x52,
-	0x09, 0x6c, 0x61, 0x73, 0x74, 0x4d, 0x61, 0x74, 0x63, 0x68, 0x22, 0x90, 0x01, 0x0a, 0x12, 0x52,
-	0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x56, 0x6f, 0x74, 0x65, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73,
-	0x74, 0x12, 0x12, 0x0a, 0x04, 0x74, 0x65, 0x72, 0x6d, 0x18, 0x01, 0x20, 0x01, 0x28, 0x03, 0x52,
-	0x04, 0x74, 0x65, 0x72, 0x6d, 0x12, 0x20, 0x0a, 0x0b, 0x63, 0x61, 0x6e, 0x64, 0x69, 0x64, 0x61,
-	0x74, 0x65, 0x49, 0x44, 0x18, 0x02, 0x20, 0x01, 0x28, 0x03, 0x52, 0x0b, 0x63, 0x61, 0x6e, 0x64,
-	0x69, 0x64, 0x61, 0x74, 0x65, 0x49, 0x44, 0x12, 0x22, 0x0a, 0x0c, 0x6c, 0x61, 0x73, 0x74, 0x4c,
-	0x6f, 0x67, 0x49, 0x6e, 0x64, 0x65, 0x78, 0x18, 0x03, 0x20, 0x01, 0x28, 0x03, 0x52, 0x0c, 0x6c,
-	0x61, 0x73, 0x74, 0x4c, 0x6f, 0x67, 0x49, 0x6e, 0x64, 0x65, 0x78, 0x12, 0x20, 0x0a, 0x0b, 0x6c,
-	0x61, 0x73, 0x74, 0x4c, 0x6f, 0x67, 0x54, 0x65, 0x72, 0x6d, 0x18, 0x04, 0x20, 0x01, 0x28, 0x03,
-	0x52, 0x0b, 0x6c, 0x61, 0x73, 0x74, 0x4c, 0x6f, 0x67, 0x54, 0x65, 0x72, 0x6d, 0x22, 0x7e, 0x0a,
-	0x10, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x56, 0x6f, 0x74, 0x65, 0x52, 0x65, 0x70, 0x6c,
-	0x79, 0x12, 0x12, 0x0a, 0x04, 0x74, 0x65, 0x72, 0x6d, 0x18, 0x01, 0x20, 0x01, 0x28, 0x03, 0x52,
-	0x04, 0x74, 0x65, 0x72, 0x6d, 0x12, 0x16, 0x0a, 0x06, 0x6e, 0x6f, 0x64, 0x65, 0x49, 0x44, 0x18,
-	0x02, 0x20, 0x01, 0x28, 0x03, 0x52, 0x06, 0x6e, 0x6f, 0x64, 0x65, 0x49, 0x44, 0x12, 0x1c, 0x0a,
-	0x09, 0x76, 0x6f, 0x74, 0x65, 0x64, 0x54, 0x65, 0x72, 0x6d, 0x18, 0x03, 0x20, 0x01, 0x28, 0x03,
-	0x52, 0x09, 0x76, 0x6f, 0x74, 0x65, 0x64, 0x54, 0x65, 0x72, 0x6d, 0x12, 0x20, 0x0a, 0x0b, 0x76,
-	0x6f, 0x74, 0x65, 0x47, 0x72, 0x61, 0x6e, 0x74, 0x65, 0x64, 0x18, 0x04, 0x20, 0x01, 0x28, 0x08,
-	0x52, 0x0b, 0x76, 0x6f, 0x74, 0x65, 0x47, 0x72, 0x61, 0x6e, 0x74, 0x65, 0x64, 0x22, 0x9f, 0x01,
-	0x0a, 0x0f, 0x53, 0x6e, 0x61, 0x70, 0x73, 0x68, 0x6f, 0x74, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73,
-	0x74, 0x12, 0x12, 0x0a, 0x04, 0x74, 0x65, 0x72, 0x6d, 0x18, 0x01, 0x20, 0x01, 0x28, 0x03, 0x52,
-	0x04, 0x74, 0x65, 0x72, 0x6d, 0x12, 0x1a, 0x0a, 0x08, 0x6c, 0x65, 0x61, 0x64, 0x65, 0x72, 0x49,
-	0x44, 0x18, 0x02, 0x20, 0x01, 0x28, 0x03, 0x52, 0x08, 0x6c, 0x65, 0x61, 0x64, 0x65, 0x72, 0x49,
-	0x44, 0x12, 0x24, 0x0a, 0x0d, 0x73, 0x6e, 0x61, 0x70, 0x73, 0x68, 0x6f, 0x74, 0x49, 0x6e, 0x64,
-	0x65, 0x78, 0x18, 0x03, 0x20, 0x01, 0x28, 0x03, 0x52, 0x0d, 0x73, 0x6e, 0x61, 0x70, 0x73, 0x68,
-	0x6f, 0x74, 0x49, 0x6e, 0x64, 0x65, 0x78, 0x12, 0x22, 0x0a, 0x0c, 0x73, 0x6e, 0x61, 0x70, 0x73,
-	0x68, 0x6f, 0x74, 0x54, 0x65, 0x72, 0x6d, 0x18, 0x04, 0x20, 0x01, 0x28, 0x03, 0x52, 0x0c, 0x73,
-	0x6e, 0x61, 0x70, 0x73, 0x68, 0x6f, 0x74, 0x54, 0x65, 0x72, 0x6d, 0x12, 0x12, 0x0a, 0x04, 0x64,
-	0x61, 0x74, 0x61, 0x18, 0x05, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x04, 0x64, 0x61, 0x74, 0x61, 0x22,
-	0x34, 0x0a, 0x0a, 0x53, 0x65, 0x74, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x10, 0x0a,
-	0x03, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x12,
-	0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05,
-	0x76, 0x61, 0x6c, 0x75, 0x65, 0x22, 0x3c, 0x0a, 0x08, 0x53, 0x65, 0x74, 0x52, 0x65, 0x70, 0x6c,
-	0x79, 0x12, 0x16, 0x0a, 0x06, 0x6e, 0x6f, 0x64, 0x65, 0x49, 0x44, 0x18, 0x01, 0x20, 0x01, 0x28,
-	0x03, 0x52, 0x06, 0x6e, 0x6f, 0x64, 0x65, 0x49, 0x44, 0x12, 0x18, 0x0a, 0x07, 0x73, 0x75, 0x63,
-	0x63, 0x65, 0x73, 0x73, 0x18, 0x02, 0x20, 0x01, 0x28, 0x08, 0x52, 0x07, 0x73, 0x75, 0x63, 0x63,
-	0x65, 0x73, 0x73, 0x22, 0x21, 0x0a, 0x0d, 0x44, 0x65, 0x6c, 0x65, 0x74, 0x65, 0x52, 0x65, 0x71,
-	0x75, 0x65, 0x73, 0x74, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28,
-	0x09, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x22, 0x3f, 0x0a, 0x0b, 0x44, 0x65, 0x6c, 0x65, 0x74, 0x65,
-	0x52, 0x65, 0x70, 0x6c, 0x79, 0x12, 0x16, 0x0a, 0x06, 0x6e, 0x6f, 0x64, 0x65, 0x49, 0x44, 0x18,
-	0x01, 0x20, 0x01, 0x28, 0x03, 0x52, 0x06, 0x6e, 0x6f, 0x64, 0x65, 0x49, 0x44, 0x12, 0x18, 0x0a,
-	0x07, 0x73, 0x75, 0x63, 0x63, 0x65, 0x73, 0x73, 0x18, 0x02, 0x20, 0x01, 0x28, 0x08, 0x52, 0x07,
-	0x73, 0x75, 0x63, 0x63, 0x65, 0x73, 0x73, 0x22, 0x1e, 0x0a, 0x0a, 0x47, 0x65, 0x74, 0x52, 0x65,
-	0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01,
-	0x28, 0x09, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x22, 0x52, 0x0a, 0x08, 0x47, 0x65, 0x74, 0x52, 0x65,
-	0x70, 0x6c, 0x79, 0x12, 0x16, 0x0a, 0x06, 0x6e, 0x6f, 0x64, 0x65, 0x49, 0x44, 0x18, 0x01, 0x20,
-	0x01, 0x28, 0x03, 0x52, 0x06, 0x6e, 0x6f, 0x64, 0x65, 0x49, 0x44, 0x12, 0x18, 0x0a, 0x07, 0x73,
-	0x75, 0x63, 0x63, 0x65, 0x73, 0x73, 0x18, 0x02, 0x20, 0x01, 0x28, 0x08, 0x52, 0x07, 0x73, 0x75,
-	0x63, 0x63, 0x65, 0x73, 0x73, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x03,
-	0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x32, 0xd3, 0x02, 0x0a, 0x0b,
-	0x4b, 0x56, 0x53, 0x74, 0x6f, 0x72, 0x65, 0x52, 0x61, 0x66, 0x74, 0x12, 0x43, 0x0a, 0x0d, 0x41,
-	0x70, 0x70, 0x65, 0x6e, 0x64, 0x45, 0x6e, 0x74, 0x72, 0x69, 0x65, 0x73, 0x12, 0x18, 0x2e, 0x70,
-	0x62, 0x2e, 0x41, 0x70, 0x70, 0x65, 0x6e, 0x64, 0x45, 0x6e, 0x74, 0x72, 0x69, 0x65, 0x73, 0x52,
-	0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x16, 0x2e, 0x70, 0x62, 0x2e, 0x41, 0x70, 0x70, 0x65,
-	0x6e, 0x64, 0x45, 0x6e, 0x74, 0x72, 0x69, 0x65, 0x73, 0x52, 0x65, 0x70, 0x6c, 0x79, 0x22, 0x00,
-	0x12, 0x3d, 0x0a, 0x0b, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x56, 0x6f, 0x74, 0x65, 0x12,
-	0x16, 0x2e, 0x70, 0x62, 0x2e, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x56, 0x6f, 0x74, 0x65,
-	0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x14, 0x2e, 0x70, 0x62, 0x2e, 0x52, 0x65, 0x71,
-	0x75, 0x65, 0x73, 0x74, 0x56, 0x6f, 0x74, 0x65, 0x52, 0x65, 0x70, 0x6c, 0x79, 0x22, 0x00, 0x12,
-	0x42, 0x0a, 0x0f, 0x49, 0x6e, 0x73, 0x74, 0x61, 0x6c, 0x6c, 0x53, 0x6e, 0x61, 0x70, 0x73, 0x68,
-	0x6f, 0x74, 0x12, 0x13, 0x2e, 0x70, 0x62, 0x2e, 0x53, 0x6e, 0x61, 0x70, 0x73, 0x68, 0x6f, 0x74,
-	0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x16, 0x2e, 0x70, 0x62, 0x2e, 0x41, 0x70, 0x70,
-	0x65, 0x6e, 0x64, 0x45, 0x6e, 0x74, 0x72, 0x69, 0x65, 0x73, 0x52, 0x65, 0x70, 0x6c, 0x79, 0x22,
-	0x00, 0x28, 0x01, 0x12, 0x25, 0x0a, 0x03, 0x53, 0x65, 0x74, 0x12, 0x0e, 0x2e, 0x70, 0x62, 0x2e,
-	0x53, 0x65, 0x74, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x0c, 0x2e, 0x70, 0x62, 0x2e,
-	0x53, 0x65, 0x74, 0x52, 0x65, 0x70, 0x6c, 0x79, 0x22, 0x00, 0x12, 0x2e, 0x0a, 0x06, 0x44, 0x65,
-	0x6c, 0x65, 0x74, 0x65, 0x12, 0x11, 0x2e, 0x70, 0x62, 0x2e, 0x44, 0x65, 0x6c, 0x65, 0x74, 0x65,
-	0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x0f, 0x2e, 0x70, 0x62, 0x2e, 0x44, 0x65, 0x6c,
-	0x65, 0x74, 0x65, 0x52, 0x65, 0x70, 0x6c, 0x79, 0x22, 0x00, 0x12, 0x25, 0x0a, 0x03, 0x47, 0x65,
-	0x74, 0x12, 0x0e, 0x2e, 0x70, 0x62, 0x2e, 0x47, 0x65, 0x74, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73,
-	0x74, 0x1a, 0x0c, 0x2e, 0x70, 0x62, 0x2e, 0x47, 0x65, 0x74, 0x52, 0x65, 0x70, 0x6c, 0x79, 0x22,
-	0x00, 0x42, 0x4c, 0x0a, 0x1f, 0x63, 0x6f, 0x6d, 0x2e, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e,
-	0x73, 0x69, 0x64, 0x65, 0x63, 0x75, 0x73, 0x2e, 0x72, 0x61, 0x66, 0x74, 0x2e, 0x70, 0x6b, 0x67,
-	0x2e, 0x72, 0x6b, 0x76, 0x42, 0x03, 0x52, 0x4b, 0x56, 0x50, 0x01, 0x5a, 0x22, 0x67, 0x69, 0x74,
-	0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x73, 0x69, 0x64, 0x65, 0x63, 0x75, 0x73, 0x2f,
-	0x72, 0x61, 0x66, 0x74, 0x2f, 0x70, 0x6b, 0x67, 0x2f, 0x72, 0x6b, 0x76, 0x2f, 0x70, 0x62, 0x62,
-	0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+	0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12, 0x02, 0x70, 0x62, 0x22, 0x34, 0x0a, 0x0a, 0x53, 0x65,
+	0x74, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18,
+	0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61,
+	0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65,
+	0x22, 0x3c, 0x0a, 0x08, 0x53, 0x65, 0x74, 0x52, 0x65, 0x70, 0x6c, 0x79, 0x12, 0x16, 0x0a, 0x06,
+	0x6e, 0x6f, 0x64, 0x65, 0x49, 0x44, 0x18, 0x01, 0x20, 0x01, 0x28, 0x03, 0x52, 0x06, 0x6e, 0x6f,
+	0x64, 0x65, 0x49, 0x44, 0x12, 0x18, 0x0a, 0x07, 0x73, 0x75, 0x63, 0x63, 0x65, 0x73, 0x73, 0x18,
+	0x02, 0x20, 0x01, 0x28, 0x08, 0x52, 0x07, 0x73, 0x75, 0x63, 0x63, 0x65, 0x73, 0x73, 0x22, 0x21,
+	0x0a, 0x0d, 0x44, 0x65, 0x6c, 0x65, 0x74, 0x65, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12,
+	0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6b, 0x65,
+	0x79, 0x22, 0x3f, 0x0a, 0x0b, 0x44, 0x65, 0x6c, 0x65, 0x74, 0x65, 0x52, 0x65, 0x70, 0x6c, 0x79,
+	0x12, 0x16, 0x0a, 0x06, 0x6e, 0x6f, 0x64, 0x65, 0x49, 0x44, 0x18, 0x01, 0x20, 0x01, 0x28, 0x03,
+	0x52, 0x06, 0x6e, 0x6f, 0x64, 0x65, 0x49, 0x44, 0x12, 0x18, 0x0a, 0x07, 0x73, 0x75, 0x63, 0x63,
+	0x65, 0x73, 0x73, 0x18, 0x02, 0x20, 0x01, 0x28, 0x08, 0x52, 0x07, 0x73, 0x75, 0x63, 0x63, 0x65,
+	0x73, 0x73, 0x22, 0x1e, 0x0a, 0x0a, 0x47, 0x65, 0x74, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74,
+	0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6b,
+	0x65, 0x79, 0x22, 0x52, 0x0a, 0x08, 0x47, 0x65, 0x74, 0x52, 0x65, 0x70, 0x6c, 0x79, 0x12, 0x16,
+	0x0a, 0x06, 0x6e, 0x6f, 0x64, 0x65, 0x49, 0x44, 0x18, 0x01, 0x20, 0x01, 0x28, 0x03, 0x52, 0x06,
+	0x6e, 0x6f, 0x64, 0x65, 0x49, 0x44, 0x12, 0x18, 0x0a, 0x07, 0x73, 0x75, 0x63, 0x63, 0x65, 0x73,
+	0x73, 0x18, 0x02, 0x20, 0x01, 0x28, 0x08, 0x52, 0x07, 0x73, 0x75, 0x63, 0x63, 0x65, 0x73, 0x73,
+	0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x22, 0x44, 0x0a, 0x0a, 0x4c, 0x65, 0x61, 0x64, 0x65, 0x72,
+	0x48, 0x69, 0x6e, 0x74, 0x12, 0x1a, 0x0a, 0x08, 0x6c, 0x65, 0x61, 0x64, 0x65, 0x72, 0x49, 0x44,
+	0x18, 0x01, 0x20, 0x01, 0x28, 0x03, 0x52, 0x08, 0x6c, 0x65, 0x61, 0x64, 0x65, 0x72, 0x49, 0x44,
+	0x12, 0x1a, 0x0a, 0x08, 0x65, 0x6e, 0x64, 0x70, 0x6f, 0x69, 0x6e, 0x74, 0x18, 0x02, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x08, 0x65, 0x6e, 0x64, 0x70, 0x6f, 0x69, 0x6e, 0x74, 0x32, 0x8b, 0x01, 0x0a,
+	0x0b, 0x4b, 0x56, 0x53, 0x74, 0x6f, 0x72, 0x65, 0x52, 0x61, 0x66, 0x74, 0x12, 0x25, 0x0a, 0x03,
+	0x53, 0x65, 0x74, 0x12, 0x0e, 0x2e, 0x70, 0x62, 0x2e, 0x53, 0x65, 0x74, 0x52, 0x65, 0x71, 0x75,
+	0x65, 0x73, 0x74, 0x1a, 0x0c, 0x2e, 0x70, 0x62, 0x2e, 0x53, 0x65, 0x74, 0x52, 0x65, 0x70, 0x6c,
+	0x79, 0x22, 0x00, 0x12, 0x2e, 0x0a, 0x06, 0x44, 0x65, 0x6c, 0x65, 0x74, 0x65, 0x12, 0x11, 0x2e,
+	0x70, 0x62, 0x2e, 0x44, 0x65, 0x6c, 0x65, 0x74, 0x65, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74,
+	0x1a, 0x0f, 0x2e, 0x70, 0x62, 0x2e, 0x44, 0x65, 0x6c, 0x65, 0x74, 0x65, 0x52, 0x65, 0x70, 0x6c,
+	0x79, 0x22, 0x00, 0x12, 0x25, 0x0a, 0x03, 0x47, 0x65, 0x74, 0x12, 0x0e, 0x2e, 0x70, 0x62, 0x2e,
+	0x47, 0x65, 0x74, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x0c, 0x2e, 0x70, 0x62, 0x2e,
+	0x47, 0x65, 0x74, 0x52, 0x65, 0x70, 0x6c, 0x79, 0x22, 0x00, 0x42, 0x4c, 0x0a, 0x1f, 0x63, 0x6f,
+	0x6d, 0x2e, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x73, 0x69, 0x64, 0x65, 0x63, 0x75, 0x73,
+	0x2e, 0x72, 0x61, 0x66, 0x74, 0x2e, 0x70, 0x6b, 0x67, 0x2e, 0x72, 0x6b, 0x76, 0x42, 0x03, 0x52,
+	0x4b, 0x56, 0x50, 0x01, 0x5a, 0x22, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d,
+	0x2f, 0x73, 0x69, 0x64, 0x65, 0x63, 0x75, 0x73, 0x2f, 0x72, 0x61, 0x66, 0x74, 0x2f, 0x70, 0x6b,
+	0x67, 0x2f, 0x72, 0x6b, 0x76, 0x2f, 0x70, 0x62, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
 }
 
 var (
@@ -1048,44 +463,28 @@ func file_pb_kvstoreraft_proto_rawDescGZIP() []byte {
 	return file_pb_kvstoreraft_proto_rawDescData
 }
 
-var file_pb_kvstoreraft_proto_msgTypes = make([]protoimpl.MessageInfo, 14)
+var file_pb_kvstoreraft_proto_msgTypes = make([]protoimpl.MessageInfo, 7)
 var file_pb_kvstoreraft_proto_goTypes = []interface{}{
-	(*KVCmdData)(nil),            // 0: pb.KVCmdData
-	(*KVCmd)(nil),                // 1: pb.KVCmd
-	(*LogEntry)(nil),             // 2: pb.LogEntry
-	(*AppendEntriesRequest)(nil), // 3: pb.AppendEntriesRequest
-	(*AppendEntriesReply)(nil),   // 4: pb.AppendEntriesReply
-	(*RequestVoteRequest)(nil),   // 5: pb.RequestVoteRequest
-	(*RequestVoteReply)(nil),     // 6: pb.RequestVoteReply
-	(*SnapshotRequest)(nil),      // 7: pb.SnapshotRequest
-	(*SetRequest)(nil),           // 8: pb.SetRequest
-	(*SetReply)(nil),             // 9: pb.SetReply
-	(*DeleteRequest)(nil),        // 10: pb.DeleteRequest
-	(*DeleteReply)(nil),          // 11: pb.DeleteReply
-	(*GetRequest)(nil),           // 12: pb.GetRequest
-	(*GetReply)(nil),             // 13: pb.GetReply
+	(*SetRequest)(nil),    // 0: pb.SetRequest
+	(*SetReply)(nil),      // 1: pb.SetReply
+	(*DeleteRequest)(nil), // 2: pb.DeleteRequest
+	(*DeleteReply)(nil),   // 3: pb.DeleteReply
+	(*GetRequest)(nil),    // 4: pb.GetRequest
+	(*GetReply)(nil),      // 5: pb.GetReply
+	(*LeaderHint)(nil),    // 6: pb.LeaderHint
 }
 var file_pb_kvstoreraft_proto_depIdxs = []int32{
-	0,  // 0: pb.KVCmd.Data:type_name -> pb.KVCmdData
-	1,  // 1: pb.LogEntry.cmd:type_name -> pb.KVCmd
-	2,  // 2: pb.AppendEntriesRequest.entries:type_name -> pb.LogEntry
-	3,  // 3: pb.KVStoreRaft.AppendEntries:input_type -> pb.AppendEntriesRequest
-	5,  // 4: pb.KVStoreRaft.RequestVote:input_type -> pb.RequestVoteRequest
-	7,  // 5: pb.KVStoreRaft.InstallSnapshot:input_type -> pb.SnapshotRequest
-	8,  // 6: pb.KVStoreRaft.Set:input_type -> pb.SetRequest
-	10, // 7: pb.KVStoreRaft.Delete:input_type -> pb.DeleteRequest
-	12, // 8: pb.KVStoreRaft.Get:input_type -> pb.GetRequest
-	4,  // 9: pb.KVStoreRaft.AppendEntries:output_type -> pb.AppendEntriesReply
-	6,  // 10: pb.KVStoreRaft.RequestVote:output_type -> pb.RequestVoteReply
-	4,  // 11: pb.KVStoreRaft.InstallSnapshot:output_type -> pb.AppendEntriesReply
-	9,  // 12: pb.KVStoreRaft.Set:output_type -> pb.SetReply
-	11, // 13: pb.KVStoreRaft.Delete:output_type -> pb.DeleteReply
-	13, // 14: pb.KVStoreRaft.Get:output_type -> pb.GetReply
-	9,  // [9:15] is the sub-list for method output_type
-	3,  // [3:9] is the sub-list for method input_type
-	3,  // [3:3] is the sub-list for extension type_name
-	3,  // [3:3] is the sub-list for extension extendee
-	0,  // [0:3] is the sub-list for field type_name
+	0, // 0: pb.KVStoreRaft.Set:input_type -> pb.SetRequest
+	2, // 1: pb.KVStoreRaft.Delete:input_type -> pb.DeleteRequest
+	4, // 2: pb.KVStoreRaft.Get:input_type -> pb.GetRequest
+	1, // 3: pb.KVStoreRaft.Set:output_type -> pb.SetReply
+	3, // 4: pb.KVStoreRaft.Delete:output_type -> pb.DeleteReply
+	5, // 5: pb.KVStoreRaft.Get:output_type -> pb.GetReply
+	3, // [3:6] is the sub-list for method output_type
+	0, // [0:3] is the sub-list for method input_type
+	0, // [0:0] is the sub-list for extension type_name
+	0, // [0:0] is the sub-list for extension extendee
+	0, // [0:0] is the sub-list for field type_name
 }
 
 func init() { file_pb_kvstoreraft_proto_init() }
@@ -1095,7 +494,7 @@ func file_pb_kvstoreraft_proto_init() {
 	}
 	if !protoimpl.UnsafeEnabled {
 		file_pb_kvstoreraft_proto_msgTypes[0].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*KVCmdData); i {
+			switch v := v.(*SetRequest); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -1107,7 +506,7 @@ func file_pb_kvstoreraft_proto_init() {
 			}
 		}
 		file_pb_kvstoreraft_proto_msgTypes[1].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*KVCmd); i {
+			switch v := v.(*SetReply); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -1119,7 +518,7 @@ func file_pb_kvstoreraft_proto_init() {
 			}
 		}
 		file_pb_kvstoreraft_proto_msgTypes[2].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*LogEntry); i {
+			switch v := v.(*DeleteRequest); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -1131,7 +530,7 @@ func file_pb_kvstoreraft_proto_init() {
 			}
 		}
 		file_pb_kvstoreraft_proto_msgTypes[3].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*AppendEntriesRequest); i {
+			switch v := v.(*DeleteReply); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -1143,7 +542,7 @@ func file_pb_kvstoreraft_proto_init() {
 			}
 		}
 		file_pb_kvstoreraft_proto_msgTypes[4].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*AppendEntriesReply); i {
+			switch v := v.(*GetRequest); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -1155,7 +554,7 @@ func file_pb_kvstoreraft_proto_init() {
 			}
 		}
 		file_pb_kvstoreraft_proto_msgTypes[5].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*RequestVoteRequest); i {
+			switch v := v.(*GetReply); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -1167,91 +566,7 @@ func file_pb_kvstoreraft_proto_init() {
 			}
 		}
 		file_pb_kvstoreraft_proto_msgTypes[6].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*RequestVoteReply); i {
-			case 0:
-				return &v.state
-			case 1:
-				return &v.sizeCache
-			case 2:
-				return &v.unknownFields
-			default:
-				return nil
-			}
-		}
-		file_pb_kvstoreraft_proto_msgTypes[7].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*SnapshotRequest); i {
-			case 0:
-				return &v.state
-			case 1:
-				return &v.sizeCache
-			case 2:
-				return &v.unknownFields
-			default:
-				return nil
-			}
-		}
-		file_pb_kvstoreraft_proto_msgTypes[8].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*SetRequest); i {
-			case 0:
-				return &v.state
-			case 1:
-				return &v.sizeCache
-			case 2:
-				return &v.unknownFields
-			default:
-				return nil
-			}
-		}
-		file_pb_kvstoreraft_proto_msgTypes[9].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*SetReply); i {
-			case 0:
-				return &v.state
-			case 1:
-				return &v.sizeCache
-			case 2:
-				return &v.unknownFields
-			default:
-				return nil
-			}
-		}
-		file_pb_kvstoreraft_proto_msgTypes[10].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*DeleteRequest); i {
-			case 0:
-				return &v.state
-			case 1:
-				return &v.sizeCache
-			case 2:
-				return &v.unknownFields
-			default:
-				return nil
-			}
-		}
-		file_pb_kvstoreraft_proto_msgTypes[11].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*DeleteReply); i {
-			case 0:
-				return &v.state
-			case 1:
-				return &v.sizeCache
-			case 2:
-				return &v.unknownFields
-			default:
-				return nil
-			}
-		}
-		file_pb_kvstoreraft_proto_msgTypes[12].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*GetRequest); i {
-			case 0:
-				return &v.state
-			case 1:
-				return &v.sizeCache
-			case 2:
-				return &v.unknownFields
-			default:
-				return nil
-			}
-		}
-		file_pb_kvstoreraft_proto_msgTypes[13].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*GetReply); i {
+			switch v := v.(*LeaderHint); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -1269,7 +584,7 @@ func file_pb_kvstoreraft_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: file_pb_kvstoreraft_proto_rawDesc,
 			NumEnums:      0,
-			NumMessages:   14,
+			NumMessages:   7,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
@@ -1,4 +1,8 @@
 // Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.2.0
+// - protoc             v3.14.0
+// source: pb/kvstoreraft.proto
 
 package pb
 
@@ -11,18 +15,13 @@ import (
 
 // This is a compile-time assertion to ensure that this generated file
 // is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
 const _ = grpc.SupportPackageIsVersion7
 
 // KVStoreRaftClient is the client API for KVStoreRaft service.
 //
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
 type KVStoreRaftClient interface {
-	// AppendEntries
-	AppendEntries(ctx context.Context, in *AppendEntriesRequest, opts ...grpc.CallOption) (*AppendEntriesReply, error)
-	// RequestVote
-	RequestVote(ctx context.Context, in *RequestVoteRequest, opts ...grpc.CallOption) (*RequestVoteReply, error)
-	// InstallSnapshot - note we are returning AppendEntriesReply since this is a special kind of AppendEntries
-	InstallSnapshot(ctx context.Context, opts ...grpc.CallOption) (KVStoreRaft_InstallSnapshotClient, error)
 	// KVStore write operations, needs to be processed by raft node and tracked by logs
 	Set(ctx context.Context, in *SetRequest, opts ...grpc.CallOption) (*SetReply, error)
 	Delete(ctx context.Context, in *DeleteRequest, opts ...grpc.CallOption) (*DeleteReply, error)
@@ -38,58 +37,6 @@ func NewKVStoreRaftClient(cc grpc.ClientConnInterface) KVStoreRaftClient {
 	return &kVStoreRaftClient{cc}
 }
 
-func (c *kVStoreRaftClient) AppendEntries(ctx context.Context, in *AppendEntriesRequest, opts ...grpc.CallOption) (*AppendEntriesReply, error) {
-	out := new(AppendEntriesReply)
-	err := c.cc.Invoke(ctx, "/pb.KVStoreRaft/AppendEntries", in, out, opts...)
-	if err != nil {
-		return nil, err
-	}
-	return out, nil
-}
-
-func (c *kVStoreRaftClient) RequestVote(ctx context.Context, in *RequestVoteRequest, opts ...grpc.CallOption) (*RequestVoteReply, error) {
-	out := new(RequestVoteReply)
-	err := c.cc.Invoke(ctx, "/pb.KVStoreRaft/RequestVote", in, out, opts...)
-	if err != nil {
-		return nil, err
-	}
-	return out, nil
-}
-
-func (c *kVStoreRaftClient) InstallSnapshot(ctx context.Context, opts ...grpc.CallOption) (KVStoreRaft_InstallSnapshotClient, error) {
-	stream, err := c.cc.NewStream(ctx, &_KVStoreRaft_serviceDesc.Streams[0], "/pb.KVStoreRaft/InstallSnapshot", opts...)
-	if err != nil {
-		return nil, err
-	}
-	x := &kVStoreRaftInstallSnapshotClient{stream}
-	return x, nil
-}
-
-type KVStoreRaft_InstallSnapshotClient interface {
-	Send(*SnapshotRequest) error
-	CloseAndRecv() (*AppendEntriesReply, error)
-	grpc.ClientStream
-}
-
-type kVStoreRaftInstallSnapshotClient struct {
-	grpc.ClientStream
-}
-
-func (x *kVStoreRaftInstallSnapshotClient) Send(m *SnapshotRequest) error {
-	return x.ClientStream.SendMsg(m)
-}
-
-func (x *kVStoreRaftInstallSnapshotClient) CloseAndRecv() (*AppendEntriesReply, error) {
-	if err := x.ClientStream.CloseSend(); err != nil {
-		return nil, err
-	}
-	m := new(AppendEntriesReply)
-	if err := x.ClientStream.RecvMsg(m); err != nil {
-		return nil, err
-	}
-	return m, nil
-}
-
 func (c *kVStoreRaftClient) Set(ctx context.Context, in *SetRequest, opts ...grpc.CallOption) (*SetReply, error) {
 	out := new(SetReply)
 	err := c.cc.Invoke(ctx, "/pb.KVStoreRaft/Set", in, out, opts...)
@@ -121,12 +68,6 @@ func (c *kVStoreRaftClient) Get(ctx context.Context, in *GetRequest, opts ...grp
 // All implementations must embed UnimplementedKVStoreRaftServer
 // for forward compatibility
 type KVStoreRaftServer interface {
-	// AppendEntries
-	AppendEntries(context.Context, *AppendEntriesRequest) (*AppendEntriesReply, error)
-	// RequestVote
-	RequestVote(context.Context, *RequestVoteRequest) (*RequestVoteReply, error)
-	// InstallSnapshot - note we are returning AppendEntriesReply since this is a special kind of AppendEntries
-	InstallSnapshot(KVStoreRaft_InstallSnapshotServer) error
 	// KVStore write operations, needs to be processed by raft node and tracked by logs
 	Set(context.Context, *SetRequest) (*SetReply, error)
 	Delete(context.Context, *DeleteRequest) (*DeleteReply, error)
@@ -139,15 +80,6 @@ type KVStoreRaftServer interface {
 type UnimplementedKVStoreRaftServer struct {
 }
 
-func (UnimplementedKVStoreRaftServer) AppendEntries(context.Context, *AppendEntriesRequest) (*AppendEntriesReply, error) {
-	return nil, status.Errorf(codes.Unimplemented, "method AppendEntries not implemented")
-}
-func (UnimplementedKVStoreRaftServer) RequestVote(context.Context, *RequestVoteRequest) (*RequestVoteReply, error) {
-	return nil, status.Errorf(codes.Unimplemented, "method RequestVote not implemented")
-}
-func (UnimplementedKVStoreRaftServer) InstallSnapshot(KVStoreRaft_InstallSnapshotServer) error {
-	return status.Errorf(codes.Unimplemented, "method InstallSnapshot not implemented")
-}
 func (UnimplementedKVStoreRaftServer) Set(context.Context, *SetRequest) (*SetReply, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method Set not implemented")
 }
@@ -167,69 +99,7 @@ type UnsafeKVStoreRaftServer interface {
 }
 
 func RegisterKVStoreRaftServer(s grpc.ServiceRegistrar, srv KVStoreRaftServer) {
-	s.RegisterService(&_KVStoreRaft_serviceDesc, srv)
-}
-
-func _KVStoreRaft_AppendEntries_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(AppendEntriesRequest)
-	if err := dec(in); err != nil {
-		return nil, err
-	}
-	if interceptor == nil {
-		return srv.(KVStoreRaftServer).AppendEntries(ctx, in)
-	}
-	info := &grpc.UnaryServerInfo{
-		Server:     srv,
-		FullMethod: "/pb.KVStoreRaft/AppendEntries",
-	}
-	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(KVStoreRaftServer).AppendEntries(ctx, req.(*AppendEntriesRequest))
-	}
-	return interceptor(ctx, in, info, handler)
-}
-
-func _KVStoreRaft_RequestVote_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(RequestVoteRequest)
-	if err := dec(in); err != nil {
-		return nil, err
-	}
-	if interceptor == nil {
-		return srv.(KVStoreRaftServer).RequestVote(ctx, in)
-	}
-	info := &grpc.UnaryServerInfo{
-		Server:     srv,
-		FullMethod: "/pb.KVStoreRaft/RequestVote",
-	}
-	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(KVStoreRaftServer).RequestVote(ctx, req.(*RequestVoteRequest))
-	}
-	return interceptor(ctx, in, info, handler)
-}
-
-func _KVStoreRaft_InstallSnapshot_Handler(srv interface{}, stream grpc.ServerStream) error {
-	return srv.(KVStoreRaftServer).InstallSnapshot(&kVStoreRaftInstallSnapshotServer{stream})
-}
-
-type KVStoreRaft_InstallSnapshotServer interface {
-	SendAndClose(*AppendEntriesReply) error
-	Recv() (*SnapshotRequest, error)
-	grpc.ServerStream
-}
-
-type kVStoreRaftInstallSnapshotServer struct {
-	grpc.ServerStream
-}
-
-func (x *kVStoreRaftInstallSnapshotServer) SendAndClose(m *AppendEntriesReply) error {
-	return x.ServerStream.SendMsg(m)
-}
-
-func (x *kVStoreRaftInstallSnapshotServer) Recv() (*SnapshotRequest, error) {
-	m := new(SnapshotRequest)
-	if err := x.ServerStream.RecvMsg(m); err != nil {
-		return nil, err
-	}
-	return m, nil
+	s.RegisterService(&KVStoreRaft_ServiceDesc, srv)
 }
 
 func _KVStoreRaft_Set_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
@@ -286,18 +156,13 @@ func _KVStoreRaft_Get_Handler(srv interface{}, ctx context.Context, dec func(int
 	return interceptor(ctx, in, info, handler)
 }
 
-var _KVStoreRaft_serviceDesc = grpc.ServiceDesc{
+// KVStoreRaft_ServiceDesc is the grpc.ServiceDesc for KVStoreRaft service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var KVStoreRaft_ServiceDesc = grpc.ServiceDesc{
 	ServiceName: "pb.KVStoreRaft",
 	HandlerType: (*KVStoreRaftServer)(nil),
 	Methods: []grpc.MethodDesc{
-		{
-			MethodName: "AppendEntries",
-			Handler:    _KVStoreRaft_AppendEntries_Handler,
-		},
-		{
-			MethodName: "RequestVote",
-			Handler:    _KVStoreRaft_RequestVote_Handler,
-		},
 		{
 			MethodName: "Set",
 			Handler:    _KVStoreRaft_Set_Handler,
@@ -311,12 +176,6 @@ var _KVStoreRaft_serviceDesc = grpc.ServiceDesc{
 			Handler:    _KVStoreRaft_Get_Handler,
 		},
 	},
-	Streams: []grpc.StreamDesc{
-		{
-			StreamName:    "InstallSnapshot",
-			Handler:       _KVStoreRaft_InstallSnapshot_Handler,
-			ClientStreams: true,
-		},
-	},
+	Streams:  []grpc.StreamDesc{},
 	Metadata: "pb/kvstoreraft.proto",
 }
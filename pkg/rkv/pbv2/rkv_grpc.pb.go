@@ -0,0 +1,830 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.2.0
+// - protoc             v3.14.0
+// source: pbv2/rkv.proto
+
+package pbv2
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+// KVStoreClient is the client API for KVStore service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type KVStoreClient interface {
+	// KVStore write operations, needs to be processed by raft node and tracked by logs
+	Set(ctx context.Context, in *SetRequest, opts ...grpc.CallOption) (*SetReply, error)
+	Delete(ctx context.Context, in *DeleteRequest, opts ...grpc.CallOption) (*DeleteReply, error)
+	// CompareAndSwap writes a key only if the condition is met
+	CompareAndSwap(ctx context.Context, in *CompareAndSwapRequest, opts ...grpc.CallOption) (*CompareAndSwapReply, error)
+	// Txn applies ops on multiple keys atomically based on compare conditions
+	Txn(ctx context.Context, in *TxnRequest, opts ...grpc.CallOption) (*TxnReply, error)
+	// BatchSet and BatchDelete write many keys atomically in one log entry
+	BatchSet(ctx context.Context, in *BatchSetRequest, opts ...grpc.CallOption) (*BatchSetReply, error)
+	BatchDelete(ctx context.Context, in *BatchDeleteRequest, opts ...grpc.CallOption) (*BatchDeleteReply, error)
+	// BulkLoad applies each streamed request as a BatchSet. The load as a whole is not atomic
+	BulkLoad(ctx context.Context, opts ...grpc.CallOption) (KVStore_BulkLoadClient, error)
+	// Compact drops versions older than a revision, after which they can't be read.
+	// The leader also compacts automatically to keep the last -history-retention revisions, since every write adds a version
+	Compact(ctx context.Context, in *CompactRequest, opts ...grpc.CallOption) (*CompactReply, error)
+	// Lease operations. Keys attached to a lease are deleted when the lease is revoked or expires
+	LeaseGrant(ctx context.Context, in *LeaseGrantRequest, opts ...grpc.CallOption) (*LeaseGrantReply, error)
+	LeaseRevoke(ctx context.Context, in *LeaseRevokeRequest, opts ...grpc.CallOption) (*LeaseRevokeReply, error)
+	// LeaseKeepAlive refreshes the lease once for each request on the stream
+	LeaseKeepAlive(ctx context.Context, opts ...grpc.CallOption) (KVStore_LeaseKeepAliveClient, error)
+	// Watch streams changes to a key or prefix. It's served by the node the client connects to, from its apply loop
+	Watch(ctx context.Context, in *WatchRequest, opts ...grpc.CallOption) (KVStore_WatchClient, error)
+	// KVStore read operations, no need to be tracked by logs
+	Get(ctx context.Context, in *GetRequest, opts ...grpc.CallOption) (*GetReply, error)
+	// Range and Prefix list keys in order, one page at a time
+	Range(ctx context.Context, in *RangeRequest, opts ...grpc.CallOption) (*RangeReply, error)
+	Prefix(ctx context.Context, in *PrefixRequest, opts ...grpc.CallOption) (*RangeReply, error)
+	// History lists the retained versions of a key. Versions older than the history retention are compacted automatically
+	History(ctx context.Context, in *HistoryRequest, opts ...grpc.CallOption) (*HistoryReply, error)
+	// Export streams all keys with a prefix at one revision, for backups and migrations
+	Export(ctx context.Context, in *ExportRequest, opts ...grpc.CallOption) (KVStore_ExportClient, error)
+}
+
+type kVStoreClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewKVStoreClient(cc grpc.ClientConnInterface) KVStoreClient {
+	return &kVStoreClient{cc}
+}
+
+func (c *kVStoreClient) Set(ctx context.Context, in *SetRequest, opts ...grpc.CallOption) (*SetReply, error) {
+	out := new(SetReply)
+	err := c.cc.Invoke(ctx, "/rkv.v2.KVStore/Set", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *kVStoreClient) Delete(ctx context.Context, in *DeleteRequest, opts ...grpc.CallOption) (*DeleteReply, error) {
+	out := new(DeleteReply)
+	err := c.cc.Invoke(ctx, "/rkv.v2.KVStore/Delete", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *kVStoreClient) CompareAndSwap(ctx context.Context, in *CompareAndSwapRequest, opts ...grpc.CallOption) (*CompareAndSwapReply, error) {
+	out := new(CompareAndSwapReply)
+	err := c.cc.Invoke(ctx, "/rkv.v2.KVStore/CompareAndSwap", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *kVStoreClient) Txn(ctx context.Context, in *TxnRequest, opts ...grpc.CallOption) (*TxnReply, error) {
+	out := new(TxnReply)
+	err := c.cc.Invoke(ctx, "/rkv.v2.KVStore/Txn", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *kVStoreClient) BatchSet(ctx context.Context, in *BatchSetRequest, opts ...grpc.CallOption) (*BatchSetReply, error) {
+	out := new(BatchSetReply)
+	err := c.cc.Invoke(ctx, "/rkv.v2.KVStore/BatchSet", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *kVStoreClient) BatchDelete(ctx context.Context, in *BatchDeleteRequest, opts ...grpc.CallOption) (*BatchDeleteReply, error) {
+	out := new(BatchDeleteReply)
+	err := c.cc.Invoke(ctx, "/rkv.v2.KVStore/BatchDelete", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *kVStoreClient) BulkLoad(ctx context.Context, opts ...grpc.CallOption) (KVStore_BulkLoadClient, error) {
+	stream, err := c.cc.NewStream(ctx, &KVStore_ServiceDesc.Streams[0], "/rkv.v2.KVStore/BulkLoad", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &kVStoreBulkLoadClient{stream}
+	return x, nil
+}
+
+type KVStore_BulkLoadClient interface {
+	Send(*BatchSetRequest) error
+	CloseAndRecv() (*BulkLoadReply, error)
+	grpc.ClientStream
+}
+
+type kVStoreBulkLoadClient struct {
+	grpc.ClientStream
+}
+
+func (x *kVStoreBulkLoadClient) Send(m *BatchSetRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *kVStoreBulkLoadClient) CloseAndRecv() (*BulkLoadReply, error) {
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	m := new(BulkLoadReply)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *kVStoreClient) Compact(ctx context.Context, in *CompactRequest, opts ...grpc.CallOption) (*CompactReply, error) {
+	out := new(CompactReply)
+	err := c.cc.Invoke(ctx, "/rkv.v2.KVStore/Compact", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *kVStoreClient) LeaseGrant(ctx context.Context, in *LeaseGrantRequest, opts ...grpc.CallOption) (*LeaseGrantReply, error) {
+	out := new(LeaseGrantReply)
+	err := c.cc.Invoke(ctx, "/rkv.v2.KVStore/LeaseGrant", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *kVStoreClient) LeaseRevoke(ctx context.Context, in *LeaseRevokeRequest, opts ...grpc.CallOption) (*LeaseRevokeReply, error) {
+	out := new(LeaseRevokeReply)
+	err := c.cc.Invoke(ctx, "/rkv.v2.KVStore/LeaseRevoke", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *kVStoreClient) LeaseKeepAlive(ctx context.Context, opts ...grpc.CallOption) (KVStore_LeaseKeepAliveClient, error) {
+	stream, err := c.cc.NewStream(ctx, &KVStore_ServiceDesc.Streams[1], "/rkv.v2.KVStore/LeaseKeepAlive", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &kVStoreLeaseKeepAliveClient{stream}
+	return x, nil
+}
+
+type KVStore_LeaseKeepAliveClient interface {
+	Send(*LeaseKeepAliveRequest) error
+	Recv() (*LeaseKeepAliveReply, error)
+	grpc.ClientStream
+}
+
+type kVStoreLeaseKeepAliveClient struct {
+	grpc.ClientStream
+}
+
+func (x *kVStoreLeaseKeepAliveClient) Send(m *LeaseKeepAliveRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *kVStoreLeaseKeepAliveClient) Recv() (*LeaseKeepAliveReply, error) {
+	m := new(LeaseKeepAliveReply)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *kVStoreClient) Watch(ctx context.Context, in *WatchRequest, opts ...grpc.CallOption) (KVStore_WatchClient, error) {
+	stream, err := c.cc.NewStream(ctx, &KVStore_ServiceDesc.Streams[2], "/rkv.v2.KVStore/Watch", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &kVStoreWatchClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type KVStore_WatchClient interface {
+	Recv() (*WatchReply, error)
+	grpc.ClientStream
+}
+
+type kVStoreWatchClient struct {
+	grpc.ClientStream
+}
+
+func (x *kVStoreWatchClient) Recv() (*WatchReply, error) {
+	m := new(WatchReply)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *kVStoreClient) Get(ctx context.Context, in *GetRequest, opts ...grpc.CallOption) (*GetReply, error) {
+	out := new(GetReply)
+	err := c.cc.Invoke(ctx, "/rkv.v2.KVStore/Get", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *kVStoreClient) Range(ctx context.Context, in *RangeRequest, opts ...grpc.CallOption) (*RangeReply, error) {
+	out := new(RangeReply)
+	err := c.cc.Invoke(ctx, "/rkv.v2.KVStore/Range", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *kVStoreClient) Prefix(ctx context.Context, in *PrefixRequest, opts ...grpc.CallOption) (*RangeReply, error) {
+	out := new(RangeReply)
+	err := c.cc.Invoke(ctx, "/rkv.v2.KVStore/Prefix", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *kVStoreClient) History(ctx context.Context, in *HistoryRequest, opts ...grpc.CallOption) (*HistoryReply, error) {
+	out := new(HistoryReply)
+	err := c.cc.Invoke(ctx, "/rkv.v2.KVStore/History", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *kVStoreClient) Export(ctx context.Context, in *ExportRequest, opts ...grpc.CallOption) (KVStore_ExportClient, error) {
+	stream, err := c.cc.NewStream(ctx, &KVStore_ServiceDesc.Streams[3], "/rkv.v2.KVStore/Export", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &kVStoreExportClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type KVStore_ExportClient interface {
+	Recv() (*ExportReply, error)
+	grpc.ClientStream
+}
+
+type kVStoreExportClient struct {
+	grpc.ClientStream
+}
+
+func (x *kVStoreExportClient) Recv() (*ExportReply, error) {
+	m := new(ExportReply)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// KVStoreServer is the server API for KVStore service.
+// All implementations must embed UnimplementedKVStoreServer
+// for forward compatibility
+type KVStoreServer interface {
+	// KVStore write operations, needs to be processed by raft node and tracked by logs
+	Set(context.Context, *SetRequest) (*SetReply, error)
+	Delete(context.Context, *DeleteRequest) (*DeleteReply, error)
+	// CompareAndSwap writes a key only if the condition is met
+	CompareAndSwap(context.Context, *CompareAndSwapRequest) (*CompareAndSwapReply, error)
+	// Txn applies ops on multiple keys atomically based on compare conditions
+	Txn(context.Context, *TxnRequest) (*TxnReply, error)
+	// BatchSet and BatchDelete write many keys atomically in one log entry
+	BatchSet(context.Context, *BatchSetRequest) (*BatchSetReply, error)
+	BatchDelete(context.Context, *BatchDeleteRequest) (*BatchDeleteReply, error)
+	// BulkLoad applies each streamed request as a BatchSet. The load as a whole is not atomic
+	BulkLoad(KVStore_BulkLoadServer) error
+	// Compact drops versions older than a revision, after which they can't be read.
+	// The leader also compacts automatically to keep the last -history-retention revisions, since every write adds a version
+	Compact(context.Context, *CompactRequest) (*CompactReply, error)
+	// Lease operations. Keys attached to a lease are deleted when the lease is revoked or expires
+	LeaseGrant(context.Context, *LeaseGrantRequest) (*LeaseGrantReply, error)
+	LeaseRevoke(context.Context, *LeaseRevokeRequest) (*LeaseRevokeReply, error)
+	// LeaseKeepAlive refreshes the lease once for each request on the stream
+	LeaseKeepAlive(KVStore_LeaseKeepAliveServer) error
+	// Watch streams changes to a key or prefix. It's served by the node the client connects to, from its apply loop
+	Watch(*WatchRequest, KVStore_WatchServer) error
+	// KVStore read operations, no need to be tracked by logs
+	Get(context.Context, *GetRequest) (*GetReply, error)
+	// Range and Prefix list keys in order, one page at a time
+	Range(context.Context, *RangeRequest) (*RangeReply, error)
+	Prefix(context.Context, *PrefixRequest) (*RangeReply, error)
+	// History lists the retained versions of a key. Versions older than the history retention are compacted automatically
+	History(context.Context, *HistoryRequest) (*HistoryReply, error)
+	// Export streams all keys with a prefix at one revision, for backups and migrations
+	Export(*ExportRequest, KVStore_ExportServer) error
+	mustEmbedUnimplementedKVStoreServer()
+}
+
+// UnimplementedKVStoreServer must be embedded to have forward compatible implementations.
+type UnimplementedKVStoreServer struct {
+}
+
+func (UnimplementedKVStoreServer) Set(context.Context, *SetRequest) (*SetReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Set not implemented")
+}
+func (UnimplementedKVStoreServer) Delete(context.Context, *DeleteRequest) (*DeleteReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Delete not implemented")
+}
+func (UnimplementedKVStoreServer) CompareAndSwap(context.Context, *CompareAndSwapRequest) (*CompareAndSwapReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CompareAndSwap not implemented")
+}
+func (UnimplementedKVStoreServer) Txn(context.Context, *TxnRequest) (*TxnReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Txn not implemented")
+}
+func (UnimplementedKVStoreServer) BatchSet(context.Context, *BatchSetRequest) (*BatchSetReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method BatchSet not implemented")
+}
+func (UnimplementedKVStoreServer) BatchDelete(context.Context, *BatchDeleteRequest) (*BatchDeleteReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method BatchDelete not implemented")
+}
+func (UnimplementedKVStoreServer) BulkLoad(KVStore_BulkLoadServer) error {
+	return status.Errorf(codes.Unimplemented, "method BulkLoad not implemented")
+}
+func (UnimplementedKVStoreServer) Compact(context.Context, *CompactRequest) (*CompactReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Compact not implemented")
+}
+func (UnimplementedKVStoreServer) LeaseGrant(context.Context, *LeaseGrantRequest) (*LeaseGrantReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method LeaseGrant not implemented")
+}
+func (UnimplementedKVStoreServer) LeaseRevoke(context.Context, *LeaseRevokeRequest) (*LeaseRevokeReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method LeaseRevoke not implemented")
+}
+func (UnimplementedKVStoreServer) LeaseKeepAlive(KVStore_LeaseKeepAliveServer) error {
+	return status.Errorf(codes.Unimplemented, "method LeaseKeepAlive not implemented")
+}
+func (UnimplementedKVStoreServer) Watch(*WatchRequest, KVStore_WatchServer) error {
+	return status.Errorf(codes.Unimplemented, "method Watch not implemented")
+}
+func (UnimplementedKVStoreServer) Get(context.Context, *GetRequest) (*GetReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Get not implemented")
+}
+func (UnimplementedKVStoreServer) Range(context.Context, *RangeRequest) (*RangeReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Range not implemented")
+}
+func (UnimplementedKVStoreServer) Prefix(context.Context, *PrefixRequest) (*RangeReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Prefix not implemented")
+}
+func (UnimplementedKVStoreServer) History(context.Context, *HistoryRequest) (*HistoryReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method History not implemented")
+}
+func (UnimplementedKVStoreServer) Export(*ExportRequest, KVStore_ExportServer) error {
+	return status.Errorf(codes.Unimplemented, "method Export not implemented")
+}
+func (UnimplementedKVStoreServer) mustEmbedUnimplementedKVStoreServer() {}
+
+// UnsafeKVStoreServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to KVStoreServer will
+// result in compilation errors.
+type UnsafeKVStoreServer interface {
+	mustEmbedUnimplementedKVStoreServer()
+}
+
+func RegisterKVStoreServer(s grpc.ServiceRegistrar, srv KVStoreServer) {
+	s.RegisterService(&KVStore_ServiceDesc, srv)
+}
+
+func _KVStore_Set_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KVStoreServer).Set(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/rkv.v2.KVStore/Set",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KVStoreServer).Set(ctx, req.(*SetRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _KVStore_Delete_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KVStoreServer).Delete(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/rkv.v2.KVStore/Delete",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KVStoreServer).Delete(ctx, req.(*DeleteRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _KVStore_CompareAndSwap_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CompareAndSwapRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KVStoreServer).CompareAndSwap(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/rkv.v2.KVStore/CompareAndSwap",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KVStoreServer).CompareAndSwap(ctx, req.(*CompareAndSwapRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _KVStore_Txn_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TxnRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KVStoreServer).Txn(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/rkv.v2.KVStore/Txn",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KVStoreServer).Txn(ctx, req.(*TxnRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _KVStore_BatchSet_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BatchSetRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KVStoreServer).BatchSet(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/rkv.v2.KVStore/BatchSet",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KVStoreServer).BatchSet(ctx, req.(*BatchSetRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _KVStore_BatchDelete_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BatchDeleteRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KVStoreServer).BatchDelete(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/rkv.v2.KVStore/BatchDelete",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KVStoreServer).BatchDelete(ctx, req.(*BatchDeleteRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _KVStore_BulkLoad_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(KVStoreServer).BulkLoad(&kVStoreBulkLoadServer{stream})
+}
+
+type KVStore_BulkLoadServer interface {
+	SendAndClose(*BulkLoadReply) error
+	Recv() (*BatchSetRequest, error)
+	grpc.ServerStream
+}
+
+type kVStoreBulkLoadServer struct {
+	grpc.ServerStream
+}
+
+func (x *kVStoreBulkLoadServer) SendAndClose(m *BulkLoadReply) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *kVStoreBulkLoadServer) Recv() (*BatchSetRequest, error) {
+	m := new(BatchSetRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _KVStore_Compact_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CompactRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KVStoreServer).Compact(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/rkv.v2.KVStore/Compact",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KVStoreServer).Compact(ctx, req.(*CompactRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _KVStore_LeaseGrant_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(LeaseGrantRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KVStoreServer).LeaseGrant(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/rkv.v2.KVStore/LeaseGrant",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KVStoreServer).LeaseGrant(ctx, req.(*LeaseGrantRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _KVStore_LeaseRevoke_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(LeaseRevokeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KVStoreServer).LeaseRevoke(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/rkv.v2.KVStore/LeaseRevoke",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KVStoreServer).LeaseRevoke(ctx, req.(*LeaseRevokeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _KVStore_LeaseKeepAlive_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(KVStoreServer).LeaseKeepAlive(&kVStoreLeaseKeepAliveServer{stream})
+}
+
+type KVStore_LeaseKeepAliveServer interface {
+	Send(*LeaseKeepAliveReply) error
+	Recv() (*LeaseKeepAliveRequest, error)
+	grpc.ServerStream
+}
+
+type kVStoreLeaseKeepAliveServer struct {
+	grpc.ServerStream
+}
+
+func (x *kVStoreLeaseKeepAliveServer) Send(m *LeaseKeepAliveReply) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *kVStoreLeaseKeepAliveServer) Recv() (*LeaseKeepAliveRequest, error) {
+	m := new(LeaseKeepAliveRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _KVStore_Watch_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(KVStoreServer).Watch(m, &kVStoreWatchServer{stream})
+}
+
+type KVStore_WatchServer interface {
+	Send(*WatchReply) error
+	grpc.ServerStream
+}
+
+type kVStoreWatchServer struct {
+	grpc.ServerStream
+}
+
+func (x *kVStoreWatchServer) Send(m *WatchReply) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _KVStore_Get_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KVStoreServer).Get(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/rkv.v2.KVStore/Get",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KVStoreServer).Get(ctx, req.(*GetRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _KVStore_Range_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RangeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KVStoreServer).Range(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/rkv.v2.KVStore/Range",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KVStoreServer).Range(ctx, req.(*RangeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _KVStore_Prefix_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PrefixRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KVStoreServer).Prefix(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/rkv.v2.KVStore/Prefix",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KVStoreServer).Prefix(ctx, req.(*PrefixRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _KVStore_History_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(HistoryRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KVStoreServer).History(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/rkv.v2.KVStore/History",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KVStoreServer).History(ctx, req.(*HistoryRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _KVStore_Export_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ExportRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(KVStoreServer).Export(m, &kVStoreExportServer{stream})
+}
+
+type KVStore_ExportServer interface {
+	Send(*ExportReply) error
+	grpc.ServerStream
+}
+
+type kVStoreExportServer struct {
+	grpc.ServerStream
+}
+
+func (x *kVStoreExportServer) Send(m *ExportReply) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+// KVStore_ServiceDesc is the grpc.ServiceDesc for KVStore service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var KVStore_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "rkv.v2.KVStore",
+	HandlerType: (*KVStoreServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Set",
+			Handler:    _KVStore_Set_Handler,
+		},
+		{
+			MethodName: "Delete",
+			Handler:    _KVStore_Delete_Handler,
+		},
+		{
+			MethodName: "CompareAndSwap",
+			Handler:    _KVStore_CompareAndSwap_Handler,
+		},
+		{
+			MethodName: "Txn",
+			Handler:    _KVStore_Txn_Handler,
+		},
+		{
+			MethodName: "BatchSet",
+			Handler:    _KVStore_BatchSet_Handler,
+		},
+		{
+			MethodName: "BatchDelete",
+			Handler:    _KVStore_BatchDelete_Handler,
+		},
+		{
+			MethodName: "Compact",
+			Handler:    _KVStore_Compact_Handler,
+		},
+		{
+			MethodName: "LeaseGrant",
+			Handler:    _KVStore_LeaseGrant_Handler,
+		},
+		{
+			MethodName: "LeaseRevoke",
+			Handler:    _KVStore_LeaseRevoke_Handler,
+		},
+		{
+			MethodName: "Get",
+			Handler:    _KVStore_Get_Handler,
+		},
+		{
+			MethodName: "Range",
+			Handler:    _KVStore_Range_Handler,
+		},
+		{
+			MethodName: "Prefix",
+			Handler:    _KVStore_Prefix_Handler,
+		},
+		{
+			MethodName: "History",
+			Handler:    _KVStore_History_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "BulkLoad",
+			Handler:       _KVStore_BulkLoad_Handler,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "LeaseKeepAlive",
+			Handler:       _KVStore_LeaseKeepAlive_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "Watch",
+			Handler:       _KVStore_Watch_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "Export",
+			Handler:       _KVStore_Export_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "pbv2/rkv.proto",
+}
@@ -1,18 +1,69 @@
 package rkv
 
 import (
+	"context"
+	"fmt"
+	"net/http"
 	"os"
+	"os/signal"
+	"path/filepath"
 	"sync"
+	"syscall"
+	"time"
 
 	"github.com/sidecus/raft/pkg/raft"
+	grpctransport "github.com/sidecus/raft/pkg/raft/transport/grpc"
+	"github.com/sidecus/raft/pkg/trace"
 	"github.com/sidecus/raft/pkg/util"
 )
 
-// StartRKV starts the raft kv store and waits for it to finish
+// Options are optional settings for rkv
+type Options struct {
+	// DisableProxy stops followers from proxying requests to the leader.
+	// They return not leader errors with leader hints instead, so that clients can redirect themselves
+	DisableProxy bool
+	// DataDir keeps the kv store in a bolt db in the dir, so that it can be larger than memory and survives restarts.
+	// The store is kept in memory when it's empty
+	DataDir string
+	// BootstrapFrom starts a new cluster from the snapshot file, e.g. after the old cluster lost quorum for good.
+	// Other nodes start empty with Join, and receive the snapshot from the leader
+	BootstrapFrom string
+	// Join starts an empty node which doesn't start elections until it hears from a leader, to join a cluster started by BootstrapFrom
+	Join bool
+	// ForceNewCluster starts a single node cluster from what's left of the node, ignoring peers. This is unsafe, and meant for
+	// recovering from a surviving minority. The node resumes from its data dir if any, otherwise its latest snapshot file
+	ForceNewCluster bool
+	// MetricsAddress serves raft and kv store metrics in Prometheus format over HTTP on /metrics, e.g. ":9100".
+	// Metrics are not collected when it's empty
+	MetricsAddress string
+	// Logger receives raft logs, and rkv logs under LogSubsystemRKV. util.DefaultLogger() is used when it's nil
+	Logger raft.Logger
+	// TraceExporter turns on tracing of client requests across nodes, with spans exported to it.
+	// Sample ratio can be set by trace.SetSampleRatio
+	TraceExporter trace.IExporter
+	// HealthAddress serves /healthz and /readyz over HTTP, e.g. ":9100". It can be the same as MetricsAddress.
+	// The gRPC health checking protocol is always served on the node's port, with readiness reported for the rkv.v2.KVStore service
+	HealthAddress string
+	// ReadyMaxLag is how many entries the node's applied index can be behind the leader's commit index while it's ready.
+	// DefaultReadyMaxLag is used if it's not positive
+	ReadyMaxLag int
+	// HistoryRetention is the number of revisions of key history kept. The leader compacts older history automatically.
+	// DefaultHistoryRetention is used if it's 0, and history is kept until clients call Compact if it's negative
+	HistoryRetention int64
+	// ShutdownTimeout bounds how long shutdown waits for requests in flight, and then for leadership transfer, each.
+	// DefaultShutdownTimeout is used if it's not positive
+	ShutdownTimeout time.Duration
+}
+
+// DefaultShutdownTimeout is the default time a node waits for requests in flight, and for leadership transfer, when shutting down
+const DefaultShutdownTimeout = 10 * time.Second
+
+// StartRKV starts the raft kv store, and shuts it down gracefully on SIGINT or SIGTERM. A second signal exits right away
 // nodeID: id for current node
 // port: port for current node
 // peers: info for all other nodes
-func StartRKV(nodeID int, port string, peers map[int]raft.NodeInfo) {
+// opts: optional settings
+func StartRKV(nodeID int, port string, peers map[int]raft.NodeInfo, opts Options) {
 	cwd, err := os.Getwd()
 	if err != nil {
 		util.Fatalf("Failed to get current working directory for snapshot. %s", err)
@@ -20,18 +71,141 @@ func StartRKV(nodeID int, port string, peers map[int]raft.NodeInfo) {
 
 	raft.SetSnapshotPath(cwd)
 
-	// create node
-	node, err := raft.NewNode(nodeID, peers, newRKVStore(), rkvProxyFactory)
+	if opts.TraceExporter != nil {
+		trace.SetExporter(opts.TraceExporter)
+	}
+
+	// HTTP endpoints on the same address share one server
+	muxes := make(map[string]*http.ServeMux)
+	httpMux := func(address string) *http.ServeMux {
+		if muxes[address] == nil {
+			muxes[address] = http.NewServeMux()
+		}
+		return muxes[address]
+	}
+
+	var metrics *rkvMetrics
+	if opts.MetricsAddress != "" {
+		metrics = newRKVMetrics()
+		raft.SetMetrics(metrics)
+		metrics.registerHTTP(httpMux(opts.MetricsAddress))
+	}
+
+	// create store and node
+	store := newRKVStore()
+	if opts.DataDir != "" {
+		backend, err := newBoltBackend(filepath.Join(opts.DataDir, fmt.Sprintf("Node%d.rkvdb", nodeID)))
+		if err != nil {
+			util.Fatalf("Failed to open kv store db in %s. %s", opts.DataDir, err)
+		}
+		store = newRKVStoreWithBackend(backend)
+	}
+	store.log = newRKVLogger(nodeID, opts.Logger)
+	if metrics != nil {
+		metrics.registerStore(store)
+	}
+
+	var node raft.INode
+	switch {
+	case opts.ForceNewCluster:
+		peers = map[int]raft.NodeInfo{}
+		node, err = raft.ForceNewCluster(nodeID, store, grpctransport.NewProxyFactory(rkvCodec), opts.Logger)
+	case opts.BootstrapFrom != "":
+		node, err = raft.RecoverCluster(nodeID, peers, store, grpctransport.NewProxyFactory(rkvCodec), opts.Logger, opts.BootstrapFrom)
+	case opts.Join:
+		node, err = raft.JoinCluster(nodeID, peers, store, grpctransport.NewProxyFactory(rkvCodec), opts.Logger)
+	default:
+		node, err = raft.NewNode(nodeID, peers, store, grpctransport.NewProxyFactory(rkvCodec), opts.Logger)
+	}
 	if err != nil {
 		util.Fatalf("%s\n", err)
 	}
 
 	// create rpc server
 	var wg sync.WaitGroup
-	rpcServer := newRKVRPCServer(node, &wg)
+	rpcServer := newRKVRPCServer(node, peers, rkvCodec, store.watches, metrics, opts, &wg)
+	if opts.HealthAddress != "" {
+		rpcServer.health.registerHTTP(httpMux(opts.HealthAddress))
+	}
+	var httpServers []*http.Server
+	for address, mux := range muxes {
+		httpServers = append(httpServers, serveHTTP(address, mux))
+	}
 
 	// start
 	rpcServer.Start(port)
 	node.Start()
-	wg.Wait()
+	expirer := newExpirer(node, store)
+	expirer.start()
+	var compactor *compactor
+	if retention := opts.HistoryRetention; retention >= 0 {
+		if retention == 0 {
+			retention = DefaultHistoryRetention
+		}
+		compactor = newCompactor(node, store, retention)
+		compactor.start()
+	}
+
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	store.log.info("Shutting down", "signal", <-signals)
+	go func() {
+		util.Fatalf("Received %s, exiting without waiting for shutdown", <-signals)
+	}()
+
+	timeout := opts.ShutdownTimeout
+	if timeout <= 0 {
+		timeout = DefaultShutdownTimeout
+	}
+	shutdown(node, rpcServer, expirer, compactor, store, httpServers, timeout)
+}
+
+// shutdown stops the node gracefully:
+// 1. stop accepting client requests, and wait for the ones in flight
+// 2. stop the expirer and compactor, and transfer leadership if we are the leader
+// 3. stop the node, which waits for applies and snapshots in flight
+// 4. close the store, flushing it to disk
+// 5. stop the gRPC server after raft RPCs in flight finish, and the HTTP servers
+// Waiting for requests, leadership transfer and raft RPCs is bounded by timeout each, after which shutdown goes on without them.
+// Separate deadlines make sure slow requests don't leave no time for leadership transfer
+func shutdown(node raft.INode, rpcServer *rkvRPCServer, expirer *expirer, compactor *compactor, store *rkvStore, httpServers []*http.Server, timeout time.Duration) {
+	drainCtx, cancelDrain := context.WithTimeout(context.Background(), timeout)
+	defer cancelDrain()
+	if err := rpcServer.drain(drainCtx); err != nil {
+		store.log.warn("Requests in flight didn't finish in time", "err", err)
+	}
+
+	expirer.stop()
+	if compactor != nil {
+		compactor.stop()
+	}
+	transferCtx, cancelTransfer := context.WithTimeout(context.Background(), timeout)
+	defer cancelTransfer()
+	if err := node.TransferLeadership(transferCtx); err != nil {
+		store.log.warn("Failed to transfer leadership", "err", err)
+	}
+
+	node.Stop()
+	if err := store.close(); err != nil {
+		store.log.error("Failed to close kv store", "err", err)
+	}
+
+	stopCtx, cancelStop := context.WithTimeout(context.Background(), timeout)
+	defer cancelStop()
+	rpcServer.gracefulStop(stopCtx)
+	for _, server := range httpServers {
+		server.Shutdown(stopCtx)
+	}
+	store.log.info("Shut down")
+}
+
+// serveHTTP serves HTTP endpoints on the address, e.g. metrics and health checks
+func serveHTTP(address string, handler http.Handler) *http.Server {
+	server := &http.Server{Addr: address, Handler: handler}
+	go func() {
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			util.Fatalf("Failed to serve HTTP on %s. %s", address, err)
+		}
+	}()
+	return server
 }
@@ -0,0 +1,295 @@
+package rkv
+
+import (
+	"encoding/json"
+	"io"
+	"sort"
+	"time"
+)
+
+// storeMeta is the store state besides keys and leases, saved by backends with the changes of each cmd
+type storeMeta struct {
+	Revision        int64
+	CompactRevision int64 `json:",omitempty"`
+	LastLeaseID     int64 `json:",omitempty"`
+	// AppliedIndex and AppliedTerm are the index and term of the last applied raft log entry
+	AppliedIndex int
+	AppliedTerm  int
+}
+
+// newStoreMeta creates the meta of an empty store
+func newStoreMeta() storeMeta {
+	return storeMeta{AppliedIndex: -1, AppliedTerm: -1}
+}
+
+// kvBackend keeps the keys with their versions, the leases and the meta of rkvStore.
+// Changes are only saved by commit. Backends are not concurrency safe, the store protects them with its lock
+type kvBackend interface {
+	// meta returns the meta saved by the last commit
+	meta() storeMeta
+	// commit saves the changes since the last commit with meta
+	commit(meta storeMeta) error
+
+	// get returns the current entry of key
+	get(key string) (KVEntry, bool)
+	// put sets the current entry of key, and adds it as a new version
+	put(key string, entry KVEntry)
+	// remove deletes the current entry of key, and adds a delete version at revision
+	remove(key string, revision int64)
+	// ascend calls fn on current entries with keys in [start, end) in key order, until fn returns false. Empty end means no upper bound
+	ascend(start string, end string, fn func(key string, entry KVEntry) bool)
+	// expiredKeys returns at most max keys with ExpireAt at or before now, with their revisions
+	expiredKeys(now time.Time, max int) map[string]int64
+
+	// versions returns the retained versions of key in revision order
+	versions(key string) []kvVersion
+	// versionAt returns the version of key visible at revision
+	versionAt(key string, revision int64) (KVEntry, bool)
+	// ascendAt is ascend on the entries visible at revision
+	ascendAt(start string, end string, revision int64, fn func(key string, entry KVEntry) bool)
+	// compact drops versions not visible at revision or after
+	compact(revision int64)
+
+	// leases returns all leases, without their keys
+	leases() []*kvLease
+	// leaseKeys returns the current keys attached to the lease in key order. put and remove keep them up to date
+	leaseKeys(id int64) []string
+	putLease(lease *kvLease)
+	deleteLease(id int64)
+
+	// serialize writes everything saved by the last commit as a snapshot
+	serialize(w io.Writer) error
+	// deserialize replaces everything with a snapshot written by serialize
+	deserialize(r io.Reader) error
+	close() error
+}
+
+// memBackend keeps everything in memory, commit is a no-op.
+// index has all keys with versions in history, including deleted ones. leaseIndex has the current keys of each lease
+type memBackend struct {
+	data       map[string]KVEntry
+	history    map[string][]kvVersion
+	index      *keyIndex
+	leaseMap   map[int64]kvLease
+	leaseIndex map[int64]map[string]struct{}
+	saved      storeMeta
+}
+
+// rkvSnapshot is the serialized form of memBackend.
+// History is empty in snapshots taken before MVCC, in which case it's rebuilt from Data
+type rkvSnapshot struct {
+	Revision        int64
+	Data            map[string]KVEntry
+	History         map[string][]kvVersion `json:",omitempty"`
+	CompactRevision int64                  `json:",omitempty"`
+	Leases          []*kvLease             `json:",omitempty"`
+	LastLeaseID     int64                  `json:",omitempty"`
+}
+
+func newMemBackend() *memBackend {
+	return &memBackend{
+		data:       make(map[string]KVEntry),
+		history:    make(map[string][]kvVersion),
+		index:      newKeyIndex(),
+		leaseMap:   make(map[int64]kvLease),
+		leaseIndex: make(map[int64]map[string]struct{}),
+		saved:      newStoreMeta(),
+	}
+}
+
+func (b *memBackend) meta() storeMeta {
+	return b.saved
+}
+
+func (b *memBackend) commit(meta storeMeta) error {
+	b.saved = meta
+	return nil
+}
+
+func (b *memBackend) get(key string) (KVEntry, bool) {
+	e, ok := b.data[key]
+	return e, ok
+}
+
+func (b *memBackend) put(key string, entry KVEntry) {
+	b.detach(key)
+	b.attach(key, entry.Lease)
+	b.data[key] = entry
+	b.history[key] = append(b.history[key], kvVersion{KVEntry: entry})
+	b.index.insert(key)
+}
+
+// remove keeps the key in the index until the delete is compacted
+func (b *memBackend) remove(key string, revision int64) {
+	b.detach(key)
+	delete(b.data, key)
+	b.history[key] = append(b.history[key], kvVersion{KVEntry: KVEntry{Revision: revision}, Deleted: true})
+}
+
+func (b *memBackend) ascend(start string, end string, fn func(key string, entry KVEntry) bool) {
+	b.index.ascend(start, end, func(key string) bool {
+		if e, ok := b.data[key]; ok {
+			return fn(key, e)
+		}
+		return true
+	})
+}
+
+func (b *memBackend) expiredKeys(now time.Time, max int) map[string]int64 {
+	keys := make(map[string]int64)
+	for k, v := range b.data {
+		if len(keys) >= max {
+			break
+		}
+		if v.expired(now) {
+			keys[k] = v.Revision
+		}
+	}
+	return keys
+}
+
+func (b *memBackend) versions(key string) []kvVersion {
+	return b.history[key]
+}
+
+func (b *memBackend) versionAt(key string, revision int64) (KVEntry, bool) {
+	versions := b.history[key]
+	// versions are in revision order, find the last one at or before revision
+	i := sort.Search(len(versions), func(i int) bool { return versions[i].Revision > revision }) - 1
+	if i < 0 || versions[i].Deleted {
+		return KVEntry{}, false
+	}
+	return versions[i].KVEntry, true
+}
+
+func (b *memBackend) ascendAt(start string, end string, revision int64, fn func(key string, entry KVEntry) bool) {
+	b.index.ascend(start, end, func(key string) bool {
+		if e, ok := b.versionAt(key, revision); ok {
+			return fn(key, e)
+		}
+		return true
+	})
+}
+
+func (b *memBackend) compact(revision int64) {
+	for key, versions := range b.history {
+		i := sort.Search(len(versions), func(i int) bool { return versions[i].Revision > revision }) - 1
+		if i < 0 {
+			continue
+		}
+		// keep the version visible at the compact revision, unless it's a delete
+		if versions[i].Deleted {
+			i++
+		}
+		if i == len(versions) {
+			delete(b.history, key)
+			b.index.remove(key)
+			continue
+		}
+		b.history[key] = append([]kvVersion(nil), versions[i:]...)
+	}
+}
+
+func (b *memBackend) leases() []*kvLease {
+	leases := make([]*kvLease, 0, len(b.leaseMap))
+	for _, l := range b.leaseMap {
+		lease := l
+		leases = append(leases, &lease)
+	}
+	return leases
+}
+
+func (b *memBackend) putLease(lease *kvLease) {
+	b.leaseMap[lease.ID] = kvLease{ID: lease.ID, TTL: lease.TTL, ExpireAt: lease.ExpireAt}
+}
+
+func (b *memBackend) deleteLease(id int64) {
+	delete(b.leaseMap, id)
+}
+
+func (b *memBackend) leaseKeys(id int64) []string {
+	keys := make([]string, 0, len(b.leaseIndex[id]))
+	for k := range b.leaseIndex[id] {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// attach adds key to the keys of lease if it's not 0
+func (b *memBackend) attach(key string, lease int64) {
+	if lease == 0 {
+		return
+	}
+	if b.leaseIndex[lease] == nil {
+		b.leaseIndex[lease] = make(map[string]struct{})
+	}
+	b.leaseIndex[lease][key] = struct{}{}
+}
+
+// detach removes key from the keys of the lease of its current entry
+func (b *memBackend) detach(key string) {
+	current, ok := b.data[key]
+	if !ok || current.Lease == 0 {
+		return
+	}
+	delete(b.leaseIndex[current.Lease], key)
+	if len(b.leaseIndex[current.Lease]) == 0 {
+		delete(b.leaseIndex, current.Lease)
+	}
+}
+
+// serialize writes the JSON rkvSnapshot
+func (b *memBackend) serialize(w io.Writer) error {
+	snapshot := rkvSnapshot{
+		Revision:        b.saved.Revision,
+		Data:            b.data,
+		History:         b.history,
+		CompactRevision: b.saved.CompactRevision,
+		Leases:          b.leases(),
+		LastLeaseID:     b.saved.LastLeaseID,
+	}
+	return json.NewEncoder(w).Encode(snapshot)
+}
+
+func (b *memBackend) deserialize(r io.Reader) error {
+	var snapshot rkvSnapshot
+	if err := json.NewDecoder(r).Decode(&snapshot); err != nil {
+		return err
+	}
+
+	b.saved = newStoreMeta()
+	b.saved.Revision = snapshot.Revision
+	b.saved.CompactRevision = snapshot.CompactRevision
+	b.saved.LastLeaseID = snapshot.LastLeaseID
+
+	b.data = snapshot.Data
+	if b.data == nil {
+		b.data = make(map[string]KVEntry)
+	}
+	b.history = snapshot.History
+	if b.history == nil {
+		b.history = make(map[string][]kvVersion)
+		for k, v := range b.data {
+			b.history[k] = []kvVersion{{KVEntry: v}}
+		}
+	}
+	b.index = newKeyIndex()
+	for k := range b.history {
+		b.index.insert(k)
+	}
+	b.leaseIndex = make(map[int64]map[string]struct{})
+	for k, v := range b.data {
+		b.attach(k, v.Lease)
+	}
+
+	b.leaseMap = make(map[int64]kvLease)
+	for _, lease := range snapshot.Leases {
+		b.putLease(lease)
+	}
+	return nil
+}
+
+func (b *memBackend) close() error {
+	return nil
+}
@@ -0,0 +1,68 @@
+package rkv
+
+import (
+	"errors"
+
+	"github.com/sidecus/raft/pkg/util"
+)
+
+// maxBatchOps is the max number of ops in one batch. It keeps log entries small enough to be replicated in one AppendEntries call
+const maxBatchOps = 1000
+
+var errorInvalidBatch = errors.New("batch must have 1 to 1000 ops")
+
+// KVBatchOp is one write in a batch. CmdType is KVCmdSet or KVCmdDel. Conditions in KVCmdData are ignored
+type KVBatchOp struct {
+	CmdType int
+	KVCmdData
+}
+
+// KVBatch is the data of KVCmdBatch in the log entry. All ops are applied atomically at one revision, in order
+type KVBatch struct {
+	Ops []KVBatchOp
+}
+
+// KVBatchResult is the result of applying a KVBatch, returned by Execute
+type KVBatchResult struct {
+	// Succeeded is false when a set is attached to a non existent lease, in which case nothing is applied
+	Succeeded bool
+	// Revision is the store revision after the batch
+	Revision int64
+	// Deleted is the number of existing keys deleted
+	Deleted int64 `json:",omitempty"`
+}
+
+// applyBatch applies a batch, needs to be called with lock held.
+// Like txns, all writes in the batch share one revision, and the store revision is only bumped if something is written
+func (store *rkvStore) applyBatch(batch KVBatch) KVBatchResult {
+	for _, op := range batch.Ops {
+		if op.CmdType == KVCmdSet && op.Lease != 0 && store.leases[op.Lease] == nil {
+			return KVBatchResult{Revision: store.meta.Revision}
+		}
+	}
+
+	revision := store.meta.Revision + 1
+	written := false
+	result := KVBatchResult{Succeeded: true}
+	for _, op := range batch.Ops {
+		switch op.CmdType {
+		case KVCmdSet:
+			store.put(op.Key, KVEntry{Value: op.Value, Revision: revision, ExpireAt: op.ExpireAt, Lease: op.Lease})
+			written = true
+		case KVCmdDel:
+			if _, exists := store.backend.get(op.Key); exists {
+				store.remove(op.Key, revision)
+				result.Deleted++
+				written = true
+			}
+		default:
+			util.Panicf("Unexpected kv batch op cmdtype %d", op.CmdType)
+		}
+	}
+
+	if written {
+		store.meta.Revision = revision
+	}
+	result.Revision = store.meta.Revision
+	return result
+}
@@ -0,0 +1,49 @@
+package rkv
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sidecus/raft/pkg/raft"
+)
+
+func applyBatch(store *rkvStore, ops ...KVBatchOp) KVBatchResult {
+	return store.Apply(raft.StateMachineCmd{CmdType: KVCmdBatch, Data: KVBatch{Ops: ops}}).(KVBatchResult)
+}
+
+func TestCmdBatch(t *testing.T) {
+	store := newRKVStore()
+	applyKV(store, KVCmdSet, KVCmdData{Key: "c", Value: "c"})
+
+	r := applyBatch(store,
+		KVBatchOp{CmdType: KVCmdSet, KVCmdData: KVCmdData{Key: "a", Value: "a"}},
+		KVBatchOp{CmdType: KVCmdSet, KVCmdData: KVCmdData{Key: "b", Value: "b"}},
+		KVBatchOp{CmdType: KVCmdDel, KVCmdData: KVCmdData{Key: "c"}},
+		KVBatchOp{CmdType: KVCmdDel, KVCmdData: KVCmdData{Key: "d"}},
+	)
+	if !r.Succeeded || r.Revision != 2 || r.Deleted != 1 {
+		t.Fatal("Batch should apply all ops at one revision, and count existing keys deleted")
+	}
+	if v, _ := store.Get("b"); v.(KVEntry).Revision != 2 || keyCount(store) != 2 {
+		t.Error("Batch should set keys at the batch revision and delete existing keys")
+	}
+
+	if r = applyBatch(store, KVBatchOp{CmdType: KVCmdDel, KVCmdData: KVCmdData{Key: "d"}}); !r.Succeeded || r.Revision != 2 {
+		t.Error("Batch deleting no existing keys should not bump revision")
+	}
+
+	r = applyBatch(store,
+		KVBatchOp{CmdType: KVCmdSet, KVCmdData: KVCmdData{Key: "e", Value: "e"}},
+		KVBatchOp{CmdType: KVCmdSet, KVCmdData: KVCmdData{Key: "f", Value: "f", Lease: 100}},
+	)
+	if r.Succeeded || store.meta.Revision != 2 || keyCount(store) != 2 {
+		t.Error("Batch with non existent lease should not apply any op")
+	}
+
+	lease := applyLease(store, KVCmdLeaseGrant, KVLeaseCmdData{TTL: 1000, Time: time.Now().UnixNano()}).ID
+	applyBatch(store, KVBatchOp{CmdType: KVCmdSet, KVCmdData: KVCmdData{Key: "e", Value: "e", Lease: lease}})
+	applyLease(store, KVCmdLeaseRevoke, KVLeaseCmdData{ID: lease})
+	if _, err := store.Get("e"); err == nil {
+		t.Error("Keys set by batch should be attached to the lease")
+	}
+}
@@ -0,0 +1,491 @@
+package rkv
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"os"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/sidecus/raft/pkg/util"
+)
+
+// bolt buckets used by boltBackend
+var (
+	// metaBucket has the storeMeta under metaKey
+	metaBucket = []byte("meta")
+	metaKey    = []byte("meta")
+	// keysBucket maps keys to their current KVEntry
+	keysBucket = []byte("keys")
+	// versionsBucket maps versionKey(key, revision) to kvVersion
+	versionsBucket = []byte("versions")
+	// expiryBucket has expiryKey(expireAt, key) of keys with ExpireAt
+	expiryBucket = []byte("expiry")
+	// leasesBucket maps lease IDs to kvLease
+	leasesBucket = []byte("leases")
+	// leaseKeysBucket has leaseKey(id) followed by the key for keys attached to leases
+	leaseKeysBucket = []byte("leasekeys")
+)
+
+// boltBackend keeps everything in a bolt db on disk, so the dataset doesn't need to fit in memory.
+// Changes of each cmd are made in one write tx, committed with the meta so that the store
+// and its last applied log entry are always in sync on disk.
+// Snapshots are the db file itself, streamed from a read tx
+type boltBackend struct {
+	path string
+	db   *bolt.DB
+	// tx is the pending write tx, started by the first change after a commit
+	tx *bolt.Tx
+}
+
+// newBoltBackend opens or creates the bolt db at path
+func newBoltBackend(path string) (*boltBackend, error) {
+	b := &boltBackend{path: path}
+	if err := b.open(); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// open opens the db and creates buckets.
+// Dbs created before leaseKeysBucket, including ones from older snapshots, get it filled from keysBucket once
+func (b *boltBackend) open() error {
+	db, err := bolt.Open(b.path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return err
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		indexLeaseKeys := tx.Bucket(leaseKeysBucket) == nil
+		for _, name := range [][]byte{metaBucket, keysBucket, versionsBucket, expiryBucket, leasesBucket, leaseKeysBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		if !indexLeaseKeys {
+			return nil
+		}
+		return tx.Bucket(keysBucket).ForEach(func(k, v []byte) error {
+			var entry KVEntry
+			unmarshal(v, &entry)
+			if entry.Lease == 0 {
+				return nil
+			}
+			return tx.Bucket(leaseKeysBucket).Put(leaseKeysKey(entry.Lease, string(k)), nil)
+		})
+	})
+	if err != nil {
+		db.Close()
+		return err
+	}
+
+	b.db = db
+	return nil
+}
+
+// view runs fn in the pending write tx if there is one so that uncommitted changes are seen, otherwise in a read tx
+func (b *boltBackend) view(fn func(tx *bolt.Tx)) {
+	if b.tx != nil {
+		fn(b.tx)
+		return
+	}
+
+	err := b.db.View(func(tx *bolt.Tx) error {
+		fn(tx)
+		return nil
+	})
+	if err != nil {
+		util.Panicf("Failed to read kv store db. err:%s", err)
+	}
+}
+
+// update runs fn in the pending write tx, starting one if needed
+func (b *boltBackend) update(fn func(tx *bolt.Tx) error) {
+	if b.tx == nil {
+		tx, err := b.db.Begin(true)
+		if err != nil {
+			util.Panicf("Failed to begin kv store db tx. err:%s", err)
+		}
+		b.tx = tx
+	}
+
+	if err := fn(b.tx); err != nil {
+		util.Panicf("Failed to write kv store db. err:%s", err)
+	}
+}
+
+func (b *boltBackend) meta() storeMeta {
+	meta := newStoreMeta()
+	b.view(func(tx *bolt.Tx) {
+		if v := tx.Bucket(metaBucket).Get(metaKey); v != nil {
+			unmarshal(v, &meta)
+		}
+	})
+	return meta
+}
+
+func (b *boltBackend) commit(meta storeMeta) error {
+	b.update(func(tx *bolt.Tx) error {
+		return tx.Bucket(metaBucket).Put(metaKey, marshal(meta))
+	})
+
+	tx := b.tx
+	b.tx = nil
+	return tx.Commit()
+}
+
+func (b *boltBackend) get(key string) (entry KVEntry, ok bool) {
+	b.view(func(tx *bolt.Tx) {
+		if v := tx.Bucket(keysBucket).Get([]byte(key)); v != nil {
+			unmarshal(v, &entry)
+			ok = true
+		}
+	})
+	return
+}
+
+func (b *boltBackend) put(key string, entry KVEntry) {
+	current, exists := b.get(key)
+	b.update(func(tx *bolt.Tx) error {
+		if exists && current.ExpireAt != 0 {
+			if err := tx.Bucket(expiryBucket).Delete(expiryKey(current.ExpireAt, key)); err != nil {
+				return err
+			}
+		}
+		if entry.ExpireAt != 0 {
+			if err := tx.Bucket(expiryBucket).Put(expiryKey(entry.ExpireAt, key), nil); err != nil {
+				return err
+			}
+		}
+		if exists && current.Lease != 0 {
+			if err := tx.Bucket(leaseKeysBucket).Delete(leaseKeysKey(current.Lease, key)); err != nil {
+				return err
+			}
+		}
+		if entry.Lease != 0 {
+			if err := tx.Bucket(leaseKeysBucket).Put(leaseKeysKey(entry.Lease, key), nil); err != nil {
+				return err
+			}
+		}
+		if err := tx.Bucket(keysBucket).Put([]byte(key), marshal(entry)); err != nil {
+			return err
+		}
+		return tx.Bucket(versionsBucket).Put(versionKey(key, entry.Revision), marshal(kvVersion{KVEntry: entry}))
+	})
+}
+
+func (b *boltBackend) remove(key string, revision int64) {
+	current, exists := b.get(key)
+	if !exists {
+		return
+	}
+
+	b.update(func(tx *bolt.Tx) error {
+		if current.ExpireAt != 0 {
+			if err := tx.Bucket(expiryBucket).Delete(expiryKey(current.ExpireAt, key)); err != nil {
+				return err
+			}
+		}
+		if current.Lease != 0 {
+			if err := tx.Bucket(leaseKeysBucket).Delete(leaseKeysKey(current.Lease, key)); err != nil {
+				return err
+			}
+		}
+		if err := tx.Bucket(keysBucket).Delete([]byte(key)); err != nil {
+			return err
+		}
+		deleted := kvVersion{KVEntry: KVEntry{Revision: revision}, Deleted: true}
+		return tx.Bucket(versionsBucket).Put(versionKey(key, revision), marshal(deleted))
+	})
+}
+
+func (b *boltBackend) ascend(start string, end string, fn func(key string, entry KVEntry) bool) {
+	b.view(func(tx *bolt.Tx) {
+		c := tx.Bucket(keysBucket).Cursor()
+		for k, v := c.Seek([]byte(start)); k != nil && (end == "" || string(k) < end); k, v = c.Next() {
+			var entry KVEntry
+			unmarshal(v, &entry)
+			if !fn(string(k), entry) {
+				return
+			}
+		}
+	})
+}
+
+func (b *boltBackend) expiredKeys(now time.Time, max int) map[string]int64 {
+	keys := make(map[string]int64)
+	b.view(func(tx *bolt.Tx) {
+		// expiry keys are ordered by expiry time
+		c := tx.Bucket(expiryBucket).Cursor()
+		limit := expiryKey(now.UnixNano()+1, "")
+		for k, _ := c.First(); k != nil && bytes.Compare(k, limit) < 0 && len(keys) < max; k, _ = c.Next() {
+			key := string(k[8:])
+			var entry KVEntry
+			unmarshal(tx.Bucket(keysBucket).Get(k[8:]), &entry)
+			keys[key] = entry.Revision
+		}
+	})
+	return keys
+}
+
+func (b *boltBackend) versions(key string) []kvVersion {
+	var versions []kvVersion
+	b.view(func(tx *bolt.Tx) {
+		prefix := versionPrefix(key)
+		c := tx.Bucket(versionsBucket).Cursor()
+		for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+			var version kvVersion
+			unmarshal(v, &version)
+			versions = append(versions, version)
+		}
+	})
+	return versions
+}
+
+func (b *boltBackend) versionAt(key string, revision int64) (entry KVEntry, ok bool) {
+	b.view(func(tx *bolt.Tx) {
+		// the version visible at revision is the last one before the first version after it
+		c := tx.Bucket(versionsBucket).Cursor()
+		k, v := c.Seek(versionKey(key, revision+1))
+		if k == nil {
+			k, v = c.Last()
+		} else {
+			k, v = c.Prev()
+		}
+
+		if k == nil || !bytes.HasPrefix(k, versionPrefix(key)) {
+			return
+		}
+
+		var version kvVersion
+		unmarshal(v, &version)
+		entry, ok = version.KVEntry, !version.Deleted
+	})
+	return
+}
+
+func (b *boltBackend) ascendAt(start string, end string, revision int64, fn func(key string, entry KVEntry) bool) {
+	b.view(func(tx *bolt.Tx) {
+		// versions of each key are next to each other in revision order, keep the last one visible at revision
+		var key string
+		var visible *kvVersion
+		emit := func() bool {
+			return visible == nil || visible.Deleted || fn(key, visible.KVEntry)
+		}
+
+		c := tx.Bucket(versionsBucket).Cursor()
+		for k, v := c.Seek(versionPrefix(start)); k != nil; k, v = c.Next() {
+			vkey, rev := parseVersionKey(k)
+			if end != "" && vkey >= end {
+				break
+			}
+			if vkey != key {
+				if !emit() {
+					return
+				}
+				key, visible = vkey, nil
+			}
+			if rev <= revision {
+				var version kvVersion
+				unmarshal(v, &version)
+				visible = &version
+			}
+		}
+		emit()
+	})
+}
+
+func (b *boltBackend) compact(revision int64) {
+	// collect versions to delete first, deleting while iterating with a bolt cursor skips items
+	var drop [][]byte
+	b.view(func(tx *bolt.Tx) {
+		var key string
+		var pending [][]byte
+		var lastDeleted bool
+		flush := func() {
+			if lastDeleted {
+				drop = append(drop, pending...)
+			} else if len(pending) > 0 {
+				// keep the version visible at the compact revision
+				drop = append(drop, pending[:len(pending)-1]...)
+			}
+			pending, lastDeleted = nil, false
+		}
+
+		c := tx.Bucket(versionsBucket).Cursor()
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			vkey, rev := parseVersionKey(k)
+			if vkey != key {
+				flush()
+				key = vkey
+			}
+			if rev <= revision {
+				var version kvVersion
+				unmarshal(v, &version)
+				pending = append(pending, append([]byte(nil), k...))
+				lastDeleted = version.Deleted
+			}
+		}
+		flush()
+	})
+
+	b.update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(versionsBucket)
+		for _, k := range drop {
+			if err := bucket.Delete(k); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (b *boltBackend) leases() []*kvLease {
+	var leases []*kvLease
+	b.view(func(tx *bolt.Tx) {
+		tx.Bucket(leasesBucket).ForEach(func(k, v []byte) error {
+			lease := &kvLease{}
+			unmarshal(v, lease)
+			leases = append(leases, lease)
+			return nil
+		})
+	})
+	return leases
+}
+
+func (b *boltBackend) putLease(lease *kvLease) {
+	b.update(func(tx *bolt.Tx) error {
+		return tx.Bucket(leasesBucket).Put(leaseKey(lease.ID), marshal(lease))
+	})
+}
+
+func (b *boltBackend) deleteLease(id int64) {
+	b.update(func(tx *bolt.Tx) error {
+		return tx.Bucket(leasesBucket).Delete(leaseKey(id))
+	})
+}
+
+func (b *boltBackend) leaseKeys(id int64) []string {
+	var keys []string
+	b.view(func(tx *bolt.Tx) {
+		prefix := leaseKey(id)
+		c := tx.Bucket(leaseKeysBucket).Cursor()
+		for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+			keys = append(keys, string(k[8:]))
+		}
+	})
+	return keys
+}
+
+// serialize streams the db file from a read tx
+func (b *boltBackend) serialize(w io.Writer) error {
+	return b.db.View(func(tx *bolt.Tx) error {
+		_, err := tx.WriteTo(w)
+		return err
+	})
+}
+
+// deserialize writes the snapshot next to the db, and replaces the db with it once it's fully written
+func (b *boltBackend) deserialize(r io.Reader) error {
+	tmp := b.path + ".snapshot"
+	if err := writeFile(tmp, r); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	if err := b.db.Close(); err != nil {
+		return err
+	}
+	renameErr := os.Rename(tmp, b.path)
+	if err := b.open(); err != nil {
+		return err
+	}
+	return renameErr
+}
+
+func (b *boltBackend) close() error {
+	return b.db.Close()
+}
+
+// writeFile writes r to a new file at path and syncs it
+func writeFile(path string, r io.Reader) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if _, err = io.Copy(f, r); err != nil {
+		return err
+	}
+	return f.Sync()
+}
+
+// versionPrefix encodes key so that encoded keys sort like keys and none is a prefix of another.
+// 0x00 bytes are escaped as 0x00 0xff, and the key is terminated by 0x00 0x01
+func versionPrefix(key string) []byte {
+	prefix := make([]byte, 0, len(key)+2)
+	for i := 0; i < len(key); i++ {
+		prefix = append(prefix, key[i])
+		if key[i] == 0 {
+			prefix = append(prefix, 0xff)
+		}
+	}
+	return append(prefix, 0, 1)
+}
+
+// versionKey is the encoded key followed by the big endian revision, so versions sort by key and then revision
+func versionKey(key string, revision int64) []byte {
+	return appendUint64(versionPrefix(key), uint64(revision))
+}
+
+// parseVersionKey decodes a versionKey
+func parseVersionKey(k []byte) (string, int64) {
+	key := make([]byte, 0, len(k)-10)
+	for i := 0; i < len(k)-10; i++ {
+		key = append(key, k[i])
+		if k[i] == 0 {
+			i++ // skip the escape byte
+		}
+	}
+	return string(key), int64(binary.BigEndian.Uint64(k[len(k)-8:]))
+}
+
+// expiryKey is the big endian expiry time followed by the key, so keys sort by expiry time
+func expiryKey(expireAt int64, key string) []byte {
+	return append(appendUint64(nil, uint64(expireAt)), key...)
+}
+
+func leaseKey(id int64) []byte {
+	return appendUint64(nil, uint64(id))
+}
+
+// leaseKeysKey is the lease key followed by the key, so keys of a lease are next to each other in key order
+func leaseKeysKey(id int64, key string) []byte {
+	return append(leaseKey(id), key...)
+}
+
+func appendUint64(b []byte, v uint64) []byte {
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], v)
+	return append(b, buf[:]...)
+}
+
+// marshal encodes values stored in bolt. They are all plain structs which can always be marshalled
+func marshal(v interface{}) []byte {
+	data, err := json.Marshal(v)
+	if err != nil {
+		util.Panicf("Failed to marshal %T. err:%s", v, err)
+	}
+	return data
+}
+
+// unmarshal decodes values stored in bolt
+func unmarshal(data []byte, v interface{}) {
+	if err := json.Unmarshal(data, v); err != nil {
+		util.Panicf("Failed to unmarshal %T from kv store db. err:%s", v, err)
+	}
+}
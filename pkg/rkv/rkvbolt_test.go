@@ -0,0 +1,125 @@
+package rkv
+
+import (
+	"bytes"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/sidecus/raft/pkg/raft"
+)
+
+func newTestBoltStore(t *testing.T, path string) *rkvStore {
+	backend, err := newBoltBackend(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return newRKVStoreWithBackend(backend)
+}
+
+// dump reads everything visible through the store, to compare stores on different backends
+func dump(store *rkvStore, revision int64) []interface{} {
+	now := time.Now()
+	var result []interface{}
+	for _, q := range []interface{}{"a", "b", "c", "k\x00", "k\x00\x01", KVRange{Start: "a"}, KVRange{Start: "b", End: "k", Revision: revision}} {
+		v, err := store.Get(q)
+		result = append(result, v, err)
+	}
+	for _, q := range []interface{}{KVGetAt{Key: "a", Revision: revision}, KVGetAt{Key: "k\x00", Revision: revision}, KVHistory{Key: "a"}, KVHistory{Key: "k\x00"}} {
+		v, err := store.Get(q)
+		result = append(result, v, err)
+	}
+	return append(result, store.meta.Revision, store.meta.CompactRevision, len(store.leases), store.expiredKeys(now, 10))
+}
+
+func TestBoltBackend(t *testing.T) {
+	dir := t.TempDir()
+	mem, disk := newRKVStore(), newTestBoltStore(t, filepath.Join(dir, "test.rkvdb"))
+	past := time.Now().Add(-time.Second).UnixNano()
+	cmds := []raft.StateMachineCmd{
+		{CmdType: KVCmdSet, Data: KVCmdData{Key: "a", Value: "1"}},
+		{CmdType: KVCmdSet, Data: KVCmdData{Key: "k\x00", Value: "1"}},
+		{CmdType: KVCmdSet, Data: KVCmdData{Key: "k\x00\x01", Value: "1"}},
+		{CmdType: KVCmdLeaseGrant, Data: KVLeaseCmdData{TTL: 60000, Time: time.Now().UnixNano()}},
+		{CmdType: KVCmdSet, Data: KVCmdData{Key: "b", Value: "1", Lease: 1}},
+		{CmdType: KVCmdSet, Data: KVCmdData{Key: "c", Value: "1", ExpireAt: past}},
+		{CmdType: KVCmdSet, Data: KVCmdData{Key: "a", Value: "2"}},
+		{CmdType: KVCmdTxn, Data: KVTxn{Success: []KVTxnOp{{CmdType: KVCmdSet, Key: "a", Value: "3"}, {CmdType: KVCmdDel, Key: "k\x00"}}}},
+		{CmdType: KVCmdCAS, Data: KVCmdData{Key: "a", Value: "4", PrevValue: "x"}},
+		{CmdType: KVCmdSet, Data: KVCmdData{Key: "k\x00", Value: "2"}},
+		{CmdType: KVCmdLeaseRevoke, Data: KVLeaseCmdData{ID: 1}},
+		{CmdType: KVCmdCompact, Data: KVCompactCmdData{Revision: 4}},
+	}
+	for i, cmd := range cmds {
+		r1, r2 := mem.Apply(cmd), disk.ApplyEntry(i, 1, cmd)
+		if !reflect.DeepEqual(r1, r2) {
+			t.Fatalf("cmd %d returns %v on bolt backend, expecting %v", i, r2, r1)
+		}
+		for _, rev := range []int64{4, mem.meta.Revision} {
+			if rev <= mem.meta.Revision && !reflect.DeepEqual(dump(mem, rev), dump(disk, rev)) {
+				t.Fatalf("bolt backend differs from memory backend after cmd %d at revision %d:\n%v\n%v", i, rev, dump(disk, rev), dump(mem, rev))
+			}
+		}
+	}
+
+	// reopen to check everything is saved
+	expected := dump(disk, 5)
+	disk.close()
+	disk = newTestBoltStore(t, filepath.Join(dir, "test.rkvdb"))
+	if index, term := disk.LastApplied(); index != len(cmds)-1 || term != 1 {
+		t.Error("Bolt backend should save the last applied entry")
+	}
+	if !reflect.DeepEqual(dump(disk, 5), expected) {
+		t.Error("Bolt backend should load the same state after reopen")
+	}
+
+	buf := &bytes.Buffer{}
+	if err := disk.Serialize(buf); err != nil {
+		t.Fatal(err)
+	}
+	other := newTestBoltStore(t, filepath.Join(dir, "other.rkvdb"))
+	defer other.close()
+	if err := other.DeserializeAt(buf, 100, 2); err != nil {
+		t.Fatal(err)
+	}
+	if index, term := other.LastApplied(); index != 100 || term != 2 || !reflect.DeepEqual(dump(other, 5), expected) {
+		t.Error("Bolt backend snapshot should install the same state at the snapshot index")
+	}
+	disk.close()
+}
+
+func TestBoltLeaseKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.rkvdb")
+	store := newTestBoltStore(t, path)
+	now := time.Now().UnixNano()
+	store.ApplyEntry(0, 1, raft.StateMachineCmd{CmdType: KVCmdLeaseGrant, Data: KVLeaseCmdData{TTL: 60000, Time: now}})
+	store.ApplyEntry(1, 1, raft.StateMachineCmd{CmdType: KVCmdLeaseGrant, Data: KVLeaseCmdData{TTL: 60000, Time: now}})
+	store.ApplyEntry(2, 1, raft.StateMachineCmd{CmdType: KVCmdSet, Data: KVCmdData{Key: "b", Value: "1", Lease: 1}})
+	store.ApplyEntry(3, 1, raft.StateMachineCmd{CmdType: KVCmdSet, Data: KVCmdData{Key: "a", Value: "1", Lease: 1}})
+	store.ApplyEntry(4, 1, raft.StateMachineCmd{CmdType: KVCmdSet, Data: KVCmdData{Key: "c", Value: "1", Lease: 1}})
+	store.ApplyEntry(5, 1, raft.StateMachineCmd{CmdType: KVCmdSet, Data: KVCmdData{Key: "c", Value: "2", Lease: 2}})
+	store.ApplyEntry(6, 1, raft.StateMachineCmd{CmdType: KVCmdDel, Data: KVCmdData{Key: "b"}})
+	if keys := store.backend.leaseKeys(1); !reflect.DeepEqual(keys, []string{"a"}) {
+		t.Error("Bolt backend should keep keys of leases up to date", keys)
+	}
+
+	// dbs without lease keys get them from the keys when opened
+	store.backend.(*boltBackend).update(func(tx *bolt.Tx) error {
+		return tx.DeleteBucket(leaseKeysBucket)
+	})
+	store.backend.commit(store.meta)
+	store.close()
+	store = newTestBoltStore(t, path)
+	defer store.close()
+	if keys := store.backend.leaseKeys(2); !reflect.DeepEqual(keys, []string{"c"}) {
+		t.Error("Bolt backend should fill lease keys of older dbs", keys)
+	}
+
+	store.ApplyEntry(7, 1, raft.StateMachineCmd{CmdType: KVCmdLeaseRevoke, Data: KVLeaseCmdData{ID: 1}})
+	if _, ok := store.backend.get("a"); ok || len(store.backend.leaseKeys(1)) != 0 {
+		t.Error("Revoking a lease should delete its keys")
+	}
+}
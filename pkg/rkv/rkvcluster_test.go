@@ -0,0 +1,171 @@
+package rkv
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/sidecus/raft/pkg/raft"
+	grpctransport "github.com/sidecus/raft/pkg/raft/transport/grpc"
+	"github.com/sidecus/raft/pkg/rkv/client"
+	"github.com/sidecus/raft/pkg/rkv/pb"
+	"github.com/sidecus/raft/pkg/rkv/pbv2"
+)
+
+// freePort returns a local port which is free at the time of the call
+func freePort(t *testing.T) string {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lis.Close()
+	return strconv.Itoa(lis.Addr().(*net.TCPAddr).Port)
+}
+
+// testCluster is a cluster of rkv nodes talking to each other over gRPC on local ports, like nodes started by StartRKV
+type testCluster struct {
+	nodes     []raft.INode
+	endpoints []string
+}
+
+// startTestCluster starts the nodes on listeners bound up front, so that no node dials a peer which isn't listening yet
+// and waits out gRPC's reconnect backoff
+func startTestCluster(t *testing.T, size int) *testCluster {
+	raft.SetSnapshotPath(t.TempDir())
+
+	listeners := make([]net.Listener, size)
+	c := &testCluster{nodes: make([]raft.INode, size), endpoints: make([]string, size)}
+	for i := range listeners {
+		lis, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		listeners[i], c.endpoints[i] = lis, lis.Addr().String()
+	}
+
+	servers := make([]*rkvRPCServer, size)
+	for i := 0; i < size; i++ {
+		peers := make(map[int]raft.NodeInfo)
+		for j := 0; j < size; j++ {
+			if j != i {
+				peers[j] = raft.NodeInfo{NodeID: j, Endpoint: c.endpoints[j]}
+			}
+		}
+
+		store := newRKVStore()
+		node, err := raft.NewNode(i, peers, store, grpctransport.NewProxyFactory(rkvCodec), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c.nodes[i] = node
+		servers[i] = newRKVRPCServer(node, peers, rkvCodec, store.watches, nil, Options{}, &sync.WaitGroup{})
+		servers[i].serve(listeners[i])
+	}
+	for _, n := range c.nodes {
+		n.Start()
+	}
+
+	t.Cleanup(func() {
+		for i, n := range c.nodes {
+			servers[i].Stop()
+			n.Stop()
+		}
+	})
+	return c
+}
+
+// waitForLeader waits until all nodes know the same leader, and returns it
+func (c *testCluster) waitForLeader(t *testing.T) int {
+	for deadline := time.Now().Add(10 * time.Second); time.Now().Before(deadline); time.Sleep(50 * time.Millisecond) {
+		leader := c.nodes[0].LeaderID()
+		agreed := leader != -1
+		for _, n := range c.nodes {
+			agreed = agreed && n.LeaderID() == leader
+		}
+		if agreed {
+			return leader
+		}
+	}
+	t.Fatal("cluster should elect a leader")
+	return -1
+}
+
+// follower returns a follower's ID
+func (c *testCluster) follower(t *testing.T) int {
+	return (c.waitForLeader(t) + 1) % len(c.nodes)
+}
+
+// dial connects to a node
+func (c *testCluster) dial(t *testing.T, nodeID int) *grpc.ClientConn {
+	conn, err := grpc.Dial(c.endpoints[nodeID], grpc.WithInsecure())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func TestFollowerProxiesStateMachineErrors(t *testing.T) {
+	c := startTestCluster(t, 3)
+	conn := c.dial(t, c.follower(t))
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// the follower proxies reads to the leader, and gets the leader's error back with its code
+	if _, err := pb.NewKVStoreRaftClient(conn).Get(ctx, &pb.GetRequest{Key: "missing"}); status.Code(err) != codes.NotFound {
+		t.Error("v1 Get of a missing key from a follower should return NotFound", err)
+	}
+	reply, err := pbv2.NewKVStoreClient(conn).Get(ctx, &pbv2.GetRequest{Key: "missing"})
+	if err != nil || reply.Header.Error.GetCode() != pbv2.ErrorCode_KEY_NOT_FOUND {
+		t.Error("v2 Get of a missing key from a follower should return KEY_NOT_FOUND", err, reply.GetHeader().GetError())
+	}
+}
+
+func TestClientOnFollower(t *testing.T) {
+	c := startTestCluster(t, 3)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// the client tries node 0 first, make sure it's a follower
+	if c.waitForLeader(t) == 0 {
+		if err := c.nodes[0].TransferLeadership(ctx); err != nil {
+			t.Fatal(err)
+		}
+		for c.waitForLeader(t) == 0 {
+			time.Sleep(50 * time.Millisecond)
+		}
+	}
+
+	kv, err := client.New(client.Options{Endpoints: c.endpoints})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer kv.Close()
+
+	if _, err = kv.Get(ctx, "a"); !errors.Is(err, client.ErrKeyNotFound) {
+		t.Error("Get of a missing key through a follower should return ErrKeyNotFound", err)
+	}
+	for i := 0; i < 3; i++ {
+		if err = kv.Set(ctx, "a", fmt.Sprint(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if v, err := kv.Get(ctx, "a"); err != nil || v != "2" {
+		t.Error("Get should return the latest value", v, err)
+	}
+	if err = kv.Compact(ctx, 2); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = kv.GetAt(ctx, "a", 1); !errors.Is(err, client.ErrCompacted) {
+		t.Error("GetAt of a compacted revision should return ErrCompacted", err)
+	}
+}
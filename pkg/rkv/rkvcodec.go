@@ -0,0 +1,223 @@
+package rkv
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+var errorInvalidGetRequest = errors.New("Get request doesn't have key")
+
+// rkvCmdCodec implements raft.ICodec for rkv commands and reads, and raft.IErrorCodec for kv store errors. Everything is JSON encoded
+type rkvCmdCodec struct{}
+
+// rkvCodec is the const codec instance registered with rkv's transport
+var rkvCodec = rkvCmdCodec{}
+
+// isKVCmd tells whether cmdType is a known single key cmd type
+func isKVCmd(cmdType int) bool {
+	switch cmdType {
+	case KVCmdSet, KVCmdDel, KVCmdCAS, KVCmdSetIfAbsent, KVCmdDelIfRevision, KVCmdExpire:
+		return true
+	default:
+		return false
+	}
+}
+
+// Encode implements raft.ICommandCodec.Encode
+func (c rkvCmdCodec) Encode(cmdType int, data interface{}) ([]byte, error) {
+	ok := false
+	switch {
+	case isKVCmd(cmdType):
+		_, ok = data.(KVCmdData)
+	case cmdType == KVCmdTxn:
+		_, ok = data.(KVTxn)
+	case cmdType == KVCmdBatch:
+		_, ok = data.(KVBatch)
+	case cmdType == KVCmdExpireBatch:
+		_, ok = data.(KVExpireBatch)
+	case isLeaseCmd(cmdType):
+		_, ok = data.(KVLeaseCmdData)
+	case cmdType == KVCmdCompact:
+		_, ok = data.(KVCompactCmdData)
+	default:
+		return nil, fmt.Errorf("Unexpected kv cmdtype %d", cmdType)
+	}
+
+	if !ok {
+		return nil, fmt.Errorf("Unexpected data type %T for kv cmdtype %d", data, cmdType)
+	}
+	return json.Marshal(data)
+}
+
+// Decode implements raft.ICommandCodec.Decode
+func (c rkvCmdCodec) Decode(cmdType int, data []byte) (interface{}, error) {
+	switch {
+	case isKVCmd(cmdType):
+		var cmdData KVCmdData
+		err := json.Unmarshal(data, &cmdData)
+		return cmdData, err
+	case cmdType == KVCmdTxn:
+		var txn KVTxn
+		err := json.Unmarshal(data, &txn)
+		return txn, err
+	case cmdType == KVCmdBatch:
+		var batch KVBatch
+		err := json.Unmarshal(data, &batch)
+		return batch, err
+	case cmdType == KVCmdExpireBatch:
+		var batch KVExpireBatch
+		err := json.Unmarshal(data, &batch)
+		return batch, err
+	case isLeaseCmd(cmdType):
+		var leaseData KVLeaseCmdData
+		err := json.Unmarshal(data, &leaseData)
+		return leaseData, err
+	case cmdType == KVCmdCompact:
+		var compactData KVCompactCmdData
+		err := json.Unmarshal(data, &compactData)
+		return compactData, err
+	default:
+		return nil, fmt.Errorf("Unexpected kv cmdtype %d", cmdType)
+	}
+}
+
+// EncodeCmdResult implements raft.ICommandCodec.EncodeCmdResult.
+// Txns return KVTxnResult, batches and expire batches return KVBatchResult, lease cmds return KVLeaseResult, other kv cmds return KVCmdResult
+func (c rkvCmdCodec) EncodeCmdResult(cmdType int, result interface{}) ([]byte, error) {
+	ok := false
+	switch {
+	case cmdType == KVCmdTxn:
+		_, ok = result.(KVTxnResult)
+	case cmdType == KVCmdBatch, cmdType == KVCmdExpireBatch:
+		_, ok = result.(KVBatchResult)
+	case isLeaseCmd(cmdType):
+		_, ok = result.(KVLeaseResult)
+	default:
+		_, ok = result.(KVCmdResult)
+	}
+
+	if !ok {
+		return nil, fmt.Errorf("Unexpected result type %T for kv cmdtype %d", result, cmdType)
+	}
+	return json.Marshal(result)
+}
+
+// DecodeCmdResult implements raft.ICommandCodec.DecodeCmdResult
+func (c rkvCmdCodec) DecodeCmdResult(cmdType int, data []byte) (interface{}, error) {
+	switch {
+	case cmdType == KVCmdTxn:
+		var result KVTxnResult
+		err := json.Unmarshal(data, &result)
+		return result, err
+	case cmdType == KVCmdBatch, cmdType == KVCmdExpireBatch:
+		var result KVBatchResult
+		err := json.Unmarshal(data, &result)
+		return result, err
+	case isLeaseCmd(cmdType):
+		var result KVLeaseResult
+		err := json.Unmarshal(data, &result)
+		return result, err
+	}
+
+	var result KVCmdResult
+	err := json.Unmarshal(data, &result)
+	return result, err
+}
+
+// EncodeError implements raft.IErrorCodec.EncodeError for stateMachineErrors
+func (c rkvCmdCodec) EncodeError(err error) (string, bool) {
+	for _, v := range stateMachineErrors {
+		if errors.Is(err, v.err) {
+			return v.code, true
+		}
+	}
+	return "", false
+}
+
+// DecodeError implements raft.IErrorCodec.DecodeError for stateMachineErrors
+func (c rkvCmdCodec) DecodeError(code string) (error, bool) {
+	for _, v := range stateMachineErrors {
+		if code == v.code {
+			return v.err, true
+		}
+	}
+	return nil, false
+}
+
+// rkvQuery is the encoded form of rkv Get params, which is either a key, a range, a key at a revision or a key's history
+type rkvQuery struct {
+	Key     string     `json:",omitempty"`
+	Range   *KVRange   `json:",omitempty"`
+	At      *KVGetAt   `json:",omitempty"`
+	History *KVHistory `json:",omitempty"`
+}
+
+// EncodeParams implements raft.IQueryCodec.EncodeParams. rkv Get params are a single key, KVRange, KVGetAt or KVHistory
+func (c rkvCmdCodec) EncodeParams(params []interface{}) ([]byte, error) {
+	if len(params) != 1 {
+		return nil, errorInvalidGetRequest
+	}
+
+	switch p := params[0].(type) {
+	case string:
+		return json.Marshal(rkvQuery{Key: p})
+	case KVRange:
+		return json.Marshal(rkvQuery{Range: &p})
+	case KVGetAt:
+		return json.Marshal(rkvQuery{At: &p})
+	case KVHistory:
+		return json.Marshal(rkvQuery{History: &p})
+	default:
+		return nil, errorInvalidGetRequest
+	}
+}
+
+// DecodeParams implements raft.IQueryCodec.DecodeParams
+func (c rkvCmdCodec) DecodeParams(data []byte) ([]interface{}, error) {
+	var query rkvQuery
+	if err := json.Unmarshal(data, &query); err != nil {
+		return nil, err
+	}
+
+	switch {
+	case query.Range != nil:
+		return []interface{}{*query.Range}, nil
+	case query.At != nil:
+		return []interface{}{*query.At}, nil
+	case query.History != nil:
+		return []interface{}{*query.History}, nil
+	}
+	return []interface{}{query.Key}, nil
+}
+
+// EncodeResult implements raft.IQueryCodec.EncodeResult.
+// rkv Get results are KVEntry for keys, KVRangeResult for ranges and KVHistoryResult for key history
+func (c rkvCmdCodec) EncodeResult(params []interface{}, result interface{}) ([]byte, error) {
+	switch result.(type) {
+	case KVEntry, KVRangeResult, KVHistoryResult:
+		return json.Marshal(result)
+	default:
+		return nil, fmt.Errorf("Unexpected kv get result type %T", result)
+	}
+}
+
+// DecodeResult implements raft.IQueryCodec.DecodeResult
+func (c rkvCmdCodec) DecodeResult(params []interface{}, data []byte) (interface{}, error) {
+	if len(params) == 1 {
+		switch params[0].(type) {
+		case KVRange:
+			var result KVRangeResult
+			err := json.Unmarshal(data, &result)
+			return result, err
+		case KVHistory:
+			var result KVHistoryResult
+			err := json.Unmarshal(data, &result)
+			return result, err
+		}
+	}
+
+	var entry KVEntry
+	err := json.Unmarshal(data, &entry)
+	return entry, err
+}
@@ -0,0 +1,236 @@
+package rkv
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestCodec(t *testing.T) {
+	data := KVCmdData{Key: "a", Value: "b"}
+
+	encoded, err := rkvCodec.Encode(KVCmdSet, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decoded, err := rkvCodec.Decode(KVCmdSet, encoded)
+	if err != nil || decoded.(KVCmdData) != data {
+		t.Error("Decode returns different data")
+	}
+
+	if _, err = rkvCodec.Encode(100, data); err == nil {
+		t.Error("Encode should fail on unknown cmd type")
+	}
+	if _, err = rkvCodec.Encode(KVCmdSet, "a"); err == nil {
+		t.Error("Encode should fail on wrong data type")
+	}
+	if _, err = rkvCodec.Decode(100, encoded); err == nil {
+		t.Error("Decode should fail on unknown cmd type")
+	}
+
+	data = KVCmdData{Key: "a", Value: "b", PrevValue: "c", PrevRevision: 2}
+	encoded, err = rkvCodec.Encode(KVCmdCAS, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded, err = rkvCodec.Decode(KVCmdCAS, encoded); err != nil || decoded.(KVCmdData) != data {
+		t.Error("Decode returns different conditional write data")
+	}
+}
+
+func TestExpireBatchCodec(t *testing.T) {
+	batch := KVExpireBatch{Keys: []KVCmdData{{Key: "a", PrevRevision: 1}}, Leases: []KVLeaseCmdData{{ID: 1, ExpireAt: 2}}}
+
+	encoded, err := rkvCodec.Encode(KVCmdExpireBatch, batch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := rkvCodec.Decode(KVCmdExpireBatch, encoded)
+	if err != nil || !reflect.DeepEqual(decoded.(KVExpireBatch), batch) {
+		t.Error("Decode returns different expire batch")
+	}
+	if _, err = rkvCodec.EncodeCmdResult(KVCmdExpireBatch, KVBatchResult{Succeeded: true}); err != nil {
+		t.Error("Expire batch results should be KVBatchResult")
+	}
+}
+
+func TestTxnCodec(t *testing.T) {
+	txn := KVTxn{
+		Compares: []KVCompare{{Key: "a", Target: KVCompareRevision, Result: KVCompareGreater, Revision: 1}},
+		Success:  []KVTxnOp{{CmdType: KVCmdSet, Key: "a", Value: "b"}},
+		Failure:  []KVTxnOp{{CmdType: KVTxnGet, Key: "a"}},
+	}
+
+	encoded, err := rkvCodec.Encode(KVCmdTxn, txn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := rkvCodec.Decode(KVCmdTxn, encoded)
+	if err != nil || !reflect.DeepEqual(decoded.(KVTxn), txn) {
+		t.Error("Decode returns different txn")
+	}
+	if _, err = rkvCodec.Encode(KVCmdTxn, KVCmdData{}); err == nil {
+		t.Error("Encode should fail on wrong txn data type")
+	}
+
+	r := KVTxnResult{Succeeded: true, Results: []KVCmdResult{{Succeeded: true, Revision: 2, Value: "b"}}}
+	if encoded, err = rkvCodec.EncodeCmdResult(KVCmdTxn, r); err != nil {
+		t.Fatal(err)
+	}
+	result, err := rkvCodec.DecodeCmdResult(KVCmdTxn, encoded)
+	if err != nil || !reflect.DeepEqual(result.(KVTxnResult), r) {
+		t.Error("DecodeCmdResult returns different txn result")
+	}
+	if _, err = rkvCodec.EncodeCmdResult(KVCmdTxn, KVCmdResult{}); err == nil {
+		t.Error("EncodeCmdResult should fail on wrong txn result type")
+	}
+}
+
+func TestBatchCodec(t *testing.T) {
+	batch := KVBatch{Ops: []KVBatchOp{
+		{CmdType: KVCmdSet, KVCmdData: KVCmdData{Key: "a", Value: "b", ExpireAt: 10, Lease: 1}},
+		{CmdType: KVCmdDel, KVCmdData: KVCmdData{Key: "c"}},
+	}}
+
+	encoded, err := rkvCodec.Encode(KVCmdBatch, batch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := rkvCodec.Decode(KVCmdBatch, encoded)
+	if err != nil || !reflect.DeepEqual(decoded.(KVBatch), batch) {
+		t.Error("Decode returns different batch")
+	}
+
+	r := KVBatchResult{Succeeded: true, Revision: 3, Deleted: 1}
+	if encoded, err = rkvCodec.EncodeCmdResult(KVCmdBatch, r); err != nil {
+		t.Fatal(err)
+	}
+	result, err := rkvCodec.DecodeCmdResult(KVCmdBatch, encoded)
+	if err != nil || result.(KVBatchResult) != r {
+		t.Error("DecodeCmdResult returns different batch result")
+	}
+}
+
+func TestCmdResultCodec(t *testing.T) {
+	r := KVCmdResult{Succeeded: true, Revision: 5, Value: "a"}
+
+	encoded, err := rkvCodec.EncodeCmdResult(KVCmdCAS, r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := rkvCodec.DecodeCmdResult(KVCmdCAS, encoded)
+	if err != nil || decoded.(KVCmdResult) != r {
+		t.Error("DecodeCmdResult returns different result")
+	}
+
+	if _, err = rkvCodec.EncodeCmdResult(KVCmdCAS, "a"); err == nil {
+		t.Error("EncodeCmdResult should fail on wrong result type")
+	}
+}
+
+func TestQueryCodec(t *testing.T) {
+	params := []interface{}{"a"}
+
+	encoded, err := rkvCodec.EncodeParams(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := rkvCodec.DecodeParams(encoded)
+	if err != nil || len(decoded) != 1 || decoded[0].(string) != "a" {
+		t.Error("DecodeParams returns different params")
+	}
+
+	entry := KVEntry{Value: "b", Revision: 3}
+	encoded, err = rkvCodec.EncodeResult(params, entry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := rkvCodec.DecodeResult(params, encoded)
+	if err != nil || result.(KVEntry) != entry {
+		t.Error("DecodeResult returns different result")
+	}
+
+	if _, err = rkvCodec.EncodeParams([]interface{}{}); err == nil {
+		t.Error("EncodeParams should fail without key")
+	}
+	if _, err = rkvCodec.EncodeResult(params, 1); err == nil {
+		t.Error("EncodeResult should fail on wrong result type")
+	}
+}
+
+func TestRangeQueryCodec(t *testing.T) {
+	params := []interface{}{KVRange{Start: "a", End: "b", Limit: 2}}
+
+	encoded, err := rkvCodec.EncodeParams(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := rkvCodec.DecodeParams(encoded)
+	if err != nil || len(decoded) != 1 || decoded[0].(KVRange) != params[0] {
+		t.Error("DecodeParams returns different range")
+	}
+
+	r := KVRangeResult{Entries: []KVKeyEntry{{Key: "a", KVEntry: KVEntry{Value: "1", Revision: 2}}}, NextKey: "a1"}
+	if encoded, err = rkvCodec.EncodeResult(params, r); err != nil {
+		t.Fatal(err)
+	}
+	result, err := rkvCodec.DecodeResult(params, encoded)
+	if err != nil || !reflect.DeepEqual(result.(KVRangeResult), r) {
+		t.Error("DecodeResult returns different range result")
+	}
+
+	if _, err = rkvCodec.EncodeParams([]interface{}{1}); err == nil {
+		t.Error("EncodeParams should fail on wrong param type")
+	}
+}
+
+func TestMVCCQueryCodec(t *testing.T) {
+	for _, p := range []interface{}{KVGetAt{Key: "a", Revision: 3}, KVHistory{Key: "a"}} {
+		encoded, err := rkvCodec.EncodeParams([]interface{}{p})
+		if err != nil {
+			t.Fatal(err)
+		}
+		decoded, err := rkvCodec.DecodeParams(encoded)
+		if err != nil || len(decoded) != 1 || decoded[0] != p {
+			t.Errorf("DecodeParams returns different params for %T", p)
+		}
+	}
+
+	params := []interface{}{KVHistory{Key: "a"}}
+	h := KVHistoryResult{Versions: []KVEvent{{Type: KVEventDelete, Key: "a", Revision: 2}}, CompactRevision: 1}
+	encoded, err := rkvCodec.EncodeResult(params, h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := rkvCodec.DecodeResult(params, encoded)
+	if err != nil || !reflect.DeepEqual(result.(KVHistoryResult), h) {
+		t.Error("DecodeResult returns different history result")
+	}
+
+	data := KVCompactCmdData{Revision: 5}
+	if encoded, err = rkvCodec.Encode(KVCmdCompact, data); err != nil {
+		t.Fatal(err)
+	}
+	if decoded, err := rkvCodec.Decode(KVCmdCompact, encoded); err != nil || decoded.(KVCompactCmdData) != data {
+		t.Error("Decode returns different compact data")
+	}
+}
+
+func TestErrorCodec(t *testing.T) {
+	code, ok := rkvCodec.EncodeError(fmt.Errorf("Key a: %w", errorKeyNotFound))
+	if !ok {
+		t.Fatal("EncodeError should encode kv store errors")
+	}
+	if err, ok := rkvCodec.DecodeError(code); !ok || err != errorKeyNotFound {
+		t.Error("DecodeError should decode encoded errors")
+	}
+
+	if _, ok = rkvCodec.EncodeError(errors.New("other")); ok {
+		t.Error("EncodeError should not encode unknown errors")
+	}
+	if _, ok = rkvCodec.DecodeError("other"); ok {
+		t.Error("DecodeError should not decode unknown codes")
+	}
+}
@@ -0,0 +1,78 @@
+package rkv
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/sidecus/raft/pkg/raft"
+)
+
+const compactInterval = 5 * time.Second
+const compactTimeout = time.Second
+
+// DefaultHistoryRetention is the default number of revisions of key history kept by auto compaction
+const DefaultHistoryRetention = 100000
+
+// compactor runs on every node, but only the leader proposes KVCmdCompact to keep the latest retention revisions of history.
+// Without it versions of updated and deleted keys pile up until clients call Compact.
+// It waits for history to grow 10% over retention before compacting, since each compaction scans all versions
+type compactor struct {
+	node      raft.INode
+	store     *rkvStore
+	retention int64
+	done      chan struct{}
+	wg        sync.WaitGroup
+}
+
+func newCompactor(node raft.INode, store *rkvStore, retention int64) *compactor {
+	return &compactor{
+		node:      node,
+		store:     store,
+		retention: retention,
+		done:      make(chan struct{}),
+	}
+}
+
+// start starts the compactor goroutine
+func (c *compactor) start() {
+	c.wg.Add(1)
+	go func() {
+		ticker := time.NewTicker(compactInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-c.done:
+				c.wg.Done()
+				return
+			case <-ticker.C:
+				if c.node.LeaderID() == c.node.NodeID() {
+					c.compact()
+				}
+			}
+		}
+	}()
+}
+
+// stop stops the compactor and waits for it to finish
+func (c *compactor) stop() {
+	close(c.done)
+	c.wg.Wait()
+}
+
+// compact proposes KVCmdCompact to drop history older than retention revisions, and waits for it to be processed
+func (c *compactor) compact() {
+	revision, compactRevision := c.store.revisions()
+	if revision-compactRevision <= c.retention+c.retention/10 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), compactTimeout)
+	defer cancel()
+
+	target := revision - c.retention
+	if _, err := c.node.Execute(ctx, &raft.StateMachineCmd{CmdType: KVCmdCompact, Data: KVCompactCmdData{Revision: target}}); err != nil {
+		c.store.log.warn("Failed to compact history", "revision", target, "err", err)
+	}
+}
@@ -0,0 +1,29 @@
+package rkv
+
+import (
+	"testing"
+)
+
+func TestCompactor(t *testing.T) {
+	node := &fakeNode{leader: 0, store: newRKVStore(), success: true}
+	for i := 0; i < 21; i++ {
+		applyKV(node.store, KVCmdSet, KVCmdData{Key: "a", Value: "a"})
+	}
+
+	c := newCompactor(node, node.store, 20)
+	c.compact()
+	if node.store.meta.CompactRevision != 0 {
+		t.Error("compact should not compact before history grows over retention by 10%")
+	}
+
+	c = newCompactor(node, node.store, 4)
+	c.compact()
+	if node.store.meta.CompactRevision != 17 || len(node.store.backend.versions("a")) != 5 {
+		t.Errorf("compact should keep retention revisions of history, got compact revision %d", node.store.meta.CompactRevision)
+	}
+
+	c.compact()
+	if node.store.meta.CompactRevision != 17 {
+		t.Error("compact should not compact again until history grows")
+	}
+}
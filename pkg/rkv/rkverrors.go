@@ -0,0 +1,118 @@
+package rkv
+
+import (
+	"context"
+	"errors"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/sidecus/raft/pkg/raft"
+	"github.com/sidecus/raft/pkg/rkv/pb"
+)
+
+var errorEmptyKey = errors.New("key cannot be empty")
+var errorInvalidRevision = errors.New("revision must be positive")
+var errorInvalidCondition = errors.New("invalid compare and swap condition")
+var errorInvalidTxn = errors.New("invalid txn compare or op")
+var errorInvalidTTL = errors.New("ttl cannot be negative")
+var errorInvalidLeaseTTL = errors.New("lease ttl must be positive")
+var errorLeaseNotFound = errors.New("lease doesn't exist")
+var errorInvalidLimit = errors.New("limit cannot be negative")
+var errorInvalidPageToken = errors.New("invalid page token")
+var errorNotCommitted = errors.New("write is not committed in time, it might still be committed later")
+var errorShuttingDown = errors.New("node is shutting down")
+
+// invalidArgumentErrors are errors caused by malformed requests
+var invalidArgumentErrors = []error{
+	errorEmptyKey,
+	errorInvalidRevision,
+	errorInvalidCondition,
+	errorInvalidTxn,
+	errorInvalidTTL,
+	errorInvalidLeaseTTL,
+	errorInvalidLimit,
+	errorInvalidPageToken,
+	errorFutureRevision,
+	errorInvalidBatch,
+}
+
+// stateMachineErrors are kv store errors carried across nodes by rkvCodec, so that followers proxying requests to the leader
+// map them to the same v1 and v2 error codes as the leader
+var stateMachineErrors = []struct {
+	code string
+	err  error
+}{
+	{"KEY_NOT_FOUND", errorKeyNotFound},
+	{"LEASE_NOT_FOUND", errorLeaseNotFound},
+	{"COMPACTED", errorCompacted},
+	{"FUTURE_REVISION", errorFutureRevision},
+	{"EMPTY_KEY", errorEmptyKey},
+	{"INVALID_REVISION", errorInvalidRevision},
+	{"INVALID_CONDITION", errorInvalidCondition},
+	{"INVALID_TXN", errorInvalidTxn},
+	{"INVALID_TTL", errorInvalidTTL},
+	{"INVALID_LEASE_TTL", errorInvalidLeaseTTL},
+	{"INVALID_LIMIT", errorInvalidLimit},
+	{"INVALID_PAGE_TOKEN", errorInvalidPageToken},
+	{"INVALID_BATCH", errorInvalidBatch},
+}
+
+// isInvalidArgument tells whether err is caused by a malformed request
+func isInvalidArgument(err error) bool {
+	for _, e := range invalidArgumentErrors {
+		if errors.Is(err, e) {
+			return true
+		}
+	}
+	return false
+}
+
+// toStatusError converts errors to gRPC status errors for the v1 API.
+// Not leader errors carry a pb.LeaderHint detail when the leader is known
+func toStatusError(err error, g *leaderGuard) error {
+	if _, ok := status.FromError(err); ok {
+		// already a status error, e.g. proxied from the leader
+		return err
+	}
+
+	code := codes.Unknown
+	switch {
+	case isInvalidArgument(err):
+		code = codes.InvalidArgument
+	case errors.Is(err, errorKeyNotFound):
+		code = codes.NotFound
+	case errors.Is(err, raft.ErrorNoLeaderAvailable), errors.Is(err, raft.ErrorNodeStopped), errors.Is(err, errorShuttingDown):
+		code = codes.Unavailable
+	case errors.Is(err, errorNotLeader), errors.Is(err, raft.ErrorNoLongerLeader), errors.Is(err, raft.ErrorLeadershipNotConfirmed):
+		code = codes.FailedPrecondition
+	case errors.Is(err, errorNotCommitted), errors.Is(err, context.DeadlineExceeded):
+		code = codes.DeadlineExceeded
+	}
+
+	s := status.New(code, err.Error())
+	if code == codes.FailedPrecondition {
+		if leader, endpoint := g.leader(); leader != -1 {
+			if detailed, e := s.WithDetails(&pb.LeaderHint{LeaderID: int64(leader), Endpoint: endpoint}); e == nil {
+				s = detailed
+			}
+		}
+	}
+
+	return s.Err()
+}
+
+// LeaderHintFromError returns the leader hint carried by a v1 not leader status error, nil if there is none
+func LeaderHintFromError(err error) *pb.LeaderHint {
+	s, ok := status.FromError(err)
+	if !ok || s.Code() != codes.FailedPrecondition {
+		return nil
+	}
+
+	for _, d := range s.Details() {
+		if hint, ok := d.(*pb.LeaderHint); ok {
+			return hint
+		}
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package rkv
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sidecus/raft/pkg/raft"
+)
+
+const expireInterval = 200 * time.Millisecond
+const expireTimeout = time.Second
+
+// maxExpirePerRound is the max number of keys and leases expired in one round. Like maxBatchOps it keeps the log entry small
+const maxExpirePerRound = maxBatchOps
+
+// KVExpireBatch is the data of KVCmdExpireBatch in the log entry.
+// Keys are deleted if their current revision matches PrevRevision, and leases are revoked if their expiry time matches ExpireAt,
+// same as KVCmdExpire and KVCmdLeaseExpire, in order
+type KVExpireBatch struct {
+	Keys   []KVCmdData      `json:",omitempty"`
+	Leases []KVLeaseCmdData `json:",omitempty"`
+}
+
+// expirer runs on every node, but only the leader proposes a KVCmdExpireBatch for expired keys and leases each round.
+// Going through the log keeps expiration deterministic across replicas. A round waits for its cmd to be processed
+// before the next one starts, so there is at most one expiration proposal in flight
+type expirer struct {
+	node  raft.INode
+	store *rkvStore
+	done  chan struct{}
+	wg    sync.WaitGroup
+}
+
+func newExpirer(node raft.INode, store *rkvStore) *expirer {
+	return &expirer{
+		node:  node,
+		store: store,
+		done:  make(chan struct{}),
+	}
+}
+
+// start starts the expirer goroutine
+func (e *expirer) start() {
+	e.wg.Add(1)
+	go func() {
+		ticker := time.NewTicker(expireInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-e.done:
+				e.wg.Done()
+				return
+			case <-ticker.C:
+				if e.node.LeaderID() == e.node.NodeID() {
+					e.expire(time.Now())
+				}
+			}
+		}
+	}()
+}
+
+// stop stops the expirer and waits for it to finish
+func (e *expirer) stop() {
+	close(e.done)
+	e.wg.Wait()
+}
+
+// expire proposes one KVCmdExpireBatch for keys and leases expired at now, and waits for it to be processed.
+// The revision and expiry time checks make sure keys updated or leases refreshed since we looked are not deleted
+func (e *expirer) expire(now time.Time) {
+	var batch KVExpireBatch
+	for k, revision := range e.store.expiredKeys(now, maxExpirePerRound) {
+		batch.Keys = append(batch.Keys, KVCmdData{Key: k, PrevRevision: revision})
+	}
+	for id, expireAt := range e.store.expiredLeases(now, maxExpirePerRound-len(batch.Keys)) {
+		batch.Leases = append(batch.Leases, KVLeaseCmdData{ID: id, ExpireAt: expireAt})
+	}
+	if len(batch.Keys) == 0 && len(batch.Leases) == 0 {
+		return
+	}
+	sort.Slice(batch.Keys, func(i, j int) bool { return batch.Keys[i].Key < batch.Keys[j].Key })
+	sort.Slice(batch.Leases, func(i, j int) bool { return batch.Leases[i].ID < batch.Leases[j].ID })
+
+	ctx, cancel := context.WithTimeout(context.Background(), expireTimeout)
+	defer cancel()
+
+	if _, err := e.node.Execute(ctx, &raft.StateMachineCmd{CmdType: KVCmdExpireBatch, Data: batch}); err != nil {
+		e.store.log.warn("Failed to expire keys and leases", "keys", len(batch.Keys), "leases", len(batch.Leases), "err", err)
+	}
+}
+
+// applyExpireBatch applies an expire batch, needs to be called with lock held.
+// Each key or lease expired is its own modification like KVCmdExpire and KVCmdLeaseExpire. Deleted counts keys expired
+func (store *rkvStore) applyExpireBatch(batch KVExpireBatch) KVBatchResult {
+	result := KVBatchResult{Succeeded: true}
+	for _, data := range batch.Keys {
+		if store.applyCmd(KVCmdExpire, data).Succeeded {
+			result.Deleted++
+		}
+	}
+	for _, data := range batch.Leases {
+		store.applyLease(KVCmdLeaseExpire, data)
+	}
+
+	result.Revision = store.meta.Revision
+	return result
+}
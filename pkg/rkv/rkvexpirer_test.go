@@ -0,0 +1,92 @@
+package rkv
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sidecus/raft/pkg/raft"
+)
+
+// countingNode counts the cmds executed
+type countingNode struct {
+	*fakeNode
+	executed []*raft.StateMachineCmd
+}
+
+func (n *countingNode) Execute(ctx context.Context, cmd *raft.StateMachineCmd) (*raft.ExecuteReply, error) {
+	n.executed = append(n.executed, cmd)
+	return n.fakeNode.Execute(ctx, cmd)
+}
+
+func TestExpirer(t *testing.T) {
+	node := &countingNode{fakeNode: &fakeNode{leader: 0, store: newRKVStore(), success: true}}
+	past := time.Now().Add(-time.Second).UnixNano()
+	applyKV(node.store, KVCmdSet, KVCmdData{Key: "a", Value: "a", ExpireAt: past})
+	applyKV(node.store, KVCmdSet, KVCmdData{Key: "b", Value: "b", ExpireAt: past})
+	applyKV(node.store, KVCmdSet, KVCmdData{Key: "c", Value: "c"})
+
+	e := newExpirer(node, node.store)
+	e.expire(time.Now())
+
+	if keyCount(node.store) != 1 || node.store.meta.Revision != 5 {
+		t.Error("expire should expire all expired keys")
+	}
+	if len(node.executed) != 1 || node.executed[0].CmdType != KVCmdExpireBatch {
+		t.Errorf("expire should propose all expired keys in one KVCmdExpireBatch, got %d cmds", len(node.executed))
+	}
+
+	e.expire(time.Now())
+	if len(node.executed) != 1 {
+		t.Error("expire should not propose anything when nothing expired")
+	}
+}
+
+func TestApplyExpireBatch(t *testing.T) {
+	store := newRKVStore()
+	past := time.Now().Add(-time.Second).UnixNano()
+	applyKV(store, KVCmdSet, KVCmdData{Key: "a", Value: "a", ExpireAt: past})
+	applyKV(store, KVCmdSet, KVCmdData{Key: "b", Value: "b", ExpireAt: past})
+	lease := applyLease(store, KVCmdLeaseGrant, KVLeaseCmdData{TTL: 1, Time: past})
+	applyKV(store, KVCmdSet, KVCmdData{Key: "c", Value: "c", Lease: lease.ID})
+
+	batch := KVExpireBatch{
+		// b was updated since the leader looked
+		Keys:   []KVCmdData{{Key: "a", PrevRevision: 1}, {Key: "b", PrevRevision: 1}},
+		Leases: []KVLeaseCmdData{{ID: lease.ID, ExpireAt: lease.ExpireAt}},
+	}
+	r := store.Apply(raft.StateMachineCmd{CmdType: KVCmdExpireBatch, Data: batch}).(KVBatchResult)
+
+	if !r.Succeeded || r.Deleted != 1 || r.Revision != 5 {
+		t.Errorf("Expire batch should only expire keys with matching revision, got %v", r)
+	}
+	if keyCount(store) != 1 || len(store.leases) != 0 {
+		t.Error("Expire batch should expire keys and revoke leases")
+	}
+}
+
+func TestExpirerFollower(t *testing.T) {
+	node := &fakeNode{leader: 1, store: newRKVStore(), success: true}
+	applyKV(node.store, KVCmdSet, KVCmdData{Key: "a", Value: "a", ExpireAt: time.Now().UnixNano()})
+
+	e := newExpirer(node, node.store)
+	e.start()
+	time.Sleep(expireInterval * 2)
+	e.stop()
+
+	if keyCount(node.store) != 1 {
+		t.Error("Followers should not expire keys")
+	}
+}
+
+func TestExpirerLease(t *testing.T) {
+	node := &fakeNode{leader: 0, store: newRKVStore(), success: true}
+	lease := applyLease(node.store, KVCmdLeaseGrant, KVLeaseCmdData{TTL: 1, Time: time.Now().Add(-time.Second).UnixNano()}).ID
+	applyKV(node.store, KVCmdSet, KVCmdData{Key: "a", Value: "a", Lease: lease})
+
+	newExpirer(node, node.store).expire(time.Now())
+
+	if len(node.store.leases) != 0 || keyCount(node.store) != 0 {
+		t.Error("expire should revoke expired leases")
+	}
+}
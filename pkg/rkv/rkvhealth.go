@@ -0,0 +1,129 @@
+package rkv
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+
+	"github.com/sidecus/raft/pkg/raft"
+	"github.com/sidecus/raft/pkg/rkv/pbv2"
+)
+
+// DefaultReadyMaxLag is the default number of entries a node can be behind the leader's commit index and still be ready
+const DefaultReadyMaxLag = 1000
+
+const healthCheckInterval = time.Second
+
+// readinessService is the gRPC health service reporting readiness. The "" service reports liveness
+var readinessService = pbv2.KVStore_ServiceDesc.ServiceName
+
+var errorNoKnownLeader = errors.New("no known leader")
+var errorInstallingSnapshot = errors.New("installing snapshot from the leader")
+
+// rkvHealth reports whether the node is alive and ready to serve, over the gRPC health checking protocol and HTTP.
+// A node is ready when it has a known leader, it's not installing a snapshot, and its applied index is within maxLag
+// of the leader's commit index
+type rkvHealth struct {
+	node   raft.INode
+	maxLag int
+	server *health.Server
+	stop   chan struct{}
+	wg     sync.WaitGroup
+	once   sync.Once
+}
+
+// newRKVHealth creates the health checker. maxLag defaults to DefaultReadyMaxLag if it's not positive
+func newRKVHealth(node raft.INode, maxLag int) *rkvHealth {
+	if maxLag <= 0 {
+		maxLag = DefaultReadyMaxLag
+	}
+	return &rkvHealth{
+		node:   node,
+		maxLag: maxLag,
+		server: health.NewServer(),
+		stop:   make(chan struct{}),
+	}
+}
+
+// ready returns nil if the node is ready to serve, otherwise why it's not
+func (h *rkvHealth) ready() error {
+	select {
+	case <-h.stop:
+		return errorShuttingDown
+	default:
+	}
+
+	status := h.node.Status()
+	switch {
+	case status.InstallingSnapshot:
+		return errorInstallingSnapshot
+	case status.LeaderID == -1:
+		return errorNoKnownLeader
+	case status.LeaderCommitIndex-status.AppliedIndex > h.maxLag:
+		return fmt.Errorf("applied index %d is more than %d behind the leader's commit index %d", status.AppliedIndex, h.maxLag, status.LeaderCommitIndex)
+	}
+	return nil
+}
+
+// register registers the gRPC health service on the server
+func (h *rkvHealth) register(server *grpc.Server) {
+	healthpb.RegisterHealthServer(server, h.server)
+}
+
+// registerHTTP serves /healthz and /readyz on mux. Both return 200 when OK, and /readyz returns 503 with the reason otherwise
+func (h *rkvHealth) registerHTTP(mux *http.ServeMux) {
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "ok")
+	})
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		if err := h.ready(); err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprintln(w, "ok")
+	})
+}
+
+// start updates the gRPC readiness status periodically until stop is called
+func (h *rkvHealth) start() {
+	h.update()
+
+	h.wg.Add(1)
+	go func() {
+		defer h.wg.Done()
+		ticker := time.NewTicker(healthCheckInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				h.update()
+			case <-h.stop:
+				return
+			}
+		}
+	}()
+}
+
+// update sets the gRPC readiness status
+func (h *rkvHealth) update() {
+	status := healthpb.HealthCheckResponse_SERVING
+	if h.ready() != nil {
+		status = healthpb.HealthCheckResponse_NOT_SERVING
+	}
+	h.server.SetServingStatus(readinessService, status)
+}
+
+// shutdown stops updating the status, and reports NOT_SERVING for all services. Only the first call counts
+func (h *rkvHealth) shutdown() {
+	h.once.Do(func() {
+		close(h.stop)
+		h.wg.Wait()
+		h.server.Shutdown()
+	})
+}
@@ -0,0 +1,103 @@
+package rkv
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+
+	"github.com/sidecus/raft/pkg/raft"
+)
+
+func TestReady(t *testing.T) {
+	node := &fakeNode{}
+	h := newRKVHealth(node, 10)
+
+	cases := []struct {
+		status raft.NodeStatus
+		ready  bool
+	}{
+		{raft.NodeStatus{LeaderID: -1}, false},
+		{raft.NodeStatus{LeaderID: 1, InstallingSnapshot: true}, false},
+		{raft.NodeStatus{LeaderID: 1, AppliedIndex: 10, LeaderCommitIndex: 21}, false},
+		{raft.NodeStatus{LeaderID: 1, AppliedIndex: 10, LeaderCommitIndex: 20}, true},
+		{raft.NodeStatus{LeaderID: 0, State: raft.NodeStateLeader, AppliedIndex: 5, LeaderCommitIndex: 5}, true},
+	}
+	for i, c := range cases {
+		node.status = c.status
+		if err := h.ready(); (err == nil) != c.ready {
+			t.Errorf("case %d: expected ready %v, got %v", i, c.ready, err)
+		}
+	}
+
+	if newRKVHealth(node, 0).maxLag != DefaultReadyMaxLag {
+		t.Error("maxLag should default to DefaultReadyMaxLag")
+	}
+}
+
+func TestHealthHTTP(t *testing.T) {
+	node := &fakeNode{status: raft.NodeStatus{LeaderID: -1}}
+	h := newRKVHealth(node, 10)
+	mux := http.NewServeMux()
+	h.registerHTTP(mux)
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	get := func(path string) int {
+		resp, err := server.Client().Get(server.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	if get("/healthz") != http.StatusOK {
+		t.Error("healthz should be OK while the process is alive")
+	}
+	if get("/readyz") != http.StatusServiceUnavailable {
+		t.Error("readyz should be unavailable without a leader")
+	}
+	node.status.LeaderID = 1
+	if get("/readyz") != http.StatusOK {
+		t.Error("readyz should be OK with a leader")
+	}
+}
+
+func TestHealthGRPC(t *testing.T) {
+	node := &fakeNode{status: raft.NodeStatus{LeaderID: -1}}
+	h := newRKVHealth(node, 10)
+	h.update()
+
+	check := func(service string) healthpb.HealthCheckResponse_ServingStatus {
+		resp, err := h.server.Check(context.Background(), &healthpb.HealthCheckRequest{Service: service})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp.Status
+	}
+
+	if check("") != healthpb.HealthCheckResponse_SERVING {
+		t.Error("Liveness should be SERVING")
+	}
+	if check(readinessService) != healthpb.HealthCheckResponse_NOT_SERVING {
+		t.Error("Readiness should be NOT_SERVING without a leader")
+	}
+
+	node.status.LeaderID = 1
+	h.update()
+	if check(readinessService) != healthpb.HealthCheckResponse_SERVING {
+		t.Error("Readiness should be SERVING with a leader")
+	}
+
+	h.shutdown()
+	if check("") != healthpb.HealthCheckResponse_NOT_SERVING || check(readinessService) != healthpb.HealthCheckResponse_NOT_SERVING {
+		t.Error("All services should be NOT_SERVING after shutdown")
+	}
+	if h.ready() != errorShuttingDown {
+		t.Error("Node should not be ready after shutdown")
+	}
+	h.shutdown()
+}
@@ -0,0 +1,103 @@
+package rkv
+
+import "math/rand"
+
+const maxIndexLevel = 24
+
+// indexNode is a skiplist node. next[i] is the next node at level i
+type indexNode struct {
+	key  string
+	next []*indexNode
+}
+
+// keyIndex is a skiplist of keys, kept alongside the kv map to support ordered scans.
+// It's not concurrency safe, the store protects it with its own lock
+type keyIndex struct {
+	head  *indexNode
+	level int
+	len   int
+	rnd   *rand.Rand
+}
+
+func newKeyIndex() *keyIndex {
+	return &keyIndex{
+		head:  &indexNode{next: make([]*indexNode, maxIndexLevel)},
+		level: 1,
+		rnd:   rand.New(rand.NewSource(1)),
+	}
+}
+
+// randomLevel picks a node level with probability 1/4 for each level up
+func (idx *keyIndex) randomLevel() int {
+	level := 1
+	for level < maxIndexLevel && idx.rnd.Intn(4) == 0 {
+		level++
+	}
+	return level
+}
+
+// findPrev fills prev with the last node before key at each level, and returns the first node at or after key
+func (idx *keyIndex) findPrev(key string, prev []*indexNode) *indexNode {
+	node := idx.head
+	for i := idx.level - 1; i >= 0; i-- {
+		for node.next[i] != nil && node.next[i].key < key {
+			node = node.next[i]
+		}
+		if prev != nil {
+			prev[i] = node
+		}
+	}
+	return node.next[0]
+}
+
+// insert adds key to the index if it's not there yet
+func (idx *keyIndex) insert(key string) {
+	prev := make([]*indexNode, maxIndexLevel)
+	if n := idx.findPrev(key, prev); n != nil && n.key == key {
+		return
+	}
+
+	level := idx.randomLevel()
+	for i := idx.level; i < level; i++ {
+		prev[i] = idx.head
+	}
+	if level > idx.level {
+		idx.level = level
+	}
+
+	node := &indexNode{key: key, next: make([]*indexNode, level)}
+	for i := 0; i < level; i++ {
+		node.next[i] = prev[i].next[i]
+		prev[i].next[i] = node
+	}
+	idx.len++
+}
+
+// remove deletes key from the index if it's there
+func (idx *keyIndex) remove(key string) {
+	prev := make([]*indexNode, maxIndexLevel)
+	node := idx.findPrev(key, prev)
+	if node == nil || node.key != key {
+		return
+	}
+
+	for i := 0; i < len(node.next); i++ {
+		prev[i].next[i] = node.next[i]
+	}
+	for idx.level > 1 && idx.head.next[idx.level-1] == nil {
+		idx.level--
+	}
+	idx.len--
+}
+
+// ascend calls fn for keys in [start, end) in order until fn returns false. Empty end means no upper bound
+func (idx *keyIndex) ascend(start string, end string, fn func(key string) bool) {
+	for node := idx.findPrev(start, nil); node != nil; node = node.next[0] {
+		if end != "" && node.key >= end {
+			return
+		}
+		if !fn(node.key) {
+			return
+		}
+	}
+}
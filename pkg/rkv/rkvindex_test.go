@@ -0,0 +1,63 @@
+package rkv
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func collect(idx *keyIndex, start string, end string) []string {
+	var keys []string
+	idx.ascend(start, end, func(key string) bool {
+		keys = append(keys, key)
+		return true
+	})
+	return keys
+}
+
+func TestKeyIndex(t *testing.T) {
+	idx := newKeyIndex()
+	expected := make(map[string]bool)
+
+	for i := 0; i < 2000; i++ {
+		key := fmt.Sprintf("k%04d", rand.Intn(500))
+		if rand.Intn(3) == 0 {
+			idx.remove(key)
+			delete(expected, key)
+		} else {
+			idx.insert(key)
+			expected[key] = true
+		}
+	}
+
+	var sorted []string
+	for k := range expected {
+		sorted = append(sorted, k)
+	}
+	sort.Strings(sorted)
+
+	keys := collect(idx, "", "")
+	if idx.len != len(sorted) || fmt.Sprint(keys) != fmt.Sprint(sorted) {
+		t.Fatal("Index should keep keys in order")
+	}
+
+	var inRange []string
+	for _, k := range sorted {
+		if k >= "k0100" && k < "k0200" {
+			inRange = append(inRange, k)
+		}
+	}
+	if fmt.Sprint(collect(idx, "k0100", "k0200")) != fmt.Sprint(inRange) {
+		t.Error("ascend should only return keys in range")
+	}
+
+	count := 0
+	idx.ascend("", "", func(key string) bool {
+		count++
+		return count < 3
+	})
+	if count != 3 {
+		t.Error("ascend should stop when fn returns false")
+	}
+}
@@ -0,0 +1,40 @@
+package rkv
+
+import (
+	"errors"
+
+	"github.com/sidecus/raft/pkg/raft"
+)
+
+var errorNotLeader = errors.New("Current node is not the leader")
+
+// leaderGuard resolves leader hints for replies, and rejects requests on non leader nodes when proxying is disabled
+type leaderGuard struct {
+	node         raft.INode
+	peers        map[int]raft.NodeInfo
+	disableProxy bool
+}
+
+// check returns an error if the request should not be processed by current node.
+// Leadership might change right after the check, in which case the node still proxies the request
+func (g *leaderGuard) check() error {
+	if !g.disableProxy {
+		return nil
+	}
+
+	switch g.node.LeaderID() {
+	case -1:
+		return raft.ErrorNoLeaderAvailable
+	case g.node.NodeID():
+		return nil
+	default:
+		return errorNotLeader
+	}
+}
+
+// leader returns the known leader ID and its endpoint, -1 if there is none.
+// endpoint is empty if current node is the leader
+func (g *leaderGuard) leader() (int, string) {
+	leader := g.node.LeaderID()
+	return leader, g.peers[leader].Endpoint
+}
@@ -0,0 +1,118 @@
+package rkv
+
+import (
+	"time"
+
+	"github.com/sidecus/raft/pkg/util"
+)
+
+// KVLeaseCmdData is the data of lease cmds in the log entry
+type KVLeaseCmdData struct {
+	// ID is the lease ID, not used by KVCmdLeaseGrant
+	ID int64 `json:",omitempty"`
+	// TTL is the lease ttl in milliseconds for KVCmdLeaseGrant
+	TTL int64 `json:",omitempty"`
+	// Time is the unix time in nanoseconds when KVCmdLeaseGrant or KVCmdLeaseKeepAlive is proposed.
+	// The lease expires TTL after it. Like ExpireAt in KVCmdData it's set before proposing
+	Time int64 `json:",omitempty"`
+	// ExpireAt is the lease expiry time seen by the leader for KVCmdLeaseExpire
+	ExpireAt int64 `json:",omitempty"`
+}
+
+// KVLeaseResult is the result of applying a lease cmd, returned by Execute
+type KVLeaseResult struct {
+	// Succeeded is false if the lease doesn't exist
+	Succeeded bool
+	ID        int64
+	TTL       int64
+	ExpireAt  int64
+}
+
+// kvLease is a lease. Keys attached to it are kept by the backend, and deleted when the lease is revoked or expires
+type kvLease struct {
+	ID       int64
+	TTL      int64
+	ExpireAt int64
+}
+
+// expired tells whether the lease has expired at now
+func (l *kvLease) expired(now time.Time) bool {
+	return l.ExpireAt <= now.UnixNano()
+}
+
+// isLeaseCmd tells whether cmdType is a lease cmd type
+func isLeaseCmd(cmdType int) bool {
+	switch cmdType {
+	case KVCmdLeaseGrant, KVCmdLeaseKeepAlive, KVCmdLeaseRevoke, KVCmdLeaseExpire:
+		return true
+	default:
+		return false
+	}
+}
+
+// applyLease applies a lease cmd, needs to be called with lock held.
+// Lease IDs are assigned in log order, so they are the same on all replicas
+func (store *rkvStore) applyLease(cmdType int, data KVLeaseCmdData) KVLeaseResult {
+	lease, exists := store.leases[data.ID]
+
+	switch cmdType {
+	case KVCmdLeaseGrant:
+		store.meta.LastLeaseID++
+		lease = &kvLease{
+			ID:       store.meta.LastLeaseID,
+			TTL:      data.TTL,
+			ExpireAt: data.Time + data.TTL*int64(time.Millisecond),
+		}
+		store.leases[lease.ID] = lease
+		store.backend.putLease(lease)
+	case KVCmdLeaseKeepAlive:
+		if !exists {
+			return KVLeaseResult{ID: data.ID}
+		}
+		// keepalives proposed concurrently might be applied out of order, never shorten the lease
+		if expireAt := data.Time + lease.TTL*int64(time.Millisecond); expireAt > lease.ExpireAt {
+			lease.ExpireAt = expireAt
+			store.backend.putLease(lease)
+		}
+	case KVCmdLeaseRevoke, KVCmdLeaseExpire:
+		if !exists || (cmdType == KVCmdLeaseExpire && lease.ExpireAt != data.ExpireAt) {
+			return KVLeaseResult{ID: data.ID}
+		}
+		store.revoke(lease)
+	default:
+		util.Panicf("Unexpected kv lease cmdtype %d", cmdType)
+	}
+
+	return KVLeaseResult{Succeeded: true, ID: lease.ID, TTL: lease.TTL, ExpireAt: lease.ExpireAt}
+}
+
+// revoke deletes the lease and all keys attached to it as one modification. Needs to be called with lock held.
+// Keys are deleted in order so that delete events are the same on all replicas
+func (store *rkvStore) revoke(lease *kvLease) {
+	keys := store.backend.leaseKeys(lease.ID)
+	if len(keys) > 0 {
+		store.meta.Revision++
+	}
+	for _, k := range keys {
+		store.remove(k, store.meta.Revision)
+	}
+	delete(store.leases, lease.ID)
+	store.backend.deleteLease(lease.ID)
+}
+
+// expiredLeases returns at most max leases expired at now, with their expiry time
+func (store *rkvStore) expiredLeases(now time.Time, max int) map[int64]int64 {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	leases := make(map[int64]int64)
+	for id, lease := range store.leases {
+		if len(leases) >= max {
+			break
+		}
+		if lease.expired(now) {
+			leases[id] = lease.ExpireAt
+		}
+	}
+	return leases
+}
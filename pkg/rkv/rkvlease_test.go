@@ -0,0 +1,135 @@
+package rkv
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/sidecus/raft/pkg/raft"
+)
+
+func applyLease(store *rkvStore, cmdType int, data KVLeaseCmdData) KVLeaseResult {
+	return store.Apply(raft.StateMachineCmd{CmdType: cmdType, Data: data}).(KVLeaseResult)
+}
+
+func TestLeaseGrantKeepAlive(t *testing.T) {
+	store := newRKVStore()
+	now := time.Now().UnixNano()
+
+	r := applyLease(store, KVCmdLeaseGrant, KVLeaseCmdData{TTL: 1000, Time: now})
+	if !r.Succeeded || r.ID != 1 || r.ExpireAt != now+int64(time.Second) {
+		t.Fatal("LeaseGrant should create lease with expiry ttl after proposal time")
+	}
+	if r = applyLease(store, KVCmdLeaseGrant, KVLeaseCmdData{TTL: 1000, Time: now}); r.ID != 2 {
+		t.Error("Lease IDs should be assigned in order")
+	}
+
+	if r = applyLease(store, KVCmdLeaseKeepAlive, KVLeaseCmdData{ID: 1, Time: now + 10}); !r.Succeeded || r.ExpireAt != now+10+int64(time.Second) {
+		t.Error("KeepAlive should refresh the lease")
+	}
+	if r = applyLease(store, KVCmdLeaseKeepAlive, KVLeaseCmdData{ID: 1, Time: now}); r.ExpireAt != now+10+int64(time.Second) {
+		t.Error("KeepAlive should not shorten the lease")
+	}
+	if r = applyLease(store, KVCmdLeaseKeepAlive, KVLeaseCmdData{ID: 3, Time: now}); r.Succeeded {
+		t.Error("KeepAlive should fail on non existent lease")
+	}
+}
+
+func TestLeaseRevoke(t *testing.T) {
+	store := newRKVStore()
+	lease := applyLease(store, KVCmdLeaseGrant, KVLeaseCmdData{TTL: 1000, Time: time.Now().UnixNano()}).ID
+
+	if r := applyKV(store, KVCmdSet, KVCmdData{Key: "a", Value: "a", Lease: 100}); r.Succeeded {
+		t.Error("Set should fail on non existent lease")
+	}
+
+	applyKV(store, KVCmdSet, KVCmdData{Key: "a", Value: "a", Lease: lease})
+	applyKV(store, KVCmdSet, KVCmdData{Key: "b", Value: "b", Lease: lease})
+	applyKV(store, KVCmdSet, KVCmdData{Key: "c", Value: "c", Lease: lease})
+	applyKV(store, KVCmdSet, KVCmdData{Key: "d", Value: "d"})
+	// c is detached from the lease when set without it
+	applyKV(store, KVCmdSet, KVCmdData{Key: "c", Value: "c"})
+
+	if v, _ := store.Get("a"); v.(KVEntry).Lease != lease {
+		t.Error("Key should be attached to the lease")
+	}
+
+	revision := store.meta.Revision
+	if r := applyLease(store, KVCmdLeaseRevoke, KVLeaseCmdData{ID: lease}); !r.Succeeded {
+		t.Fatal("LeaseRevoke should succeed")
+	}
+	if keyCount(store) != 2 || store.meta.Revision != revision+1 {
+		t.Error("LeaseRevoke should delete all attached keys as one modification")
+	}
+	if r := applyLease(store, KVCmdLeaseRevoke, KVLeaseCmdData{ID: lease}); r.Succeeded {
+		t.Error("LeaseRevoke should fail on revoked lease")
+	}
+}
+
+func TestLeaseRevokeEventOrder(t *testing.T) {
+	store := newRKVStore()
+	lease := applyLease(store, KVCmdLeaseGrant, KVLeaseCmdData{TTL: 1000, Time: time.Now().UnixNano()}).ID
+	for i := 20; i > 0; i-- {
+		applyKV(store, KVCmdSet, KVCmdData{Key: fmt.Sprintf("k%02d", i), Value: "v", Lease: lease})
+	}
+
+	w, _ := store.watches.watch("", true, 0)
+	applyLease(store, KVCmdLeaseRevoke, KVLeaseCmdData{ID: lease})
+
+	events := <-w.events
+	if len(events) != 20 {
+		t.Fatalf("LeaseRevoke should send delete events for all attached keys, got %d", len(events))
+	}
+	for i, e := range events {
+		if e.Type != KVEventDelete || e.Key != fmt.Sprintf("k%02d", i+1) {
+			t.Fatalf("LeaseRevoke should delete keys in order, got %v at %d", e, i)
+		}
+	}
+}
+
+func TestLeaseExpire(t *testing.T) {
+	store := newRKVStore()
+	r := applyLease(store, KVCmdLeaseGrant, KVLeaseCmdData{TTL: 1, Time: time.Now().Add(-time.Second).UnixNano()})
+	applyKV(store, KVCmdSet, KVCmdData{Key: "a", Value: "a", Lease: r.ID})
+
+	if _, err := store.Get("a"); err == nil {
+		t.Error("Get should hide keys attached to expired lease")
+	}
+
+	expired := store.expiredLeases(time.Now(), 10)
+	if len(expired) != 1 || expired[r.ID] != r.ExpireAt {
+		t.Fatal("expiredLeases should return expired leases")
+	}
+
+	if res := applyLease(store, KVCmdLeaseExpire, KVLeaseCmdData{ID: r.ID, ExpireAt: r.ExpireAt - 1}); res.Succeeded {
+		t.Error("LeaseExpire should not revoke lease refreshed since")
+	}
+	if res := applyLease(store, KVCmdLeaseExpire, KVLeaseCmdData{ID: r.ID, ExpireAt: r.ExpireAt}); !res.Succeeded || keyCount(store) != 0 {
+		t.Error("LeaseExpire should revoke the lease")
+	}
+}
+
+func TestLeaseSnapshot(t *testing.T) {
+	store := newRKVStore()
+	lease := applyLease(store, KVCmdLeaseGrant, KVLeaseCmdData{TTL: 1000, Time: time.Now().UnixNano()}).ID
+	applyKV(store, KVCmdSet, KVCmdData{Key: "a", Value: "a", Lease: lease})
+	applyKV(store, KVCmdSet, KVCmdData{Key: "b", Value: "b"})
+
+	buf := &bytes.Buffer{}
+	if err := store.Serialize(buf); err != nil {
+		t.Fatal(err)
+	}
+	newStore := newRKVStore()
+	if err := newStore.Deserialize(buf); err != nil {
+		t.Fatal(err)
+	}
+
+	if newStore.meta.LastLeaseID != lease || len(newStore.backend.leaseKeys(lease)) != 1 {
+		t.Fatal("Snapshot should preserve leases and attached keys")
+	}
+	applyLease(newStore, KVCmdLeaseRevoke, KVLeaseCmdData{ID: lease})
+	if keyCount(newStore) != 1 {
+		t.Error("Revoking lease from snapshot should delete attached keys")
+	}
+}
@@ -0,0 +1,40 @@
+package rkv
+
+import (
+	"github.com/sidecus/raft/pkg/raft"
+	"github.com/sidecus/raft/pkg/util"
+)
+
+// LogSubsystemRKV logs the kv store and node lifecycle, e.g. expiration, compaction and shutdown.
+// Like raft subsystems it can have its own log level
+const LogSubsystemRKV = "rkv"
+
+// rkvLogger adds the node ID to rkv logs, which go to LogSubsystemRKV. The zero value logs to the default logger
+type rkvLogger struct {
+	logger raft.Logger
+	nodeID int
+}
+
+func newRKVLogger(nodeID int, logger raft.Logger) rkvLogger {
+	return rkvLogger{logger: logger, nodeID: nodeID}
+}
+
+func (l rkvLogger) log(level int, msg string, keyvals []interface{}) {
+	logger := l.logger
+	if logger == nil {
+		logger = util.DefaultLogger()
+	}
+	logger.Log(level, LogSubsystemRKV, msg, append([]interface{}{"node", l.nodeID}, keyvals...)...)
+}
+
+func (l rkvLogger) error(msg string, keyvals ...interface{}) {
+	l.log(util.LevelError, msg, keyvals)
+}
+
+func (l rkvLogger) warn(msg string, keyvals ...interface{}) {
+	l.log(util.LevelWarning, msg, keyvals)
+}
+
+func (l rkvLogger) info(msg string, keyvals ...interface{}) {
+	l.log(util.LevelInfo, msg, keyvals)
+}
@@ -0,0 +1,33 @@
+package rkv
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/sidecus/raft/pkg/util"
+)
+
+func TestRKVLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := util.NewLogger(&buf)
+	logger.SetFormat(util.LogFormatJSON)
+
+	store := newRKVStore()
+	store.log = newRKVLogger(2, logger)
+	applyKV(store, KVCmdSet, KVCmdData{Key: "a", Value: "a"})
+	applyCompact(store, 1)
+
+	out := buf.String()
+	if !strings.Contains(out, `"subsystem":"rkv","msg":"Compacted history","node":2,"revision":1}`) {
+		t.Errorf("rkv logs should be structured with the rkv subsystem and node ID, got %s", out)
+	}
+
+	buf.Reset()
+	logger.SetSubsystemLevel(LogSubsystemRKV, util.LevelWarning)
+	applyKV(store, KVCmdSet, KVCmdData{Key: "a", Value: "b"})
+	applyCompact(store, 2)
+	if buf.Len() != 0 {
+		t.Error("rkv subsystem log level should apply to rkv logs")
+	}
+}
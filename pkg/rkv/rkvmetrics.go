@@ -0,0 +1,188 @@
+package rkv
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+
+	"github.com/sidecus/raft/pkg/raft"
+)
+
+// rkvMetrics collects raft and kv store metrics in a Prometheus registry. Implements raft.IMetrics
+type rkvMetrics struct {
+	registry *prometheus.Registry
+
+	term            prometheus.Gauge
+	termChanges     prometheus.Counter
+	state           prometheus.Gauge
+	electionStarts  prometheus.Counter
+	electionWins    prometheus.Counter
+	rpcDuration     *prometheus.HistogramVec
+	rpcFailures     *prometheus.CounterVec
+	replicationLag  *prometheus.GaugeVec
+	commitDuration  prometheus.Histogram
+	applyDuration   prometheus.Histogram
+	appliedEntries  prometheus.Counter
+	snapshotSize    *prometheus.GaugeVec
+	snapshotTime    *prometheus.HistogramVec
+	requests        *prometheus.CounterVec
+	requestDuration *prometheus.HistogramVec
+}
+
+// newRKVMetrics creates the metrics and registers them, together with Go runtime and process metrics
+func newRKVMetrics() *rkvMetrics {
+	m := &rkvMetrics{
+		registry: prometheus.NewRegistry(),
+		term: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "raft_term", Help: "Current raft term of the node.",
+		}),
+		termChanges: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "raft_term_changes_total", Help: "Number of times the node moved to a higher term.",
+		}),
+		state: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "raft_state", Help: "Raft state of the node. 1 - follower, 2 - candidate, 3 - leader.",
+		}),
+		electionStarts: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "raft_elections_started_total", Help: "Number of elections started by the node.",
+		}),
+		electionWins: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "raft_elections_won_total", Help: "Number of elections won by the node.",
+		}),
+		rpcDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name: "raft_rpc_duration_seconds", Help: "Latency of raft RPCs to peers.", Buckets: prometheus.ExponentialBuckets(0.0005, 2, 14),
+		}, []string{"rpc", "peer"}),
+		rpcFailures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "raft_rpc_failures_total", Help: "Number of failed raft RPCs to peers.",
+		}, []string{"rpc", "peer"}),
+		replicationLag: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "raft_replication_lag_entries", Help: "Number of log entries a follower is behind the leader, reported by the leader.",
+		}, []string{"peer"}),
+		commitDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name: "raft_commit_duration_seconds", Help: "Time from proposing a cmd to committing it, on the leader.", Buckets: prometheus.ExponentialBuckets(0.0005, 2, 14),
+		}),
+		applyDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name: "raft_apply_duration_seconds", Help: "Time to apply a batch of committed entries to the state machine.", Buckets: prometheus.ExponentialBuckets(0.00005, 2, 16),
+		}),
+		appliedEntries: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "raft_applied_entries_total", Help: "Number of log entries applied to the state machine.",
+		}),
+		snapshotSize: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "raft_snapshot_size_bytes", Help: "Size of the latest snapshot taken or installed.",
+		}, []string{"op"}),
+		snapshotTime: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name: "raft_snapshot_duration_seconds", Help: "Time to take or install a snapshot.", Buckets: prometheus.ExponentialBuckets(0.001, 2, 14),
+		}, []string{"op"}),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "rkv_requests_total", Help: "Number of gRPC requests served, by method and status code.",
+		}, []string{"method", "code"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name: "rkv_request_duration_seconds", Help: "Latency of gRPC requests served, by method. Streams are measured until they end.", Buckets: prometheus.ExponentialBuckets(0.0005, 2, 14),
+		}, []string{"method"}),
+	}
+
+	m.registry.MustRegister(
+		prometheus.NewGoCollector(),
+		prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}),
+		m.term, m.termChanges, m.state, m.electionStarts, m.electionWins,
+		m.rpcDuration, m.rpcFailures, m.replicationLag,
+		m.commitDuration, m.applyDuration, m.appliedEntries,
+		m.snapshotSize, m.snapshotTime,
+		m.requests, m.requestDuration,
+	)
+	return m
+}
+
+// registerStore reports the kv store's history size, which grows with writes until compacted
+func (m *rkvMetrics) registerStore(store *rkvStore) {
+	m.registry.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "rkv_history_revisions", Help: "Number of revisions of key history retained, from the compact revision to the store revision.",
+	}, func() float64 {
+		revision, compactRevision := store.revisions()
+		return float64(revision - compactRevision)
+	}))
+}
+
+// registerHTTP serves the metrics on /metrics in Prometheus text format
+func (m *rkvMetrics) registerHTTP(mux *http.ServeMux) {
+	mux.Handle("/metrics", promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{}))
+}
+
+// TermChanged implements raft.IMetrics
+func (m *rkvMetrics) TermChanged(term int) {
+	m.term.Set(float64(term))
+	m.termChanges.Inc()
+}
+
+// StateChanged implements raft.IMetrics
+func (m *rkvMetrics) StateChanged(state raft.NodeState) {
+	m.state.Set(float64(state))
+}
+
+// ElectionStarted implements raft.IMetrics
+func (m *rkvMetrics) ElectionStarted() {
+	m.electionStarts.Inc()
+}
+
+// ElectionWon implements raft.IMetrics
+func (m *rkvMetrics) ElectionWon() {
+	m.electionWins.Inc()
+}
+
+// ObserveRPC implements raft.IMetrics
+func (m *rkvMetrics) ObserveRPC(rpc string, peerID int, duration time.Duration, err error) {
+	peer := strconv.Itoa(peerID)
+	m.rpcDuration.WithLabelValues(rpc, peer).Observe(duration.Seconds())
+	if err != nil {
+		m.rpcFailures.WithLabelValues(rpc, peer).Inc()
+	}
+}
+
+// SetReplicationLag implements raft.IMetrics
+func (m *rkvMetrics) SetReplicationLag(peerID int, lag int) {
+	m.replicationLag.WithLabelValues(strconv.Itoa(peerID)).Set(float64(lag))
+}
+
+// ObserveCommit implements raft.IMetrics
+func (m *rkvMetrics) ObserveCommit(duration time.Duration) {
+	m.commitDuration.Observe(duration.Seconds())
+}
+
+// ObserveApply implements raft.IMetrics
+func (m *rkvMetrics) ObserveApply(entries int, duration time.Duration) {
+	m.applyDuration.Observe(duration.Seconds())
+	m.appliedEntries.Add(float64(entries))
+}
+
+// ObserveSnapshot implements raft.IMetrics
+func (m *rkvMetrics) ObserveSnapshot(op string, size int64, duration time.Duration) {
+	m.snapshotSize.WithLabelValues(op).Set(float64(size))
+	m.snapshotTime.WithLabelValues(op).Observe(duration.Seconds())
+}
+
+// observeRequest records a served gRPC request
+func (m *rkvMetrics) observeRequest(method string, start time.Time, err error) {
+	m.requests.WithLabelValues(method, status.Code(err).String()).Inc()
+	m.requestDuration.WithLabelValues(method).Observe(time.Since(start).Seconds())
+}
+
+// unaryInterceptor counts and times unary gRPC requests, including raft node to node RPCs
+func (m *rkvMetrics) unaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	start := time.Now()
+	resp, err := handler(ctx, req)
+	m.observeRequest(info.FullMethod, start, err)
+	return resp, err
+}
+
+// streamInterceptor counts and times streaming gRPC requests, e.g. Watch and InstallSnapshot
+func (m *rkvMetrics) streamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	start := time.Now()
+	err := handler(srv, ss)
+	m.observeRequest(info.FullMethod, start, err)
+	return err
+}
@@ -0,0 +1,75 @@
+package rkv
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/sidecus/raft/pkg/raft"
+)
+
+func TestRKVMetrics(t *testing.T) {
+	m := newRKVMetrics()
+	m.TermChanged(3)
+	m.StateChanged(raft.NodeStateLeader)
+	m.ElectionStarted()
+	m.ElectionWon()
+	m.ObserveRPC(raft.RPCAppendEntries, 1, time.Millisecond, nil)
+	m.ObserveRPC(raft.RPCAppendEntries, 1, time.Millisecond, errors.New("unavailable"))
+	m.SetReplicationLag(1, 5)
+	m.ObserveApply(4, time.Millisecond)
+	m.ObserveSnapshot(raft.SnapshotTake, 1024, time.Millisecond)
+	store := newRKVStore()
+	applyKV(store, KVCmdSet, KVCmdData{Key: "a", Value: "a"})
+	applyKV(store, KVCmdSet, KVCmdData{Key: "a", Value: "b"})
+	m.registerStore(store)
+
+	info := &grpc.UnaryServerInfo{FullMethod: "/pbv2.KV/Put"}
+	m.unaryInterceptor(context.Background(), nil, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, nil
+	})
+	m.unaryInterceptor(context.Background(), nil, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, status.Error(codes.Unavailable, "no leader")
+	})
+
+	server := httptest.NewServer(promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{}))
+	defer server.Close()
+	resp, err := server.Client().Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	text := string(body)
+
+	expected := []string{
+		"raft_term 3",
+		"raft_state 3",
+		"raft_elections_started_total 1",
+		"raft_elections_won_total 1",
+		`raft_rpc_duration_seconds_count{peer="1",rpc="AppendEntries"} 2`,
+		`raft_rpc_failures_total{peer="1",rpc="AppendEntries"} 1`,
+		`raft_replication_lag_entries{peer="1"} 5`,
+		"raft_applied_entries_total 4",
+		`raft_snapshot_size_bytes{op="take"} 1024`,
+		`rkv_requests_total{code="OK",method="/pbv2.KV/Put"} 1`,
+		`rkv_requests_total{code="Unavailable",method="/pbv2.KV/Put"} 1`,
+		`rkv_request_duration_seconds_count{method="/pbv2.KV/Put"} 2`,
+		"rkv_history_revisions 2",
+		"go_goroutines",
+	}
+	for _, s := range expected {
+		if !strings.Contains(text, s) {
+			t.Errorf("Metrics should contain %s", s)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package rkv
+
+import (
+	"errors"
+	"fmt"
+)
+
+var errorFutureRevision = errors.New("revision is newer than the store revision")
+
+// KVCompactCmdData is the data of KVCmdCompact in the log entry
+type KVCompactCmdData struct {
+	// Revision is the revision to compact up to. Versions replaced or deleted before it are dropped
+	Revision int64
+}
+
+// KVGetAt is a query for a key at a past revision, which returns KVEntry
+type KVGetAt struct {
+	Key      string
+	Revision int64
+}
+
+// KVHistory is a query for all retained versions of a key, which returns KVHistoryResult
+type KVHistory struct {
+	Key string
+}
+
+// KVHistoryResult has the retained versions of a key in revision order, deletes included
+type KVHistoryResult struct {
+	Versions []KVEvent
+	// CompactRevision is the store's compact revision. Versions before it might be gone
+	CompactRevision int64 `json:",omitempty"`
+}
+
+// kvVersion is one version of a key. A delete is kept as a version with Deleted set
+type kvVersion struct {
+	KVEntry
+	Deleted bool `json:",omitempty"`
+}
+
+// checkRevision validates a historical read revision. Needs to be called with lock held
+func (store *rkvStore) checkRevision(revision int64) error {
+	switch {
+	case revision <= 0:
+		return errorInvalidRevision
+	case revision > store.meta.Revision:
+		return fmt.Errorf("revision %d, store revision %d: %w", revision, store.meta.Revision, errorFutureRevision)
+	case revision < store.meta.CompactRevision:
+		return fmt.Errorf("revision %d, compact revision %d: %w", revision, store.meta.CompactRevision, errorCompacted)
+	}
+	return nil
+}
+
+// getAt reads a key at a past revision. Needs to be called with lock held.
+// Unlike current reads, expiry is not checked, since expired keys are deleted through the log like other deletes
+func (store *rkvStore) getAt(q KVGetAt) (KVEntry, error) {
+	if err := store.checkRevision(q.Revision); err != nil {
+		return KVEntry{}, err
+	}
+	if v, ok := store.backend.versionAt(q.Key, q.Revision); ok {
+		return v, nil
+	}
+	return KVEntry{}, fmt.Errorf("Key %s at revision %d: %w", q.Key, q.Revision, errorKeyNotFound)
+}
+
+// getHistory returns the retained versions of a key. Needs to be called with lock held
+func (store *rkvStore) getHistory(q KVHistory) KVHistoryResult {
+	versions := store.backend.versions(q.Key)
+	result := KVHistoryResult{Versions: make([]KVEvent, len(versions)), CompactRevision: store.meta.CompactRevision}
+	for i, v := range versions {
+		result.Versions[i] = KVEvent{Type: KVEventPut, Key: q.Key, Value: v.Value, Revision: v.Revision}
+		if v.Deleted {
+			result.Versions[i].Type = KVEventDelete
+		}
+	}
+	return result
+}
+
+// revisions returns the store revision and compact revision. History is kept for revisions in between
+func (store *rkvStore) revisions() (revision int64, compactRevision int64) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	return store.meta.Revision, store.meta.CompactRevision
+}
+
+// applyCompact drops versions no longer visible at data.Revision and after, needs to be called with lock held.
+// Fails if the revision is already compacted or newer than the store revision. Returns the compact revision after the cmd
+func (store *rkvStore) applyCompact(data KVCompactCmdData) KVCmdResult {
+	if data.Revision <= store.meta.CompactRevision || data.Revision > store.meta.Revision {
+		return KVCmdResult{Revision: store.meta.CompactRevision}
+	}
+
+	store.backend.compact(data.Revision)
+	store.meta.CompactRevision = data.Revision
+	store.log.info("Compacted history", "revision", data.Revision)
+	return KVCmdResult{Succeeded: true, Revision: store.meta.CompactRevision}
+}
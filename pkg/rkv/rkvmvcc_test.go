@@ -0,0 +1,106 @@
+package rkv
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/sidecus/raft/pkg/raft"
+)
+
+func applyCompact(store *rkvStore, revision int64) KVCmdResult {
+	return store.Apply(raft.StateMachineCmd{CmdType: KVCmdCompact, Data: KVCompactCmdData{Revision: revision}}).(KVCmdResult)
+}
+
+func getAt(store *rkvStore, key string, revision int64) (string, error) {
+	v, err := store.Get(KVGetAt{Key: key, Revision: revision})
+	return v.(KVEntry).Value, err
+}
+
+func TestGetAt(t *testing.T) {
+	store := newRKVStore()
+	applyKV(store, KVCmdSet, KVCmdData{Key: "a", Value: "1"}) // 1
+	applyKV(store, KVCmdSet, KVCmdData{Key: "b", Value: "1"}) // 2
+	applyKV(store, KVCmdSet, KVCmdData{Key: "a", Value: "2"}) // 3
+	applyKV(store, KVCmdDel, KVCmdData{Key: "a"})             // 4
+	applyKV(store, KVCmdSet, KVCmdData{Key: "a", Value: "3"}) // 5
+
+	expected := map[int64]string{1: "1", 2: "1", 3: "2", 5: "3"}
+	for rev, value := range expected {
+		if v, err := getAt(store, "a", rev); err != nil || v != value {
+			t.Errorf("Get a at %d should return %s, got %s %v", rev, value, v, err)
+		}
+	}
+	if _, err := getAt(store, "a", 4); !errors.Is(err, errorKeyNotFound) {
+		t.Error("Get at revision of a delete should return key not found")
+	}
+	if _, err := getAt(store, "b", 1); !errors.Is(err, errorKeyNotFound) {
+		t.Error("Get before a key is created should return key not found")
+	}
+	if _, err := getAt(store, "a", 6); !errors.Is(err, errorFutureRevision) {
+		t.Error("Get after store revision should fail")
+	}
+
+	v, _ := store.Get(KVRange{Start: "a", Revision: 4})
+	if r := v.(KVRangeResult); len(r.Entries) != 1 || r.Entries[0].Key != "b" || r.Revision != 4 {
+		t.Error("Range at revision should return keys at that revision")
+	}
+	v, _ = store.Get(KVRange{Start: "a"})
+	if r := v.(KVRangeResult); len(r.Entries) != 2 || r.Revision != 5 {
+		t.Error("Range should return the current revision")
+	}
+
+	v, _ = store.Get(KVHistory{Key: "a"})
+	h := v.(KVHistoryResult)
+	if len(h.Versions) != 4 || h.Versions[2].Type != KVEventDelete || h.Versions[3].Value != "3" {
+		t.Errorf("History should return all versions, got %v", h.Versions)
+	}
+}
+
+func TestCompact(t *testing.T) {
+	store := newRKVStore()
+	applyKV(store, KVCmdSet, KVCmdData{Key: "a", Value: "1"}) // 1
+	applyKV(store, KVCmdSet, KVCmdData{Key: "b", Value: "1"}) // 2
+	applyKV(store, KVCmdSet, KVCmdData{Key: "a", Value: "2"}) // 3
+	applyKV(store, KVCmdDel, KVCmdData{Key: "b"})             // 4
+	applyKV(store, KVCmdSet, KVCmdData{Key: "a", Value: "3"}) // 5
+
+	if r := applyCompact(store, 6); r.Succeeded {
+		t.Error("Compact after store revision should fail")
+	}
+	if r := applyCompact(store, 4); !r.Succeeded || r.Revision != 4 {
+		t.Fatal("Compact should succeed")
+	}
+	if r := applyCompact(store, 4); r.Succeeded || r.Revision != 4 {
+		t.Error("Compact at compacted revision should fail")
+	}
+
+	if _, err := getAt(store, "a", 3); !errors.Is(err, errorCompacted) {
+		t.Error("Get before compact revision should fail")
+	}
+	if v, err := getAt(store, "a", 4); err != nil || v != "2" {
+		t.Error("Version visible at compact revision should be kept")
+	}
+	if mem := store.backend.(*memBackend); len(mem.history) != 1 || mem.index.len != 1 {
+		t.Error("Deleted key should be dropped by compaction")
+	}
+
+	buf := &bytes.Buffer{}
+	store.Serialize(buf)
+	newStore := newRKVStore()
+	newStore.Deserialize(buf)
+	if v, err := getAt(newStore, "a", 4); err != nil || v != "2" || newStore.meta.CompactRevision != 4 {
+		t.Error("Snapshot should carry retained versions")
+	}
+	if _, err := getAt(newStore, "a", 3); !errors.Is(err, errorCompacted) {
+		t.Error("Snapshot should carry compact revision")
+	}
+}
+
+func TestSnapshotWithoutHistory(t *testing.T) {
+	store := newRKVStore()
+	store.Deserialize(bytes.NewBufferString(`{"Revision":3,"Data":{"a":{"Value":"1","Revision":2}}}`))
+	if v, err := getAt(store, "a", 3); err != nil || v != "1" {
+		t.Error("History should be rebuilt from data for old snapshots")
+	}
+}
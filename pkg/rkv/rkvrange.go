@@ -0,0 +1,69 @@
+package rkv
+
+import "time"
+
+// KVRange is a range query on keys in [Start, End), ordered by key. Empty End means no upper bound
+type KVRange struct {
+	Start string
+	End   string `json:",omitempty"`
+	// Limit is the max number of entries returned, 0 means no limit
+	Limit int `json:",omitempty"`
+	// Revision reads the range at a past revision, 0 means the current revision
+	Revision int64 `json:",omitempty"`
+}
+
+// KVKeyEntry is a key with its entry
+type KVKeyEntry struct {
+	Key string
+	KVEntry
+}
+
+// KVRangeResult is the result of a KVRange query
+type KVRangeResult struct {
+	Entries []KVKeyEntry
+	// NextKey is the first key not returned because of Limit, empty if all keys in range are returned
+	NextKey string `json:",omitempty"`
+	// Revision is the store revision the range is read at. Reading further pages at it gives a consistent view
+	Revision int64
+}
+
+// prefixRange returns the range of all keys with the prefix
+func prefixRange(prefix string, limit int) KVRange {
+	// the range ends at the prefix with its last byte incremented, skipping 0xff bytes which can't be
+	end := []byte(prefix)
+	for len(end) > 0 {
+		if end[len(end)-1] < 0xff {
+			end[len(end)-1]++
+			break
+		}
+		end = end[:len(end)-1]
+	}
+	return KVRange{Start: prefix, End: string(end), Limit: limit}
+}
+
+// scan returns entries in the range, skipping expired ones for current reads. Needs to be called with lock held
+func (store *rkvStore) scan(r KVRange, now time.Time) (KVRangeResult, error) {
+	result := KVRangeResult{Revision: store.meta.Revision}
+	collect := func(key string, entry KVEntry) bool {
+		if r.Limit > 0 && len(result.Entries) >= r.Limit {
+			result.NextKey = key
+			return false
+		}
+		result.Entries = append(result.Entries, KVKeyEntry{Key: key, KVEntry: entry})
+		return true
+	}
+
+	if r.Revision == 0 {
+		store.backend.ascend(r.Start, r.End, func(key string, entry KVEntry) bool {
+			return store.expired(entry, now) || collect(key, entry)
+		})
+		return result, nil
+	}
+
+	if err := store.checkRevision(r.Revision); err != nil {
+		return KVRangeResult{}, err
+	}
+	result.Revision = r.Revision
+	store.backend.ascendAt(r.Start, r.End, r.Revision, collect)
+	return result, nil
+}
@@ -3,153 +3,242 @@ package rkv
 import (
 	"context"
 	"net"
+	"strings"
 	"sync"
-	"time"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 
 	"github.com/sidecus/raft/pkg/raft"
+	grpctransport "github.com/sidecus/raft/pkg/raft/transport/grpc"
 	"github.com/sidecus/raft/pkg/rkv/pb"
+	"github.com/sidecus/raft/pkg/rkv/pbv2"
+	"github.com/sidecus/raft/pkg/trace"
 	"github.com/sidecus/raft/pkg/util"
 )
 
-// rkvRPCServer is used to implement pb.KVStoreRPCServer
+// rkvRPCServer is used to implement pb.KVStoreRaftServer.
+// Raft node to node RPCs are served by the raft gRPC transport on the same gRPC server
 type rkvRPCServer struct {
-	wg     *sync.WaitGroup
-	node   raft.INode
-	server *grpc.Server
+	wg        *sync.WaitGroup
+	node      raft.INode
+	guard     *leaderGuard
+	transport *grpctransport.Server
+	v2        *rkvRPCServerV2
+	server    *grpc.Server
+	metrics   *rkvMetrics
+	tracing   bool
+	health    *rkvHealth
 	pb.UnimplementedKVStoreRaftServer
+
+	// client requests are rejected once draining, and the ones in flight are tracked, see drain
+	mu       sync.Mutex
+	draining bool
+	inflight sync.WaitGroup
 }
 
-// newRKVRPCServer creates a new RPC server
-func newRKVRPCServer(node raft.INode, wg *sync.WaitGroup) *rkvRPCServer {
+// newRKVRPCServer creates a new RPC server, serving both v1 and v2 APIs. Requests are counted in metrics if it's not nil
+func newRKVRPCServer(node raft.INode, peers map[int]raft.NodeInfo, codec raft.ICodec, watches *watchHub, metrics *rkvMetrics, opts Options, wg *sync.WaitGroup) *rkvRPCServer {
+	guard := &leaderGuard{node: node, peers: peers, disableProxy: opts.DisableProxy}
 	return &rkvRPCServer{
-		node: node,
-		wg:   wg,
+		node:      node,
+		guard:     guard,
+		transport: grpctransport.NewServer(node, codec),
+		v2:        newRKVRPCServerV2(node, guard, watches),
+		metrics:   metrics,
+		tracing:   opts.TraceExporter != nil,
+		health:    newRKVHealth(node, opts.ReadyMaxLag),
+		wg:        wg,
 	}
 }
 
-// AppendEntries implements KVStoreRPCServer.AppendEntries
-func (s *rkvRPCServer) AppendEntries(ctx context.Context, req *pb.AppendEntriesRequest) (*pb.AppendEntriesReply, error) {
-	ae := toRaftAERequest(req)
-	resp, err := s.node.AppendEntries(ctx, ae)
+// Set sets a value in the kv store
+func (s *rkvRPCServer) Set(ctx context.Context, req *pb.SetRequest) (*pb.SetReply, error) {
+	cmd := toRaftSetRequest(req)
 
+	resp, err := s.execute(ctx, cmd)
 	if err != nil {
-		return nil, err
+		return nil, toStatusError(err, s.guard)
 	}
 
-	return fromRaftAEReply(resp), nil
+	return fromRaftSetReply(resp), nil
 }
 
-// RequestVote requests a vote from the node
-func (s *rkvRPCServer) RequestVote(ctx context.Context, req *pb.RequestVoteRequest) (*pb.RequestVoteReply, error) {
-	rv := toRaftRVRequest(req)
-	resp, err := s.node.RequestVote(ctx, rv)
+// Delete deletes a value from the kv store
+func (s *rkvRPCServer) Delete(ctx context.Context, req *pb.DeleteRequest) (*pb.DeleteReply, error) {
+	cmd := toRaftDeleteRequest(req)
 
+	resp, err := s.execute(ctx, cmd)
 	if err != nil {
-		return nil, err
+		return nil, toStatusError(err, s.guard)
 	}
 
-	return fromRaftRVReply(resp), nil
+	return fromRaftDeleteReply(resp), nil
 }
 
-// InstallSnapshot receives and installs snapshot on current node
-func (s *rkvRPCServer) InstallSnapshot(stream pb.KVStoreRaft_InstallSnapshotServer) error {
-	// Create snapshot reader over grpc
-	recvFunc := func() (*raft.SnapshotRequestHeader, []byte, error) {
-		var pbReq *pb.SnapshotRequest
-		var err error
-		if pbReq, err = stream.Recv(); err != nil {
-			return nil, nil, err
-		}
+// execute runs the cmd and turns an uncommitted write into an error
+func (s *rkvRPCServer) execute(ctx context.Context, cmd *raft.StateMachineCmd) (*raft.ExecuteReply, error) {
+	if err := s.guard.check(); err != nil {
+		return nil, err
+	}
 
-		return toRaftSnapshotRequestHeader(pbReq), pbReq.Data, nil
+	resp, err := s.node.Execute(ctx, cmd)
+	if err == nil && !resp.Success {
+		err = errorNotCommitted
 	}
-	reader, err := raft.NewSnapshotStreamReader(recvFunc, s.node.OnSnapshotPart)
-	if err != nil {
-		return err
+	return resp, err
+}
+
+// Get implements pb.KVStoreRaftRPCServer.Get
+func (s *rkvRPCServer) Get(ctx context.Context, req *pb.GetRequest) (*pb.GetReply, error) {
+	if err := s.guard.check(); err != nil {
+		return nil, toStatusError(err, s.guard)
 	}
 
-	// receive snapshot to file
-	req, err := raft.ReceiveSnapshot(s.node.NodeID(), reader)
+	resp, err := s.node.Get(ctx, toRaftGetRequest(req))
 	if err != nil {
-		return err
+		return nil, toStatusError(err, s.guard)
 	}
 
-	// Install and reply
-	var reply *raft.AppendEntriesReply
-	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(500)*time.Millisecond)
-	defer cancel()
+	return fromRaftGetReply(resp), nil
+}
 
-	if reply, err = s.node.InstallSnapshot(ctx, req); err != nil {
-		return err
+// Start starts the grpc server on a different go routine
+func (s *rkvRPCServer) Start(port string) {
+	lis, err := net.Listen("tcp", ":"+port)
+	if err != nil {
+		util.Fatalf("Cannot listen on port %s. Error:%s", port, err)
 	}
-
-	return stream.SendAndClose(fromRaftAEReply(reply))
+	s.serve(lis)
 }
 
-// Set sets a value in the kv store
-func (s *rkvRPCServer) Set(ctx context.Context, req *pb.SetRequest) (*pb.SetReply, error) {
-	cmd := toRaftSetRequest(req)
-
-	exeCtx, cancel := context.WithCancel(ctx)
-	defer cancel()
-	resp, err := s.node.Execute(exeCtx, cmd)
-
-	if err != nil {
-		return nil, err
+// serve serves the grpc server on lis on a different go routine
+func (s *rkvRPCServer) serve(lis net.Listener) {
+	var unary []grpc.UnaryServerInterceptor
+	var stream []grpc.StreamServerInterceptor
+	if s.tracing {
+		unary = append(unary, trace.UnaryServerInterceptor(isClientMethod))
 	}
+	if s.metrics != nil {
+		unary = append(unary, s.metrics.unaryInterceptor)
+		stream = append(stream, s.metrics.streamInterceptor)
+	}
+	unary = append(unary, s.drainUnaryInterceptor)
+	stream = append(stream, s.drainStreamInterceptor)
+	s.server = grpc.NewServer(grpc.ChainUnaryInterceptor(unary...), grpc.ChainStreamInterceptor(stream...))
+	pb.RegisterKVStoreRaftServer(s.server, s)
+	pbv2.RegisterKVStoreServer(s.server, s.v2)
+	s.transport.Register(s.server)
+	s.health.register(s.server)
+	s.health.start()
 
-	return fromRaftSetReply(resp), nil
+	s.wg.Add(1)
+	go func() {
+		if err := s.server.Serve(lis); err != nil {
+			util.Fatalf("Failed to server %ss", err)
+		}
+
+		s.wg.Done()
+	}()
 }
 
-// Delete deletes a value from the kv store
-func (s *rkvRPCServer) Delete(ctx context.Context, req *pb.DeleteRequest) (*pb.DeleteReply, error) {
-	cmd := toRaftDeleteRequest(req)
+// isClientMethod tells whether a gRPC method is served to clients, which start new traces.
+// Raft RPCs between nodes only continue traces, otherwise each heartbeat would be traced
+func isClientMethod(method string) bool {
+	return !strings.HasPrefix(method, "/raft.RaftTransport/")
+}
 
-	exeCtx, cancel := context.WithCancel(ctx)
-	defer cancel()
-	resp, err := s.node.Execute(exeCtx, cmd)
+// isDrainable tells whether a gRPC method is rejected when draining. Raft RPCs are still served so that leadership
+// can be transferred, and health checks so that they report NOT_SERVING
+func isDrainable(method string) bool {
+	return isClientMethod(method) && !strings.HasPrefix(method, "/grpc.health.v1.Health/")
+}
 
-	if err != nil {
-		return nil, err
+// begin tracks a request until done is called. Client requests are rejected with Unavailable once draining,
+// so that clients retry on other nodes
+func (s *rkvRPCServer) begin(method string) (done func(), err error) {
+	if !isDrainable(method) {
+		return func() {}, nil
 	}
 
-	return fromRaftDeleteReply(resp), nil
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.draining {
+		return nil, status.Error(codes.Unavailable, errorShuttingDown.Error())
+	}
+	s.inflight.Add(1)
+	return s.inflight.Done, nil
 }
 
-// Get implements pb.KVStoreRaftRPCServer.Get
-func (s *rkvRPCServer) Get(ctx context.Context, req *pb.GetRequest) (*pb.GetReply, error) {
-	gr := toRaftGetRequest(req)
-	resp, err := s.node.Get(ctx, gr)
-
+// drainUnaryInterceptor tracks unary requests, see begin
+func (s *rkvRPCServer) drainUnaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	done, err := s.begin(info.FullMethod)
 	if err != nil {
 		return nil, err
 	}
+	defer done()
+	return handler(ctx, req)
+}
 
-	return fromRaftGetReply(resp), nil
+// drainStreamInterceptor tracks streaming requests, see begin
+func (s *rkvRPCServer) drainStreamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	done, err := s.begin(info.FullMethod)
+	if err != nil {
+		return err
+	}
+	defer done()
+	return handler(srv, ss)
 }
 
-// Start starts the grpc server on a different go routine
-func (s *rkvRPCServer) Start(port string) {
-	var opts []grpc.ServerOption
-	s.server = grpc.NewServer(opts...)
-	pb.RegisterKVStoreRaftServer(s.server, s)
+// drain stops accepting client requests and waits for the ones in flight until ctx is done.
+// Readiness is reported NOT_SERVING. Watches and other long lived client streams end with errorShuttingDown,
+// so that clients resume them on other nodes instead of holding draining
+func (s *rkvRPCServer) drain(ctx context.Context) error {
+	s.health.shutdown()
 
-	s.wg.Add(1)
+	s.mu.Lock()
+	s.draining = true
+	s.mu.Unlock()
+	s.v2.watches.close(errorShuttingDown)
+	s.v2.closeStreams()
+
+	done := make(chan struct{})
 	go func() {
-		if lis, err := net.Listen("tcp", ":"+port); err != nil {
-			util.Fatalf("Cannot listen on port %s. Error:%s", port, err)
-		} else if err := s.server.Serve(lis); err != nil {
-			util.Fatalf("Failed to server %ss", err)
-		}
+		s.inflight.Wait()
+		close(done)
+	}()
 
-		s.wg.Done()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// gracefulStop stops the rpc server after RPCs in flight finish, or right away once ctx is done
+func (s *rkvRPCServer) gracefulStop(ctx context.Context) {
+	s.health.shutdown()
+
+	done := make(chan struct{})
+	go func() {
+		s.server.GracefulStop()
+		close(done)
 	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		s.server.Stop()
+		<-done
+	}
+	s.wg.Wait()
 }
 
 // Stop stops the rpc server
 func (s *rkvRPCServer) Stop() {
+	s.health.shutdown()
 	s.server.Stop()
 	s.wg.Wait()
 }
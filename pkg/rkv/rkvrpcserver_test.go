@@ -0,0 +1,208 @@
+package rkv
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/sidecus/raft/pkg/raft"
+	"github.com/sidecus/raft/pkg/rkv/pb"
+	"github.com/sidecus/raft/pkg/rkv/pbv2"
+)
+
+func newTestRPCServer(node raft.INode, disableProxy bool) *rkvRPCServer {
+	guard := newTestGuard(node, disableProxy)
+	return &rkvRPCServer{node: node, guard: guard}
+}
+
+func TestStatusErrors(t *testing.T) {
+	node := &fakeNode{leader: 1, store: newRKVStore()}
+	s := newTestRPCServer(node, false)
+
+	_, err := s.Set(context.Background(), &pb.SetRequest{Key: "a", Value: "1"})
+	if status.Code(err) != codes.DeadlineExceeded {
+		t.Error("Uncommitted write should return DeadlineExceeded")
+	}
+
+	_, err = s.Get(context.Background(), &pb.GetRequest{Key: "a"})
+	if status.Code(err) != codes.NotFound {
+		t.Error("Get on non existent key should return NotFound")
+	}
+
+	node.err = raft.ErrorNoLongerLeader
+	_, err = s.Delete(context.Background(), &pb.DeleteRequest{Key: "a"})
+	hint := LeaderHintFromError(err)
+	if status.Code(err) != codes.FailedPrecondition || hint.GetLeaderID() != 1 || hint.GetEndpoint() != "node1" {
+		t.Error("Losing leadership should return FailedPrecondition with leader hint")
+	}
+
+	node.err = raft.ErrorNoLeaderAvailable
+	node.leader = -1
+	_, err = s.Get(context.Background(), &pb.GetRequest{Key: "a"})
+	if status.Code(err) != codes.Unavailable || LeaderHintFromError(err) != nil {
+		t.Error("No leader should return Unavailable without leader hint")
+	}
+
+	// status errors proxied from the leader are returned as is
+	node.err = status.Error(codes.Aborted, "proxied")
+	_, err = s.Get(context.Background(), &pb.GetRequest{Key: "a"})
+	if status.Code(err) != codes.Aborted {
+		t.Error("Status errors should be returned as is")
+	}
+}
+
+func TestDisableProxy(t *testing.T) {
+	node := &fakeNode{leader: 1, store: newRKVStore(), success: true}
+	s := newTestRPCServer(node, true)
+
+	_, err := s.Set(context.Background(), &pb.SetRequest{Key: "a", Value: "1"})
+	if hint := LeaderHintFromError(err); hint.GetLeaderID() != 1 || hint.GetEndpoint() != "node1" {
+		t.Error("Follower should return leader hint when proxying is disabled")
+	}
+	if _, err := node.store.Get("a"); err == nil {
+		t.Error("Follower should not execute the request when proxying is disabled")
+	}
+
+	node.leader = 0
+	if _, err = s.Set(context.Background(), &pb.SetRequest{Key: "a", Value: "1"}); err != nil {
+		t.Error("Leader should execute the request when proxying is disabled")
+	}
+	if reply, err := s.Get(context.Background(), &pb.GetRequest{Key: "a"}); err != nil || reply.Value != "1" {
+		t.Error("Leader should serve reads when proxying is disabled")
+	}
+}
+
+func TestIsClientMethod(t *testing.T) {
+	if !isClientMethod("/rkv.v2.KVStore/Set") || !isClientMethod("/pb.KVStoreRaft/Get") {
+		t.Error("KV store methods should start new traces")
+	}
+	if isClientMethod("/raft.RaftTransport/AppendEntries") || isClientMethod("/raft.RaftTransport/Execute") {
+		t.Error("Raft transport methods should only continue traces")
+	}
+}
+
+func TestDrain(t *testing.T) {
+	node := &fakeNode{leader: 0, store: newRKVStore()}
+	var wg sync.WaitGroup
+	s := newRKVRPCServer(node, nil, rkvCodec, node.store.watches, nil, Options{}, &wg)
+	w, _ := node.store.watches.watch("a", false, 0)
+
+	// a request in flight holds draining until it finishes
+	release := make(chan struct{})
+	started := make(chan struct{})
+	go s.drainUnaryInterceptor(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: "/rkv.v2.KVStore/Get"}, func(ctx context.Context, req interface{}) (interface{}, error) {
+		close(started)
+		<-release
+		return nil, nil
+	})
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := s.drain(ctx); err != context.DeadlineExceeded {
+		t.Error("drain should wait for requests in flight until ctx is done")
+	}
+	if _, ok := <-w.events; ok || w.err != errorShuttingDown {
+		t.Error("Watches should end when draining")
+	}
+
+	// new client requests are rejected, raft RPCs and health checks are still served
+	call := func(method string) error {
+		_, err := s.drainUnaryInterceptor(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: method}, func(ctx context.Context, req interface{}) (interface{}, error) {
+			return nil, nil
+		})
+		return err
+	}
+	if status.Code(call("/rkv.v2.KVStore/Set")) != codes.Unavailable {
+		t.Error("Client requests should be rejected with Unavailable when draining")
+	}
+	if call("/raft.RaftTransport/AppendEntries") != nil || call("/grpc.health.v1.Health/Check") != nil {
+		t.Error("Raft RPCs and health checks should be served when draining")
+	}
+
+	close(release)
+	if err := s.drain(context.Background()); err != nil {
+		t.Error("drain should return once requests in flight finish")
+	}
+}
+
+func TestShutdown(t *testing.T) {
+	node := &fakeNode{leader: 0, store: newRKVStore()}
+	var wg sync.WaitGroup
+	s := newRKVRPCServer(node, nil, rkvCodec, node.store.watches, nil, Options{}, &wg)
+	s.Start("0")
+	expirer := newExpirer(node, node.store)
+	expirer.start()
+	compactor := newCompactor(node, node.store, DefaultHistoryRetention)
+	compactor.start()
+
+	done := make(chan struct{})
+	go func() {
+		shutdown(node, s, expirer, compactor, node.store, nil, time.Second)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("shutdown should finish")
+	}
+
+	// leadership is transferred before the node is stopped
+	if len(node.calls) != 2 || node.calls[0] != "transfer" || node.calls[1] != "stop" {
+		t.Error("shutdown should transfer leadership and then stop the node", node.calls)
+	}
+}
+
+func TestShutdownWithKeepAlive(t *testing.T) {
+	port := freePort(t)
+	node := &fakeNode{leader: 0, store: newRKVStore(), success: true}
+	var wg sync.WaitGroup
+	s := newRKVRPCServer(node, nil, rkvCodec, node.store.watches, nil, Options{}, &wg)
+	s.Start(port)
+	expirer := newExpirer(node, node.store)
+	expirer.start()
+
+	conn, err := grpc.Dial("127.0.0.1:"+port, grpc.WithInsecure(), grpc.WithBlock())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	client := pbv2.NewKVStoreClient(conn)
+	grant, err := client.LeaseGrant(context.Background(), &pbv2.LeaseGrantRequest{Ttl: 60000})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// keep the stream open and idle after the first refresh, like a client waiting for the next one
+	stream, err := client.LeaseKeepAlive(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	stream.Send(&pbv2.LeaseKeepAliveRequest{Id: grant.Id})
+	if reply, err := stream.Recv(); err != nil || reply.Header.Error != nil {
+		t.Fatal("LeaseKeepAlive should refresh the lease", err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		shutdown(node, s, expirer, nil, node.store, nil, 2*time.Second)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("open keepalive streams should not hold shutdown until timeout")
+	}
+
+	if reply, err := stream.Recv(); err != nil || reply.Header.Error.GetCode() != pbv2.ErrorCode_NO_LEADER {
+		t.Error("keepalive streams should end with NO_LEADER when shutting down", err)
+	}
+	if node.leader != 1 {
+		t.Error("shutdown should transfer leadership with open keepalive streams", node.calls)
+	}
+}
@@ -0,0 +1,683 @@
+package rkv
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/sidecus/raft/pkg/raft"
+	"github.com/sidecus/raft/pkg/rkv/pbv2"
+)
+
+const defaultRangeLimit = 100
+const maxRangeLimit = 1000
+
+// rkvRPCServerV2 implements pbv2.KVStoreServer. It shares the node with the v1 server
+type rkvRPCServerV2 struct {
+	node    raft.INode
+	guard   *leaderGuard
+	watches *watchHub
+	pbv2.UnimplementedKVStoreServer
+
+	// closing is closed when the server drains, to end streams waiting on clients, see closeStreams
+	closing   chan struct{}
+	closeOnce sync.Once
+}
+
+func newRKVRPCServerV2(node raft.INode, guard *leaderGuard, watches *watchHub) *rkvRPCServerV2 {
+	return &rkvRPCServerV2{
+		node:    node,
+		guard:   guard,
+		watches: watches,
+		closing: make(chan struct{}),
+	}
+}
+
+// closeStreams ends LeaseKeepAlive and BulkLoad streams with errorShuttingDown, so that they don't hold draining.
+// Export streams end at the next page
+func (s *rkvRPCServerV2) closeStreams() {
+	s.closeOnce.Do(func() { close(s.closing) })
+}
+
+// recvResult is a request received from a client stream, or the error which ended it
+type recvResult struct {
+	req interface{}
+	err error
+}
+
+// recvAll receives requests from a client stream in the background until it fails, so that the handler can stop waiting
+// for requests when the server drains. recv is the stream's Recv, and ctx the stream's context
+func recvAll(ctx context.Context, recv func() (interface{}, error)) <-chan recvResult {
+	results := make(chan recvResult)
+	go func() {
+		for {
+			req, err := recv()
+			select {
+			case results <- recvResult{req: req, err: err}:
+			case <-ctx.Done():
+				return
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+	return results
+}
+
+// Set implements pbv2.KVStoreServer.Set
+func (s *rkvRPCServerV2) Set(ctx context.Context, req *pbv2.SetRequest) (*pbv2.SetReply, error) {
+	if req.Key == "" {
+		return &pbv2.SetReply{Header: s.newResponseHeader(req.Header, errorEmptyKey)}, nil
+	}
+	if req.Ttl < 0 {
+		return &pbv2.SetReply{Header: s.newResponseHeader(req.Header, errorInvalidTTL)}, nil
+	}
+
+	data := KVCmdData{Key: req.Key, Value: req.Value, Lease: req.Lease}
+	if req.Ttl > 0 {
+		// expiry time is decided here so that all replicas agree on it
+		data.ExpireAt = time.Now().Add(time.Duration(req.Ttl) * time.Millisecond).UnixNano()
+	}
+	cmd := &raft.StateMachineCmd{CmdType: KVCmdSet, Data: data}
+	resp, err := s.execute(ctx, cmd)
+	result, _ := resp.(KVCmdResult)
+	if err == nil && !result.Succeeded {
+		// unconditional set only fails when the lease doesn't exist
+		err = errorLeaseNotFound
+	}
+	return &pbv2.SetReply{Header: s.newResponseHeader(req.Header, err), Revision: result.Revision}, nil
+}
+
+// Delete implements pbv2.KVStoreServer.Delete
+func (s *rkvRPCServerV2) Delete(ctx context.Context, req *pbv2.DeleteRequest) (*pbv2.DeleteReply, error) {
+	if req.Key == "" {
+		return &pbv2.DeleteReply{Header: s.newResponseHeader(req.Header, errorEmptyKey)}, nil
+	}
+
+	cmd := &raft.StateMachineCmd{CmdType: KVCmdDel, Data: KVCmdData{Key: req.Key}}
+	_, err := s.execute(ctx, cmd)
+	return &pbv2.DeleteReply{Header: s.newResponseHeader(req.Header, err)}, nil
+}
+
+// Get implements pbv2.KVStoreServer.Get
+func (s *rkvRPCServerV2) Get(ctx context.Context, req *pbv2.GetRequest) (*pbv2.GetReply, error) {
+	if req.Key == "" {
+		return &pbv2.GetReply{Header: s.newResponseHeader(req.Header, errorEmptyKey)}, nil
+	}
+	if req.Revision < 0 {
+		return &pbv2.GetReply{Header: s.newResponseHeader(req.Header, errorInvalidRevision)}, nil
+	}
+
+	var param interface{} = req.Key
+	if req.Revision != 0 {
+		param = KVGetAt{Key: req.Key, Revision: req.Revision}
+	}
+
+	reply := &pbv2.GetReply{}
+	data, err := s.read(ctx, param, req.AllowStale)
+	if err == nil {
+		entry := data.(KVEntry)
+		reply.Value, reply.Revision, reply.Lease = entry.Value, entry.Revision, entry.Lease
+	}
+
+	reply.Header = s.newResponseHeader(req.Header, err)
+	return reply, nil
+}
+
+// Range implements pbv2.KVStoreServer.Range
+func (s *rkvRPCServerV2) Range(ctx context.Context, req *pbv2.RangeRequest) (*pbv2.RangeReply, error) {
+	r := KVRange{Start: req.Start, End: req.End, Revision: req.Revision}
+	return s.scan(ctx, req.Header, r, req.Limit, req.PageToken, req.AllowStale), nil
+}
+
+// Prefix implements pbv2.KVStoreServer.Prefix
+func (s *rkvRPCServerV2) Prefix(ctx context.Context, req *pbv2.PrefixRequest) (*pbv2.RangeReply, error) {
+	r := prefixRange(req.Prefix, 0)
+	r.Revision = req.Revision
+	return s.scan(ctx, req.Header, r, req.Limit, req.PageToken, req.AllowStale), nil
+}
+
+// scan reads one page of the range. Page tokens are the encoded first key of the next page
+func (s *rkvRPCServerV2) scan(ctx context.Context, header *pbv2.RequestHeader, r KVRange, limit int64, pageToken string, allowStale bool) *pbv2.RangeReply {
+	switch {
+	case r.Revision < 0:
+		return &pbv2.RangeReply{Header: s.newResponseHeader(header, errorInvalidRevision)}
+	case limit < 0:
+		return &pbv2.RangeReply{Header: s.newResponseHeader(header, errorInvalidLimit)}
+	case limit == 0:
+		limit = defaultRangeLimit
+	case limit > maxRangeLimit:
+		limit = maxRangeLimit
+	}
+	r.Limit = int(limit)
+
+	if pageToken != "" {
+		next, err := base64.RawURLEncoding.DecodeString(pageToken)
+		if err != nil || string(next) < r.Start || (r.End != "" && string(next) >= r.End) {
+			return &pbv2.RangeReply{Header: s.newResponseHeader(header, errorInvalidPageToken)}
+		}
+		r.Start = string(next)
+	}
+
+	reply := &pbv2.RangeReply{}
+	data, err := s.read(ctx, r, allowStale)
+	if err == nil {
+		result := data.(KVRangeResult)
+		reply.Revision, reply.Kvs = result.Revision, toV2KeyValues(result.Entries)
+		if result.NextKey != "" {
+			reply.NextPageToken = base64.RawURLEncoding.EncodeToString([]byte(result.NextKey))
+		}
+	}
+
+	reply.Header = s.newResponseHeader(header, err)
+	return reply
+}
+
+// toV2KeyValues converts range entries to v2 key values
+func toV2KeyValues(entries []KVKeyEntry) []*pbv2.KeyValue {
+	var kvs []*pbv2.KeyValue
+	for _, e := range entries {
+		kvs = append(kvs, &pbv2.KeyValue{Key: e.Key, Value: e.Value, Revision: e.Revision, Lease: e.Lease})
+	}
+	return kvs
+}
+
+// Export implements pbv2.KVStoreServer.Export. Keys are read one page at a time at the revision of the first page,
+// which gives a consistent view of the keyspace without holding the store lock for the whole export
+func (s *rkvRPCServerV2) Export(req *pbv2.ExportRequest, stream pbv2.KVStore_ExportServer) error {
+	if req.Revision < 0 {
+		return stream.Send(&pbv2.ExportReply{Header: s.newResponseHeader(req.Header, errorInvalidRevision)})
+	}
+
+	r := prefixRange(req.Prefix, maxRangeLimit)
+	r.Revision = req.Revision
+	for {
+		select {
+		case <-s.closing:
+			return stream.Send(&pbv2.ExportReply{Header: s.newResponseHeader(req.Header, errorShuttingDown)})
+		default:
+		}
+
+		data, err := s.read(stream.Context(), r, req.AllowStale)
+		if err != nil {
+			return stream.Send(&pbv2.ExportReply{Header: s.newResponseHeader(req.Header, err)})
+		}
+
+		result := data.(KVRangeResult)
+		reply := &pbv2.ExportReply{Header: s.newResponseHeader(req.Header, nil), Kvs: toV2KeyValues(result.Entries), Revision: result.Revision}
+		if err = stream.Send(reply); err != nil {
+			return err
+		}
+		if result.NextKey == "" {
+			return nil
+		}
+		r.Start, r.Revision = result.NextKey, result.Revision
+	}
+}
+
+// History implements pbv2.KVStoreServer.History
+func (s *rkvRPCServerV2) History(ctx context.Context, req *pbv2.HistoryRequest) (*pbv2.HistoryReply, error) {
+	if req.Key == "" {
+		return &pbv2.HistoryReply{Header: s.newResponseHeader(req.Header, errorEmptyKey)}, nil
+	}
+
+	reply := &pbv2.HistoryReply{}
+	data, err := s.read(ctx, KVHistory{Key: req.Key}, req.AllowStale)
+	if err == nil {
+		result := data.(KVHistoryResult)
+		reply.Events, reply.CompactRevision = toV2Events(result.Versions), result.CompactRevision
+	}
+
+	reply.Header = s.newResponseHeader(req.Header, err)
+	return reply, nil
+}
+
+// read runs the query through the node. Stale reads are served locally by any node
+func (s *rkvRPCServerV2) read(ctx context.Context, param interface{}, allowStale bool) (interface{}, error) {
+	if !allowStale {
+		if err := s.guard.check(); err != nil {
+			return nil, err
+		}
+	}
+
+	resp, err := s.node.Get(ctx, &raft.GetRequest{Params: []interface{}{param}, AllowStale: allowStale})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Data, nil
+}
+
+// CompareAndSwap implements pbv2.KVStoreServer.CompareAndSwap
+func (s *rkvRPCServerV2) CompareAndSwap(ctx context.Context, req *pbv2.CompareAndSwapRequest) (*pbv2.CompareAndSwapReply, error) {
+	cmd, err := toCASCmd(req)
+	if err != nil {
+		return &pbv2.CompareAndSwapReply{Header: s.newResponseHeader(req.Header, err)}, nil
+	}
+
+	resp, err := s.execute(ctx, cmd)
+	result, _ := resp.(KVCmdResult)
+	return &pbv2.CompareAndSwapReply{
+		Header:    s.newResponseHeader(req.Header, err),
+		Succeeded: result.Succeeded,
+		Revision:  result.Revision,
+		Value:     result.Value,
+	}, nil
+}
+
+// toCASCmd converts a CompareAndSwap request to the conditional write cmd
+func toCASCmd(req *pbv2.CompareAndSwapRequest) (*raft.StateMachineCmd, error) {
+	if req.Key == "" {
+		return nil, errorEmptyKey
+	}
+
+	data := KVCmdData{Key: req.Key, Value: req.Value}
+	cmdType := 0
+	switch req.Condition {
+	case pbv2.Condition_VALUE:
+		cmdType, data.PrevValue = KVCmdCAS, req.PrevValue
+	case pbv2.Condition_REVISION:
+		if req.PrevRevision <= 0 {
+			return nil, errorInvalidRevision
+		}
+		cmdType, data.PrevRevision = KVCmdCAS, req.PrevRevision
+		if req.Delete {
+			cmdType, data.Value = KVCmdDelIfRevision, ""
+		}
+	case pbv2.Condition_ABSENT:
+		cmdType = KVCmdSetIfAbsent
+	}
+
+	if cmdType == 0 || (req.Delete && cmdType != KVCmdDelIfRevision) {
+		return nil, errorInvalidCondition
+	}
+
+	return &raft.StateMachineCmd{CmdType: cmdType, Data: data}, nil
+}
+
+// Txn implements pbv2.KVStoreServer.Txn
+func (s *rkvRPCServerV2) Txn(ctx context.Context, req *pbv2.TxnRequest) (*pbv2.TxnReply, error) {
+	txn, err := toKVTxn(req)
+	if err != nil {
+		return &pbv2.TxnReply{Header: s.newResponseHeader(req.Header, err)}, nil
+	}
+
+	resp, err := s.execute(ctx, &raft.StateMachineCmd{CmdType: KVCmdTxn, Data: txn})
+	result, _ := resp.(KVTxnResult)
+	reply := &pbv2.TxnReply{
+		Header:    s.newResponseHeader(req.Header, err),
+		Succeeded: result.Succeeded,
+	}
+	for _, r := range result.Results {
+		reply.Results = append(reply.Results, &pbv2.TxnOpResult{Found: r.Succeeded, Revision: r.Revision, Value: r.Value})
+	}
+	return reply, nil
+}
+
+// toKVTxn converts a Txn request to KVTxn, validating compares and ops
+func toKVTxn(req *pbv2.TxnRequest) (KVTxn, error) {
+	txn := KVTxn{}
+	for _, c := range req.Compares {
+		if c.Key == "" {
+			return KVTxn{}, errorEmptyKey
+		}
+
+		compare := KVCompare{Key: c.Key, Target: int(c.Target), Result: int(c.Result)}
+		switch c.Target {
+		case pbv2.Compare_VALUE:
+			compare.Value = c.Value
+		case pbv2.Compare_REVISION:
+			compare.Revision = c.Revision
+		default:
+			return KVTxn{}, errorInvalidTxn
+		}
+		if _, ok := pbv2.Compare_Result_name[int32(c.Result)]; !ok {
+			return KVTxn{}, errorInvalidTxn
+		}
+
+		txn.Compares = append(txn.Compares, compare)
+	}
+
+	var err error
+	if txn.Success, err = toKVTxnOps(req.Success); err != nil {
+		return KVTxn{}, err
+	}
+	if txn.Failure, err = toKVTxnOps(req.Failure); err != nil {
+		return KVTxn{}, err
+	}
+
+	return txn, nil
+}
+
+// toKVTxnOps converts txn ops to KVTxnOp
+func toKVTxnOps(ops []*pbv2.TxnOp) ([]KVTxnOp, error) {
+	var result []KVTxnOp
+	for _, op := range ops {
+		if op.Key == "" {
+			return nil, errorEmptyKey
+		}
+
+		kvOp := KVTxnOp{Key: op.Key}
+		switch op.Type {
+		case pbv2.TxnOp_GET:
+			kvOp.CmdType = KVTxnGet
+		case pbv2.TxnOp_SET:
+			kvOp.CmdType, kvOp.Value = KVCmdSet, op.Value
+		case pbv2.TxnOp_DELETE:
+			kvOp.CmdType = KVCmdDel
+		default:
+			return nil, errorInvalidTxn
+		}
+
+		result = append(result, kvOp)
+	}
+	return result, nil
+}
+
+// BatchSet implements pbv2.KVStoreServer.BatchSet
+func (s *rkvRPCServerV2) BatchSet(ctx context.Context, req *pbv2.BatchSetRequest) (*pbv2.BatchSetReply, error) {
+	result, err := s.batchSet(ctx, req)
+	return &pbv2.BatchSetReply{Header: s.newResponseHeader(req.Header, err), Revision: result.Revision}, nil
+}
+
+// BatchDelete implements pbv2.KVStoreServer.BatchDelete
+func (s *rkvRPCServerV2) BatchDelete(ctx context.Context, req *pbv2.BatchDeleteRequest) (*pbv2.BatchDeleteReply, error) {
+	if len(req.Keys) == 0 || len(req.Keys) > maxBatchOps {
+		return &pbv2.BatchDeleteReply{Header: s.newResponseHeader(req.Header, errorInvalidBatch)}, nil
+	}
+
+	batch := KVBatch{Ops: make([]KVBatchOp, len(req.Keys))}
+	for i, key := range req.Keys {
+		if key == "" {
+			return &pbv2.BatchDeleteReply{Header: s.newResponseHeader(req.Header, errorEmptyKey)}, nil
+		}
+		batch.Ops[i] = KVBatchOp{CmdType: KVCmdDel, KVCmdData: KVCmdData{Key: key}}
+	}
+
+	result, err := s.executeBatch(ctx, batch)
+	return &pbv2.BatchDeleteReply{Header: s.newResponseHeader(req.Header, err), Deleted: result.Deleted, Revision: result.Revision}, nil
+}
+
+// BulkLoad implements pbv2.KVStoreServer.BulkLoad. Requests are applied one by one as they arrive,
+// and the reply is sent when the client closes the stream or a request fails
+func (s *rkvRPCServerV2) BulkLoad(stream pbv2.KVStore_BulkLoadServer) error {
+	// the reply echoes the request ID of the first request
+	var header *pbv2.RequestHeader
+	reply := &pbv2.BulkLoadReply{}
+	requests := recvAll(stream.Context(), func() (interface{}, error) { return stream.Recv() })
+	for {
+		var r recvResult
+		select {
+		case <-s.closing:
+			// the count tells the client how many keys are loaded
+			reply.Header = s.newResponseHeader(header, errorShuttingDown)
+			return stream.SendAndClose(reply)
+		case r = <-requests:
+		}
+		if r.err == io.EOF {
+			reply.Header = s.newResponseHeader(header, nil)
+			return stream.SendAndClose(reply)
+		}
+		if r.err != nil {
+			return r.err
+		}
+		req := r.req.(*pbv2.BatchSetRequest)
+		if header == nil {
+			header = req.Header
+		}
+
+		result, err := s.batchSet(stream.Context(), req)
+		if err != nil {
+			reply.Header = s.newResponseHeader(header, err)
+			return stream.SendAndClose(reply)
+		}
+		reply.Count += int64(len(req.Kvs))
+		reply.Revision = result.Revision
+	}
+}
+
+// batchSet validates and applies a BatchSet request
+func (s *rkvRPCServerV2) batchSet(ctx context.Context, req *pbv2.BatchSetRequest) (KVBatchResult, error) {
+	if len(req.Kvs) == 0 || len(req.Kvs) > maxBatchOps {
+		return KVBatchResult{}, errorInvalidBatch
+	}
+	if req.Ttl < 0 {
+		return KVBatchResult{}, errorInvalidTTL
+	}
+
+	var expireAt int64
+	if req.Ttl > 0 {
+		expireAt = time.Now().Add(time.Duration(req.Ttl) * time.Millisecond).UnixNano()
+	}
+
+	batch := KVBatch{Ops: make([]KVBatchOp, len(req.Kvs))}
+	for i, kv := range req.Kvs {
+		if kv.Key == "" {
+			return KVBatchResult{}, errorEmptyKey
+		}
+		batch.Ops[i] = KVBatchOp{CmdType: KVCmdSet, KVCmdData: KVCmdData{Key: kv.Key, Value: kv.Value, ExpireAt: expireAt, Lease: kv.Lease}}
+	}
+
+	return s.executeBatch(ctx, batch)
+}
+
+// executeBatch runs a batch cmd
+func (s *rkvRPCServerV2) executeBatch(ctx context.Context, batch KVBatch) (KVBatchResult, error) {
+	resp, err := s.execute(ctx, &raft.StateMachineCmd{CmdType: KVCmdBatch, Data: batch})
+	result, _ := resp.(KVBatchResult)
+	if err == nil && !result.Succeeded {
+		// batches only fail when a lease doesn't exist
+		err = errorLeaseNotFound
+	}
+	return result, err
+}
+
+// Compact implements pbv2.KVStoreServer.Compact
+func (s *rkvRPCServerV2) Compact(ctx context.Context, req *pbv2.CompactRequest) (*pbv2.CompactReply, error) {
+	if req.Revision <= 0 {
+		return &pbv2.CompactReply{Header: s.newResponseHeader(req.Header, errorInvalidRevision)}, nil
+	}
+
+	resp, err := s.execute(ctx, &raft.StateMachineCmd{CmdType: KVCmdCompact, Data: KVCompactCmdData{Revision: req.Revision}})
+	result, _ := resp.(KVCmdResult)
+	if err == nil && !result.Succeeded {
+		// compaction fails when the revision is already compacted or newer than the store revision
+		if req.Revision <= result.Revision {
+			err = errorCompacted
+		} else {
+			err = errorFutureRevision
+		}
+	}
+	return &pbv2.CompactReply{Header: s.newResponseHeader(req.Header, err), CompactRevision: result.Revision}, nil
+}
+
+// LeaseGrant implements pbv2.KVStoreServer.LeaseGrant
+func (s *rkvRPCServerV2) LeaseGrant(ctx context.Context, req *pbv2.LeaseGrantRequest) (*pbv2.LeaseGrantReply, error) {
+	if req.Ttl <= 0 {
+		return &pbv2.LeaseGrantReply{Header: s.newResponseHeader(req.Header, errorInvalidLeaseTTL)}, nil
+	}
+
+	data := KVLeaseCmdData{TTL: req.Ttl, Time: time.Now().UnixNano()}
+	resp, err := s.execute(ctx, &raft.StateMachineCmd{CmdType: KVCmdLeaseGrant, Data: data})
+	result, _ := resp.(KVLeaseResult)
+	return &pbv2.LeaseGrantReply{Header: s.newResponseHeader(req.Header, err), Id: result.ID, Ttl: result.TTL}, nil
+}
+
+// LeaseRevoke implements pbv2.KVStoreServer.LeaseRevoke
+func (s *rkvRPCServerV2) LeaseRevoke(ctx context.Context, req *pbv2.LeaseRevokeRequest) (*pbv2.LeaseRevokeReply, error) {
+	_, err := s.executeLease(ctx, KVCmdLeaseRevoke, KVLeaseCmdData{ID: req.Id})
+	return &pbv2.LeaseRevokeReply{Header: s.newResponseHeader(req.Header, err)}, nil
+}
+
+// LeaseKeepAlive implements pbv2.KVStoreServer.LeaseKeepAlive. Each request refreshes the lease through the log.
+// The stream ends with errorShuttingDown when the server drains, so that the client moves to the next leader
+func (s *rkvRPCServerV2) LeaseKeepAlive(stream pbv2.KVStore_LeaseKeepAliveServer) error {
+	requests := recvAll(stream.Context(), func() (interface{}, error) { return stream.Recv() })
+	for {
+		var r recvResult
+		select {
+		case <-s.closing:
+			return stream.Send(&pbv2.LeaseKeepAliveReply{Header: s.newResponseHeader(nil, errorShuttingDown)})
+		case r = <-requests:
+		}
+		if r.err == io.EOF {
+			return nil
+		}
+		if r.err != nil {
+			return r.err
+		}
+		req := r.req.(*pbv2.LeaseKeepAliveRequest)
+
+		data := KVLeaseCmdData{ID: req.Id, Time: time.Now().UnixNano()}
+		result, err := s.executeLease(stream.Context(), KVCmdLeaseKeepAlive, data)
+		reply := &pbv2.LeaseKeepAliveReply{Header: s.newResponseHeader(req.Header, err), Id: req.Id, Ttl: result.TTL}
+		if err = stream.Send(reply); err != nil {
+			return err
+		}
+	}
+}
+
+// Watch implements pbv2.KVStoreServer.Watch
+func (s *rkvRPCServerV2) Watch(req *pbv2.WatchRequest, stream pbv2.KVStore_WatchServer) error {
+	if req.Key == "" && !req.Prefix {
+		return stream.Send(&pbv2.WatchReply{Header: s.newResponseHeader(req.Header, errorEmptyKey)})
+	}
+
+	w, err := s.watches.watch(req.Key, req.Prefix, req.StartRevision)
+	if err != nil {
+		return stream.Send(s.newWatchErrorReply(req.Header, err))
+	}
+	defer s.watches.cancel(w)
+
+	if err = stream.Send(&pbv2.WatchReply{Header: s.newResponseHeader(req.Header, nil), StartRevision: w.start}); err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case events, ok := <-w.events:
+			if !ok {
+				return stream.Send(s.newWatchErrorReply(req.Header, w.err))
+			}
+			if err = stream.Send(&pbv2.WatchReply{Header: s.newResponseHeader(req.Header, nil), Events: toV2Events(events)}); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// newWatchErrorReply creates the last reply of a failed watch
+func (s *rkvRPCServerV2) newWatchErrorReply(reqHeader *pbv2.RequestHeader, err error) *pbv2.WatchReply {
+	reply := &pbv2.WatchReply{Header: s.newResponseHeader(reqHeader, err)}
+	if errors.Is(err, errorCompacted) {
+		reply.CompactRevision = s.watches.compactRevision()
+	}
+	return reply
+}
+
+// toV2Events converts store events to v2 events
+func toV2Events(events []KVEvent) []*pbv2.Event {
+	result := make([]*pbv2.Event, len(events))
+	for i, e := range events {
+		result[i] = &pbv2.Event{Type: pbv2.Event_PUT, Key: e.Key, Value: e.Value, Revision: e.Revision}
+		if e.Type == KVEventDelete {
+			result[i].Type = pbv2.Event_DELETE
+		}
+	}
+	return result
+}
+
+// executeLease runs a lease cmd on an existing lease
+func (s *rkvRPCServerV2) executeLease(ctx context.Context, cmdType int, data KVLeaseCmdData) (KVLeaseResult, error) {
+	resp, err := s.execute(ctx, &raft.StateMachineCmd{CmdType: cmdType, Data: data})
+	result, _ := resp.(KVLeaseResult)
+	if err == nil && !result.Succeeded {
+		err = errorLeaseNotFound
+	}
+	return result, err
+}
+
+// execute runs the cmd and returns its result. An uncommitted write is turned into an error
+func (s *rkvRPCServerV2) execute(ctx context.Context, cmd *raft.StateMachineCmd) (interface{}, error) {
+	if err := s.guard.check(); err != nil {
+		return nil, err
+	}
+
+	resp, err := s.node.Execute(ctx, cmd)
+	if err != nil {
+		return nil, err
+	}
+	if !resp.Success {
+		return nil, errorNotCommitted
+	}
+
+	return resp.Result, nil
+}
+
+// newResponseHeader creates the reply header with the request ID, the error if any and the leader hint
+func (s *rkvRPCServerV2) newResponseHeader(reqHeader *pbv2.RequestHeader, err error) *pbv2.ResponseHeader {
+	header := &pbv2.ResponseHeader{
+		RequestID: reqHeader.GetRequestID(),
+		NodeID:    int64(s.node.NodeID()),
+		Error:     toV2Error(err),
+	}
+
+	if header.RequestID == "" {
+		header.RequestID = newRequestID()
+	}
+
+	if leader, endpoint := s.guard.leader(); leader != -1 {
+		// endpoint is left empty when we are the leader, clients are already talking to us
+		header.Leader = &pbv2.LeaderHint{
+			NodeID:   int64(leader),
+			Endpoint: endpoint,
+		}
+	}
+
+	return header
+}
+
+// toV2Error maps errors from the node to v2 error codes. Returns nil for nil error.
+// kv store errors proxied from the leader match like local ones, since rkvCodec carries them across nodes
+func toV2Error(err error) *pbv2.Error {
+	if err == nil {
+		return nil
+	}
+
+	code := pbv2.ErrorCode_UNKNOWN
+	switch {
+	case isInvalidArgument(err):
+		code = pbv2.ErrorCode_INVALID_ARGUMENT
+	case errors.Is(err, errorKeyNotFound):
+		code = pbv2.ErrorCode_KEY_NOT_FOUND
+	case errors.Is(err, errorLeaseNotFound):
+		code = pbv2.ErrorCode_LEASE_NOT_FOUND
+	case errors.Is(err, errorCompacted):
+		code = pbv2.ErrorCode_COMPACTED
+	case errors.Is(err, raft.ErrorNoLeaderAvailable), errors.Is(err, raft.ErrorNodeStopped), errors.Is(err, errorShuttingDown):
+		code = pbv2.ErrorCode_NO_LEADER
+	case errors.Is(err, errorNotLeader), errors.Is(err, raft.ErrorNoLongerLeader), errors.Is(err, raft.ErrorLeadershipNotConfirmed):
+		code = pbv2.ErrorCode_NOT_LEADER
+	case errors.Is(err, errorNotCommitted), errors.Is(err, context.DeadlineExceeded), status.Code(err) == codes.DeadlineExceeded:
+		code = pbv2.ErrorCode_TIMEOUT
+	}
+
+	return &pbv2.Error{Code: code, Message: err.Error()}
+}
+
+// newRequestID generates a random request ID
+func newRequestID() string {
+	var b [8]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
@@ -0,0 +1,652 @@
+package rkv
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+
+	"github.com/sidecus/raft/pkg/raft"
+	"github.com/sidecus/raft/pkg/rkv/pbv2"
+)
+
+// fakeNode serves reads from a store and fails requests with err when it's set
+type fakeNode struct {
+	leader  int
+	store   *rkvStore
+	err     error
+	success bool
+	status  raft.NodeStatus
+	calls   []string // lifecycle calls, e.g. stop
+}
+
+func (n *fakeNode) Start()                                               {}
+func (n *fakeNode) Stop()                                                { n.calls = append(n.calls, "stop") }
+func (n *fakeNode) NodeID() int                                          { return 0 }
+func (n *fakeNode) LeaderID() int                                        { return n.leader }
+func (n *fakeNode) Status() raft.NodeStatus                              { return n.status }
+func (n *fakeNode) OnSnapshotPart(part *raft.SnapshotRequestHeader) bool { return true }
+func (n *fakeNode) TransferLeadership(ctx context.Context) error {
+	n.calls = append(n.calls, "transfer")
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	n.leader = 1
+	return nil
+}
+func (n *fakeNode) AppendEntries(ctx context.Context, req *raft.AppendEntriesRequest) (*raft.AppendEntriesReply, error) {
+	return nil, nil
+}
+func (n *fakeNode) RequestVote(ctx context.Context, req *raft.RequestVoteRequest) (*raft.RequestVoteReply, error) {
+	return nil, nil
+}
+func (n *fakeNode) TimeoutNow(ctx context.Context, req *raft.TimeoutNowRequest) (*raft.TimeoutNowReply, error) {
+	return nil, nil
+}
+func (n *fakeNode) InstallSnapshot(ctx context.Context, req *raft.SnapshotRequest) (*raft.AppendEntriesReply, error) {
+	return nil, nil
+}
+func (n *fakeNode) Get(ctx context.Context, req *raft.GetRequest) (*raft.GetReply, error) {
+	if n.err != nil {
+		return nil, n.err
+	}
+	v, err := n.store.Get(req.Params...)
+	if err != nil {
+		return nil, err
+	}
+	return &raft.GetReply{NodeID: 0, Data: v}, nil
+}
+func (n *fakeNode) Execute(ctx context.Context, cmd *raft.StateMachineCmd) (*raft.ExecuteReply, error) {
+	if n.err != nil {
+		return nil, n.err
+	}
+	if !n.success {
+		return &raft.ExecuteReply{NodeID: 0, Success: false}, nil
+	}
+	return &raft.ExecuteReply{NodeID: 0, Success: true, Result: n.store.Apply(*cmd)}, nil
+}
+
+func newTestGuard(node raft.INode, disableProxy bool) *leaderGuard {
+	peers := map[int]raft.NodeInfo{1: {NodeID: 1, Endpoint: "node1"}}
+	return &leaderGuard{node: node, peers: peers, disableProxy: disableProxy}
+}
+
+func TestV2SetGet(t *testing.T) {
+	node := &fakeNode{leader: 0, store: newRKVStore(), success: true}
+	s := newRKVRPCServerV2(node, newTestGuard(node, false), node.store.watches)
+
+	setReply, _ := s.Set(context.Background(), &pbv2.SetRequest{Header: &pbv2.RequestHeader{RequestID: "req1"}, Key: "a", Value: "1"})
+	if setReply.Header.Error != nil || setReply.Header.RequestID != "req1" {
+		t.Error("Set should succeed and echo the request ID")
+	}
+	if setReply.Header.Leader.GetNodeID() != 0 || setReply.Header.Leader.GetEndpoint() != "" {
+		t.Error("Set should return self as leader hint without endpoint")
+	}
+
+	getReply, _ := s.Get(context.Background(), &pbv2.GetRequest{Key: "a"})
+	if getReply.Header.Error != nil || getReply.Value != "1" || getReply.Revision != setReply.Revision || getReply.Revision == 0 {
+		t.Error("Get should return the value and revision set")
+	}
+	if getReply.Header.RequestID == "" {
+		t.Error("Request ID should be generated when it's missing")
+	}
+
+	delReply, _ := s.Delete(context.Background(), &pbv2.DeleteRequest{Key: "a"})
+	if delReply.Header.Error != nil {
+		t.Error("Delete should succeed")
+	}
+
+	getReply, _ = s.Get(context.Background(), &pbv2.GetRequest{Key: "a"})
+	if getReply.Header.Error.GetCode() != pbv2.ErrorCode_KEY_NOT_FOUND {
+		t.Error("Get should return KEY_NOT_FOUND after delete")
+	}
+}
+
+func TestV2Errors(t *testing.T) {
+	node := &fakeNode{leader: 1, store: newRKVStore()}
+	s := newRKVRPCServerV2(node, newTestGuard(node, false), node.store.watches)
+
+	reply, _ := s.Set(context.Background(), &pbv2.SetRequest{Key: "a", Value: "1"})
+	if reply.Header.Error.GetCode() != pbv2.ErrorCode_TIMEOUT {
+		t.Error("Uncommitted write should return TIMEOUT")
+	}
+	if reply.Header.Leader.GetNodeID() != 1 || reply.Header.Leader.GetEndpoint() != "node1" {
+		t.Error("Reply should carry the leader hint")
+	}
+
+	reply, _ = s.Set(context.Background(), &pbv2.SetRequest{Value: "1"})
+	if reply.Header.Error.GetCode() != pbv2.ErrorCode_INVALID_ARGUMENT {
+		t.Error("Empty key should return INVALID_ARGUMENT")
+	}
+
+	codes := map[error]pbv2.ErrorCode{
+		raft.ErrorNoLeaderAvailable:                               pbv2.ErrorCode_NO_LEADER,
+		raft.ErrorNoLongerLeader:                                  pbv2.ErrorCode_NOT_LEADER,
+		fmt.Errorf("proxy: %w", raft.ErrorLeadershipNotConfirmed): pbv2.ErrorCode_NOT_LEADER,
+		context.DeadlineExceeded:                                  pbv2.ErrorCode_TIMEOUT,
+		fmt.Errorf("something else"):                              pbv2.ErrorCode_UNKNOWN,
+	}
+	for err, code := range codes {
+		node.err = err
+		node.leader = -1
+		reply, _ := s.Get(context.Background(), &pbv2.GetRequest{Key: "a"})
+		if reply.Header.Error.GetCode() != code || reply.Header.Error.GetMessage() != err.Error() {
+			t.Errorf("Error %q should map to %s", err, code)
+		}
+		if reply.Header.Leader != nil {
+			t.Error("Reply should not carry leader hint when there is no leader")
+		}
+	}
+}
+
+func TestV2DisableProxy(t *testing.T) {
+	node := &fakeNode{leader: 1, store: newRKVStore(), success: true}
+	s := newRKVRPCServerV2(node, newTestGuard(node, true), node.store.watches)
+
+	reply, _ := s.Set(context.Background(), &pbv2.SetRequest{Key: "a", Value: "1"})
+	if reply.Header.Error.GetCode() != pbv2.ErrorCode_NOT_LEADER || reply.Header.Leader.GetEndpoint() != "node1" {
+		t.Error("Follower should return NOT_LEADER with leader hint when proxying is disabled")
+	}
+	if _, err := node.store.Get("a"); err == nil {
+		t.Error("Follower should not execute the request when proxying is disabled")
+	}
+
+	node.leader = -1
+	getReply, _ := s.Get(context.Background(), &pbv2.GetRequest{Key: "a"})
+	if getReply.Header.Error.GetCode() != pbv2.ErrorCode_NO_LEADER {
+		t.Error("Follower should return NO_LEADER when there is no leader")
+	}
+}
+
+func TestV2CompareAndSwap(t *testing.T) {
+	node := &fakeNode{leader: 0, store: newRKVStore(), success: true}
+	s := newRKVRPCServerV2(node, newTestGuard(node, false), node.store.watches)
+	ctx := context.Background()
+
+	reply, _ := s.CompareAndSwap(ctx, &pbv2.CompareAndSwapRequest{Key: "a", Value: "1", Condition: pbv2.Condition_ABSENT})
+	if reply.Header.Error != nil || !reply.Succeeded || reply.Revision != 1 {
+		t.Fatal("SetIfAbsent should succeed on non existent key")
+	}
+
+	reply, _ = s.CompareAndSwap(ctx, &pbv2.CompareAndSwapRequest{Key: "a", Value: "2", Condition: pbv2.Condition_VALUE, PrevValue: "x"})
+	if reply.Header.Error != nil || reply.Succeeded || reply.Value != "1" {
+		t.Error("CAS should fail on value mismatch with current value returned")
+	}
+
+	reply, _ = s.CompareAndSwap(ctx, &pbv2.CompareAndSwapRequest{Key: "a", Value: "2", Condition: pbv2.Condition_REVISION, PrevRevision: 1})
+	if !reply.Succeeded || reply.Revision != 2 {
+		t.Error("CAS should succeed on revision match")
+	}
+
+	reply, _ = s.CompareAndSwap(ctx, &pbv2.CompareAndSwapRequest{Key: "a", Condition: pbv2.Condition_REVISION, PrevRevision: 2, Delete: true})
+	if !reply.Succeeded || reply.Revision != 0 {
+		t.Error("DeleteIfRevision should succeed on revision match")
+	}
+
+	invalid := []*pbv2.CompareAndSwapRequest{
+		{Value: "1"},
+		{Key: "a", Condition: pbv2.Condition_REVISION},
+		{Key: "a", Condition: pbv2.Condition_VALUE, Delete: true},
+		{Key: "a", Condition: pbv2.Condition(100)},
+	}
+	for _, req := range invalid {
+		reply, _ = s.CompareAndSwap(ctx, req)
+		if reply.Header.Error.GetCode() != pbv2.ErrorCode_INVALID_ARGUMENT {
+			t.Errorf("Invalid CAS request %v should return INVALID_ARGUMENT", req)
+		}
+	}
+}
+
+func TestV2Txn(t *testing.T) {
+	node := &fakeNode{leader: 0, store: newRKVStore(), success: true}
+	s := newRKVRPCServerV2(node, newTestGuard(node, false), node.store.watches)
+	ctx := context.Background()
+
+	s.Set(ctx, &pbv2.SetRequest{Key: "a", Value: "1"})
+	req := &pbv2.TxnRequest{
+		Compares: []*pbv2.Compare{{Key: "a", Target: pbv2.Compare_VALUE, Result: pbv2.Compare_EQUAL, Value: "1"}},
+		Success:  []*pbv2.TxnOp{{Type: pbv2.TxnOp_DELETE, Key: "a"}, {Type: pbv2.TxnOp_SET, Key: "b", Value: "1"}},
+		Failure:  []*pbv2.TxnOp{{Type: pbv2.TxnOp_GET, Key: "b"}},
+	}
+
+	reply, _ := s.Txn(ctx, req)
+	if reply.Header.Error != nil || !reply.Succeeded || len(reply.Results) != 2 || !reply.Results[0].Found || reply.Results[1].Revision != 2 {
+		t.Fatal("Txn should apply success ops")
+	}
+
+	reply, _ = s.Txn(ctx, req)
+	if reply.Header.Error != nil || reply.Succeeded || len(reply.Results) != 1 || reply.Results[0].Value != "1" {
+		t.Error("Txn should apply failure ops")
+	}
+
+	invalid := []*pbv2.TxnRequest{
+		{Compares: []*pbv2.Compare{{Target: pbv2.Compare_VALUE}}},
+		{Compares: []*pbv2.Compare{{Key: "a", Target: pbv2.Compare_Target(100)}}},
+		{Compares: []*pbv2.Compare{{Key: "a", Result: pbv2.Compare_Result(100)}}},
+		{Success: []*pbv2.TxnOp{{Type: pbv2.TxnOp_SET}}},
+		{Failure: []*pbv2.TxnOp{{Key: "a", Type: pbv2.TxnOp_Type(100)}}},
+	}
+	for _, req := range invalid {
+		reply, _ = s.Txn(ctx, req)
+		if reply.Header.Error.GetCode() != pbv2.ErrorCode_INVALID_ARGUMENT {
+			t.Errorf("Invalid txn %v should return INVALID_ARGUMENT", req)
+		}
+	}
+}
+
+func TestV2SetTTL(t *testing.T) {
+	node := &fakeNode{leader: 0, store: newRKVStore(), success: true}
+	s := newRKVRPCServerV2(node, newTestGuard(node, false), node.store.watches)
+	ctx := context.Background()
+
+	s.Set(ctx, &pbv2.SetRequest{Key: "a", Value: "1", Ttl: 1})
+	s.Set(ctx, &pbv2.SetRequest{Key: "b", Value: "1", Ttl: 60000})
+	time.Sleep(5 * time.Millisecond)
+
+	if reply, _ := s.Get(ctx, &pbv2.GetRequest{Key: "a"}); reply.Header.Error.GetCode() != pbv2.ErrorCode_KEY_NOT_FOUND {
+		t.Error("Get should not return expired key")
+	}
+	if reply, _ := s.Get(ctx, &pbv2.GetRequest{Key: "b"}); reply.Header.Error != nil {
+		t.Error("Get should return key not expired yet")
+	}
+	if reply, _ := s.Set(ctx, &pbv2.SetRequest{Key: "a", Value: "1", Ttl: -1}); reply.Header.Error.GetCode() != pbv2.ErrorCode_INVALID_ARGUMENT {
+		t.Error("Set should reject negative ttl")
+	}
+}
+
+// startTestV2Server serves the v2 API over gRPC for streaming tests
+func startTestV2Server(t *testing.T, node *fakeNode) (pbv2.KVStoreClient, func()) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := grpc.NewServer()
+	pbv2.RegisterKVStoreServer(server, newRKVRPCServerV2(node, newTestGuard(node, false), node.store.watches))
+	go server.Serve(lis)
+
+	conn, err := grpc.Dial(lis.Addr().String(), grpc.WithInsecure())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return pbv2.NewKVStoreClient(conn), func() {
+		conn.Close()
+		server.Stop()
+	}
+}
+
+func TestV2Lease(t *testing.T) {
+	node := &fakeNode{leader: 0, store: newRKVStore(), success: true}
+	client, stop := startTestV2Server(t, node)
+	defer stop()
+	ctx := context.Background()
+
+	if reply, _ := client.LeaseGrant(ctx, &pbv2.LeaseGrantRequest{}); reply.Header.Error.GetCode() != pbv2.ErrorCode_INVALID_ARGUMENT {
+		t.Error("LeaseGrant should reject non positive ttl")
+	}
+	grant, _ := client.LeaseGrant(ctx, &pbv2.LeaseGrantRequest{Ttl: 60000})
+	if grant.Header.Error != nil || grant.Id != 1 || grant.Ttl != 60000 {
+		t.Fatal("LeaseGrant should return the lease")
+	}
+
+	client.Set(ctx, &pbv2.SetRequest{Key: "a", Value: "1", Lease: grant.Id})
+	if reply, _ := client.Get(ctx, &pbv2.GetRequest{Key: "a"}); reply.Lease != grant.Id {
+		t.Error("Get should return the lease of the key")
+	}
+	if reply, _ := client.Set(ctx, &pbv2.SetRequest{Key: "b", Value: "1", Lease: 100}); reply.Header.Error.GetCode() != pbv2.ErrorCode_LEASE_NOT_FOUND {
+		t.Error("Set should fail on non existent lease")
+	}
+
+	stream, err := client.LeaseKeepAlive(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []int64{grant.Id, 100} {
+		stream.Send(&pbv2.LeaseKeepAliveRequest{Id: id})
+		reply, err := stream.Recv()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if (id == grant.Id) != (reply.Header.Error == nil) {
+			t.Errorf("KeepAlive on lease %d returns wrong error %v", id, reply.Header.Error)
+		}
+	}
+	stream.CloseSend()
+
+	if reply, _ := client.LeaseRevoke(ctx, &pbv2.LeaseRevokeRequest{Id: grant.Id}); reply.Header.Error != nil {
+		t.Error("LeaseRevoke should succeed")
+	}
+	if reply, _ := client.Get(ctx, &pbv2.GetRequest{Key: "a"}); reply.Header.Error.GetCode() != pbv2.ErrorCode_KEY_NOT_FOUND {
+		t.Error("LeaseRevoke should delete attached keys")
+	}
+	if reply, _ := client.LeaseRevoke(ctx, &pbv2.LeaseRevokeRequest{Id: grant.Id}); reply.Header.Error.GetCode() != pbv2.ErrorCode_LEASE_NOT_FOUND {
+		t.Error("LeaseRevoke should fail on revoked lease")
+	}
+}
+
+func TestV2Watch(t *testing.T) {
+	node := &fakeNode{leader: 0, store: newRKVStore(), success: true}
+	client, stop := startTestV2Server(t, node)
+	defer stop()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	client.Set(ctx, &pbv2.SetRequest{Key: "a/1", Value: "1"})
+
+	// starts from revision 1 so the first set is replayed from history
+	stream, err := client.Watch(ctx, &pbv2.WatchRequest{Key: "a/", Prefix: true, StartRevision: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reply, err := stream.Recv(); err != nil || reply.Header.Error != nil || reply.StartRevision != 1 {
+		t.Fatal("Watch should confirm creation")
+	}
+
+	client.Set(ctx, &pbv2.SetRequest{Key: "b", Value: "1"})
+	client.Delete(ctx, &pbv2.DeleteRequest{Key: "a/1"})
+
+	var events []*pbv2.Event
+	for len(events) < 2 {
+		reply, err := stream.Recv()
+		if err != nil {
+			t.Fatal(err)
+		}
+		events = append(events, reply.Events...)
+	}
+	if events[0].Type != pbv2.Event_PUT || events[0].Revision != 1 || events[1].Type != pbv2.Event_DELETE || events[1].Revision != 3 {
+		t.Errorf("Watch returns wrong events %v", events)
+	}
+
+	if stream, err = client.Watch(ctx, &pbv2.WatchRequest{Key: "a"}); err != nil {
+		t.Fatal(err)
+	}
+	if reply, _ := stream.Recv(); reply.Header.Error != nil || reply.StartRevision != 4 {
+		t.Error("Watch on key from now should start from next revision")
+	}
+	if stream, err = client.Watch(ctx, &pbv2.WatchRequest{}); err != nil {
+		t.Fatal(err)
+	}
+	if reply, _ := stream.Recv(); reply.Header.Error.GetCode() != pbv2.ErrorCode_INVALID_ARGUMENT {
+		t.Error("Watch should require key when not watching a prefix")
+	}
+}
+
+func TestV2WatchCompacted(t *testing.T) {
+	node := &fakeNode{leader: 0, store: newRKVStore(), success: true}
+	node.store.watches = newWatchHub(2)
+	client, stop := startTestV2Server(t, node)
+	defer stop()
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		client.Set(ctx, &pbv2.SetRequest{Key: "a", Value: fmt.Sprint(i)})
+	}
+
+	stream, err := client.Watch(ctx, &pbv2.WatchRequest{Key: "a", StartRevision: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	reply, err := stream.Recv()
+	if err != nil || reply.Header.Error.GetCode() != pbv2.ErrorCode_COMPACTED || reply.CompactRevision != 1 {
+		t.Fatal("Watch should return COMPACTED when revision is no longer in history")
+	}
+	if _, err = stream.Recv(); err != io.EOF {
+		t.Error("Watch stream should end after error")
+	}
+}
+
+func TestV2Range(t *testing.T) {
+	node := &fakeNode{leader: 0, store: newRKVStore(), success: true}
+	s := newRKVRPCServerV2(node, newTestGuard(node, false), node.store.watches)
+	ctx := context.Background()
+
+	for _, k := range []string{"t1/c", "t1/a", "t2/a", "t1/b", "t0"} {
+		s.Set(ctx, &pbv2.SetRequest{Key: k, Value: k})
+	}
+
+	var keys []string
+	token := ""
+	for pages := 0; ; pages++ {
+		reply, _ := s.Prefix(ctx, &pbv2.PrefixRequest{Prefix: "t1/", Limit: 2, PageToken: token})
+		if reply.Header.Error != nil || pages > 2 {
+			t.Fatal("Prefix should page through keys")
+		}
+		for _, kv := range reply.Kvs {
+			keys = append(keys, kv.Key)
+		}
+		if token = reply.NextPageToken; token == "" {
+			break
+		}
+	}
+	if fmt.Sprint(keys) != "[t1/a t1/b t1/c]" {
+		t.Errorf("Prefix returns wrong keys %v", keys)
+	}
+
+	reply, _ := s.Range(ctx, &pbv2.RangeRequest{Start: "t0", End: "t1/b"})
+	if len(reply.Kvs) != 2 || reply.Kvs[1].Key != "t1/a" || reply.Kvs[1].Value != "t1/a" || reply.NextPageToken != "" {
+		t.Error("Range should return keys in [start, end)")
+	}
+
+	invalid := []*pbv2.RangeRequest{
+		{Limit: -1},
+		{PageToken: "!"},
+		{Start: "b", PageToken: base64.RawURLEncoding.EncodeToString([]byte("a"))},
+	}
+	for _, req := range invalid {
+		if reply, _ = s.Range(ctx, req); reply.Header.Error.GetCode() != pbv2.ErrorCode_INVALID_ARGUMENT {
+			t.Errorf("Invalid range %v should return INVALID_ARGUMENT", req)
+		}
+	}
+}
+
+func TestV2MVCC(t *testing.T) {
+	node := &fakeNode{leader: 0, store: newRKVStore(), success: true}
+	s := newRKVRPCServerV2(node, newTestGuard(node, false), node.store.watches)
+	ctx := context.Background()
+
+	s.Set(ctx, &pbv2.SetRequest{Key: "a", Value: "1"}) // 1
+	s.Set(ctx, &pbv2.SetRequest{Key: "b", Value: "1"}) // 2
+	s.Set(ctx, &pbv2.SetRequest{Key: "a", Value: "2"}) // 3
+
+	if reply, _ := s.Get(ctx, &pbv2.GetRequest{Key: "a", Revision: 2}); reply.Header.Error != nil || reply.Value != "1" {
+		t.Error("Get at revision should return the old value")
+	}
+	reply, _ := s.Prefix(ctx, &pbv2.PrefixRequest{Prefix: "", Revision: 1})
+	if len(reply.Kvs) != 1 || reply.Kvs[0].Value != "1" || reply.Revision != 1 {
+		t.Error("Prefix at revision should return keys at that revision")
+	}
+	if reply, _ = s.Range(ctx, &pbv2.RangeRequest{}); len(reply.Kvs) != 2 || reply.Revision != 3 {
+		t.Error("Range should return the store revision")
+	}
+	if reply, _ := s.History(ctx, &pbv2.HistoryRequest{Key: "a"}); len(reply.Events) != 2 || reply.Events[0].Value != "1" {
+		t.Error("History should return all versions")
+	}
+
+	if reply, _ := s.Compact(ctx, &pbv2.CompactRequest{Revision: 4}); reply.Header.Error.GetCode() != pbv2.ErrorCode_INVALID_ARGUMENT {
+		t.Error("Compact after store revision should return INVALID_ARGUMENT")
+	}
+	if reply, _ := s.Compact(ctx, &pbv2.CompactRequest{Revision: 3}); reply.Header.Error != nil || reply.CompactRevision != 3 {
+		t.Fatal("Compact should succeed")
+	}
+	if reply, _ := s.Compact(ctx, &pbv2.CompactRequest{Revision: 2}); reply.Header.Error.GetCode() != pbv2.ErrorCode_COMPACTED {
+		t.Error("Compact at compacted revision should return COMPACTED")
+	}
+	if reply, _ := s.Get(ctx, &pbv2.GetRequest{Key: "a", Revision: 2}); reply.Header.Error.GetCode() != pbv2.ErrorCode_COMPACTED {
+		t.Error("Get at compacted revision should return COMPACTED")
+	}
+	if reply, _ := s.History(ctx, &pbv2.HistoryRequest{Key: "a"}); len(reply.Events) != 1 || reply.CompactRevision != 3 {
+		t.Error("History should only return retained versions")
+	}
+}
+
+func TestV2Batch(t *testing.T) {
+	node := &fakeNode{leader: 0, store: newRKVStore(), success: true}
+	s := newRKVRPCServerV2(node, newTestGuard(node, false), node.store.watches)
+	ctx := context.Background()
+
+	setReply, _ := s.BatchSet(ctx, &pbv2.BatchSetRequest{Kvs: []*pbv2.KeyValue{{Key: "a", Value: "1"}, {Key: "b", Value: "2"}}})
+	if setReply.Header.Error != nil || setReply.Revision != 1 {
+		t.Fatal("BatchSet should set all keys at one revision")
+	}
+	if reply, _ := s.BatchSet(ctx, &pbv2.BatchSetRequest{Kvs: []*pbv2.KeyValue{{Key: "c", Value: "3", Lease: 100}}}); reply.Header.Error.GetCode() != pbv2.ErrorCode_LEASE_NOT_FOUND {
+		t.Error("BatchSet should fail on non existent lease")
+	}
+
+	delReply, _ := s.BatchDelete(ctx, &pbv2.BatchDeleteRequest{Keys: []string{"a", "c"}})
+	if delReply.Header.Error != nil || delReply.Deleted != 1 || delReply.Revision != 2 {
+		t.Error("BatchDelete should delete existing keys")
+	}
+
+	tooLarge := make([]*pbv2.KeyValue, maxBatchOps+1)
+	for i := range tooLarge {
+		tooLarge[i] = &pbv2.KeyValue{Key: fmt.Sprint(i)}
+	}
+	invalid := []*pbv2.BatchSetRequest{
+		{},
+		{Kvs: tooLarge},
+		{Kvs: []*pbv2.KeyValue{{Key: "a"}, {Key: ""}}},
+		{Kvs: []*pbv2.KeyValue{{Key: "a"}}, Ttl: -1},
+	}
+	for _, req := range invalid {
+		if reply, _ := s.BatchSet(ctx, req); reply.Header.Error.GetCode() != pbv2.ErrorCode_INVALID_ARGUMENT {
+			t.Errorf("Invalid batch with %d keys should return INVALID_ARGUMENT", len(req.Kvs))
+		}
+	}
+	if reply, _ := s.BatchDelete(ctx, &pbv2.BatchDeleteRequest{}); reply.Header.Error.GetCode() != pbv2.ErrorCode_INVALID_ARGUMENT {
+		t.Error("Empty BatchDelete should return INVALID_ARGUMENT")
+	}
+}
+
+func TestV2BulkLoad(t *testing.T) {
+	node := &fakeNode{leader: 0, store: newRKVStore(), success: true}
+	client, stop := startTestV2Server(t, node)
+	defer stop()
+	ctx := context.Background()
+
+	stream, err := client.BulkLoad(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stream.Send(&pbv2.BatchSetRequest{Header: &pbv2.RequestHeader{RequestID: "req1"}, Kvs: []*pbv2.KeyValue{{Key: "a"}, {Key: "b"}}})
+	stream.Send(&pbv2.BatchSetRequest{Kvs: []*pbv2.KeyValue{{Key: "c"}}})
+	reply, err := stream.CloseAndRecv()
+	if err != nil || reply.Header.Error != nil || reply.Header.RequestID != "req1" || reply.Count != 3 || reply.Revision != 2 {
+		t.Fatal("BulkLoad should apply each request as a batch")
+	}
+
+	stream, _ = client.BulkLoad(ctx)
+	stream.Send(&pbv2.BatchSetRequest{Kvs: []*pbv2.KeyValue{{Key: "d"}}})
+	stream.Send(&pbv2.BatchSetRequest{Kvs: []*pbv2.KeyValue{{Key: ""}}})
+	stream.Send(&pbv2.BatchSetRequest{Kvs: []*pbv2.KeyValue{{Key: "e"}}})
+	reply, err = stream.CloseAndRecv()
+	if err != nil || reply.Header.Error.GetCode() != pbv2.ErrorCode_INVALID_ARGUMENT || reply.Count != 1 {
+		t.Error("BulkLoad should stop at the first failed request, with the count of keys set before it")
+	}
+	if _, err = node.store.Get("e"); err == nil {
+		t.Error("BulkLoad should not apply requests after the failed one")
+	}
+}
+
+func TestV2Export(t *testing.T) {
+	node := &fakeNode{leader: 0, store: newRKVStore(), success: true}
+	client, stop := startTestV2Server(t, node)
+	defer stop()
+	ctx := context.Background()
+
+	kvs := make([]*pbv2.KeyValue, maxBatchOps)
+	for i := range kvs {
+		kvs[i] = &pbv2.KeyValue{Key: fmt.Sprintf("k%04d", i)}
+	}
+	client.BatchSet(ctx, &pbv2.BatchSetRequest{Kvs: kvs})
+	client.Set(ctx, &pbv2.SetRequest{Key: "a", Value: "1"})
+	client.Set(ctx, &pbv2.SetRequest{Key: "z", Value: "1"}) // revision 3
+
+	export := func(req *pbv2.ExportRequest) (keys []string, revision int64, err *pbv2.Error) {
+		stream, e := client.Export(ctx, req)
+		if e != nil {
+			t.Fatal(e)
+		}
+		for {
+			reply, e := stream.Recv()
+			if e == io.EOF {
+				return
+			}
+			if e != nil {
+				t.Fatal(e)
+			}
+			for _, kv := range reply.Kvs {
+				keys = append(keys, kv.Key)
+			}
+			revision, err = reply.Revision, reply.Header.Error
+		}
+	}
+
+	keys, revision, err := export(&pbv2.ExportRequest{Prefix: "k"})
+	if err != nil || len(keys) != maxBatchOps || keys[maxBatchOps-1] != "k0999" || revision != 3 {
+		t.Errorf("Export should stream all keys with the prefix in pages, got %d keys at revision %d", len(keys), revision)
+	}
+
+	if keys, revision, err = export(&pbv2.ExportRequest{Revision: 2}); err != nil || len(keys) != maxBatchOps+1 || revision != 2 {
+		t.Errorf("Export should read keys at the revision, got %d keys at revision %d", len(keys), revision)
+	}
+
+	client.Compact(ctx, &pbv2.CompactRequest{Revision: 3})
+	if _, _, err = export(&pbv2.ExportRequest{Revision: 2}); err.GetCode() != pbv2.ErrorCode_COMPACTED {
+		t.Error("Export at compacted revision should return COMPACTED")
+	}
+}
+
+func TestV2ForwardedErrors(t *testing.T) {
+	c := startTestCluster(t, 3)
+	follower := c.follower(t)
+	leaderClient := pbv2.NewKVStoreClient(c.dial(t, c.waitForLeader(t)))
+	followerClient := pbv2.NewKVStoreClient(c.dial(t, follower))
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// writes are forwarded too
+	for i := 0; i < 3; i++ {
+		if reply, err := followerClient.Set(ctx, &pbv2.SetRequest{Key: "a", Value: fmt.Sprint(i)}); err != nil || reply.Header.Error != nil {
+			t.Fatal("Set through a follower should succeed", err, reply.GetHeader().GetError())
+		}
+	}
+	if reply, err := followerClient.Compact(ctx, &pbv2.CompactRequest{Revision: 2}); err != nil || reply.Header.Error != nil {
+		t.Fatal("Compact through a follower should succeed", err, reply.GetHeader().GetError())
+	}
+
+	// requests forwarded by the follower fail with the same codes as on the leader
+	tests := []struct {
+		name string
+		call func(client pbv2.KVStoreClient) (*pbv2.ResponseHeader, error)
+		code pbv2.ErrorCode
+	}{
+		{"missing key", func(client pbv2.KVStoreClient) (*pbv2.ResponseHeader, error) {
+			reply, err := client.Get(ctx, &pbv2.GetRequest{Key: "b"})
+			return reply.GetHeader(), err
+		}, pbv2.ErrorCode_KEY_NOT_FOUND},
+		{"compacted revision", func(client pbv2.KVStoreClient) (*pbv2.ResponseHeader, error) {
+			reply, err := client.Get(ctx, &pbv2.GetRequest{Key: "a", Revision: 1})
+			return reply.GetHeader(), err
+		}, pbv2.ErrorCode_COMPACTED},
+		{"future revision", func(client pbv2.KVStoreClient) (*pbv2.ResponseHeader, error) {
+			reply, err := client.Get(ctx, &pbv2.GetRequest{Key: "a", Revision: 100})
+			return reply.GetHeader(), err
+		}, pbv2.ErrorCode_INVALID_ARGUMENT},
+		{"missing lease", func(client pbv2.KVStoreClient) (*pbv2.ResponseHeader, error) {
+			reply, err := client.LeaseRevoke(ctx, &pbv2.LeaseRevokeRequest{Id: 100})
+			return reply.GetHeader(), err
+		}, pbv2.ErrorCode_LEASE_NOT_FOUND},
+	}
+	for _, test := range tests {
+		for name, client := range map[string]pbv2.KVStoreClient{"leader": leaderClient, "follower": followerClient} {
+			if header, err := test.call(client); err != nil || header.Error.GetCode() != test.code {
+				t.Errorf("%s on the %s should fail with %s, got %v %v", test.name, name, test.code, err, header.GetError())
+			}
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package rkv
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// Snapshot formats, which depend on the store backend. All nodes in a cluster use the same one
+const (
+	// SnapshotFormatJSON is the snapshot format of in memory stores
+	SnapshotFormatJSON = "json"
+	// SnapshotFormatBolt is the snapshot format of stores in a bolt db, used with Options.DataDir
+	SnapshotFormatBolt = "bolt"
+)
+
+// SnapshotInfo describes the kv store in a snapshot
+type SnapshotInfo struct {
+	Format          string
+	Revision        int64
+	CompactRevision int64
+	Keys            int
+	Leases          int
+}
+
+// SnapshotStore is a read only kv store loaded from the state machine data of a snapshot, for offline tools like rkvadmin
+type SnapshotStore struct {
+	store  *rkvStore
+	format string
+	// dir is the temp dir of the bolt db, empty for JSON snapshots
+	dir string
+}
+
+// LoadSnapshot loads the state machine data of a snapshot in either format. Bolt snapshots are written to a temp dir,
+// which is removed by Close
+func LoadSnapshot(r io.Reader) (*SnapshotStore, error) {
+	br := bufio.NewReader(r)
+	first, err := br.Peek(1)
+	if err != nil {
+		return nil, err
+	}
+
+	if first[0] == '{' {
+		backend := newMemBackend()
+		if err = backend.deserialize(br); err != nil {
+			return nil, err
+		}
+		return &SnapshotStore{store: newRKVStoreWithBackend(backend), format: SnapshotFormatJSON}, nil
+	}
+
+	s := &SnapshotStore{format: SnapshotFormatBolt}
+	backend, err := s.newBoltBackend()
+	if err == nil {
+		err = backend.deserialize(br)
+	}
+	if err != nil {
+		s.Close()
+		return nil, err
+	}
+	s.store = newRKVStoreWithBackend(backend)
+	return s, nil
+}
+
+// newBoltBackend creates an empty bolt backend in a new temp dir
+func (s *SnapshotStore) newBoltBackend() (*boltBackend, error) {
+	dir, err := ioutil.TempDir("", "rkvsnapshot")
+	if err != nil {
+		return nil, err
+	}
+	s.dir = dir
+	return newBoltBackend(filepath.Join(dir, "snapshot.rkvdb"))
+}
+
+// Info returns the format and the meta of the store
+func (s *SnapshotStore) Info() SnapshotInfo {
+	s.store.mu.RLock()
+	defer s.store.mu.RUnlock()
+
+	info := SnapshotInfo{
+		Format:          s.format,
+		Revision:        s.store.meta.Revision,
+		CompactRevision: s.store.meta.CompactRevision,
+		Leases:          len(s.store.leases),
+	}
+	s.store.backend.ascend("", "", func(key string, entry KVEntry) bool {
+		info.Keys++
+		return true
+	})
+	return info
+}
+
+// Ascend calls fn on current keys with the prefix in key order, until fn returns false.
+// Expired keys which are not deleted yet are included, since they are still in the store
+func (s *SnapshotStore) Ascend(prefix string, fn func(key string, entry KVEntry) bool) {
+	s.store.mu.RLock()
+	defer s.store.mu.RUnlock()
+
+	r := prefixRange(prefix, 0)
+	s.store.backend.ascend(r.Start, r.End, fn)
+}
+
+// Get returns the current entry of key, including expired ones
+func (s *SnapshotStore) Get(key string) (KVEntry, bool) {
+	s.store.mu.RLock()
+	defer s.store.mu.RUnlock()
+
+	return s.store.backend.get(key)
+}
+
+// Close closes the store and removes its temp dir if any
+func (s *SnapshotStore) Close() error {
+	var err error
+	if s.store != nil {
+		err = s.store.close()
+	}
+	if s.dir != "" {
+		os.RemoveAll(s.dir)
+	}
+	return err
+}
+
+// WriteSnapshot writes the state machine data of a store with keys from next in the format, until next returns io.EOF.
+// All keys are set at revision 1, without TTL or lease. Returns the number of keys written
+func WriteSnapshot(w io.Writer, format string, next func() (key string, value string, err error)) (int, error) {
+	s := &SnapshotStore{format: format}
+	defer s.Close()
+
+	switch format {
+	case SnapshotFormatJSON:
+		s.store = newRKVStore()
+	case SnapshotFormatBolt:
+		backend, err := s.newBoltBackend()
+		if err != nil {
+			return 0, err
+		}
+		s.store = newRKVStoreWithBackend(backend)
+	default:
+		return 0, fmt.Errorf("unknown snapshot format %s", format)
+	}
+
+	backend := s.store.backend
+	count := 0
+	for {
+		key, value, err := next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return count, err
+		}
+		if key == "" {
+			return count, errorEmptyKey
+		}
+		if _, exists := backend.get(key); exists {
+			return count, fmt.Errorf("duplicate key %s", key)
+		}
+
+		backend.put(key, KVEntry{Value: value, Revision: 1})
+		count++
+	}
+
+	meta := newStoreMeta()
+	if count > 0 {
+		meta.Revision = 1
+	}
+	if err := backend.commit(meta); err != nil {
+		return count, err
+	}
+	return count, backend.serialize(w)
+}
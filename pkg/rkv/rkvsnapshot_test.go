@@ -0,0 +1,121 @@
+package rkv
+
+import (
+	"bytes"
+	"io"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/sidecus/raft/pkg/raft"
+)
+
+// feedKVs returns a next func for WriteSnapshot over key value pairs
+func feedKVs(kvs ...string) func() (string, string, error) {
+	i := 0
+	return func() (string, string, error) {
+		if i >= len(kvs) {
+			return "", "", io.EOF
+		}
+		i += 2
+		return kvs[i-2], kvs[i-1], nil
+	}
+}
+
+// ascendAll returns all keys and values in a snapshot store, in key order
+func ascendAll(s *SnapshotStore, prefix string) []string {
+	var result []string
+	s.Ascend(prefix, func(key string, entry KVEntry) bool {
+		result = append(result, key, entry.Value)
+		return true
+	})
+	return result
+}
+
+func TestWriteSnapshot(t *testing.T) {
+	for _, format := range []string{SnapshotFormatJSON, SnapshotFormatBolt} {
+		var buf bytes.Buffer
+		n, err := WriteSnapshot(&buf, format, feedKVs("b", "2", "a/1", "1", "a/2", "2"))
+		if n != 3 || err != nil {
+			t.Fatalf("WriteSnapshot in %s returns %d, %v", format, n, err)
+		}
+
+		s, err := LoadSnapshot(&buf)
+		if err != nil {
+			t.Fatalf("LoadSnapshot in %s failed: %v", format, err)
+		}
+		if info := s.Info(); info != (SnapshotInfo{Format: format, Revision: 1, Keys: 3}) {
+			t.Errorf("Info of %s snapshot is %v", format, info)
+		}
+		if kvs := ascendAll(s, ""); !reflect.DeepEqual(kvs, []string{"a/1", "1", "a/2", "2", "b", "2"}) {
+			t.Errorf("Ascend of %s snapshot returns %v", format, kvs)
+		}
+		if kvs := ascendAll(s, "a/"); !reflect.DeepEqual(kvs, []string{"a/1", "1", "a/2", "2"}) {
+			t.Errorf("Ascend with prefix of %s snapshot returns %v", format, kvs)
+		}
+		if entry, ok := s.Get("a/2"); !ok || entry != (KVEntry{Value: "2", Revision: 1}) {
+			t.Errorf("Get of %s snapshot returns %v, %v", format, entry, ok)
+		}
+		if _, ok := s.Get("a"); ok {
+			t.Errorf("Get of %s snapshot returns non existent key", format)
+		}
+		if err = s.Close(); err != nil {
+			t.Error(err)
+		}
+
+		// snapshots are usable by the node store
+		store := newRKVStore()
+		if format == SnapshotFormatBolt {
+			store = newTestBoltStore(t, filepath.Join(t.TempDir(), "test.rkvdb"))
+		}
+		WriteSnapshot(&buf, format, feedKVs("k", "v"))
+		if err = store.Deserialize(&buf); err != nil {
+			t.Fatalf("store cannot load %s snapshot: %v", format, err)
+		}
+		if v, err := store.Get("k"); err != nil || v.(KVEntry).Value != "v" {
+			t.Errorf("store loaded from %s snapshot returns %v, %v", format, v, err)
+		}
+		store.close()
+	}
+
+	if _, err := WriteSnapshot(io.Discard, SnapshotFormatJSON, feedKVs("a", "1", "a", "2")); err == nil {
+		t.Error("WriteSnapshot should reject duplicate keys")
+	}
+	if _, err := WriteSnapshot(io.Discard, SnapshotFormatJSON, feedKVs("", "1")); err != errorEmptyKey {
+		t.Error("WriteSnapshot should reject empty keys")
+	}
+	if _, err := WriteSnapshot(io.Discard, "xml", feedKVs()); err == nil {
+		t.Error("WriteSnapshot should reject unknown formats")
+	}
+}
+
+func TestLoadSnapshot(t *testing.T) {
+	store := newRKVStore()
+	store.Apply(raft.StateMachineCmd{CmdType: KVCmdSet, Data: KVCmdData{Key: "a", Value: "1"}})
+	store.Apply(raft.StateMachineCmd{CmdType: KVCmdLeaseGrant, Data: KVLeaseCmdData{TTL: 60000, Time: time.Now().UnixNano()}})
+	store.Apply(raft.StateMachineCmd{CmdType: KVCmdSet, Data: KVCmdData{Key: "b", Value: "2", Lease: 1}})
+	store.Apply(raft.StateMachineCmd{CmdType: KVCmdDel, Data: KVCmdData{Key: "a"}})
+
+	var buf bytes.Buffer
+	store.Serialize(&buf)
+	s, err := LoadSnapshot(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	if info := s.Info(); info != (SnapshotInfo{Format: SnapshotFormatJSON, Revision: 3, Keys: 1, Leases: 1}) {
+		t.Errorf("Info is %v", info)
+	}
+	if kvs := ascendAll(s, ""); !reflect.DeepEqual(kvs, []string{"b", "2"}) {
+		t.Errorf("Ascend returns %v", kvs)
+	}
+
+	if _, err = LoadSnapshot(bytes.NewReader(nil)); err == nil {
+		t.Error("LoadSnapshot should fail on empty data")
+	}
+	if _, err = LoadSnapshot(bytes.NewReader([]byte("not a snapshot"))); err == nil {
+		t.Error("LoadSnapshot should fail on invalid data")
+	}
+}
@@ -1,11 +1,12 @@
 package rkv
 
 import (
-	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/sidecus/raft/pkg/raft"
 	"github.com/sidecus/raft/pkg/util"
@@ -14,52 +15,381 @@ import (
 // KVStore implements raft.IStateMachine
 
 var errorNoKeyProvidedForGet = errors.New("no key provided for Get")
+var errorKeyNotFound = errors.New("key doesn't exist")
 
 const (
 	// KVCmdSet Set a key/value pair
 	KVCmdSet = 1
 	// KVCmdDel Delete a key/value pair
 	KVCmdDel = 2
+	// KVCmdCAS Set a key/value pair if the key's current revision matches PrevRevision,
+	// or its current value matches PrevValue when PrevRevision is 0
+	KVCmdCAS = 3
+	// KVCmdSetIfAbsent Set a key/value pair if the key doesn't exist
+	KVCmdSetIfAbsent = 4
+	// KVCmdDelIfRevision Delete a key/value pair if the key's current revision matches PrevRevision
+	KVCmdDelIfRevision = 5
+	// KVCmdTxn Apply a KVTxn atomically
+	KVCmdTxn = 6
+	// KVCmdExpire Delete an expired key if its current revision matches PrevRevision.
+	// The leader now proposes KVCmdExpireBatch instead, this is kept for entries already in the log
+	KVCmdExpire = 7
+	// KVCmdLeaseGrant Grant a new lease, see rkvlease.go for lease cmds
+	KVCmdLeaseGrant = 8
+	// KVCmdLeaseKeepAlive Refresh a lease
+	KVCmdLeaseKeepAlive = 9
+	// KVCmdLeaseRevoke Revoke a lease and delete all keys attached to it
+	KVCmdLeaseRevoke = 10
+	// KVCmdLeaseExpire Revoke an expired lease if its expiry time matches ExpireAt. Kept like KVCmdExpire
+	KVCmdLeaseExpire = 11
+	// KVCmdCompact Drop versions older than a revision, see rkvmvcc.go
+	KVCmdCompact = 12
+	// KVCmdBatch Apply a KVBatch of sets and deletes atomically, see rkvbatch.go
+	KVCmdBatch = 13
+	// KVCmdExpireBatch Expire a KVExpireBatch of keys and leases in one log entry, see rkvexpirer.go. Proposed by the leader
+	KVCmdExpireBatch = 14
+)
+
+// KVTxnGet is a read op in a txn. Other txn ops use KVCmdSet and KVCmdDel
+const KVTxnGet = 0
+
+// KVCompare targets
+const (
+	// KVCompareValue compares the key's value
+	KVCompareValue = iota
+	// KVCompareRevision compares the key's revision, 0 when the key doesn't exist
+	KVCompareRevision
+)
+
+// KVCompare results
+const (
+	KVCompareEqual = iota
+	KVCompareNotEqual
+	KVCompareGreater
+	KVCompareLess
 )
 
 // KVCmdData represents one Key/Value command data in the log entry
 type KVCmdData struct {
 	Key   string
 	Value string
+
+	// conditions for conditional writes
+	PrevValue    string `json:",omitempty"`
+	PrevRevision int64  `json:",omitempty"`
+
+	// ExpireAt is the unix time in nanoseconds when the key expires, 0 for no expiry.
+	// It's set before proposing so that all replicas agree on it
+	ExpireAt int64 `json:",omitempty"`
+	// Lease attaches the key to a lease, which must exist
+	Lease int64 `json:",omitempty"`
+}
+
+// KVCmdResult is the result of applying a kv command, returned by Execute
+type KVCmdResult struct {
+	// Succeeded is false when the condition of a conditional write is not met, or the lease doesn't exist
+	Succeeded bool
+	// Revision is the key's revision after the command, 0 if the key doesn't exist
+	Revision int64
+	// Value is the key's value after the command
+	Value string
+}
+
+// KVCompare is a condition on a key in a txn. A non existent key has empty value and revision 0
+type KVCompare struct {
+	Key      string
+	Target   int
+	Result   int
+	Value    string `json:",omitempty"`
+	Revision int64  `json:",omitempty"`
+}
+
+// KVTxnOp is one op in a txn. CmdType is KVCmdSet, KVCmdDel or KVTxnGet
+type KVTxnOp struct {
+	CmdType int
+	Key     string
+	Value   string `json:",omitempty"`
+}
+
+// KVTxn is a multi key txn modelled on etcd. Success ops are applied when all compares are met, otherwise Failure ops
+type KVTxn struct {
+	Compares []KVCompare
+	Success  []KVTxnOp
+	Failure  []KVTxnOp
+}
+
+// KVTxnResult is the result of applying a KVTxn, returned by Execute
+type KVTxnResult struct {
+	// Succeeded tells whether all compares were met
+	Succeeded bool
+	// Results has one result per applied op. Succeeded in each result tells whether the key existed for get and del ops
+	Results []KVCmdResult
 }
 
-// rkvStore is a concurrency safe kv store
+// KVEntry is a value in the kv store with the store revision of its last modification
+type KVEntry struct {
+	Value    string
+	Revision int64
+	ExpireAt int64 `json:",omitempty"`
+	Lease    int64 `json:",omitempty"`
+}
+
+// expired tells whether the entry has expired at now
+func (e KVEntry) expired(now time.Time) bool {
+	return e.ExpireAt != 0 && e.ExpireAt <= now.UnixNano()
+}
+
+// rkvStore is a concurrency safe kv store.
+// revision is bumped on every successful write, and each key remembers the revision it was last modified at.
+// Since commands are applied in log order, revisions are the same on all replicas.
+// Keys and their older versions are kept by the backend, either in memory or on disk, and saved with the meta after each cmd.
+// Expired keys are only removed by KVCmdExpireBatch so that replicas don't depend on their own clocks when applying cmds.
+// Until then they are hidden from Get, but still seen by conditional writes and txns. Same for keys attached to expired leases
 type rkvStore struct {
-	mu   sync.RWMutex
-	data map[string]string
+	mu      sync.RWMutex
+	backend kvBackend
+	meta    storeMeta
+	// leases are loaded from the backend. Keys attached to them are only kept by the backend
+	leases map[int64]*kvLease
+
+	// events of the cmd being applied, published to watches once it's applied
+	events  []KVEvent
+	watches *watchHub
+
+	// log is also used by the expirer, compactor and shutdown of the node
+	log rkvLogger
 }
 
-// newRKVStore creates a kv store
+// newRKVStore creates an in memory kv store
 func newRKVStore() *rkvStore {
+	return newRKVStoreWithBackend(newMemBackend())
+}
+
+// newRKVStoreWithBackend creates a kv store on the backend, loading what's saved in it
+func newRKVStoreWithBackend(backend kvBackend) *rkvStore {
 	store := &rkvStore{
-		data: make(map[string]string),
+		backend: backend,
+		watches: newWatchHub(defaultWatchHistory),
 	}
+	store.load()
 	return store
 }
 
-// Apply applies the cmd to the kv store with concurrency safety
-func (store *rkvStore) Apply(cmd raft.StateMachineCmd) {
-	if cmd.CmdType != KVCmdSet && cmd.CmdType != KVCmdDel {
-		util.Panicf("Unexpected kv cmdtype %d", cmd.CmdType)
+// load loads the meta and leases from the backend. Needs to be called with lock held
+func (store *rkvStore) load() {
+	store.meta = store.backend.meta()
+	store.leases = make(map[int64]*kvLease)
+	for _, lease := range store.backend.leases() {
+		store.leases[lease.ID] = lease
 	}
+	store.watches.reset(store.meta.Revision)
+}
 
+// Apply applies the cmd to the kv store with concurrency safety. It's used for cmds not from raft logs, e.g. in tests.
+// Returns KVTxnResult for KVCmdTxn, KVBatchResult for KVCmdBatch and KVCmdExpireBatch, KVLeaseResult for lease cmds and KVCmdResult for others
+func (store *rkvStore) Apply(cmd raft.StateMachineCmd) interface{} {
 	store.mu.Lock()
 	defer store.mu.Unlock()
 
-	data := cmd.Data.(KVCmdData)
-	if cmd.CmdType == KVCmdSet {
-		store.data[data.Key] = data.Value
-	} else if cmd.CmdType == KVCmdDel {
-		delete(store.data, data.Key)
+	return store.apply(cmd)
+}
+
+// ApplyEntry implements IPersistentStateMachine.ApplyEntry. The entry's index and term are saved with its changes
+func (store *rkvStore) ApplyEntry(index int, term int, cmd raft.StateMachineCmd) interface{} {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	store.meta.AppliedIndex, store.meta.AppliedTerm = index, term
+	return store.apply(cmd)
+}
+
+// LastApplied implements IPersistentStateMachine.LastApplied
+func (store *rkvStore) LastApplied() (index int, term int) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	return store.meta.AppliedIndex, store.meta.AppliedTerm
+}
+
+// apply applies the cmd, saves the changes and publishes its events. Needs to be called with lock held
+func (store *rkvStore) apply(cmd raft.StateMachineCmd) interface{} {
+	var result interface{}
+	switch {
+	case cmd.CmdType == KVCmdTxn:
+		result = store.applyTxn(cmd.Data.(KVTxn))
+	case cmd.CmdType == KVCmdBatch:
+		result = store.applyBatch(cmd.Data.(KVBatch))
+	case cmd.CmdType == KVCmdExpireBatch:
+		result = store.applyExpireBatch(cmd.Data.(KVExpireBatch))
+	case isLeaseCmd(cmd.CmdType):
+		result = store.applyLease(cmd.CmdType, cmd.Data.(KVLeaseCmdData))
+	case cmd.CmdType == KVCmdCompact:
+		result = store.applyCompact(cmd.Data.(KVCompactCmdData))
+	default:
+		result = store.applyCmd(cmd.CmdType, cmd.Data.(KVCmdData))
+	}
+
+	// replicas can't skip a cmd, so there is no way to go on if changes can't be saved
+	if err := store.backend.commit(store.meta); err != nil {
+		util.Panicf("Failed to save kv store changes. err:%s", err)
 	}
+	store.publish()
+	return result
 }
 
-// Get Implements IStateMachine.Get
+// applyCmd applies a single key cmd, needs to be called with lock held
+func (store *rkvStore) applyCmd(cmdType int, data KVCmdData) KVCmdResult {
+	current, exists := store.backend.get(data.Key)
+
+	set, del := false, false
+	switch cmdType {
+	case KVCmdSet:
+		set = true
+	case KVCmdDel:
+		del = true
+	case KVCmdCAS:
+		if data.PrevRevision != 0 {
+			set = exists && current.Revision == data.PrevRevision
+		} else {
+			set = exists && current.Value == data.PrevValue
+		}
+	case KVCmdSetIfAbsent:
+		set = !exists
+	case KVCmdDelIfRevision, KVCmdExpire:
+		del = exists && current.Revision == data.PrevRevision
+	default:
+		util.Panicf("Unexpected kv cmdtype %d", cmdType)
+	}
+
+	if set && data.Lease != 0 && store.leases[data.Lease] == nil {
+		set = false
+	}
+
+	switch {
+	case set:
+		store.meta.Revision++
+		current = KVEntry{Value: data.Value, Revision: store.meta.Revision, ExpireAt: data.ExpireAt, Lease: data.Lease}
+		store.put(data.Key, current)
+	case del:
+		store.meta.Revision++
+		store.remove(data.Key, store.meta.Revision)
+		current = KVEntry{}
+	}
+
+	return KVCmdResult{
+		Succeeded: set || del,
+		Revision:  current.Revision,
+		Value:     current.Value,
+	}
+}
+
+// applyTxn applies a txn, needs to be called with lock held.
+// All writes in the txn share one revision, so the txn is seen as a single modification
+func (store *rkvStore) applyTxn(txn KVTxn) KVTxnResult {
+	succeeded := true
+	for _, c := range txn.Compares {
+		if !store.compare(c) {
+			succeeded = false
+			break
+		}
+	}
+
+	ops := txn.Success
+	if !succeeded {
+		ops = txn.Failure
+	}
+
+	revision := store.meta.Revision + 1
+	written := false
+	results := make([]KVCmdResult, len(ops))
+	for i, op := range ops {
+		current, exists := store.backend.get(op.Key)
+		switch op.CmdType {
+		case KVCmdSet:
+			current, exists = KVEntry{Value: op.Value, Revision: revision}, true
+			store.put(op.Key, current)
+			written = true
+		case KVCmdDel:
+			store.remove(op.Key, revision)
+			current = KVEntry{}
+			written = true
+		case KVTxnGet:
+		default:
+			util.Panicf("Unexpected kv txn op cmdtype %d", op.CmdType)
+		}
+		results[i] = KVCmdResult{Succeeded: exists, Revision: current.Revision, Value: current.Value}
+	}
+
+	if written {
+		store.meta.Revision = revision
+	}
+
+	return KVTxnResult{Succeeded: succeeded, Results: results}
+}
+
+// put sets the entry of a key. The backend moves the key to its new lease. Needs to be called with lock held
+func (store *rkvStore) put(key string, entry KVEntry) {
+	store.backend.put(key, entry)
+	store.events = append(store.events, KVEvent{Type: KVEventPut, Key: key, Value: entry.Value, Revision: entry.Revision})
+}
+
+// remove deletes a key at revision. The backend detaches it from its lease. Needs to be called with lock held
+func (store *rkvStore) remove(key string, revision int64) {
+	if _, ok := store.backend.get(key); !ok {
+		return
+	}
+	store.backend.remove(key, revision)
+	store.events = append(store.events, KVEvent{Type: KVEventDelete, Key: key, Revision: revision})
+}
+
+// publish sends events of the cmd just applied to watches. Needs to be called with lock held so that events are in order
+func (store *rkvStore) publish() {
+	store.watches.publish(store.events)
+	store.events = nil
+}
+
+// compare checks a txn condition, needs to be called with lock held
+func (store *rkvStore) compare(c KVCompare) bool {
+	current, _ := store.backend.get(c.Key)
+
+	var r int
+	switch c.Target {
+	case KVCompareValue:
+		r = strings.Compare(current.Value, c.Value)
+	case KVCompareRevision:
+		r = compareInt64(current.Revision, c.Revision)
+	default:
+		util.Panicf("Unexpected kv compare target %d", c.Target)
+	}
+
+	switch c.Result {
+	case KVCompareEqual:
+		return r == 0
+	case KVCompareNotEqual:
+		return r != 0
+	case KVCompareGreater:
+		return r > 0
+	case KVCompareLess:
+		return r < 0
+	default:
+		util.Panicf("Unexpected kv compare result %d", c.Result)
+	}
+	return false
+}
+
+func compareInt64(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// Get Implements IStateMachine.Get. Param is either a key or KVGetAt which return KVEntry,
+// a KVRange which returns KVRangeResult, or KVHistory which returns KVHistoryResult
 func (store *rkvStore) Get(param ...interface{}) (result interface{}, err error) {
 	if len(param) != 1 {
 		return nil, errorNoKeyProvidedForGet
@@ -68,21 +398,46 @@ func (store *rkvStore) Get(param ...interface{}) (result interface{}, err error)
 	store.mu.RLock()
 	defer store.mu.RUnlock()
 
+	switch p := param[0].(type) {
+	case KVRange:
+		return store.scan(p, time.Now())
+	case KVGetAt:
+		return store.getAt(p)
+	case KVHistory:
+		return store.getHistory(p), nil
+	}
+
 	key := param[0].(string)
-	if v, ok := store.data[key]; ok {
+	if v, ok := store.backend.get(key); ok && !store.expired(v, time.Now()) {
 		return v, nil
 	}
 
-	return "", fmt.Errorf("Key %s doesn't exist", key)
+	return KVEntry{}, fmt.Errorf("Key %s: %w", key, errorKeyNotFound)
 }
 
-// Serialize implements IStateMachine.TakeSnapshot
+// expired tells whether the entry or its lease has expired at now. Needs to be called with lock held
+func (store *rkvStore) expired(e KVEntry, now time.Time) bool {
+	if lease, ok := store.leases[e.Lease]; ok && lease.expired(now) {
+		return true
+	}
+	return e.expired(now)
+}
+
+// expiredKeys returns at most max keys expired at now, with their revisions
+func (store *rkvStore) expiredKeys(now time.Time, max int) map[string]int64 {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	return store.backend.expiredKeys(now, max)
+}
+
+// Serialize implements IStateMachine.TakeSnapshot. The snapshot format depends on the backend,
+// so all nodes in a cluster need to use the same kind of backend
 func (store *rkvStore) Serialize(w io.Writer) error {
 	store.mu.RLock()
 	defer store.mu.RUnlock()
 
-	// we use JSON serialized data for our kv store
-	return json.NewEncoder(w).Encode(store.data)
+	return store.backend.serialize(w)
 }
 
 // Deserialize installs a snapshot, it implements IStateMachine.InstallSnapshot
@@ -90,5 +445,35 @@ func (store *rkvStore) Deserialize(reader io.Reader) error {
 	store.mu.Lock()
 	defer store.mu.Unlock()
 
-	return json.NewDecoder(reader).Decode(&store.data)
+	if err := store.backend.deserialize(reader); err != nil {
+		return err
+	}
+	store.load()
+	return nil
+}
+
+// DeserializeAt implements IPersistentStateMachine.DeserializeAt. The snapshot's index and term are saved with it
+func (store *rkvStore) DeserializeAt(reader io.Reader, index int, term int) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	if err := store.backend.deserialize(reader); err != nil {
+		return err
+	}
+
+	meta := store.backend.meta()
+	meta.AppliedIndex, meta.AppliedTerm = index, term
+	if err := store.backend.commit(meta); err != nil {
+		return err
+	}
+	store.load()
+	return nil
+}
+
+// close closes the backend
+func (store *rkvStore) close() error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	return store.backend.close()
 }
@@ -2,7 +2,9 @@ package rkv
 
 import (
 	"bytes"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/sidecus/raft/pkg/raft"
 )
@@ -18,7 +20,7 @@ func TestCmdSet(t *testing.T) {
 		},
 	})
 
-	if v, _ := store.Get("a"); v != "a" {
+	if v, _ := store.Get("a"); v.(KVEntry).Value != "a" {
 		t.Error("Set doesn't set value correctly")
 	}
 
@@ -30,7 +32,7 @@ func TestCmdSet(t *testing.T) {
 		},
 	})
 
-	if v, _ := store.Get("a"); v != "A" {
+	if v, _ := store.Get("a"); v.(KVEntry).Value != "A" {
 		t.Error("Set doesn't set value correctly upon existing entry")
 	}
 }
@@ -72,7 +74,7 @@ func TestCmdDel(t *testing.T) {
 		},
 	})
 
-	if v, err := store.Get("a"); err != nil || v.(string) != "a" {
+	if v, err := store.Get("a"); err != nil || v.(KVEntry).Value != "a" {
 		t.Error("Del deletes wrong entry")
 	}
 }
@@ -107,11 +109,258 @@ func TestSerilization(t *testing.T) {
 		t.Errorf("InstallSnapshot returned error %s", err)
 	}
 
-	if v, err := newStore.Get("a"); err != nil || v.(string) != "a" {
+	if v, err := newStore.Get("a"); err != nil || v.(KVEntry).Value != "a" {
 		t.Error("InstallSnapshot returns different data")
 	}
 
-	if v, err := newStore.Get("ab"); err != nil || v.(string) != "ab" {
+	if v, err := newStore.Get("ab"); err != nil || v.(KVEntry).Value != "ab" {
 		t.Error("InstallSnapshot returns different data")
 	}
+
+	if newStore.meta.Revision != store.meta.Revision {
+		t.Error("InstallSnapshot returns different revision")
+	}
+}
+
+// keyCount returns the number of current keys in the store
+func keyCount(store *rkvStore) int {
+	count := 0
+	store.backend.ascend("", "", func(key string, entry KVEntry) bool {
+		count++
+		return true
+	})
+	return count
+}
+
+func applyKV(store *rkvStore, cmdType int, data KVCmdData) KVCmdResult {
+	return store.Apply(raft.StateMachineCmd{CmdType: cmdType, Data: data}).(KVCmdResult)
+}
+
+func TestRevisions(t *testing.T) {
+	store := newRKVStore()
+
+	if r := applyKV(store, KVCmdSet, KVCmdData{Key: "a", Value: "1"}); !r.Succeeded || r.Revision != 1 {
+		t.Error("Set should bump revision")
+	}
+	if r := applyKV(store, KVCmdSet, KVCmdData{Key: "b", Value: "1"}); r.Revision != 2 {
+		t.Error("Revision should be store wide")
+	}
+	if r := applyKV(store, KVCmdDel, KVCmdData{Key: "b"}); !r.Succeeded || r.Revision != 0 {
+		t.Error("Del should report revision 0 for the deleted key")
+	}
+	if v, _ := store.Get("a"); v.(KVEntry).Revision != 1 {
+		t.Error("Key should keep the revision it was last modified at")
+	}
+	if r := applyKV(store, KVCmdSet, KVCmdData{Key: "a", Value: "2"}); r.Revision != 4 {
+		t.Error("Del should bump store revision too")
+	}
+}
+
+func TestCmdCAS(t *testing.T) {
+	store := newRKVStore()
+
+	if r := applyKV(store, KVCmdCAS, KVCmdData{Key: "a", Value: "1"}); r.Succeeded {
+		t.Error("CAS should fail on non existent key")
+	}
+
+	applyKV(store, KVCmdSet, KVCmdData{Key: "a", Value: "1"})
+
+	r := applyKV(store, KVCmdCAS, KVCmdData{Key: "a", Value: "2", PrevValue: "x"})
+	if r.Succeeded || r.Value != "1" || r.Revision != 1 {
+		t.Error("CAS should fail on value mismatch and return current value")
+	}
+	if r = applyKV(store, KVCmdCAS, KVCmdData{Key: "a", Value: "2", PrevValue: "1"}); !r.Succeeded || r.Value != "2" || r.Revision != 2 {
+		t.Error("CAS should succeed on value match")
+	}
+	if r = applyKV(store, KVCmdCAS, KVCmdData{Key: "a", Value: "3", PrevRevision: 1}); r.Succeeded {
+		t.Error("CAS should fail on revision mismatch")
+	}
+	if r = applyKV(store, KVCmdCAS, KVCmdData{Key: "a", Value: "3", PrevRevision: 2}); !r.Succeeded || r.Revision != 3 {
+		t.Error("CAS should succeed on revision match")
+	}
+	if v, _ := store.Get("a"); v.(KVEntry).Value != "3" {
+		t.Error("CAS doesn't set value correctly")
+	}
+}
+
+func TestCmdSetIfAbsent(t *testing.T) {
+	store := newRKVStore()
+
+	if r := applyKV(store, KVCmdSetIfAbsent, KVCmdData{Key: "a", Value: "1"}); !r.Succeeded {
+		t.Error("SetIfAbsent should succeed on non existent key")
+	}
+	if r := applyKV(store, KVCmdSetIfAbsent, KVCmdData{Key: "a", Value: "2"}); r.Succeeded || r.Value != "1" {
+		t.Error("SetIfAbsent should fail on existing key")
+	}
+}
+
+func TestCmdDelIfRevision(t *testing.T) {
+	store := newRKVStore()
+	applyKV(store, KVCmdSet, KVCmdData{Key: "a", Value: "1"})
+
+	if r := applyKV(store, KVCmdDelIfRevision, KVCmdData{Key: "a", PrevRevision: 2}); r.Succeeded {
+		t.Error("DelIfRevision should fail on revision mismatch")
+	}
+	if r := applyKV(store, KVCmdDelIfRevision, KVCmdData{Key: "a", PrevRevision: 1}); !r.Succeeded {
+		t.Error("DelIfRevision should succeed on revision match")
+	}
+	if _, err := store.Get("a"); err == nil {
+		t.Error("DelIfRevision doesn't delete the key")
+	}
+}
+
+func TestCmdTxn(t *testing.T) {
+	store := newRKVStore()
+	applyKV(store, KVCmdSet, KVCmdData{Key: "list1", Value: "item"})
+
+	move := KVTxn{
+		Compares: []KVCompare{
+			{Key: "list1", Target: KVCompareValue, Result: KVCompareEqual, Value: "item"},
+			{Key: "list2", Target: KVCompareRevision, Result: KVCompareEqual, Revision: 0},
+		},
+		Success: []KVTxnOp{
+			{CmdType: KVCmdDel, Key: "list1"},
+			{CmdType: KVCmdSet, Key: "list2", Value: "item"},
+			{CmdType: KVTxnGet, Key: "list2"},
+		},
+		Failure: []KVTxnOp{
+			{CmdType: KVTxnGet, Key: "list2"},
+		},
+	}
+
+	r := store.Apply(raft.StateMachineCmd{CmdType: KVCmdTxn, Data: move}).(KVTxnResult)
+	if !r.Succeeded || len(r.Results) != 3 {
+		t.Fatal("Txn should apply success ops when all compares are met")
+	}
+	if !r.Results[0].Succeeded || r.Results[2].Value != "item" || r.Results[1].Revision != 2 || r.Results[2].Revision != 2 {
+		t.Error("Txn returns wrong op results")
+	}
+	if _, err := store.Get("list1"); err == nil {
+		t.Error("Txn doesn't delete the key")
+	}
+	if store.meta.Revision != 2 {
+		t.Error("Txn writes should share one revision")
+	}
+
+	r = store.Apply(raft.StateMachineCmd{CmdType: KVCmdTxn, Data: move}).(KVTxnResult)
+	if r.Succeeded || len(r.Results) != 1 || r.Results[0].Value != "item" {
+		t.Error("Txn should apply failure ops when compares are not met")
+	}
+	if store.meta.Revision != 2 {
+		t.Error("Read only txn should not bump revision")
+	}
+}
+
+func TestCompare(t *testing.T) {
+	store := newRKVStore()
+	applyKV(store, KVCmdSet, KVCmdData{Key: "a", Value: "b"})
+
+	cases := []struct {
+		c        KVCompare
+		expected bool
+	}{
+		{KVCompare{Key: "a", Target: KVCompareValue, Result: KVCompareEqual, Value: "b"}, true},
+		{KVCompare{Key: "a", Target: KVCompareValue, Result: KVCompareNotEqual, Value: "b"}, false},
+		{KVCompare{Key: "a", Target: KVCompareValue, Result: KVCompareGreater, Value: "a"}, true},
+		{KVCompare{Key: "a", Target: KVCompareValue, Result: KVCompareLess, Value: "a"}, false},
+		{KVCompare{Key: "a", Target: KVCompareRevision, Result: KVCompareEqual, Revision: 1}, true},
+		{KVCompare{Key: "a", Target: KVCompareRevision, Result: KVCompareLess, Revision: 2}, true},
+		{KVCompare{Key: "x", Target: KVCompareRevision, Result: KVCompareEqual, Revision: 0}, true},
+		{KVCompare{Key: "x", Target: KVCompareValue, Result: KVCompareEqual, Value: ""}, true},
+	}
+
+	for i, v := range cases {
+		if store.compare(v.c) != v.expected {
+			t.Errorf("Compare case %d should return %v", i, v.expected)
+		}
+	}
+}
+
+func TestExpiry(t *testing.T) {
+	store := newRKVStore()
+	past := time.Now().Add(-time.Second).UnixNano()
+	future := time.Now().Add(time.Hour).UnixNano()
+
+	applyKV(store, KVCmdSet, KVCmdData{Key: "a", Value: "a", ExpireAt: past})
+	applyKV(store, KVCmdSet, KVCmdData{Key: "b", Value: "b", ExpireAt: future})
+	applyKV(store, KVCmdSet, KVCmdData{Key: "c", Value: "c"})
+
+	if _, err := store.Get("a"); err == nil {
+		t.Error("Get should hide expired keys")
+	}
+	if v, err := store.Get("b"); err != nil || v.(KVEntry).Value != "b" {
+		t.Error("Get should return keys not expired yet")
+	}
+
+	expired := store.expiredKeys(time.Now(), 10)
+	if len(expired) != 1 || expired["a"] != 1 {
+		t.Fatal("expiredKeys should return expired keys with revisions")
+	}
+
+	// expired keys are only removed through KVCmdExpire, with revision check
+	if r := applyKV(store, KVCmdExpire, KVCmdData{Key: "a", PrevRevision: 2}); r.Succeeded {
+		t.Error("Expire should not delete key modified since")
+	}
+	if r := applyKV(store, KVCmdExpire, KVCmdData{Key: "a", PrevRevision: 1}); !r.Succeeded {
+		t.Error("Expire should delete key with matching revision")
+	}
+	if len(store.expiredKeys(time.Now(), 10)) != 0 {
+		t.Error("Expired key should be deleted")
+	}
+
+	buf := &bytes.Buffer{}
+	store.Serialize(buf)
+	newStore := newRKVStore()
+	newStore.Deserialize(buf)
+	if v, _ := newStore.Get("b"); v.(KVEntry).ExpireAt != future {
+		t.Error("Snapshot should preserve expiry")
+	}
+}
+
+func TestScan(t *testing.T) {
+	store := newRKVStore()
+	for _, k := range []string{"t1/b", "t1/a", "t2/a", "t1/c", "t1\xff", "t0"} {
+		applyKV(store, KVCmdSet, KVCmdData{Key: k, Value: k})
+	}
+	applyKV(store, KVCmdSet, KVCmdData{Key: "t1/d", Value: "d", ExpireAt: time.Now().Add(-time.Second).UnixNano()})
+	applyKV(store, KVCmdDel, KVCmdData{Key: "t1/c"})
+
+	keys := func(r KVRangeResult) string {
+		var keys []string
+		for _, e := range r.Entries {
+			keys = append(keys, e.Key)
+		}
+		return strings.Join(keys, ",")
+	}
+
+	v, _ := store.Get(prefixRange("t1/", 0))
+	if r := v.(KVRangeResult); keys(r) != "t1/a,t1/b" || r.NextKey != "" {
+		t.Errorf("Prefix scan returns wrong keys %s", keys(r))
+	}
+
+	v, _ = store.Get(KVRange{Start: "t0", End: "t2", Limit: 2})
+	if r := v.(KVRangeResult); keys(r) != "t0,t1/a" || r.NextKey != "t1/b" {
+		t.Errorf("Range scan should stop at limit, got %s next %s", keys(r), r.NextKey)
+	}
+
+	v, _ = store.Get(KVRange{Start: "t1/b"})
+	if r := v.(KVRangeResult); keys(r) != "t1/b,t1\xff,t2/a" {
+		t.Errorf("Range scan without end should return all keys after start, got %s", keys(r))
+	}
+
+	buf := &bytes.Buffer{}
+	store.Serialize(buf)
+	newStore := newRKVStore()
+	newStore.Deserialize(buf)
+	v, _ = newStore.Get(prefixRange("t1/", 0))
+	if r := v.(KVRangeResult); keys(r) != "t1/a,t1/b" {
+		t.Error("Index should be rebuilt from snapshot")
+	}
+
+	if r := prefixRange("a\xff\xff", 0); r.End != "b" {
+		t.Error("Prefix range should skip 0xff bytes")
+	}
+	if r := prefixRange("", 0); r.End != "" {
+		t.Error("Empty prefix should cover all keys")
+	}
 }
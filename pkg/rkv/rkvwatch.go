@@ -0,0 +1,188 @@
+package rkv
+
+import (
+	"errors"
+	"strings"
+	"sync"
+)
+
+var errorCompacted = errors.New("revision has been compacted")
+var errorWatcherTooSlow = errors.New("watcher is too slow to consume events")
+
+const defaultWatchHistory = 10000
+const watchBufferSize = 128
+
+// KVEvent types
+const (
+	// KVEventPut is a key being set
+	KVEventPut = iota
+	// KVEventDelete is a key being deleted
+	KVEventDelete
+)
+
+// KVEvent is a change to a key at a store revision. Changes in one txn or lease revoke share the same revision
+type KVEvent struct {
+	Type     int
+	Key      string
+	Value    string
+	Revision int64
+}
+
+// watcher receives events for a key or a prefix
+type watcher struct {
+	key    string
+	prefix bool
+	start  int64
+	events chan []KVEvent
+	err    error // why events is closed, only valid after it's closed
+}
+
+// matches tells whether the watcher is interested in key
+func (w *watcher) matches(key string) bool {
+	if w.prefix {
+		return strings.HasPrefix(key, w.key)
+	}
+	return key == w.key
+}
+
+// filter returns the events the watcher is interested in
+func (w *watcher) filter(events []KVEvent) []KVEvent {
+	var matched []KVEvent
+	for _, e := range events {
+		if w.matches(e.Key) {
+			matched = append(matched, e)
+		}
+	}
+	return matched
+}
+
+// watchHub dispatches events from the store's apply loop to watchers.
+// It keeps a bounded history of recent events so that watchers can start from a past revision,
+// e.g. to resume after reconnecting without missing events
+type watchHub struct {
+	mu         sync.Mutex
+	history    []KVEvent
+	maxHistory int
+	revision   int64 // revision of the last event published
+	compacted  int64 // events at or before this revision are no longer in history
+	watchers   map[*watcher]struct{}
+}
+
+func newWatchHub(maxHistory int) *watchHub {
+	return &watchHub{
+		maxHistory: maxHistory,
+		watchers:   make(map[*watcher]struct{}),
+	}
+}
+
+// watch creates a watcher starting from revision start, or from the next revision if start is 0.
+// Events since start still in history are replayed first. Returns errorCompacted if they are not
+func (h *watchHub) watch(key string, prefix bool, start int64) (*watcher, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if start == 0 {
+		start = h.revision + 1
+	}
+	if start <= h.compacted {
+		return nil, errorCompacted
+	}
+
+	w := &watcher{key: key, prefix: prefix, start: start, events: make(chan []KVEvent, watchBufferSize)}
+	var replay []KVEvent
+	for _, e := range h.history {
+		if e.Revision >= start && w.matches(e.Key) {
+			replay = append(replay, e)
+		}
+	}
+	if len(replay) > 0 {
+		w.events <- replay
+	}
+
+	h.watchers[w] = struct{}{}
+	return w, nil
+}
+
+// cancel removes the watcher
+func (h *watchHub) cancel(w *watcher) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.drop(w, nil)
+}
+
+// compactRevision returns the latest revision no longer in history
+func (h *watchHub) compactRevision() int64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return h.compacted
+}
+
+// publish records events in history and sends them to watchers. Watchers which can't keep up are dropped.
+// It's called by the store in apply order so events are ordered by revision
+func (h *watchHub) publish(events []KVEvent) {
+	if len(events) == 0 {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.history = append(h.history, events...)
+	h.revision = events[len(events)-1].Revision
+	if len(h.history) > h.maxHistory {
+		// never keep part of a revision
+		h.compacted = h.history[len(h.history)-h.maxHistory-1].Revision
+		i := len(h.history) - h.maxHistory
+		for i < len(h.history) && h.history[i].Revision <= h.compacted {
+			i++
+		}
+		h.history = append([]KVEvent(nil), h.history[i:]...)
+	}
+
+	for w := range h.watchers {
+		matched := w.filter(events)
+		if len(matched) == 0 {
+			continue
+		}
+
+		select {
+		case w.events <- matched:
+		default:
+			h.drop(w, errorWatcherTooSlow)
+		}
+	}
+}
+
+// reset clears history after installing a snapshot at revision. Events before are lost so all watchers are dropped
+func (h *watchHub) reset(revision int64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.history = nil
+	h.revision = revision
+	h.compacted = revision
+	for w := range h.watchers {
+		h.drop(w, errorCompacted)
+	}
+}
+
+// close drops all watchers with err, e.g. when the node is shutting down
+func (h *watchHub) close(err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for w := range h.watchers {
+		h.drop(w, err)
+	}
+}
+
+// drop removes the watcher and closes its channel, needs to be called with lock held
+func (h *watchHub) drop(w *watcher, err error) {
+	if _, ok := h.watchers[w]; ok {
+		delete(h.watchers, w)
+		w.err = err
+		close(w.events)
+	}
+}
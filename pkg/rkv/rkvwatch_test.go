@@ -0,0 +1,96 @@
+package rkv
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/sidecus/raft/pkg/raft"
+)
+
+func TestWatchEvents(t *testing.T) {
+	store := newRKVStore()
+	w, _ := store.watches.watch("a", true, 0)
+
+	applyKV(store, KVCmdSet, KVCmdData{Key: "a1", Value: "1"})
+	applyKV(store, KVCmdSet, KVCmdData{Key: "b", Value: "1"})
+	applyKV(store, KVCmdDel, KVCmdData{Key: "a2"})
+	store.Apply(raft.StateMachineCmd{CmdType: KVCmdTxn, Data: KVTxn{Success: []KVTxnOp{{CmdType: KVCmdSet, Key: "a2", Value: "2"}, {CmdType: KVCmdDel, Key: "a1"}}}})
+
+	events := <-w.events
+	if len(events) != 1 || events[0] != (KVEvent{Type: KVEventPut, Key: "a1", Value: "1", Revision: 1}) {
+		t.Errorf("Watcher should get set event, got %v", events)
+	}
+	events = <-w.events
+	if len(events) != 2 || events[0].Revision != 4 || events[1] != (KVEvent{Type: KVEventDelete, Key: "a1", Revision: 4}) {
+		t.Errorf("Txn events should be sent together with the same revision, got %v", events)
+	}
+	if len(w.events) != 0 {
+		t.Error("Deleting non existent key or other keys should not send events")
+	}
+
+	store.watches.cancel(w)
+	if _, ok := <-w.events; ok || w.err != nil {
+		t.Error("cancel should close the watcher without error")
+	}
+}
+
+func TestWatchHistory(t *testing.T) {
+	h := newWatchHub(3)
+	h.publish([]KVEvent{{Key: "a", Revision: 1}})
+	h.publish([]KVEvent{{Key: "a", Revision: 2}, {Key: "b", Revision: 2}})
+	h.publish([]KVEvent{{Key: "a", Revision: 3}})
+	h.publish([]KVEvent{{Key: "a", Revision: 4}})
+
+	// history is trimmed to 3 events, and revision 2 can't be kept partially
+	if h.compacted != 2 || len(h.history) != 2 {
+		t.Fatalf("History should be trimmed by revision, compacted %d, %d events", h.compacted, len(h.history))
+	}
+	if _, err := h.watch("a", false, 2); err != errorCompacted {
+		t.Error("watch should fail on compacted revision")
+	}
+
+	w, err := h.watch("a", false, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if events := <-w.events; len(events) != 2 || events[0].Revision != 3 {
+		t.Error("watch should replay events from history")
+	}
+}
+
+func TestWatchSlowWatcher(t *testing.T) {
+	h := newWatchHub(defaultWatchHistory)
+	w, _ := h.watch("a", false, 0)
+
+	for i := 1; i <= watchBufferSize+1; i++ {
+		h.publish([]KVEvent{{Key: "a", Revision: int64(i)}})
+	}
+
+	for range w.events {
+	}
+	if w.err != errorWatcherTooSlow {
+		t.Error("Slow watcher should be dropped")
+	}
+}
+
+func TestWatchSnapshot(t *testing.T) {
+	store := newRKVStore()
+	applyKV(store, KVCmdSet, KVCmdData{Key: "a", Value: "1"})
+	applyKV(store, KVCmdSet, KVCmdData{Key: "a", Value: "2"})
+	buf := &bytes.Buffer{}
+	store.Serialize(buf)
+
+	newStore := newRKVStore()
+	w, _ := newStore.watches.watch("a", false, 0)
+	newStore.Deserialize(buf)
+
+	if _, ok := <-w.events; ok || w.err != errorCompacted {
+		t.Error("Installing snapshot should drop watchers as compacted")
+	}
+	if _, err := newStore.watches.watch("a", false, 2); err != errorCompacted {
+		t.Error("Revisions before snapshot should be compacted")
+	}
+	if _, err := newStore.watches.watch("a", false, 3); err != nil {
+		t.Error("Watch should start after snapshot revision")
+	}
+}
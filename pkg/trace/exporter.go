@@ -0,0 +1,57 @@
+package trace
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+)
+
+// writerExporter writes spans as JSON lines, implementing IExporter
+type writerExporter struct {
+	mu      sync.Mutex
+	w       *bufio.Writer
+	encoder *json.Encoder
+	closer  io.Closer
+}
+
+// NewWriterExporter creates an exporter writing spans to w as JSON lines, e.g. to os.Stdout
+func NewWriterExporter(w io.Writer) IExporter {
+	bw := bufio.NewWriter(w)
+	return &writerExporter{w: bw, encoder: json.NewEncoder(bw)}
+}
+
+// NewFileExporter creates an exporter appending spans to a file as JSON lines. Close it to flush and close the file
+func NewFileExporter(file string) (IExporter, error) {
+	f, err := os.OpenFile(file, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	bw := bufio.NewWriter(f)
+	return &writerExporter{w: bw, encoder: json.NewEncoder(bw), closer: f}, nil
+}
+
+// Export implements IExporter. Spans are flushed right away so that they're not lost if the process is killed
+func (e *writerExporter) Export(span SpanData) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.encoder.Encode(span) == nil {
+		e.w.Flush()
+	}
+}
+
+// Close flushes pending spans, and closes the file if the exporter owns it
+func (e *writerExporter) Close() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	err := e.w.Flush()
+	if e.closer != nil {
+		if closeErr := e.closer.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
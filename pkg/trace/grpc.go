@@ -0,0 +1,59 @@
+package trace
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// traceparentKey is the gRPC metadata key carrying the W3C traceparent
+const traceparentKey = "traceparent"
+
+// OutgoingContext returns a copy of ctx which sends the span in ctx to the gRPC server, if there is one
+func OutgoingContext(ctx context.Context) context.Context {
+	sc := SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return ctx
+	}
+	return metadata.AppendToOutgoingContext(ctx, traceparentKey, sc.Traceparent())
+}
+
+// IncomingContext returns a copy of ctx carrying the span sent by the gRPC client, if there is one
+func IncomingContext(ctx context.Context) context.Context {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ctx
+	}
+	values := md.Get(traceparentKey)
+	if len(values) == 0 {
+		return ctx
+	}
+	if sc, ok := ParseTraceparent(values[0]); ok {
+		return ContextWithSpanContext(ctx, sc)
+	}
+	return ctx
+}
+
+// UnaryServerInterceptor records a span for each unary gRPC request, continuing the client's trace if any.
+// New traces are only started for methods for which root returns true, e.g. client requests but not raft heartbeats
+func UnaryServerInterceptor(root func(method string) bool) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if !Enabled() {
+			return handler(ctx, req)
+		}
+
+		ctx = IncomingContext(ctx)
+		var span *Span
+		if root(info.FullMethod) {
+			ctx, span = Start(ctx, info.FullMethod)
+		} else {
+			ctx, span = StartChild(ctx, info.FullMethod)
+		}
+
+		resp, err := handler(ctx, req)
+		span.SetError(err)
+		span.End()
+		return resp, err
+	}
+}
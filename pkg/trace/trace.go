@@ -0,0 +1,265 @@
+// Package trace records spans of requests across rkv nodes, e.g. a Set on a follower proxied to the leader,
+// replicated to followers, committed and applied. It follows OpenTelemetry concepts with W3C traceparent propagation,
+// and exports finished spans to an IExporter. Tracing is off until SetExporter is called
+package trace
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	mathrand "math/rand"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TraceID identifies a trace
+type TraceID [16]byte
+
+// SpanID identifies a span in a trace
+type SpanID [8]byte
+
+func (id TraceID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+func (id SpanID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+// SpanContext is the part of a span propagated to child spans, in process or to other nodes
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+}
+
+// IsValid tells whether sc refers to a span. Only sampled spans are propagated, so a valid context is always sampled
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID != TraceID{} && sc.SpanID != SpanID{}
+}
+
+// Traceparent formats sc as a W3C traceparent header, e.g. 00-<trace id>-<span id>-01
+func (sc SpanContext) Traceparent() string {
+	return fmt.Sprintf("00-%s-%s-01", sc.TraceID, sc.SpanID)
+}
+
+// ParseTraceparent parses a W3C traceparent header. Contexts which are not sampled are ignored
+func ParseTraceparent(s string) (SpanContext, bool) {
+	var sc SpanContext
+	parts := strings.Split(s, "-")
+	if len(parts) != 4 || len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return sc, false
+	}
+	if _, err := hex.Decode(sc.TraceID[:], []byte(parts[1])); err != nil {
+		return sc, false
+	}
+	if _, err := hex.Decode(sc.SpanID[:], []byte(parts[2])); err != nil {
+		return sc, false
+	}
+	flags, err := hex.DecodeString(parts[3])
+	if err != nil || flags[0]&1 == 0 {
+		return sc, false
+	}
+	return sc, sc.IsValid()
+}
+
+// SpanData is a finished span, as passed to exporters
+type SpanData struct {
+	TraceID    string                 `json:"traceId"`
+	SpanID     string                 `json:"spanId"`
+	ParentID   string                 `json:"parentId,omitempty"`
+	Name       string                 `json:"name"`
+	Start      time.Time              `json:"start"`
+	End        time.Time              `json:"end"`
+	DurationUS int64                  `json:"durationUs"`
+	Attributes map[string]interface{} `json:"attributes,omitempty"`
+	Error      string                 `json:"error,omitempty"`
+}
+
+// Span is an operation in a trace. A nil span, e.g. when tracing is off or the trace isn't sampled, ignores all calls
+type Span struct {
+	mu         sync.Mutex
+	name       string
+	sc         SpanContext
+	parentID   SpanID
+	start      time.Time
+	attributes map[string]interface{}
+	err        string
+	ended      bool
+}
+
+// Context returns the span's context, which is invalid for a nil span
+func (s *Span) Context() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+	return s.sc
+}
+
+// SetAttribute sets an attribute, e.g. "peer", 1
+func (s *Span) SetAttribute(key string, value interface{}) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.attributes == nil {
+		s.attributes = make(map[string]interface{})
+	}
+	s.attributes[key] = value
+}
+
+// SetError records err on the span if it's not nil
+func (s *Span) SetError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.err = err.Error()
+}
+
+// End finishes the span and exports it. Only the first call counts
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+
+	end := time.Now()
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	data := SpanData{
+		TraceID:    s.sc.TraceID.String(),
+		SpanID:     s.sc.SpanID.String(),
+		Name:       s.name,
+		Start:      s.start,
+		End:        end,
+		DurationUS: end.Sub(s.start).Microseconds(),
+		Attributes: s.attributes,
+		Error:      s.err,
+	}
+	if s.parentID != (SpanID{}) {
+		data.ParentID = s.parentID.String()
+	}
+	s.mu.Unlock()
+
+	if e := getExporter(); e != nil {
+		e.Export(data)
+	}
+}
+
+// IExporter exports finished spans. Export is called on the goroutine ending the span, so it should return quickly
+type IExporter interface {
+	Export(span SpanData)
+}
+
+var (
+	mu          sync.RWMutex
+	exporter    IExporter
+	sampleRatio = 1.0
+)
+
+// SetExporter turns tracing on with the exporter, or off if it's nil
+func SetExporter(e IExporter) {
+	mu.Lock()
+	defer mu.Unlock()
+	exporter = e
+}
+
+// SetSampleRatio sets the ratio of new traces which are recorded, between 0 and 1. Default is 1.
+// Spans continuing a trace from a parent are always recorded
+func SetSampleRatio(ratio float64) {
+	mu.Lock()
+	defer mu.Unlock()
+	sampleRatio = ratio
+}
+
+func getExporter() IExporter {
+	mu.RLock()
+	defer mu.RUnlock()
+	return exporter
+}
+
+// Enabled tells whether tracing is on
+func Enabled() bool {
+	return getExporter() != nil
+}
+
+type spanContextKey struct{}
+
+// ContextWithSpanContext returns a copy of ctx carrying sc, e.g. from a remote parent
+func ContextWithSpanContext(ctx context.Context, sc SpanContext) context.Context {
+	if !sc.IsValid() {
+		return ctx
+	}
+	return context.WithValue(ctx, spanContextKey{}, sc)
+}
+
+// SpanContextFromContext returns the span context carried by ctx, which is invalid if there is none
+func SpanContextFromContext(ctx context.Context) SpanContext {
+	sc, _ := ctx.Value(spanContextKey{}).(SpanContext)
+	return sc
+}
+
+// Start starts a child span of the span in ctx, and returns a copy of ctx carrying the new span.
+// Without a span in ctx, a new trace is started subject to sampling. Returns a nil span when nothing is recorded
+func Start(ctx context.Context, name string) (context.Context, *Span) {
+	parent := SpanContextFromContext(ctx)
+	var span *Span
+	if parent.IsValid() {
+		span = StartWithParent(parent, name)
+	} else {
+		span = startRoot(name)
+	}
+	if span == nil {
+		return ctx, nil
+	}
+	return ContextWithSpanContext(ctx, span.sc), span
+}
+
+// StartChild starts a child span of the span in ctx, without starting a new trace if there is none
+func StartChild(ctx context.Context, name string) (context.Context, *Span) {
+	span := StartWithParent(SpanContextFromContext(ctx), name)
+	if span == nil {
+		return ctx, nil
+	}
+	return ContextWithSpanContext(ctx, span.sc), span
+}
+
+// StartWithParent starts a child span of parent, e.g. for work done asynchronously for a request.
+// Returns nil if tracing is off or parent is invalid
+func StartWithParent(parent SpanContext, name string) *Span {
+	if !parent.IsValid() || !Enabled() {
+		return nil
+	}
+	return newSpan(name, parent.TraceID, parent.SpanID)
+}
+
+func startRoot(name string) *Span {
+	mu.RLock()
+	on, ratio := exporter != nil, sampleRatio
+	mu.RUnlock()
+	if !on || ratio <= 0 || (ratio < 1 && mathrand.Float64() >= ratio) {
+		return nil
+	}
+
+	var traceID TraceID
+	rand.Read(traceID[:])
+	return newSpan(name, traceID, SpanID{})
+}
+
+func newSpan(name string, traceID TraceID, parentID SpanID) *Span {
+	s := &Span{
+		name:     name,
+		parentID: parentID,
+		start:    time.Now(),
+	}
+	s.sc.TraceID = traceID
+	rand.Read(s.sc.SpanID[:])
+	return s
+}
@@ -0,0 +1,150 @@
+package trace
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+type testExporter struct {
+	mu    sync.Mutex
+	spans []SpanData
+}
+
+func (e *testExporter) Export(span SpanData) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.spans = append(e.spans, span)
+}
+
+func TestTraceparent(t *testing.T) {
+	sc := SpanContext{TraceID: TraceID{1, 2, 3}, SpanID: SpanID{4, 5, 6}}
+	s := sc.Traceparent()
+	if s != "00-01020300000000000000000000000000-0405060000000000-01" {
+		t.Error("Invalid traceparent", s)
+	}
+
+	parsed, ok := ParseTraceparent(s)
+	if !ok || parsed != sc {
+		t.Error("Traceparent should be parsed back to the span context")
+	}
+
+	invalid := []string{
+		"",
+		"00-01020300000000000000000000000000-0405060000000000-00",
+		"00-00000000000000000000000000000000-0405060000000000-01",
+		"00-0102030000000000000000000000000x-0405060000000000-01",
+		"00-010203-0405060000000000-01",
+	}
+	for _, v := range invalid {
+		if _, ok := ParseTraceparent(v); ok {
+			t.Error("Invalid or unsampled traceparent should be ignored", v)
+		}
+	}
+}
+
+func TestSpans(t *testing.T) {
+	ctx := context.Background()
+	if _, span := Start(ctx, "off"); span != nil {
+		t.Error("No span should be recorded when tracing is off")
+	}
+
+	e := &testExporter{}
+	SetExporter(e)
+	defer SetExporter(nil)
+
+	if _, span := StartChild(ctx, "child"); span != nil {
+		t.Error("StartChild should not start a new trace")
+	}
+
+	ctx, root := Start(ctx, "root")
+	if root == nil || SpanContextFromContext(ctx) != root.Context() {
+		t.Fatal("Start should start a new trace, and put the span in ctx")
+	}
+	_, child := StartChild(ctx, "child")
+	child.SetAttribute("peer", 1)
+	child.SetError(errors.New("timeout"))
+	child.End()
+	child.End()
+	async := StartWithParent(root.Context(), "async")
+	async.End()
+	root.End()
+
+	if len(e.spans) != 3 {
+		t.Fatal("Ended spans should be exported once", len(e.spans))
+	}
+	c, a, r := e.spans[0], e.spans[1], e.spans[2]
+	if r.ParentID != "" || c.ParentID != r.SpanID || a.ParentID != r.SpanID || c.TraceID != r.TraceID || a.TraceID != r.TraceID {
+		t.Error("Child spans should be in the same trace with the root as parent")
+	}
+	if c.Attributes["peer"] != 1 || c.Error != "timeout" {
+		t.Error("Span attributes and error should be exported")
+	}
+
+	SetSampleRatio(0)
+	defer SetSampleRatio(1)
+	if _, span := Start(context.Background(), "unsampled"); span != nil {
+		t.Error("New traces should not be recorded with 0 sample ratio")
+	}
+	if _, span := StartChild(ctx, "sampled"); span == nil {
+		t.Error("Spans continuing a trace should always be recorded")
+	}
+}
+
+func TestNilSpan(t *testing.T) {
+	var span *Span
+	span.SetAttribute("k", "v")
+	span.SetError(errors.New("error"))
+	span.End()
+	if span.Context().IsValid() {
+		t.Error("Nil span should have invalid context")
+	}
+}
+
+func TestWriterExporter(t *testing.T) {
+	var buf bytes.Buffer
+	e := NewWriterExporter(&buf)
+	e.Export(SpanData{TraceID: "t", SpanID: "s", Name: "raft.apply"})
+
+	var span SpanData
+	if err := json.Unmarshal(buf.Bytes(), &span); err != nil || span.Name != "raft.apply" || span.TraceID != "t" {
+		t.Error("Spans should be written as JSON lines right away", buf.String())
+	}
+}
+
+func TestGRPCPropagation(t *testing.T) {
+	e := &testExporter{}
+	SetExporter(e)
+	defer SetExporter(nil)
+
+	// client side
+	ctx, span := Start(context.Background(), "client")
+	md, _ := metadata.FromOutgoingContext(OutgoingContext(ctx))
+
+	// server side
+	incoming := metadata.NewIncomingContext(context.Background(), md)
+	var handlerSC SpanContext
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		handlerSC = SpanContextFromContext(ctx)
+		return nil, nil
+	}
+	interceptor := UnaryServerInterceptor(func(string) bool { return false })
+	interceptor(incoming, nil, &grpc.UnaryServerInfo{FullMethod: "/raft.RaftTransport/Execute"}, handler)
+	span.End()
+
+	if len(e.spans) != 2 || e.spans[0].ParentID != span.Context().SpanID.String() || handlerSC.SpanID.String() != e.spans[0].SpanID {
+		t.Fatal("Server span should continue the client's trace")
+	}
+
+	// no trace from the client, and not a root method
+	interceptor(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: "/raft.RaftTransport/AppendEntries"}, handler)
+	if len(e.spans) != 2 || handlerSC.IsValid() {
+		t.Error("Server should not start new traces for non root methods")
+	}
+}
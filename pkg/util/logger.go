@@ -1,6 +1,15 @@
 package util
 
-import "log"
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
 
 // Log levels
 const (
@@ -16,24 +25,200 @@ const (
 	LevelVerbose = 5
 )
 
-// raft logger and log level
-var logger = log.New(log.Writer(), log.Prefix(), log.Flags())
-var logLevel = LevelInfo
+// Log formats
+const (
+	// LogFormatText writes one line per log, with fields as key=value
+	LogFormatText = "text"
+	// LogFormatJSON writes one JSON object per line
+	LogFormatJSON = "json"
+)
 
-// SetLogLevel sets log level
-func SetLogLevel(level int) {
+var levelNames = map[int]string{
+	LevelError:   "error",
+	LevelWarning: "warn",
+	LevelInfo:    "info",
+	LevelTrace:   "trace",
+	LevelVerbose: "verbose",
+}
+
+// Logger writes leveled logs with key/value fields, in text or JSON.
+// Each subsystem can have its own level, otherwise the default level applies
+type Logger struct {
+	mu     sync.Mutex
+	out    io.Writer
+	json   bool
+	level  int
+	levels map[string]int
+}
+
+// NewLogger creates a text logger at LevelInfo
+func NewLogger(out io.Writer) *Logger {
+	return &Logger{
+		out:    out,
+		level:  LevelInfo,
+		levels: make(map[string]int),
+	}
+}
+
+// SetLevel sets the default log level
+func (l *Logger) SetLevel(level int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.level = clampLevel(level)
+}
+
+// SetSubsystemLevel sets the log level of a subsystem, overriding the default level
+func (l *Logger) SetSubsystemLevel(subsystem string, level int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.levels[subsystem] = clampLevel(level)
+}
+
+// SetFormat sets the log format, LogFormatText or LogFormatJSON
+func (l *Logger) SetFormat(format string) error {
+	if format != LogFormatText && format != LogFormatJSON {
+		return fmt.Errorf("unknown log format %s", format)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.json = format == LogFormatJSON
+	return nil
+}
+
+// Enabled tells whether logs at the level are written for the subsystem
+func (l *Logger) Enabled(level int, subsystem string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.enabled(level, subsystem)
+}
+
+func (l *Logger) enabled(level int, subsystem string) bool {
+	if subsystemLevel, ok := l.levels[subsystem]; ok {
+		return level <= subsystemLevel
+	}
+	return level <= l.level
+}
+
+// Log writes a log if its level is enabled for the subsystem. keyvals are alternating keys and values, e.g. "term", 3
+func (l *Logger) Log(level int, subsystem string, msg string, keyvals ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if !l.enabled(level, subsystem) {
+		return
+	}
+
+	var buf bytes.Buffer
+	now := time.Now()
+	if l.json {
+		writeJSONLog(&buf, now, level, subsystem, msg, keyvals)
+	} else {
+		writeTextLog(&buf, now, level, subsystem, msg, keyvals)
+	}
+	l.out.Write(buf.Bytes())
+}
+
+// writeTextLog writes a log like "2021/02/20 10:00:00 info election: won election node=0 term=3"
+func writeTextLog(buf *bytes.Buffer, t time.Time, level int, subsystem string, msg string, keyvals []interface{}) {
+	buf.WriteString(t.Format("2006/01/02 15:04:05 "))
+	buf.WriteString(levelNames[level])
+	buf.WriteByte(' ')
+	if subsystem != "" {
+		buf.WriteString(subsystem)
+		buf.WriteString(": ")
+	}
+	buf.WriteString(msg)
+	for i := 0; i < len(keyvals); i += 2 {
+		key, value := keyValue(keyvals, i)
+		s := fmt.Sprint(value)
+		if s == "" || strings.ContainsAny(s, " =\"\n") {
+			s = fmt.Sprintf("%q", s)
+		}
+		fmt.Fprintf(buf, " %s=%s", key, s)
+	}
+	buf.WriteByte('\n')
+}
+
+// writeJSONLog writes a log as a JSON object. Fields keep their order, after time, level, subsystem and msg
+func writeJSONLog(buf *bytes.Buffer, t time.Time, level int, subsystem string, msg string, keyvals []interface{}) {
+	writeField := func(key string, value interface{}) {
+		if err, ok := value.(error); ok {
+			value = err.Error()
+		}
+		k, _ := json.Marshal(key)
+		v, err := json.Marshal(value)
+		if err != nil {
+			v, _ = json.Marshal(fmt.Sprint(value))
+		}
+		buf.Write(k)
+		buf.WriteByte(':')
+		buf.Write(v)
+	}
+
+	buf.WriteByte('{')
+	writeField("time", t.Format(time.RFC3339Nano))
+	buf.WriteByte(',')
+	writeField("level", levelNames[level])
+	if subsystem != "" {
+		buf.WriteByte(',')
+		writeField("subsystem", subsystem)
+	}
+	buf.WriteByte(',')
+	writeField("msg", msg)
+	for i := 0; i < len(keyvals); i += 2 {
+		key, value := keyValue(keyvals, i)
+		buf.WriteByte(',')
+		writeField(key, value)
+	}
+	buf.WriteString("}\n")
+}
+
+// keyValue gets the key/value pair at i. A key without value gets nil
+func keyValue(keyvals []interface{}, i int) (string, interface{}) {
+	key := fmt.Sprint(keyvals[i])
+	if i+1 >= len(keyvals) {
+		return key, nil
+	}
+	return key, keyvals[i+1]
+}
+
+func clampLevel(level int) int {
 	if level < LevelError {
-		level = LevelError
+		return LevelError
 	}
+	if level > LevelVerbose {
+		return LevelVerbose
+	}
+	return level
+}
+
+// default logger used by the Write functions below, and by raft nodes created without a logger
+var defaultLogger = NewLogger(os.Stderr)
+
+// DefaultLogger returns the default logger
+func DefaultLogger() *Logger {
+	return defaultLogger
+}
 
-	logLevel = level
+// SetLogLevel sets log level
+func SetLogLevel(level int) {
+	defaultLogger.SetLevel(level)
+}
+
+// SetSubsystemLogLevel sets the log level of a subsystem, e.g. raft election, replication or snapshot
+func SetSubsystemLogLevel(subsystem string, level int) {
+	defaultLogger.SetSubsystemLevel(subsystem, level)
+}
+
+// SetLogFormat sets the log format, LogFormatText or LogFormatJSON
+func SetLogFormat(format string) error {
+	return defaultLogger.SetFormat(format)
 }
 
 // WriteLog writes an log entry if its level is lower than logLevel, otherwise it's ignored
 func WriteLog(level int, format string, v ...interface{}) {
-	if level <= logLevel {
-		logger.Printf(format, v...)
-	}
+	defaultLogger.Log(level, "", strings.TrimRight(fmt.Sprintf(format, v...), "\n"))
 }
 
 // WriteError writes an error log
@@ -61,17 +246,22 @@ func WriteVerbose(format string, v ...interface{}) {
 	WriteLog(LevelVerbose, format, v...)
 }
 
-// Panicf is equivalent to l.Printf() followed by a call to panic().
+// Panicf writes an error log followed by a call to panic().
 func Panicf(format string, v ...interface{}) {
-	logger.Panicf(format, v...)
+	s := strings.TrimRight(fmt.Sprintf(format, v...), "\n")
+	defaultLogger.Log(LevelError, "", s)
+	panic(s)
 }
 
-// Panicln is equivalent to l.Println() followed by a call to panic().
+// Panicln writes an error log followed by a call to panic().
 func Panicln(v ...interface{}) {
-	logger.Panicln(v...)
+	s := strings.TrimRight(fmt.Sprintln(v...), "\n")
+	defaultLogger.Log(LevelError, "", s)
+	panic(s)
 }
 
-// Fatalf is equivalent to Printf followed by os.Exit(1)
+// Fatalf writes an error log followed by os.Exit(1)
 func Fatalf(format string, v ...interface{}) {
-	logger.Fatalf(format, v...)
+	WriteError(format, v...)
+	os.Exit(1)
 }
@@ -0,0 +1,74 @@
+package util
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestLoggerText(t *testing.T) {
+	var buf bytes.Buffer
+	l := NewLogger(&buf)
+
+	l.Log(LevelInfo, "election", "Won election", "node", 1, "term", 3, "err", errors.New("some error"))
+	line := buf.String()
+	if !strings.Contains(line, " info election: Won election node=1 term=3 err=\"some error\"\n") {
+		t.Error("Text log should have level, subsystem, msg and fields", line)
+	}
+
+	buf.Reset()
+	l.Log(LevelTrace, "election", "Timer event received")
+	if buf.Len() != 0 {
+		t.Error("Logs above the level should be dropped")
+	}
+}
+
+func TestLoggerSubsystemLevel(t *testing.T) {
+	var buf bytes.Buffer
+	l := NewLogger(&buf)
+	l.SetLevel(LevelWarning)
+	l.SetSubsystemLevel("replication", LevelTrace)
+
+	if l.Enabled(LevelInfo, "election") || !l.Enabled(LevelWarning, "election") {
+		t.Error("Subsystems without levels should use the default level")
+	}
+	if !l.Enabled(LevelTrace, "replication") || l.Enabled(LevelVerbose, "replication") {
+		t.Error("Subsystem level should override the default level")
+	}
+
+	l.SetSubsystemLevel("snapshot", 0)
+	if l.Enabled(LevelWarning, "snapshot") || !l.Enabled(LevelError, "snapshot") {
+		t.Error("Subsystem level should be at least LevelError")
+	}
+}
+
+func TestLoggerJSON(t *testing.T) {
+	var buf bytes.Buffer
+	l := NewLogger(&buf)
+	if err := l.SetFormat("xml"); err == nil {
+		t.Error("Unknown format should be rejected")
+	}
+	if err := l.SetFormat(LogFormatJSON); err != nil {
+		t.Fatal(err)
+	}
+
+	l.Log(LevelError, "snapshot", "Failed to take snapshot", "node", 2, "err", errors.New("disk full"), "dangling")
+	var m map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &m); err != nil {
+		t.Fatal(err)
+	}
+	if m["level"] != "error" || m["subsystem"] != "snapshot" || m["msg"] != "Failed to take snapshot" || m["time"] == nil {
+		t.Error("JSON log should have time, level, subsystem and msg", m)
+	}
+	if m["node"] != float64(2) || m["err"] != "disk full" {
+		t.Error("JSON log should have fields, with errors as strings", m)
+	}
+	if v, ok := m["dangling"]; !ok || v != nil {
+		t.Error("Key without value should be logged with null", m)
+	}
+	if !strings.HasPrefix(buf.String(), `{"time":`) {
+		t.Error("JSON log should start with time", buf.String())
+	}
+}